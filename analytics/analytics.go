@@ -0,0 +1,177 @@
+// Package analytics provides the aggregation primitives projections are
+// built from, grouping transactions, bucketing them by time, summing
+// decimal amounts and ranking the totals, so new analyses don't need to
+// keep the map and slice bookkeeping themselves.
+package analytics
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/stonks/trade"
+)
+
+// Key returns the key of the group a transaction belongs in, or a blank
+// key to leave it out
+type Key func(t *trade.Trade) string
+
+// Decimal returns an amount of a transaction, or nil if it has none
+type Decimal func(t *trade.Trade) *big.Float
+
+// Symbol keys transactions by their symbol
+func Symbol(t *trade.Trade) string {
+	return t.Symbol
+}
+
+// Underlying keys transactions by their underlying symbol, which is the
+// symbol itself for anything but derivatives
+func Underlying(t *trade.Trade) string {
+	if t.Instrument == nil || t.Instrument.Underlying == "" {
+		return t.Symbol
+	}
+	return t.Instrument.Underlying
+}
+
+// Account keys transactions by the account they were made in
+func Account(t *trade.Trade) string {
+	return t.Account
+}
+
+// Amount returns the cash amount of a transaction
+func Amount(t *trade.Trade) *big.Float {
+	return t.Amount
+}
+
+// Groups holds transactions grouped by key
+type Groups struct {
+	Keys  []string // in the order they were first seen
+	ByKey map[string][]*trade.Trade
+}
+
+// GroupBy groups the transactions by key, keeping their order within each
+// group. transactions with a blank key are left out.
+func GroupBy(trans []*trade.Trade, key Key) *Groups {
+	g := Groups{Keys: make([]string, 0), ByKey: make(map[string][]*trade.Trade)}
+	for i := 0; i < len(trans); i++ {
+		k := key(trans[i])
+		// guard clause: a blank key leaves the transaction out
+		if k == "" {
+			continue
+		}
+		if _, ok := g.ByKey[k]; !ok {
+			g.Keys = append(g.Keys, k)
+		}
+		g.ByKey[k] = append(g.ByKey[k], trans[i])
+	}
+	return &g
+}
+
+// Sorted returns the keys in alphabetical order, which is date order for
+// the keys of time buckets
+func (g *Groups) Sorted() []string {
+	keys := make([]string, len(g.Keys))
+	copy(keys, g.Keys)
+	sort.Strings(keys)
+	return keys
+}
+
+// Sum returns the sum of the amounts of each group, keyed like the groups
+func (g *Groups) Sum(value Decimal) map[string]*big.Float {
+	sums := make(map[string]*big.Float, len(g.Keys))
+	for i := 0; i < len(g.Keys); i++ {
+		sums[g.Keys[i]] = SumDecimal(g.ByKey[g.Keys[i]], value)
+	}
+	return sums
+}
+
+// SumDecimal returns the sum of the amounts of the transactions, skipping
+// those without one
+func SumDecimal(trans []*trade.Trade, value Decimal) *big.Float {
+	total := big.NewFloat(0.0)
+	for i := 0; i < len(trans); i++ {
+		if v := value(trans[i]); v != nil {
+			total = total.Add(total, v)
+		}
+	}
+	return total
+}
+
+// Period is the length of the time buckets transactions are grouped into
+type Period string
+
+const (
+	Day     Period = "DAY"
+	Week    Period = "WEEK" // ISO weeks, starting on Monday
+	Month   Period = "MONTH"
+	Quarter Period = "QUARTER"
+	Year    Period = "YEAR"
+)
+
+// TimeBucket returns a key of the period each transaction was made in, eg
+// 2023-01-31 for days, 2023-W05 for weeks, 2023-01 for months, 2023-Q1 for
+// quarters and 2023 for years, which sort in date order
+func TimeBucket(p Period) (Key, error) {
+	switch p {
+	case Day:
+		return func(t *trade.Trade) string {
+			return t.Date.Format("2006-01-02")
+		}, nil
+	case Week:
+		return func(t *trade.Trade) string {
+			year, week := t.Date.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}, nil
+	case Month:
+		return func(t *trade.Trade) string {
+			return t.Date.Format("2006-01")
+		}, nil
+	case Quarter:
+		return func(t *trade.Trade) string {
+			return fmt.Sprintf("%d-Q%d", t.Date.Year(), (int(t.Date.Month())-1)/3+1)
+		}, nil
+	case Year:
+		return func(t *trade.Trade) string {
+			return strconv.Itoa(t.Date.Year())
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown period %q", p)
+}
+
+// Ranked is a key and its value, as ranked by TopN
+type Ranked struct {
+	Key   string
+	Value *big.Float
+}
+
+// TopN returns the n keys with the highest values, highest first and ties
+// in key order. a negative n ranks every key.
+func TopN(values map[string]*big.Float, n int) []*Ranked {
+	return rank(values, n, 1)
+}
+
+// BottomN returns the n keys with the lowest values, lowest first and ties
+// in key order
+func BottomN(values map[string]*big.Float, n int) []*Ranked {
+	return rank(values, n, -1)
+}
+
+// rank orders the values in a direction, 1 for highest first and -1 for
+// lowest first, and keeps the first n
+func rank(values map[string]*big.Float, n int, direction int) []*Ranked {
+	ranked := make([]*Ranked, 0, len(values))
+	for key, value := range values {
+		ranked = append(ranked, &Ranked{Key: key, Value: value})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if c := ranked[i].Value.Cmp(ranked[j].Value); c != 0 {
+			return c == direction
+		}
+		return ranked[i].Key < ranked[j].Key
+	})
+	if n >= 0 && n < len(ranked) {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
@@ -0,0 +1,124 @@
+package books
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/stonks/table"
+)
+
+// beancountDateFormat is the format beancount reads dates in
+const beancountDateFormat = "2006-01-02"
+
+// maxCommodity is the longest commodity beancount accepts
+const maxCommodity = 24
+
+// WriteBeancount writes the journal in the beancount format. every account
+// posted to is opened on the date of the first entry, and holdings are
+// posted with the cost, date and id of their lot so beancount books the
+// lots they close. symbols and account names are changed to the
+// characters beancount allows.
+func (j *Journal) WriteBeancount(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "option \"operating_currency\" %s\n\n", quoted(j.Currency))
+	// guard clause: nothing to open accounts for
+	if len(j.Entries) == 0 {
+		return out.Flush()
+	}
+	opened := make(map[string]bool)
+	accounts := make([]string, 0)
+	for i := 0; i < len(j.Entries); i++ {
+		for k := 0; k < len(j.Entries[i].Postings); k++ {
+			a := beancountAccount(j.Entries[i].Postings[k].Account)
+			if !opened[a] {
+				opened[a] = true
+				accounts = append(accounts, a)
+			}
+		}
+	}
+	sort.Strings(accounts)
+	opening := j.Entries[0].Date.Format(beancountDateFormat)
+	for i := 0; i < len(accounts); i++ {
+		fmt.Fprintf(out, "%s open %s\n", opening, accounts[i])
+	}
+	fmt.Fprintf(out, "\n")
+
+	for i := 0; i < len(j.Entries); i++ {
+		e := j.Entries[i]
+		fmt.Fprintf(out, "%s * %s\n", e.Date.Format(beancountDateFormat), quoted(e.Description))
+		if e.ID != "" {
+			fmt.Fprintf(out, "  id: %s\n", quoted(e.ID))
+		}
+		for k := 0; k < len(e.Postings); k++ {
+			p := e.Postings[k]
+			fmt.Fprintf(out, "  %s  %s %s", beancountAccount(p.Account), table.Decimal(p.Amount), beancountCommodity(p.Commodity))
+			if p.Cost != nil {
+				fmt.Fprintf(out, " {%s %s, %s", table.Decimal(p.Cost.Unit), beancountCommodity(p.Cost.Currency), p.Cost.Date.Format(beancountDateFormat))
+				if p.Cost.Label != "" {
+					fmt.Fprintf(out, ", %s", quoted(p.Cost.Label))
+				}
+				fmt.Fprintf(out, "}")
+			}
+			fmt.Fprintf(out, "\n")
+		}
+		fmt.Fprintf(out, "\n")
+	}
+	return out.Flush()
+}
+
+// quoted quotes a string as beancount does, escaping quotes and
+// backslashes
+func quoted(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// beancountAccount changes an account name to one beancount accepts: each
+// of its components starts with a capital letter or digit and holds only
+// letters, digits and dashes
+func beancountAccount(account string) string {
+	components := strings.Split(account, ":")
+	for i := 0; i < len(components); i++ {
+		c := []rune(strings.TrimSpace(components[i]))
+		for k := 0; k < len(c); k++ {
+			if !unicode.IsLetter(c[k]) && !unicode.IsDigit(c[k]) {
+				c[k] = '-'
+			}
+		}
+		if len(c) == 0 || c[0] == '-' {
+			c = append([]rune("X"), c...)
+		}
+		c[0] = unicode.ToUpper(c[0])
+		components[i] = string(c)
+	}
+	return strings.Join(components, ":")
+}
+
+// beancountCommodity changes a symbol to a commodity beancount accepts: up
+// to 24 capital letters, digits and the characters ' . _ -, starting with
+// a letter and ending with a letter or digit. options, eg
+// AAPL 01/21/2022 150.00 C, are compacted to AAPL01212022150.00C.
+func beancountCommodity(symbol string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(symbol) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || strings.ContainsRune("'._-", r) {
+			b.WriteRune(r)
+		}
+	}
+	c := b.String()
+	if c == "" || c[0] < 'A' || c[0] > 'Z' {
+		c = "X" + c
+	}
+	if len(c) > maxCommodity {
+		c = c[:maxCommodity]
+	}
+	c = strings.TrimRight(c, "'._-")
+	if len(c) == 1 {
+		// a commodity is at least two characters
+		c += "X"
+	}
+	return c
+}
@@ -0,0 +1,285 @@
+// Package books turns transactions into double entry journal entries for
+// plain text accounting and personal finance tools.
+package books
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/table"
+	"github.com/stonks/trade"
+)
+
+// Accounts names the accounts of the books transactions are posted to.
+// names can hold {account}, the account the transaction was made in, and
+// {symbol}, the symbol traded. blank names take the defaults.
+type Accounts struct {
+	Cash        string `json:"cash"`        // cash balance, Assets:Brokerage:{account}:Cash by default
+	Holdings    string `json:"holdings"`    // lots held, Assets:Brokerage:{account}:{symbol} by default
+	Commissions string `json:"commissions"` // Expenses:Brokerage:Commissions by default
+	Fees        string `json:"fees"`        // Expenses:Brokerage:Fees by default
+	Dividends   string `json:"dividends"`   // dividends and coupons, Income:Dividends:{symbol} by default
+	Interest    string `json:"interest"`    // interest paid and charged, Income:Interest by default
+	Gains       string `json:"gains"`       // realized gains and losses, Income:CapitalGains by default
+	Transfers   string `json:"transfers"`   // deposits, withdrawals and transfers, Equity:Transfers by default
+	Other       string `json:"other"`       // anything else, Equity:Uncategorized by default
+}
+
+// defaultAccounts are the accounts used when the configs don't name them
+var defaultAccounts = Accounts{
+	Cash:        "Assets:Brokerage:{account}:Cash",
+	Holdings:    "Assets:Brokerage:{account}:{symbol}",
+	Commissions: "Expenses:Brokerage:Commissions",
+	Fees:        "Expenses:Brokerage:Fees",
+	Dividends:   "Income:Dividends:{symbol}",
+	Interest:    "Income:Interest",
+	Gains:       "Income:CapitalGains",
+	Transfers:   "Equity:Transfers",
+	Other:       "Equity:Uncategorized",
+}
+
+// withDefaults returns the accounts with the blank names defaulted
+func (a *Accounts) withDefaults() *Accounts {
+	c := defaultAccounts
+	if a == nil {
+		return &c
+	}
+	names := []*string{&c.Cash, &c.Holdings, &c.Commissions, &c.Fees, &c.Dividends, &c.Interest, &c.Gains, &c.Transfers, &c.Other}
+	set := []string{a.Cash, a.Holdings, a.Commissions, a.Fees, a.Dividends, a.Interest, a.Gains, a.Transfers, a.Other}
+	for i := 0; i < len(names); i++ {
+		if set[i] != "" {
+			*names[i] = set[i]
+		}
+	}
+	return &c
+}
+
+// name fills in the account and symbol of an account name
+func name(template string, account string, symbol string) string {
+	return strings.NewReplacer("{account}", account, "{symbol}", symbol).Replace(template)
+}
+
+// Cost is the cost of a lot held in the books, per unit
+type Cost struct {
+	Unit     *big.Float // rounded to 8 decimal places, as written
+	Currency string
+	Date     time.Time // date the lot was opened
+	Label    string    // id of the transaction that opened the lot
+}
+
+// Posting is an amount posted to an account
+type Posting struct {
+	Account   string
+	Amount    *big.Float
+	Commodity string // currency, or the symbol of the security for holdings
+	// Cost is the cost of the lot a posting of holdings opens or closes,
+	// nil for cash
+	Cost *Cost
+	// Kind is the account of Accounts posted to, eg Fees, for tools that
+	// classify splits
+	Kind string
+}
+
+// Entry is a balanced journal entry recording a transaction
+type Entry struct {
+	Date        time.Time
+	ID          string
+	Account     string // account the transaction was made in
+	Description string
+	Postings    []*Posting
+}
+
+// Journal is every transaction as journal entries, in date order
+type Journal struct {
+	Currency string // base currency amounts are in
+	Entries  []*Entry
+}
+
+// counterAccount returns the account of Accounts balancing the cash of a
+// transaction that doesn't open or close lots
+func counterAccount(t *trade.Trade) string {
+	switch t.Type {
+	case trade.Dividend, trade.Coupon:
+		return "Dividends"
+	case trade.Interest, trade.MarginInterest:
+		return "Interest"
+	case trade.Deposit, trade.Withdrawal, trade.Journal, trade.TransferIn, trade.TransferOut:
+		return "Transfers"
+	case trade.Fee:
+		return "Fees"
+	}
+	return "Other"
+}
+
+// accountOf returns the name of the account of Accounts of a kind
+func (a *Accounts) accountOf(kind string) string {
+	switch kind {
+	case "Cash":
+		return a.Cash
+	case "Holdings":
+		return a.Holdings
+	case "Commissions":
+		return a.Commissions
+	case "Fees":
+		return a.Fees
+	case "Dividends":
+		return a.Dividends
+	case "Interest":
+		return a.Interest
+	case "Gains":
+		return a.Gains
+	case "Transfers":
+		return a.Transfers
+	}
+	return a.Other
+}
+
+// rounded rounds an amount to the 8 decimal places it's written with, so
+// the amounts the books are balanced with are the amounts they read
+func rounded(f *big.Float) *big.Float {
+	r, _, _ := big.ParseFloat(table.Decimal(f), 10, 64, big.ToNearestEven)
+	return r
+}
+
+// orZero returns the amount, or zero for none
+func orZero(f *big.Float) *big.Float {
+	if f == nil {
+		return big.NewFloat(0.0)
+	}
+	return f
+}
+
+// NewJournal records the transactions as journal entries in the base
+// currency. trans are every transaction and trading those counted as
+// trades, whose lots are matched with the options. cash moves through the
+// cash account, commissions and fees are expensed, lots are held at their
+// cost less the commissions and fees, and the gain or loss on the lots a
+// sale closes is posted to the gains account. the wash sale and other
+// adjustments made to the cost of lots after they open are left out.
+func NewJournal(trans []*trade.Trade, trading []*trade.Trade, opts *lots.Options, accounts *Accounts, currency string) *Journal {
+	a := accounts.withDefaults()
+	ordered := make([]*trade.Trade, len(trans))
+	copy(ordered, trans)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Date.Before(ordered[j].Date)
+	})
+	matched := make(map[*trade.Trade]bool, len(trading))
+	for i := 0; i < len(trading); i++ {
+		matched[trading[i]] = lots.Affects(trading[i])
+	}
+
+	j := Journal{Currency: currency, Entries: make([]*Entry, 0, len(ordered))}
+	e := lots.NewEngine(opts)
+	costs := make(map[*trade.Trade]*Cost) // cost of the lots opened by each transaction
+	for i := 0; i < len(ordered); i++ {
+		t := ordered[i]
+		symbol := strings.TrimSpace(t.Symbol)
+		entry := Entry{Date: t.Date, ID: t.ID, Account: t.Account, Description: strings.TrimSpace(t.Description), Postings: make([]*Posting, 0, 4)}
+		post := func(kind string, amount *big.Float, commodity string, cost *Cost) {
+			entry.Postings = append(entry.Postings, &Posting{
+				Account:   name(a.accountOf(kind), t.Account, symbol),
+				Amount:    amount,
+				Commodity: commodity,
+				Cost:      cost,
+				Kind:      kind,
+			})
+		}
+
+		cash := rounded(orZero(t.Amount))
+		commission := rounded(orZero(t.Commission))
+		fees := big.NewFloat(0.0)
+		if t.Fees != nil {
+			fees = rounded(t.Fees.Total())
+		}
+		// paid is the cash before the commissions and fees came out of it,
+		// and balance what the postings so far leave unbalanced
+		paid := big.NewFloat(0.0).Add(cash, commission)
+		paid = paid.Add(paid, fees)
+		balance := big.NewFloat(0.0).Copy(paid)
+		if cash.Sign() != 0 {
+			post("Cash", cash, currency, nil)
+		}
+		if commission.Sign() != 0 {
+			post("Commissions", commission, currency, nil)
+		}
+		if fees.Sign() != 0 {
+			post("Fees", fees, currency, nil)
+		}
+
+		if !matched[t] {
+			if balance.Sign() != 0 {
+				post(counterAccount(t), big.NewFloat(0.0).Neg(balance), currency, nil)
+			}
+			if len(entry.Postings) > 0 {
+				j.Entries = append(j.Entries, &entry)
+			}
+			continue
+		}
+
+		// lots are compared before and after the transaction, since a
+		// transfer moves lots without realizing a gain
+		held := e.LotsFor(t.Account, symbol)
+		before := make([]*lots.Lot, len(held))
+		quantities := make([]*big.Float, len(held))
+		for k := 0; k < len(held); k++ {
+			before[k] = held[k]
+			quantities[k] = big.NewFloat(0.0).Copy(held[k].Quantity)
+		}
+		realized := e.Apply(t)
+		after := e.LotsFor(t.Account, symbol)
+		remaining := make(map[*lots.Lot]*big.Float, len(after))
+		for k := 0; k < len(after); k++ {
+			remaining[after[k]] = after[k].Quantity
+		}
+
+		for k := 0; k < len(before); k++ {
+			left := orZero(remaining[before[k]])
+			delete(remaining, before[k])
+			change := big.NewFloat(0.0).Sub(left, quantities[k])
+			cost := costs[before[k].Opening]
+			// guard clause: untouched by the transaction
+			if change.Sign() == 0 || cost == nil {
+				continue
+			}
+			post("Holdings", change, symbol, cost)
+			balance = balance.Add(balance, new(big.Float).Mul(change, cost.Unit))
+		}
+		for k := 0; k < len(after); k++ {
+			lot := after[k]
+			// guard clause: held before the transaction
+			if remaining[lot] == nil {
+				continue
+			}
+			// lots transferred in keep the cost they were opened at
+			cost := costs[lot.Opening]
+			if cost == nil {
+				// lots opened by the transaction share what was paid for
+				// them by quantity, as the lots engine shares the amount
+				total := big.NewFloat(0.0).Neg(paid)
+				total = total.Mul(total, big.NewFloat(0.0).Abs(lot.Quantity))
+				total = total.Quo(total, big.NewFloat(0.0).Abs(t.Quantity))
+				cost = &Cost{Unit: rounded(total.Quo(total, lot.Quantity)), Currency: currency, Date: lot.OpenDate, Label: lot.OpeningID}
+				costs[lot.Opening] = cost
+			}
+			// the engine changes the quantity as the lot is closed
+			quantity := big.NewFloat(0.0).Copy(lot.Quantity)
+			post("Holdings", quantity, symbol, cost)
+			balance = balance.Add(balance, new(big.Float).Mul(quantity, cost.Unit))
+		}
+
+		if rounded(balance).Sign() != 0 {
+			kind := "Gains"
+			if len(realized) == 0 {
+				kind = counterAccount(t)
+			}
+			post(kind, rounded(balance.Neg(balance)), currency, nil)
+		}
+		if len(entry.Postings) > 0 {
+			j.Entries = append(j.Entries, &entry)
+		}
+	}
+	return &j
+}
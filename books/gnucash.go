@@ -0,0 +1,156 @@
+package books
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+
+	"github.com/stonks/table"
+)
+
+// gnuCashDateFormat is the format dates are written in for GnuCash, which
+// asks for the format on import
+const gnuCashDateFormat = "2006-01-02"
+
+// qifDateFormat is the format QIF files write dates in
+const qifDateFormat = "01/02/2006"
+
+// value returns the value of a posting in the base currency, its amount
+// at the cost of its lot for holdings
+func (p *Posting) value() *big.Float {
+	if p.Cost == nil {
+		return p.Amount
+	}
+	return rounded(new(big.Float).Mul(p.Amount, p.Cost.Unit))
+}
+
+// transactionID returns the id GnuCash groups the splits of an entry by,
+// numbering the entries without one
+func (e *Entry) transactionID(n int) string {
+	if e.ID != "" {
+		return e.ID
+	}
+	return "entry-" + strconv.Itoa(n+1)
+}
+
+// WriteGnuCashCSV writes the journal as a csv in the layout GnuCash exports
+// transactions in, one row per split, for import with the GnuCash Export
+// Format preset of its transaction importer. the splits of an entry share
+// its transaction id, with the commissions and fees split to their own
+// accounts and holdings split in shares at the cost of their lot.
+func (j *Journal) WriteGnuCashCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	header := []string{"Date", "Transaction ID", "Number", "Description", "Notes", "Commodity/Currency", "Void Reason", "Action", "Memo", "Full Account Name", "Account Name", "Amount With Sym", "Amount Num.", "Value With Sym", "Value Num.", "Reconcile", "Reconcile Date", "Rate/Price"}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	currency := "CURRENCY::" + j.Currency
+	for i := 0; i < len(j.Entries); i++ {
+		e := j.Entries[i]
+		id := e.transactionID(i)
+		for k := 0; k < len(e.Postings); k++ {
+			p := e.Postings[k]
+			action, price := "", "1"
+			if p.Cost != nil {
+				action, price = "Buy", table.Decimal(p.Cost.Unit)
+				if p.Amount.Sign() < 0 {
+					action = "Sell"
+				}
+			}
+			amount, value := table.Decimal(p.Amount), table.Decimal(p.value())
+			record := []string{
+				e.Date.Format(gnuCashDateFormat), id, e.ID, e.Description, "", currency, "",
+				action, p.Kind, p.Account, lastComponent(p.Account),
+				amount + " " + p.Commodity, amount, value + " " + j.Currency, value,
+				"c", "", price,
+			}
+			if err := out.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// lastComponent returns the name of an account without its parents
+func lastComponent(account string) string {
+	for i := len(account) - 1; i >= 0; i-- {
+		if account[i] == ':' {
+			return account[i+1:]
+		}
+	}
+	return account
+}
+
+// WriteQIF writes the journal as a QIF file of bank transactions, for
+// GnuCash's QIF importer. each entry is a transaction of the account its
+// cash moved through, or its first account if no cash moved, split to the
+// other accounts it posts to as transfers, commissions and fees included.
+// QIF only holds amounts, so holdings are split at the cost of their lot
+// with the shares noted in the memo.
+func (j *Journal) WriteQIF(w io.Writer) error {
+	accounts := make([]string, 0)
+	byAccount := make(map[string][]*Entry)
+	for i := 0; i < len(j.Entries); i++ {
+		e := j.Entries[i]
+		// guard clause: nothing to split
+		if len(e.Postings) == 0 {
+			continue
+		}
+		account := e.Postings[0].Account
+		for k := 0; k < len(e.Postings); k++ {
+			if e.Postings[k].Kind == "Cash" {
+				account = e.Postings[k].Account
+				break
+			}
+		}
+		if byAccount[account] == nil {
+			accounts = append(accounts, account)
+		}
+		byAccount[account] = append(byAccount[account], e)
+	}
+
+	out := bufio.NewWriter(w)
+	for i := 0; i < len(accounts); i++ {
+		fmt.Fprintf(out, "!Account\nN%s\nTBank\n^\n!Type:Bank\n", accounts[i])
+		entries := byAccount[accounts[i]]
+		for k := 0; k < len(entries); k++ {
+			writeQIFEntry(out, accounts[i], entries[k])
+		}
+	}
+	return out.Flush()
+}
+
+// writeQIFEntry writes an entry as a transaction of the account, split to
+// the entry's other postings. split amounts are what the transaction moved
+// out of the account to each, so they sum to its total.
+func writeQIFEntry(out io.Writer, account string, e *Entry) {
+	total := big.NewFloat(0.0)
+	splits := make([]*Posting, 0, len(e.Postings))
+	for i := 0; i < len(e.Postings); i++ {
+		p := e.Postings[i]
+		if p.Account == account && p.Cost == nil {
+			total = total.Add(total, p.value())
+			continue
+		}
+		splits = append(splits, p)
+	}
+	fmt.Fprintf(out, "D%s\nT%s\nC*\n", e.Date.Format(qifDateFormat), table.Decimal(total))
+	if e.ID != "" {
+		fmt.Fprintf(out, "N%s\n", e.ID)
+	}
+	fmt.Fprintf(out, "P%s\n", e.Description)
+	for i := 0; i < len(splits); i++ {
+		p := splits[i]
+		fmt.Fprintf(out, "S[%s]\n", p.Account)
+		if p.Cost != nil {
+			fmt.Fprintf(out, "E%s %s at %s %s\n", table.Decimal(p.Amount), p.Commodity, table.Decimal(p.Cost.Unit), p.Cost.Currency)
+		}
+		fmt.Fprintf(out, "$%s\n", table.Decimal(new(big.Float).Neg(p.value())))
+	}
+	fmt.Fprintf(out, "^\n")
+}
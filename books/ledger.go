@@ -0,0 +1,53 @@
+package books
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/stonks/table"
+)
+
+// ledgerDateFormat is the format ledger-cli reads dates in
+const ledgerDateFormat = "2006/01/02"
+
+// WriteLedger writes the journal in the ledger-cli format. each entry is
+// cleared, with the transaction id as its code, and holdings are posted
+// with the cost, date and id of their lot so ledger tracks the lots.
+func (j *Journal) WriteLedger(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	for i := 0; i < len(j.Entries); i++ {
+		e := j.Entries[i]
+		fmt.Fprintf(out, "%s *", e.Date.Format(ledgerDateFormat))
+		if e.ID != "" {
+			fmt.Fprintf(out, " (%s)", e.ID)
+		}
+		fmt.Fprintf(out, " %s\n", e.Description)
+		for k := 0; k < len(e.Postings); k++ {
+			p := e.Postings[k]
+			fmt.Fprintf(out, "    %s  %s %s", p.Account, table.Decimal(p.Amount), ledgerCommodity(p.Commodity))
+			if p.Cost != nil {
+				fmt.Fprintf(out, " {%s %s} [%s]", table.Decimal(p.Cost.Unit), ledgerCommodity(p.Cost.Currency), p.Cost.Date.Format(ledgerDateFormat))
+				if p.Cost.Label != "" {
+					fmt.Fprintf(out, " (%s)", p.Cost.Label)
+				}
+			}
+			fmt.Fprintf(out, "\n")
+		}
+		fmt.Fprintf(out, "\n")
+	}
+	return out.Flush()
+}
+
+// ledgerCommodity quotes a commodity unless it is only letters, as ledger
+// reads numbers and spaces as part of the amount
+func ledgerCommodity(c string) string {
+	for _, r := range c {
+		if !unicode.IsLetter(r) {
+			return `"` + strings.ReplaceAll(c, `"`, "") + `"`
+		}
+	}
+	return c
+}
@@ -0,0 +1,83 @@
+package chart
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	gainColor = "#2ca02c"
+	lossColor = "#d62728"
+	maxLabels = 12 // most bar labels drawn, skipping some when there are more bars
+)
+
+// Bar is a labelled value, eg the P/L of a month
+type Bar struct {
+	Label string
+	Value float64
+}
+
+// BarChart is a titled set of bars, drawn in order from the left. bars
+// above zero are green and bars below it red.
+type BarChart struct {
+	Title string
+	Unit  string // suffix of the y axis labels, eg %
+	Bars  []Bar
+}
+
+// WriteSVG draws the bar chart as an svg image
+func (c *BarChart) WriteSVG(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	begin(out, chartHeight)
+	c.write(out)
+	fmt.Fprintf(out, "</svg>\n")
+	return out.Flush()
+}
+
+// write draws the bars against an axis that always includes zero
+func (c *BarChart) write(w io.Writer) {
+	minY, maxY := 0.0, 0.0
+	for i := 0; i < len(c.Bars); i++ {
+		minY = math.Min(minY, c.Bars[i].Value)
+		maxY = math.Max(maxY, c.Bars[i].Value)
+	}
+	if minY == maxY {
+		maxY = 1
+	}
+	plotWidth := float64(chartWidth - padLeft - padRight)
+	plotHeight := float64(chartHeight - padTop - padBottom)
+	y := func(v float64) float64 {
+		return padTop + (maxY-v)/(maxY-minY)*plotHeight
+	}
+
+	fmt.Fprintf(w, `<text x="%d" y="%d" font-size="14" font-weight="bold">%s</text>`+"\n", padLeft, 18, escape(c.Title))
+	fmt.Fprintf(w, `<rect x="%d" y="%d" width="%.0f" height="%.0f" fill="none" stroke="#999"/>`+"\n", padLeft, padTop, plotWidth, plotHeight)
+	fmt.Fprintf(w, `<text x="%d" y="%.1f" font-size="10" text-anchor="end">%s</text>`+"\n", padLeft-4, y(maxY)+4, label(maxY, c.Unit))
+	fmt.Fprintf(w, `<text x="%d" y="%.1f" font-size="10" text-anchor="end">%s</text>`+"\n", padLeft-4, y(minY), label(minY, c.Unit))
+	fmt.Fprintf(w, `<line x1="%d" y1="%.1f" x2="%.0f" y2="%.1f" stroke="#ccc"/>`+"\n", padLeft, y(0), padLeft+plotWidth, y(0))
+
+	// guard clause: nothing to draw
+	if len(c.Bars) == 0 {
+		return
+	}
+	slot := plotWidth / float64(len(c.Bars))
+	step := (len(c.Bars) + maxLabels - 1) / maxLabels
+	for i := 0; i < len(c.Bars); i++ {
+		b := c.Bars[i]
+		color := gainColor
+		if b.Value < 0 {
+			color = lossColor
+		}
+		top := math.Min(y(b.Value), y(0))
+		height := math.Abs(y(b.Value) - y(0))
+		x := padLeft + float64(i)*slot
+		fmt.Fprintf(w, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %s</title></rect>`+"\n",
+			x+slot*0.1, top, slot*0.8, height, color, escape(b.Label), label(b.Value, c.Unit))
+		if i%step == 0 {
+			fmt.Fprintf(w, `<text x="%.1f" y="%d" font-size="10" text-anchor="middle">%s</text>`+"\n",
+				x+slot/2, chartHeight-padBottom+14, escape(b.Label))
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package chart
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	pieRadius = 110
+	maxSlices = 8 // slices drawn, the smaller ones are combined into Other
+)
+
+// Slice is a labelled share of a pie, eg the value held in a symbol
+type Slice struct {
+	Label string
+	Value float64
+}
+
+// PieChart is a titled pie of slices, drawn largest first
+type PieChart struct {
+	Title  string
+	Slices []Slice
+}
+
+// WriteSVG draws the pie chart as an svg image
+func (c *PieChart) WriteSVG(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	begin(out, chartHeight)
+	c.write(out)
+	fmt.Fprintf(out, "</svg>\n")
+	return out.Flush()
+}
+
+// slices returns the slices with a positive value, largest first, with
+// any past the most drawn combined into Other
+func (c *PieChart) slices() []Slice {
+	slices := make([]Slice, 0, len(c.Slices))
+	for i := 0; i < len(c.Slices); i++ {
+		if c.Slices[i].Value > 0 {
+			slices = append(slices, c.Slices[i])
+		}
+	}
+	sort.SliceStable(slices, func(i, j int) bool {
+		return slices[i].Value > slices[j].Value
+	})
+	if len(slices) > maxSlices {
+		other := Slice{Label: "Other"}
+		for i := maxSlices - 1; i < len(slices); i++ {
+			other.Value += slices[i].Value
+		}
+		slices = append(slices[:maxSlices-1], other)
+	}
+	return slices
+}
+
+// write draws the pie with a legend of the slices to its right
+func (c *PieChart) write(w io.Writer) {
+	fmt.Fprintf(w, `<text x="%d" y="%d" font-size="14" font-weight="bold">%s</text>`+"\n", padLeft, 18, escape(c.Title))
+	slices := c.slices()
+	total := 0.0
+	for i := 0; i < len(slices); i++ {
+		total += slices[i].Value
+	}
+	// guard clause: nothing to draw
+	if total == 0 {
+		return
+	}
+	cx, cy := float64(padLeft+pieRadius), float64(padTop+(chartHeight-padTop)/2)
+	angle := -math.Pi / 2 // start at the top, going clockwise
+	for i := 0; i < len(slices); i++ {
+		color := palette[i%len(palette)]
+		if i == maxSlices-1 && slices[i].Label == "Other" {
+			color = "#999"
+		}
+		share := slices[i].Value / total
+		text := fmt.Sprintf("%s %.1f%%", slices[i].Label, share*100)
+		if len(slices) == 1 {
+			fmt.Fprintf(w, `<circle cx="%.1f" cy="%.1f" r="%d" fill="%s"><title>%s</title></circle>`+"\n", cx, cy, pieRadius, color, escape(text))
+		} else {
+			end := angle + share*2*math.Pi
+			large := 0
+			if share > 0.5 {
+				large = 1
+			}
+			fmt.Fprintf(w, `<path d="M%.1f,%.1f L%.1f,%.1f A%d,%d 0 %d 1 %.1f,%.1f Z" fill="%s" stroke="white"><title>%s</title></path>`+"\n",
+				cx, cy, cx+pieRadius*math.Cos(angle), cy+pieRadius*math.Sin(angle),
+				pieRadius, pieRadius, large, cx+pieRadius*math.Cos(end), cy+pieRadius*math.Sin(end), color, escape(text))
+			angle = end
+		}
+		legendX := padLeft + 2*pieRadius + 40
+		legendY := padTop + 20 + i*18
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`+"\n", legendX, legendY-9, color)
+		fmt.Fprintf(w, `<text x="%d" y="%d" font-size="11">%s</text>`+"\n", legendX+14, legendY, escape(text))
+	}
+}
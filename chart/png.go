@@ -0,0 +1,292 @@
+package chart
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"time"
+)
+
+// pngScale is how many pixels across a unit of the svg layout is in a png
+// image, drawing pngs at twice the size so their text stays legible
+const pngScale = 2
+
+// canvas is a png image being drawn on, addressed in the units of the svg
+// layout so both formats are laid out alike
+type canvas struct {
+	img *image.RGBA
+}
+
+// newCanvas returns a white canvas as wide as a chart
+func newCanvas(height int) *canvas {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth*pngScale, height*pngScale))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	return &canvas{img: img}
+}
+
+// rgb parses a css color written as #rgb or #rrggbb, black if it isn't
+func rgb(css string) color.RGBA {
+	if len(css) == 4 {
+		css = string([]byte{'#', css[1], css[1], css[2], css[2], css[3], css[3]})
+	}
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(css, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return color.RGBA{A: 255}
+	}
+	return color.RGBA{R: r, G: g, B: b, A: 255}
+}
+
+// pixel returns the pixel a coordinate of the layout falls on
+func pixel(v float64) int {
+	return int(math.Round(v * pngScale))
+}
+
+// fill fills the rectangle between two corners
+func (c *canvas) fill(x0, y0, x1, y1 float64, col color.RGBA) {
+	r := image.Rect(pixel(x0), pixel(y0), pixel(x1), pixel(y1))
+	draw.Draw(c.img, r, &image.Uniform{C: col}, image.Point{}, draw.Src)
+}
+
+// line draws a line between two points, width units thick
+func (c *canvas) line(x0, y0, x1, y1 float64, width float64, col color.RGBA) {
+	px0, py0, px1, py1 := x0*pngScale, y0*pngScale, x1*pngScale, y1*pngScale
+	size := int(math.Max(1, math.Round(width*pngScale)))
+	steps := int(math.Ceil(math.Max(math.Abs(px1-px0), math.Abs(py1-py0))))
+	for i := 0; i <= steps; i++ {
+		t := 0.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+		x := int(math.Round(px0+t*(px1-px0))) - size/2
+		y := int(math.Round(py0+t*(py1-py0))) - size/2
+		draw.Draw(c.img, image.Rect(x, y, x+size, y+size), &image.Uniform{C: col}, image.Point{}, draw.Src)
+	}
+}
+
+// stroke outlines the rectangle between two corners
+func (c *canvas) stroke(x0, y0, x1, y1 float64, col color.RGBA) {
+	c.line(x0, y0, x1, y0, 1, col)
+	c.line(x1, y0, x1, y1, 1, col)
+	c.line(x1, y1, x0, y1, 1, col)
+	c.line(x0, y1, x0, y0, 1, col)
+}
+
+// text writes text with its baseline at y, starting at x for the start
+// anchor, ending at it for end and centred on it for middle, as in svg.
+// text of size 14 and up is drawn larger, for titles.
+func (c *canvas) text(x, y float64, s string, size int, anchor string, col color.RGBA) {
+	dot := 2
+	if size >= 14 {
+		dot = 3
+	}
+	chars := []rune(s)
+	width := len(chars)*(glyphWidth+1)*dot - dot
+	left := pixel(x)
+	switch anchor {
+	case "end":
+		left -= width
+	case "middle":
+		left -= width / 2
+	}
+	top := pixel(y) - glyphHeight*dot
+	for i := 0; i < len(chars); i++ {
+		g := glyph(chars[i])
+		for row := 0; row < glyphHeight; row++ {
+			for column := 0; column < glyphWidth; column++ {
+				if g[row][column] != '#' {
+					continue
+				}
+				x := left + (i*(glyphWidth+1)+column)*dot
+				y := top + row*dot
+				draw.Draw(c.img, image.Rect(x, y, x+dot, y+dot), &image.Uniform{C: col}, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
+
+// wedge fills the slice of a circle between two angles, in radians
+// clockwise from the positive x axis
+func (c *canvas) wedge(cx, cy, r, from, to float64, col color.RGBA) {
+	pcx, pcy, pr := cx*pngScale, cy*pngScale, r*pngScale
+	for y := int(pcy - pr); y <= int(pcy+pr); y++ {
+		for x := int(pcx - pr); x <= int(pcx+pr); x++ {
+			dx, dy := float64(x)+0.5-pcx, float64(y)+0.5-pcy
+			if dx*dx+dy*dy > pr*pr {
+				continue
+			}
+			angle := math.Atan2(dy, dx)
+			for angle < from {
+				angle += 2 * math.Pi
+			}
+			for angle >= from+2*math.Pi {
+				angle -= 2 * math.Pi
+			}
+			if angle < to {
+				c.img.SetRGBA(x, y, col)
+			}
+		}
+	}
+}
+
+// encode writes the canvas as a png image
+func (c *canvas) encode(w io.Writer) error {
+	return png.Encode(w, c.img)
+}
+
+// WritePNG draws the charts stacked on top of each other as a png image,
+// laid out as WriteSVG lays them out
+func WritePNG(w io.Writer, charts ...*Chart) error {
+	c := newCanvas(chartHeight * len(charts))
+	for i := 0; i < len(charts); i++ {
+		charts[i].draw(c, i*chartHeight)
+	}
+	return c.encode(w)
+}
+
+// draw draws the chart on the canvas with its top edge at offset
+func (c *Chart) draw(cv *canvas, offset int) {
+	minX, maxX, minY, maxY := c.bounds()
+	plotWidth := float64(chartWidth - padLeft - padRight)
+	plotHeight := float64(chartHeight - padTop - padBottom)
+	span := maxX.Sub(minX).Seconds()
+	x := func(t time.Time) float64 {
+		if span == 0 {
+			return padLeft + plotWidth/2
+		}
+		return padLeft + t.Sub(minX).Seconds()/span*plotWidth
+	}
+	y := func(v float64) float64 {
+		return float64(offset+padTop) + (maxY-v)/(maxY-minY)*plotHeight
+	}
+
+	black, grey := rgb("#222"), rgb("#999")
+	cv.text(padLeft, float64(offset+18), c.Title, 14, "start", black)
+	cv.stroke(padLeft, float64(offset+padTop), padLeft+plotWidth, float64(offset+padTop)+plotHeight, grey)
+	cv.text(padLeft-4, y(maxY)+4, label(maxY, c.Unit), 10, "end", black)
+	cv.text(padLeft-4, y(minY), label(minY, c.Unit), 10, "end", black)
+	if minY < 0 && maxY > 0 {
+		cv.line(padLeft, y(0), padLeft+plotWidth, y(0), 1, rgb("#ccc"))
+	}
+	if !minX.IsZero() {
+		bottom := float64(offset + chartHeight - padBottom + 14)
+		cv.text(padLeft, bottom, minX.Format("2006-01-02"), 10, "start", black)
+		cv.text(padLeft+plotWidth, bottom, maxX.Format("2006-01-02"), 10, "end", black)
+	}
+
+	for i := 0; i < len(c.Lines); i++ {
+		line := c.Lines[i]
+		col := rgb(palette[i%len(palette)])
+		if line.Color != "" {
+			col = rgb(line.Color)
+		}
+		for j := 1; j < len(line.Points); j++ {
+			cv.line(x(line.Points[j-1].X), y(line.Points[j-1].Y), x(line.Points[j].X), y(line.Points[j].Y), 1.5, col)
+		}
+		if len(line.Points) == 1 {
+			cv.line(x(line.Points[0].X), y(line.Points[0].Y), x(line.Points[0].X), y(line.Points[0].Y), 3, col)
+		}
+		legendX := float64(padLeft + 10 + i*150)
+		legendY := float64(offset + chartHeight - 8)
+		cv.fill(legendX, legendY-9, legendX+10, legendY+1, col)
+		cv.text(legendX+14, legendY, line.Name, 11, "start", black)
+	}
+}
+
+// WritePNG draws the bar chart as a png image
+func (c *BarChart) WritePNG(w io.Writer) error {
+	cv := newCanvas(chartHeight)
+	c.draw(cv)
+	return cv.encode(w)
+}
+
+// draw draws the bars on the canvas against an axis that always includes
+// zero
+func (c *BarChart) draw(cv *canvas) {
+	minY, maxY := 0.0, 0.0
+	for i := 0; i < len(c.Bars); i++ {
+		minY = math.Min(minY, c.Bars[i].Value)
+		maxY = math.Max(maxY, c.Bars[i].Value)
+	}
+	if minY == maxY {
+		maxY = 1
+	}
+	plotWidth := float64(chartWidth - padLeft - padRight)
+	plotHeight := float64(chartHeight - padTop - padBottom)
+	y := func(v float64) float64 {
+		return padTop + (maxY-v)/(maxY-minY)*plotHeight
+	}
+
+	black := rgb("#222")
+	cv.text(padLeft, 18, c.Title, 14, "start", black)
+	cv.stroke(padLeft, padTop, padLeft+plotWidth, padTop+plotHeight, rgb("#999"))
+	cv.text(padLeft-4, y(maxY)+4, label(maxY, c.Unit), 10, "end", black)
+	cv.text(padLeft-4, y(minY), label(minY, c.Unit), 10, "end", black)
+	cv.line(padLeft, y(0), padLeft+plotWidth, y(0), 1, rgb("#ccc"))
+
+	// guard clause: nothing to draw
+	if len(c.Bars) == 0 {
+		return
+	}
+	slot := plotWidth / float64(len(c.Bars))
+	step := (len(c.Bars) + maxLabels - 1) / maxLabels
+	for i := 0; i < len(c.Bars); i++ {
+		b := c.Bars[i]
+		col := rgb(gainColor)
+		if b.Value < 0 {
+			col = rgb(lossColor)
+		}
+		top := math.Min(y(b.Value), y(0))
+		bottom := math.Max(y(b.Value), y(0))
+		x := padLeft + float64(i)*slot
+		cv.fill(x+slot*0.1, top, x+slot*0.9, bottom, col)
+		if i%step == 0 {
+			cv.text(x+slot/2, chartHeight-padBottom+14, b.Label, 10, "middle", black)
+		}
+	}
+}
+
+// WritePNG draws the pie chart as a png image
+func (c *PieChart) WritePNG(w io.Writer) error {
+	cv := newCanvas(chartHeight)
+	c.draw(cv)
+	return cv.encode(w)
+}
+
+// draw draws the pie on the canvas with a legend of the slices to its
+// right
+func (c *PieChart) draw(cv *canvas) {
+	black := rgb("#222")
+	cv.text(padLeft, 18, c.Title, 14, "start", black)
+	slices := c.slices()
+	total := 0.0
+	for i := 0; i < len(slices); i++ {
+		total += slices[i].Value
+	}
+	// guard clause: nothing to draw
+	if total == 0 {
+		return
+	}
+	cx, cy := float64(padLeft+pieRadius), float64(padTop+(chartHeight-padTop)/2)
+	angle := -math.Pi / 2 // start at the top, going clockwise
+	for i := 0; i < len(slices); i++ {
+		col := rgb(palette[i%len(palette)])
+		if i == maxSlices-1 && slices[i].Label == "Other" {
+			col = rgb("#999")
+		}
+		share := slices[i].Value / total
+		end := angle + share*2*math.Pi
+		cv.wedge(cx, cy, pieRadius, angle, end, col)
+		if len(slices) > 1 {
+			cv.line(cx, cy, cx+pieRadius*math.Cos(angle), cy+pieRadius*math.Sin(angle), 1, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+		angle = end
+		legendX := float64(padLeft + 2*pieRadius + 40)
+		legendY := float64(padTop + 20 + i*18)
+		cv.fill(legendX, legendY-9, legendX+10, legendY+1, col)
+		cv.text(legendX+14, legendY, fmt.Sprintf("%s %.1f%%", slices[i].Label, share*100), 11, "start", black)
+	}
+}
@@ -0,0 +1,147 @@
+// Package chart draws simple line, bar and pie charts as SVG or PNG using
+// only the standard library.
+package chart
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	chartWidth  = 800
+	chartHeight = 300
+	padLeft     = 70
+	padRight    = 20
+	padTop      = 30
+	padBottom   = 40
+)
+
+// palette holds the colors given to lines that don't set their own
+var palette = []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b"}
+
+// Point is a value on a date
+type Point struct {
+	X time.Time
+	Y float64
+}
+
+// Line is a named series of points, in date order
+type Line struct {
+	Name   string
+	Color  string // css color, blank to pick from the palette
+	Points []Point
+}
+
+// Chart is a titled set of lines drawn against the same axes
+type Chart struct {
+	Title string
+	Unit  string // suffix of the y axis labels, eg %
+	Lines []*Line
+}
+
+// bounds returns the range of dates and values across the chart's lines
+func (c *Chart) bounds() (time.Time, time.Time, float64, float64) {
+	var minX, maxX time.Time
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for i := 0; i < len(c.Lines); i++ {
+		points := c.Lines[i].Points
+		for j := 0; j < len(points); j++ {
+			p := points[j]
+			if minX.IsZero() || p.X.Before(minX) {
+				minX = p.X
+			}
+			if maxX.IsZero() || p.X.After(maxX) {
+				maxX = p.X
+			}
+			minY = math.Min(minY, p.Y)
+			maxY = math.Max(maxY, p.Y)
+		}
+	}
+	if math.IsInf(minY, 1) {
+		minY, maxY = 0, 1
+	}
+	if minY == maxY {
+		minY, maxY = minY-1, maxY+1
+	}
+	return minX, maxX, minY, maxY
+}
+
+// label formats a y axis value
+func label(v float64, unit string) string {
+	return fmt.Sprintf("%.2f%s", v, unit)
+}
+
+// write draws the chart with its top edge at offset
+func (c *Chart) write(w io.Writer, offset int) {
+	minX, maxX, minY, maxY := c.bounds()
+	plotWidth := float64(chartWidth - padLeft - padRight)
+	plotHeight := float64(chartHeight - padTop - padBottom)
+	span := maxX.Sub(minX).Seconds()
+	x := func(t time.Time) float64 {
+		if span == 0 {
+			return padLeft + plotWidth/2
+		}
+		return padLeft + t.Sub(minX).Seconds()/span*plotWidth
+	}
+	y := func(v float64) float64 {
+		return float64(offset+padTop) + (maxY-v)/(maxY-minY)*plotHeight
+	}
+
+	fmt.Fprintf(w, `<text x="%d" y="%d" font-size="14" font-weight="bold">%s</text>`+"\n", padLeft, offset+18, escape(c.Title))
+	fmt.Fprintf(w, `<rect x="%d" y="%d" width="%.0f" height="%.0f" fill="none" stroke="#999"/>`+"\n", padLeft, offset+padTop, plotWidth, plotHeight)
+	fmt.Fprintf(w, `<text x="%d" y="%.1f" font-size="10" text-anchor="end">%s</text>`+"\n", padLeft-4, y(maxY)+4, label(maxY, c.Unit))
+	fmt.Fprintf(w, `<text x="%d" y="%.1f" font-size="10" text-anchor="end">%s</text>`+"\n", padLeft-4, y(minY), label(minY, c.Unit))
+	if minY < 0 && maxY > 0 {
+		fmt.Fprintf(w, `<line x1="%d" y1="%.1f" x2="%.0f" y2="%.1f" stroke="#ccc"/>`+"\n", padLeft, y(0), padLeft+plotWidth, y(0))
+	}
+	if !minX.IsZero() {
+		bottom := offset + chartHeight - padBottom + 14
+		fmt.Fprintf(w, `<text x="%d" y="%d" font-size="10">%s</text>`+"\n", padLeft, bottom, minX.Format("2006-01-02"))
+		fmt.Fprintf(w, `<text x="%.0f" y="%d" font-size="10" text-anchor="end">%s</text>`+"\n", padLeft+plotWidth, bottom, maxX.Format("2006-01-02"))
+	}
+
+	for i := 0; i < len(c.Lines); i++ {
+		line := c.Lines[i]
+		color := line.Color
+		if color == "" {
+			color = palette[i%len(palette)]
+		}
+		coords := make([]string, len(line.Points))
+		for j := 0; j < len(line.Points); j++ {
+			coords[j] = fmt.Sprintf("%.1f,%.1f", x(line.Points[j].X), y(line.Points[j].Y))
+		}
+		fmt.Fprintf(w, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`+"\n", color, strings.Join(coords, " "))
+		legendX := padLeft + 10 + i*150
+		legendY := offset + chartHeight - 8
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`+"\n", legendX, legendY-9, color)
+		fmt.Fprintf(w, `<text x="%d" y="%d" font-size="11">%s</text>`+"\n", legendX+14, legendY, escape(line.Name))
+	}
+}
+
+// escape escapes the characters with special meaning in svg text
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// begin writes the start of an svg image of charts, with a white
+// background
+func begin(w io.Writer, height int) {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif">`+"\n",
+		chartWidth, height, chartWidth, height)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+}
+
+// WriteSVG draws the charts stacked on top of each other as an svg image
+func WriteSVG(w io.Writer, charts ...*Chart) error {
+	out := bufio.NewWriter(w)
+	begin(out, chartHeight*len(charts))
+	for i := 0; i < len(charts); i++ {
+		charts[i].write(out, i*chartHeight)
+	}
+	fmt.Fprintf(out, "</svg>\n")
+	return out.Flush()
+}
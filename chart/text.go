@@ -0,0 +1,189 @@
+package chart
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	textWidth  = 60 // characters across the plot of a text chart
+	textHeight = 12 // lines down the plot of a line chart
+)
+
+// ansi escape codes of the colors text charts use
+const (
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiReset = "\x1b[0m"
+)
+
+// sparks are the blocks a sparkline is drawn with, lowest first
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws the values as a single line of blocks, one per value,
+// scaled from the lowest to the highest
+func Sparkline(values []float64) string {
+	low, high := math.Inf(1), math.Inf(-1)
+	for i := 0; i < len(values); i++ {
+		low = math.Min(low, values[i])
+		high = math.Max(high, values[i])
+	}
+	var b strings.Builder
+	for i := 0; i < len(values); i++ {
+		level := 0
+		if high > low {
+			level = int((values[i] - low) / (high - low) * float64(len(sparks)-1))
+		}
+		b.WriteRune(sparks[level])
+	}
+	return b.String()
+}
+
+// braille holds the dots of a grid of braille characters, each 2 dots
+// across and 4 down
+type braille struct {
+	cols, rows int
+	cells      [][]rune
+}
+
+// dotBits are the bits of the braille dots, by column then row
+var dotBits = [2][4]rune{{0x01, 0x02, 0x04, 0x40}, {0x08, 0x10, 0x20, 0x80}}
+
+// newBraille returns an empty grid of characters
+func newBraille(cols int, rows int) *braille {
+	g := braille{cols: cols, rows: rows, cells: make([][]rune, rows)}
+	for i := 0; i < rows; i++ {
+		g.cells[i] = make([]rune, cols)
+	}
+	return &g
+}
+
+// set sets the dot at x across and y down, ignoring dots off the grid
+func (g *braille) set(x int, y int) {
+	if x < 0 || y < 0 || x >= 2*g.cols || y >= 4*g.rows {
+		return
+	}
+	g.cells[y/4][x/2] |= dotBits[x%2][y%4]
+}
+
+// line sets the dots on a line between two dots
+func (g *braille) line(x0, y0, x1, y1 int) {
+	steps := int(math.Max(math.Abs(float64(x1-x0)), math.Abs(float64(y1-y0))))
+	if steps == 0 {
+		g.set(x0, y0)
+		return
+	}
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		g.set(x0+int(math.Round(t*float64(x1-x0))), y0+int(math.Round(t*float64(y1-y0))))
+	}
+}
+
+// WriteText draws the chart's lines in braille characters for reading in
+// a terminal, labelled with the range of its axes and the names of its
+// lines
+func (c *Chart) WriteText(w io.Writer) error {
+	minX, maxX, minY, maxY := c.bounds()
+	g := newBraille(textWidth, textHeight)
+	span := maxX.Sub(minX).Seconds()
+	dotsX, dotsY := float64(2*textWidth-1), float64(4*textHeight-1)
+	for i := 0; i < len(c.Lines); i++ {
+		points := c.Lines[i].Points
+		prevX, prevY := 0, 0
+		for j := 0; j < len(points); j++ {
+			x := int(dotsX / 2)
+			if span > 0 {
+				x = int(math.Round(points[j].X.Sub(minX).Seconds() / span * dotsX))
+			}
+			y := int(math.Round((maxY - points[j].Y) / (maxY - minY) * dotsY))
+			if j == 0 {
+				g.set(x, y)
+			} else {
+				g.line(prevX, prevY, x, y)
+			}
+			prevX, prevY = x, y
+		}
+	}
+
+	top, bottom := label(maxY, c.Unit), label(minY, c.Unit)
+	margin := utf8.RuneCountInString(top)
+	if n := utf8.RuneCountInString(bottom); n > margin {
+		margin = n
+	}
+	var b strings.Builder
+	b.WriteString(c.Title + "\n")
+	for i := 0; i < textHeight; i++ {
+		axis := ""
+		if i == 0 {
+			axis = top
+		} else if i == textHeight-1 {
+			axis = bottom
+		}
+		fmt.Fprintf(&b, "%*s ┤", margin, axis)
+		for j := 0; j < textWidth; j++ {
+			b.WriteRune(0x2800 + g.cells[i][j])
+		}
+		b.WriteString("\n")
+	}
+	if !minX.IsZero() {
+		first, last := minX.Format("2006-01-02"), maxX.Format("2006-01-02")
+		fmt.Fprintf(&b, "%*s  %s%*s\n", margin, "", first, textWidth-len(first), last)
+	}
+	for i := 0; i < len(c.Lines); i++ {
+		b.WriteString(strings.Repeat(" ", margin+2) + c.Lines[i].Name + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteText draws the bars across the terminal, one line per bar with its
+// label and value, negative bars to the left of the zero axis. with color
+// the bars above zero are green and those below it red.
+func (c *BarChart) WriteText(w io.Writer, color bool) error {
+	most, least := 0.0, 0.0
+	labels, values := 0, 0
+	for i := 0; i < len(c.Bars); i++ {
+		most = math.Max(most, c.Bars[i].Value)
+		least = math.Min(least, c.Bars[i].Value)
+		if n := utf8.RuneCountInString(c.Bars[i].Label); n > labels {
+			labels = n
+		}
+		if n := len(label(c.Bars[i].Value, c.Unit)); n > values {
+			values = n
+		}
+	}
+	// the axis splits the width in proportion to the largest bars each way
+	negative := 0
+	if most-least > 0 {
+		negative = int(math.Round(-least / (most - least) * textWidth))
+	}
+	scale := 0.0
+	if most-least > 0 {
+		scale = textWidth / (most - least)
+	}
+
+	var b strings.Builder
+	b.WriteString(c.Title + "\n")
+	for i := 0; i < len(c.Bars); i++ {
+		bar := c.Bars[i]
+		length := int(math.Round(math.Abs(bar.Value) * scale))
+		blocks := strings.Repeat("█", length)
+		if color && bar.Value > 0 {
+			blocks = ansiGreen + blocks + ansiReset
+		} else if color && bar.Value < 0 {
+			blocks = ansiRed + blocks + ansiReset
+		}
+		left, right := strings.Repeat(" ", negative), ""
+		if bar.Value < 0 {
+			left = strings.Repeat(" ", int(math.Max(0, float64(negative-length)))) + blocks
+		} else {
+			right = blocks
+		}
+		fmt.Fprintf(&b, "%-*s %*s %s│%s\n", labels, bar.Label, values, label(bar.Value, c.Unit), left, right)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
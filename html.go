@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/stonks/chart"
+	"github.com/stonks/projection"
+	"github.com/stonks/table"
+)
+
+// htmlFile is the name of the html report written to the output directory
+const htmlFile = "report.html"
+
+// htmlReport is the data of the html report template
+type htmlReport struct {
+	Charts   []template.HTML // inline svg images
+	Sections []*table.Section
+}
+
+// htmlTemplate lays out the charts, then a heading per result with its
+// tables. clicking a column header sorts the table by it.
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Transaction analysis</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+nav a { margin-right: 1em; }
+svg { max-width: 100%; height: auto; display: block; margin: 1em 0; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em; font-size: 13px; }
+th, td { border: 1px solid #ddd; padding: 3px 8px; }
+th { background: #eee; cursor: pointer; user-select: none; }
+td.n { text-align: right; font-variant-numeric: tabular-nums; }
+td.neg { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Transaction analysis</h1>
+<nav>{{range .Sections}}{{if .Tables}}<a href="#{{.Name}}">{{.Name}}</a> {{end}}{{end}}</nav>
+{{range .Charts}}{{.}}{{end}}
+{{range .Sections}}{{if .Tables}}
+<h2 id="{{.Name}}">{{.Name}}</h2>
+{{range .Tables}}{{$table := .}}<h3>{{.Name}}</h3>
+<table class="sortable">
+<thead><tr>{{range $i, $h := .Header}}<th data-numeric="{{index $table.Numeric $i}}">{{$h}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{end}}{{end}}{{end}}
+<script>
+document.querySelectorAll("table.sortable").forEach(function (table) {
+  var headers = table.querySelectorAll("th");
+  table.querySelectorAll("tbody tr").forEach(function (row) {
+    Array.prototype.forEach.call(row.cells, function (cell, i) {
+      if (headers[i] && headers[i].dataset.numeric === "true") {
+        cell.className = parseFloat(cell.textContent) < 0 ? "n neg" : "n";
+      }
+    });
+  });
+  headers.forEach(function (th, i) {
+    var ascending = true;
+    th.addEventListener("click", function () {
+      var numeric = th.dataset.numeric === "true";
+      var body = table.tBodies[0];
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (a, b) {
+        var x = a.cells[i] ? a.cells[i].textContent : "";
+        var y = b.cells[i] ? b.cells[i].textContent : "";
+        var c = numeric ? (parseFloat(x) || 0) - (parseFloat(y) || 0) : x.localeCompare(y);
+        return ascending ? c : -c;
+      });
+      ascending = !ascending;
+      rows.forEach(function (row) { body.appendChild(row); });
+    });
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+// writeHTML writes a single html file of the results to the directory.
+// the report gets charts of its equity curve, drawdown, allocation and
+// monthly P/L above its tables.
+func writeHTML(dir string, results interface{}) error {
+	h := htmlReport{Charts: make([]template.HTML, 0), Sections: table.Sections(results)}
+	if r, ok := results.(*report); ok {
+		charts, err := reportCharts(r)
+		if err != nil {
+			return err
+		}
+		h.Charts = charts
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, htmlFile), func(w io.Writer) error {
+		return htmlTemplate.Execute(w, &h)
+	})
+}
+
+// reportCharts draws the charts of the report as inline svg images
+func reportCharts(r *report) ([]template.HTML, error) {
+	images := reportImages(r)
+	charts := make([]template.HTML, len(images))
+	for i := 0; i < len(images); i++ {
+		var b bytes.Buffer
+		if err := images[i].svg(&b); err != nil {
+			return nil, err
+		}
+		// the svg is drawn by the chart package, which escapes its text
+		charts[i] = template.HTML(b.String())
+	}
+	return charts, nil
+}
+
+// equityCurve charts the portfolio value when it was priced, and the
+// cumulative realized P/L otherwise. it returns nil when there is neither.
+func equityCurve(r *report) *chart.Chart {
+	if r.Drawdown != nil && len(r.Drawdown.Equity) > 0 {
+		line := chart.Line{Name: "Portfolio value", Points: make([]chart.Point, len(r.Drawdown.Equity))}
+		for i := 0; i < len(r.Drawdown.Equity); i++ {
+			nav, _ := r.Drawdown.Equity[i].NAV.Float64()
+			line.Points[i] = chart.Point{X: r.Drawdown.Equity[i].Date, Y: nav}
+		}
+		return &chart.Chart{Title: "Equity curve", Lines: []*chart.Line{&line}}
+	}
+	// guard clause: nothing realized to chart
+	if r.Realized == nil || len(r.Realized.Transactions) == 0 {
+		return nil
+	}
+	line := chart.Line{Name: "Cumulative realized P/L", Points: make([]chart.Point, len(r.Realized.Transactions))}
+	total := big.NewFloat(0.0)
+	for i := 0; i < len(r.Realized.Transactions); i++ {
+		g := r.Realized.Transactions[i]
+		total = total.Add(total, g.Gain)
+		value, _ := total.Float64()
+		line.Points[i] = chart.Point{X: g.Date, Y: value}
+	}
+	return &chart.Chart{Title: "Equity curve", Lines: []*chart.Line{&line}}
+}
+
+// monthlyPL charts the realized P/L of each month with closing trades
+func monthlyPL(realized *projection.RealizedPL) *chart.BarChart {
+	byMonth := make(map[string]*big.Float)
+	months := make([]string, 0)
+	for i := 0; i < len(realized.Transactions); i++ {
+		g := realized.Transactions[i]
+		month := g.Date.Format("2006-01")
+		if _, ok := byMonth[month]; !ok {
+			byMonth[month] = big.NewFloat(0.0)
+			months = append(months, month)
+		}
+		byMonth[month] = byMonth[month].Add(byMonth[month], g.Gain)
+	}
+	sort.Strings(months)
+	bars := chart.BarChart{Title: "Realized P/L by month", Bars: make([]chart.Bar, len(months))}
+	for i := 0; i < len(months); i++ {
+		value, _ := byMonth[months[i]].Float64()
+		bars.Bars[i] = chart.Bar{Label: months[i], Value: value}
+	}
+	return &bars
+}
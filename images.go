@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/stonks/chart"
+)
+
+// reportImage is a chart of the report, drawn as an svg or png image
+type reportImage struct {
+	name string // file name without the extension, eg equity_curve
+	svg  func(w io.Writer) error
+	png  func(w io.Writer) error
+}
+
+// reportImages returns the equity curve, the drawdown, the allocation by
+// symbol and the realized P/L per month of the report, leaving out those
+// without data. the drawdown needs a price history.
+func reportImages(r *report) []*reportImage {
+	images := make([]*reportImage, 0, 4)
+	if equity := equityCurve(r); equity != nil {
+		images = append(images, &reportImage{
+			name: "equity_curve",
+			svg:  func(w io.Writer) error { return chart.WriteSVG(w, equity) },
+			png:  func(w io.Writer) error { return chart.WritePNG(w, equity) },
+		})
+	}
+	if r.Drawdown != nil && len(r.Drawdown.Equity) > 0 {
+		line := chart.Line{Name: "Drawdown", Color: "#d62728", Points: make([]chart.Point, len(r.Drawdown.Equity))}
+		for i := 0; i < len(r.Drawdown.Equity); i++ {
+			dd, _ := r.Drawdown.Equity[i].Drawdown.Float64()
+			line.Points[i] = chart.Point{X: r.Drawdown.Equity[i].Date, Y: dd}
+		}
+		drawdown := &chart.Chart{Title: "Drawdown", Unit: "%", Lines: []*chart.Line{&line}}
+		images = append(images, &reportImage{
+			name: "drawdown",
+			svg:  func(w io.Writer) error { return chart.WriteSVG(w, drawdown) },
+			png:  func(w io.Writer) error { return chart.WritePNG(w, drawdown) },
+		})
+	}
+	if r.Allocation != nil && len(r.Allocation.BySymbol) > 0 {
+		pie := chart.PieChart{Title: "Allocation by symbol", Slices: make([]chart.Slice, len(r.Allocation.BySymbol))}
+		for i := 0; i < len(r.Allocation.BySymbol); i++ {
+			s := r.Allocation.BySymbol[i]
+			value, _ := s.Value.Float64()
+			pie.Slices[i] = chart.Slice{Label: s.Name, Value: value}
+		}
+		images = append(images, &reportImage{name: "allocation", svg: pie.WriteSVG, png: pie.WritePNG})
+	}
+	if r.Realized != nil && len(r.Realized.Transactions) > 0 {
+		bars := monthlyPL(r.Realized)
+		images = append(images, &reportImage{name: "monthly_pl", svg: bars.WriteSVG, png: bars.WritePNG})
+	}
+	return images
+}
+
+// writeImages writes each chart of the report to an image file named
+// after it in the directory, as an svg or png by the output format
+func writeImages(dir string, format outputFormat, results interface{}) error {
+	r, ok := results.(*report)
+	// guard clause: only the report has charts
+	if !ok {
+		return fmt.Errorf("the %s output only charts the report", format)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	images := reportImages(r)
+	for i := 0; i < len(images); i++ {
+		write := images[i].svg
+		if format == pngOutput {
+			write = images[i].png
+		}
+		if err := writeFile(filepath.Join(dir, images[i].name+"."+string(format)), write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
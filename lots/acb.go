@@ -0,0 +1,206 @@
+package lots
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/trade"
+)
+
+// Canada applies the rules of Canada: identical shares are pooled at their
+// adjusted cost base (ACB), there is no long term rate and the tax year is
+// the calendar year. losses are denied as superficial when identical shares
+// are bought within 30 days and still held 30 days after the sale.
+// dispositions are computed by MatchCanada rather than against lots.
+type Canada struct{}
+
+// Name returns CA
+func (Canada) Name() string {
+	return "CA"
+}
+
+// DefaultMethod returns AverageCost, the closest lot method to ACB pooling
+func (Canada) DefaultMethod() Method {
+	return AverageCost
+}
+
+// WashSaleWindow returns the 30 days of the superficial loss rule
+func (Canada) WashSaleWindow() int {
+	return superficialLossDays
+}
+
+// LongTerm returns false, gains are taxed the same however long shares
+// were held
+func (Canada) LongTerm(start time.Time, end time.Time) bool {
+	return false
+}
+
+// TaxYear returns the calendar year
+func (Canada) TaxYear(date time.Time) int {
+	return date.Year()
+}
+
+// Currency returns CAD, as the ACB is computed in Canadian dollars
+func (Canada) Currency() string {
+	return "CAD"
+}
+
+// superficialLossDays is how many days before and after a loss purchases
+// make it superficial
+const superficialLossDays = 30
+
+// Disposition is a sale of shares computed under the Canadian ACB rules,
+// with the amounts reported on a T5008 slip
+type Disposition struct {
+	Account  string
+	Symbol   string
+	Date     time.Time
+	Quantity *big.Float
+	Proceeds *big.Float // proceeds of disposition before commissions and fees, T5008 box 21
+	ACB      *big.Float // adjusted cost base of the shares sold, T5008 box 20
+	Outlays  *big.Float // commissions and fees paid on the sale
+	Gain     *big.Float // proceeds less ACB and outlays, before any superficial loss
+	// SuperficialLoss is the part of a loss denied because identical
+	// shares were bought within 30 days and still held afterwards, as a
+	// positive amount. it's added to the ACB of the shares still held.
+	SuperficialLoss *big.Float
+}
+
+// Holding is the pooled position in a symbol under the ACB rules
+type Holding struct {
+	Symbol   string
+	Quantity *big.Float
+	ACB      *big.Float
+	PerShare *big.Float // ACB per share
+}
+
+// CanadaResult holds the dispositions computed under the Canadian rules and
+// the holdings left afterwards
+type CanadaResult struct {
+	Dispositions []*Disposition
+	Holdings     []*Holding
+}
+
+// MatchCanada computes the disposition of every sale at the average cost of
+// the pooled shares, across every account. the cost of purchases, including
+// commissions, is added to the ACB of the pool, and sales remove the
+// average ACB of the shares sold. superficial losses are denied and added
+// to the ACB of the pool. amounts are in the base currency the transactions
+// were converted to, which should be CAD. short positions are ignored.
+func MatchCanada(trans []*trade.Trade) *CanadaResult {
+	bySymbol := make(map[string][]*trade.Trade)
+	symbols := make([]string, 0)
+	ordered := Ordered(trans)
+	for i := 0; i < len(ordered); i++ {
+		t := ordered[i]
+		// guard clause: moving shares between accounts doesn't dispose of them
+		if !affectsLots(t) || t.Type == trade.TransferIn || t.Type == trade.TransferOut {
+			continue
+		}
+		if _, ok := bySymbol[t.Symbol]; !ok {
+			symbols = append(symbols, t.Symbol)
+		}
+		bySymbol[t.Symbol] = append(bySymbol[t.Symbol], t)
+	}
+	sort.Strings(symbols)
+
+	result := CanadaResult{Dispositions: make([]*Disposition, 0), Holdings: make([]*Holding, 0)}
+	for i := 0; i < len(symbols); i++ {
+		h := matchSymbolCanada(symbols[i], bySymbol[symbols[i]], &result)
+		if h.Quantity.Sign() > 0 {
+			h.PerShare = big.NewFloat(0.0).Quo(h.ACB, h.Quantity)
+			result.Holdings = append(result.Holdings, h)
+		}
+	}
+	sort.SliceStable(result.Dispositions, func(i, j int) bool {
+		return result.Dispositions[i].Date.Before(result.Dispositions[j].Date)
+	})
+	return &result
+}
+
+// matchSymbolCanada computes the dispositions of a symbol's transactions,
+// which are in date order, and returns the holding left afterwards
+func matchSymbolCanada(symbol string, trans []*trade.Trade, result *CanadaResult) *Holding {
+	h := Holding{Symbol: symbol, Quantity: big.NewFloat(0.0), ACB: big.NewFloat(0.0), PerShare: big.NewFloat(0.0)}
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		quantity := new(big.Float).SetPrec(precision(t.Quantity)).Abs(t.Quantity)
+		if t.Quantity.Sign() > 0 {
+			h.Quantity = h.Quantity.Add(h.Quantity, quantity)
+			h.ACB = h.ACB.Add(h.ACB, big.NewFloat(0.0).Abs(t.Amount))
+			continue
+		}
+		// guard clause: nothing held to dispose of
+		if h.Quantity.Sign() <= 0 {
+			continue
+		}
+
+		quantity = minAbs(quantity, h.Quantity)
+		outlays := outlays(t)
+		d := Disposition{
+			Account:         t.Account,
+			Symbol:          symbol,
+			Date:            t.Date,
+			Quantity:        quantity,
+			ACB:             share(h.ACB, quantity, h.Quantity),
+			Outlays:         outlays,
+			SuperficialLoss: big.NewFloat(0.0),
+		}
+		net := share(big.NewFloat(0.0).Abs(t.Amount), quantity, t.Quantity)
+		d.Proceeds = big.NewFloat(0.0).Add(net, outlays)
+		d.Gain = big.NewFloat(0.0).Sub(net, d.ACB)
+
+		h.ACB = h.ACB.Sub(h.ACB, d.ACB)
+		h.Quantity = h.Quantity.Sub(h.Quantity, quantity)
+
+		if d.Gain.Sign() < 0 {
+			denied := superficialFraction(trans, i, quantity, h.Quantity)
+			d.SuperficialLoss = denied.Mul(denied, big.NewFloat(0.0).Neg(d.Gain))
+			h.ACB = h.ACB.Add(h.ACB, d.SuperficialLoss)
+		}
+		result.Dispositions = append(result.Dispositions, &d)
+	}
+	return &h
+}
+
+// outlays returns the commission and fees paid on a transaction
+func outlays(t *trade.Trade) *big.Float {
+	total := big.NewFloat(0.0)
+	if t.Commission != nil {
+		total = total.Add(total, big.NewFloat(0.0).Abs(t.Commission))
+	}
+	if t.Fees != nil {
+		total = total.Add(total, big.NewFloat(0.0).Abs(t.Fees.Total()))
+	}
+	return total
+}
+
+// superficialFraction returns the fraction of the loss on the sale at index
+// i that is superficial: the least of the quantity sold, the shares bought
+// in the 30 days before or after the sale, and the shares held 30 days
+// after it, as a fraction of the quantity sold. remaining is the quantity
+// held right after the sale.
+func superficialFraction(trans []*trade.Trade, i int, sold *big.Float, remaining *big.Float) *big.Float {
+	sale := trans[i]
+	start := sale.Date.AddDate(0, 0, -superficialLossDays)
+	end := sale.Date.AddDate(0, 0, superficialLossDays)
+
+	bought := big.NewFloat(0.0)
+	for j := 0; j < len(trans); j++ {
+		t := trans[j]
+		if j != i && t.Quantity.Sign() > 0 && !t.Date.Before(start) && !t.Date.After(end) {
+			bought = bought.Add(bought, t.Quantity)
+		}
+	}
+	held := big.NewFloat(0.0).Copy(remaining)
+	for j := i + 1; j < len(trans) && !trans[j].Date.After(end); j++ {
+		held = held.Add(held, trans[j].Quantity)
+	}
+	if held.Sign() < 0 {
+		held = big.NewFloat(0.0)
+	}
+
+	denied := minAbs(minAbs(sold, bought), held)
+	return denied.Quo(denied, sold)
+}
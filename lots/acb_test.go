@@ -0,0 +1,103 @@
+package lots
+
+import "testing"
+
+func TestMatchCanada(t *testing.T) {
+	tests := []struct {
+		name            string
+		rows            []row
+		wantACB         float64 // of the first disposition
+		wantGain        float64
+		wantSuperficial float64
+		wantHeldACB     float64 // of the shares left
+	}{
+		{
+			name: "gain at average cost",
+			rows: []row{
+				{"", "01/04/2021", "Bought 10 RY @ 100", "10", "RY", "100", "-1000"},
+				{"", "02/01/2021", "Bought 10 RY @ 200", "10", "RY", "200", "-2000"},
+				{"", "03/01/2021", "Sold 10 RY @ 180", "10", "RY", "180", "1800"},
+			},
+			wantACB:     1500,
+			wantGain:    300,
+			wantHeldACB: 1500,
+		},
+		{
+			name: "loss bought back and held",
+			rows: []row{
+				{"", "01/04/2021", "Bought 10 RY @ 100", "10", "RY", "100", "-1000"},
+				{"", "06/01/2021", "Sold 10 RY @ 80", "10", "RY", "80", "800"},
+				{"", "06/15/2021", "Bought 10 RY @ 85", "10", "RY", "85", "-850"},
+			},
+			wantACB:         1000,
+			wantGain:        -200,
+			wantSuperficial: 200,
+			wantHeldACB:     1050,
+		},
+		{
+			name: "loss partly bought back",
+			rows: []row{
+				{"", "01/04/2021", "Bought 10 RY @ 100", "10", "RY", "100", "-1000"},
+				{"", "06/01/2021", "Sold 10 RY @ 80", "10", "RY", "80", "800"},
+				{"", "06/15/2021", "Bought 5 RY @ 85", "5", "RY", "85", "-425"},
+			},
+			wantACB:         1000,
+			wantGain:        -200,
+			wantSuperficial: 100,
+			wantHeldACB:     525,
+		},
+		{
+			name: "loss bought back and sold within 30 days",
+			rows: []row{
+				{"", "01/04/2021", "Bought 10 RY @ 100", "10", "RY", "100", "-1000"},
+				{"", "06/01/2021", "Sold 10 RY @ 80", "10", "RY", "80", "800"},
+				{"", "06/10/2021", "Bought 10 RY @ 85", "10", "RY", "85", "-850"},
+				{"", "06/20/2021", "Sold 10 RY @ 90", "10", "RY", "90", "900"},
+			},
+			wantACB:  1000,
+			wantGain: -200,
+		},
+		{
+			name: "loss bought back after 30 days",
+			rows: []row{
+				{"", "01/04/2021", "Bought 10 RY @ 100", "10", "RY", "100", "-1000"},
+				{"", "06/01/2021", "Sold 10 RY @ 80", "10", "RY", "80", "800"},
+				{"", "07/15/2021", "Bought 10 RY @ 85", "10", "RY", "85", "-850"},
+			},
+			wantACB:     1000,
+			wantGain:    -200,
+			wantHeldACB: 850,
+		},
+		{
+			name: "pooled across accounts",
+			rows: []row{
+				{"TFSA", "01/04/2021", "Bought 10 RY @ 100", "10", "RY", "100", "-1000"},
+				{"", "06/01/2021", "Sold 10 RY @ 80", "10", "RY", "80", "800"},
+				{"", "07/15/2021", "Bought 10 RY @ 85", "10", "RY", "85", "-850"},
+			},
+			wantACB:     1000,
+			wantGain:    -200,
+			wantHeldACB: 850,
+		},
+	}
+	for i := 0; i < len(tests); i++ {
+		tt := tests[i]
+		result := MatchCanada(newTrades(t, tt.rows))
+		if len(result.Dispositions) == 0 {
+			t.Errorf("%s: no dispositions", tt.name)
+			continue
+		}
+		d := result.Dispositions[0]
+		if !equal(d.ACB, tt.wantACB) || !equal(d.Gain, tt.wantGain) || !equal(d.SuperficialLoss, tt.wantSuperficial) {
+			t.Errorf("%s: ACB %v, gain %v, superficial loss %v, want %v, %v, %v", tt.name,
+				d.ACB, d.Gain, d.SuperficialLoss, tt.wantACB, tt.wantGain, tt.wantSuperficial)
+		}
+		held := 0.0
+		if len(result.Holdings) > 0 {
+			held, _ = result.Holdings[0].ACB.Float64()
+		}
+		if held != tt.wantHeldACB {
+			t.Errorf("%s: ACB held = %v, want %v", tt.name, held, tt.wantHeldACB)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package lots
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/stonks/trade"
+)
+
+// Engine maintains the open tax lots of every symbol in every account as
+// transactions are applied to it. other analyses can inspect and adjust
+// the lots between transactions, eg to carry a disallowed wash sale loss
+// into a replacement lot.
+type Engine struct {
+	opts     *Options
+	open     map[string][]*Lot // open lots keyed by lotKey
+	keys     []string          // keys of open in the order they were first seen
+	realized []*RealizedGain
+	// transfers holds lots sent out of one account that haven't been
+	// received by another yet, keyed by symbol
+	transfers map[string][]*Lot
+	wash      *washSales // nil unless wash sales are being detected
+}
+
+// NewEngine returns a new lots engine with no open lots. nil options
+// match every account FIFO.
+func NewEngine(opts *Options) *Engine {
+	return &Engine{
+		opts:      opts,
+		open:      make(map[string][]*Lot),
+		keys:      make([]string, 0),
+		realized:  make([]*RealizedGain, 0),
+		transfers: make(map[string][]*Lot),
+		wash:      newWashSales(opts.washSaleRule()),
+	}
+}
+
+// Apply opens, closes or adjusts lots for a transaction and returns the
+// gains realized by it. transactions must be applied in date order.
+func (e *Engine) Apply(t *trade.Trade) []*RealizedGain {
+	symbol := strings.TrimSpace(t.Symbol)
+	key := lotKey(t.Account, symbol)
+	if e.wash != nil {
+		e.wash.found = make([]*WashSale, 0)
+	}
+	if _, ok := e.open[key]; !ok {
+		e.keys = append(e.keys, key)
+	}
+
+	if isBasisAdjustment(t) {
+		adjustBasis(e.open[key], &Adjustment{
+			Date:   t.Date,
+			Amount: big.NewFloat(0.0).Neg(t.Amount),
+			Reason: t.Description,
+		})
+		return nil
+	}
+
+	if t.Type == trade.TransferOut {
+		e.transferOut(key, symbol, t)
+		return nil
+	}
+	if t.Type == trade.TransferIn && e.transferIn(key, symbol, t) {
+		return nil
+	}
+
+	c := closingTrade{
+		t:         t,
+		remaining: big.NewFloat(0.0).Copy(t.Quantity),
+		amount:    big.NewFloat(0.0).Copy(t.Amount),
+	}
+	lots := e.open[key]
+	realized := make([]*RealizedGain, 0)
+
+	// close lots in the opposite direction, starting with any lots the
+	// transaction was designated to close, then in the order of the lot
+	// selection method
+	if len(lots) > 0 && lots[0].Quantity.Sign() != c.remaining.Sign() {
+		class := trade.Equity
+		if t.Instrument != nil {
+			class = t.Instrument.Class
+		}
+		method := e.opts.methodFor(t.Account, class)
+		if method == AverageCost {
+			averageCosts(lots)
+		}
+
+		selections := e.opts.specificLots(t.ID)
+		for j := 0; j < len(selections) && c.remaining.Sign() != 0; j++ {
+			idx := findLot(lots, selections[j].OpeningID)
+			if idx < 0 {
+				continue
+			}
+			realized = append(realized, c.closeLot(lots[idx], selections[j].Quantity))
+			lots = removeClosed(lots)
+		}
+
+		for len(lots) > 0 && c.remaining.Sign() != 0 {
+			idx := selectLot(lots, method)
+			realized = append(realized, c.closeLot(lots[idx], nil))
+			lots = removeClosed(lots)
+		}
+	}
+
+	// whatever wasn't closed opens a new lot
+	if c.remaining.Sign() != 0 {
+		lots = append(lots, &Lot{
+			Account:      t.Account,
+			Symbol:       symbol,
+			OpenDate:     t.Date,
+			HoldingStart: t.Date,
+			OpeningID:    t.ID,
+			Quantity:     c.remaining,
+			Cost:         big.NewFloat(0.0).Neg(c.amount),
+			Adjustments:  make([]*Adjustment, 0),
+			Opening:      t,
+		})
+	}
+	e.open[key] = lots
+	for i := 0; i < len(realized); i++ {
+		realized[i].rules = e.opts.jurisdiction()
+	}
+	e.realized = append(e.realized, realized...)
+	if e.wash != nil {
+		e.wash.afterApply(e, t, realized)
+	}
+	return realized
+}
+
+// transferOut removes the quantity sent from the oldest lots of the
+// account and holds them until they're received by another account.
+func (e *Engine) transferOut(key string, symbol string, t *trade.Trade) {
+	remaining := new(big.Float).SetPrec(precision(t.Quantity)).Abs(t.Quantity)
+	lots := e.open[key]
+	for len(lots) > 0 && remaining.Sign() > 0 {
+		lot := lots[0]
+		sent := minAbs(lot.Quantity, remaining)
+
+		moved := lot.copy()
+		moved.Quantity = big.NewFloat(0.0).Copy(sent)
+		moved.Cost = share(lot.Cost, sent, lot.Quantity)
+		e.transfers[symbol] = append(e.transfers[symbol], moved)
+
+		lot.Cost = lot.Cost.Sub(lot.Cost, moved.Cost)
+		lot.Quantity = lot.Quantity.Sub(lot.Quantity, sent)
+		remaining = remaining.Sub(remaining, sent)
+		lots = removeClosed(lots)
+	}
+	e.open[key] = lots
+}
+
+// transferIn adds lots previously sent from another account to the
+// receiving account, keeping their open date and cost basis. returns false
+// if there are no lots in transit, in which case the receipt opens a new
+// lot like a purchase.
+func (e *Engine) transferIn(key string, symbol string, t *trade.Trade) bool {
+	pending := e.transfers[symbol]
+	if len(pending) == 0 {
+		return false
+	}
+
+	remaining := new(big.Float).SetPrec(precision(t.Quantity)).Abs(t.Quantity)
+	lots := e.open[key]
+	for len(pending) > 0 && remaining.Sign() > 0 {
+		lot := pending[0]
+		received := minAbs(lot.Quantity, remaining)
+
+		moved := lot.copy()
+		moved.Account = t.Account
+		moved.Quantity = big.NewFloat(0.0).Copy(received)
+		moved.Cost = share(lot.Cost, received, lot.Quantity)
+		lots = append(lots, moved)
+
+		lot.Cost = lot.Cost.Sub(lot.Cost, moved.Cost)
+		lot.Quantity = lot.Quantity.Sub(lot.Quantity, received)
+		remaining = remaining.Sub(remaining, received)
+		pending = removeClosed(pending)
+	}
+	e.transfers[symbol] = pending
+	e.open[key] = lots
+	return true
+}
+
+// OpenLots returns the lots currently open, grouped by account and symbol
+func (e *Engine) OpenLots() []*Lot {
+	results := make([]*Lot, 0)
+	for i := 0; i < len(e.keys); i++ {
+		results = append(results, e.open[e.keys[i]]...)
+	}
+	return results
+}
+
+// LotsFor returns the lots of a symbol currently open in an account
+func (e *Engine) LotsFor(account string, symbol string) []*Lot {
+	return e.open[lotKey(account, strings.TrimSpace(symbol))]
+}
+
+// Realized returns every gain realized so far
+func (e *Engine) Realized() []*RealizedGain {
+	return e.realized
+}
+
+// Snapshot returns a copy of the lots currently open, which won't change
+// as more transactions are applied
+func (e *Engine) Snapshot() []*Lot {
+	open := e.OpenLots()
+	results := make([]*Lot, len(open))
+	for i := 0; i < len(open); i++ {
+		results[i] = open[i].copy()
+	}
+	return results
+}
+
+// WashSales returns the wash sales found by the last transaction applied,
+// either losses it realized replaced by lots already open or losses
+// realized earlier replaced by the lot it opened. it is empty unless wash
+// sales are being detected.
+func (e *Engine) WashSales() []*WashSale {
+	if e.wash == nil {
+		return nil
+	}
+	return e.wash.found
+}
+
+// Adjust changes the cost basis of an open lot and records why
+func (e *Engine) Adjust(lot *Lot, adj *Adjustment) {
+	lot.Cost = lot.Cost.Add(lot.Cost, adj.Amount)
+	lot.Adjustments = append(lot.Adjustments, adj)
+}
+
+// split keeps quantity of a long lot open in the lot and moves the rest,
+// with its share of the cost and adjustments, to a new lot opened alongside
+// it, which is returned. the new lot is nil if there is no rest.
+func (e *Engine) split(lot *Lot, quantity *big.Float) *Lot {
+	rest := lot.copy()
+	rest.Quantity = rest.Quantity.Sub(rest.Quantity, quantity)
+	// guard clause: nothing left over to split off
+	if rest.Quantity.Sign() <= 0 || isDust(rest.Quantity) {
+		return nil
+	}
+	rest.Cost = share(lot.Cost, rest.Quantity, lot.Quantity)
+	for i := 0; i < len(lot.Adjustments); i++ {
+		adj := *lot.Adjustments[i]
+		adj.Amount = share(adj.Amount, rest.Quantity, lot.Quantity)
+		rest.Adjustments[i] = &adj
+		kept := *lot.Adjustments[i]
+		kept.Amount = big.NewFloat(0.0).Sub(kept.Amount, adj.Amount)
+		lot.Adjustments[i] = &kept
+	}
+	lot.Cost = lot.Cost.Sub(lot.Cost, rest.Cost)
+	lot.Quantity = lot.Quantity.Sub(lot.Quantity, rest.Quantity)
+
+	key := lotKey(lot.Account, lot.Symbol)
+	lots := e.open[key]
+	for i := 0; i < len(lots); i++ {
+		if lots[i] == lot {
+			lots = append(lots[:i+1], append([]*Lot{rest}, lots[i+1:]...)...)
+			break
+		}
+	}
+	e.open[key] = lots
+	return rest
+}
+
+// adjustBasis spreads an adjustment across the cost of open lots in
+// proportion to their quantity
+func adjustBasis(lots []*Lot, adj *Adjustment) {
+	total := big.NewFloat(0.0)
+	for i := 0; i < len(lots); i++ {
+		total = total.Add(total, big.NewFloat(0.0).Abs(lots[i].Quantity))
+	}
+	for i := 0; i < len(lots); i++ {
+		lot := lots[i]
+		lotAdj := Adjustment{
+			Date:   adj.Date,
+			Amount: share(adj.Amount, big.NewFloat(0.0).Abs(lot.Quantity), total),
+			Reason: adj.Reason,
+		}
+		lot.Cost = lot.Cost.Add(lot.Cost, lotAdj.Amount)
+		lot.Adjustments = append(lot.Adjustments, &lotAdj)
+	}
+}
+
+// copy returns a copy of the lot that doesn't share any amounts with it
+func (l *Lot) copy() *Lot {
+	c := *l
+	c.Quantity = big.NewFloat(0.0).Copy(l.Quantity)
+	c.Cost = big.NewFloat(0.0).Copy(l.Cost)
+	c.Adjustments = make([]*Adjustment, len(l.Adjustments))
+	copy(c.Adjustments, l.Adjustments)
+	return &c
+}
@@ -0,0 +1,86 @@
+package lots
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Jurisdiction holds the tax rules that differ between countries, so that
+// gains can be computed under rules other than the US without changing
+// how lots are matched
+type Jurisdiction interface {
+	// Name returns the code the jurisdiction is configured by, eg US
+	Name() string
+	// DefaultMethod returns the lot selection method used when none is
+	// configured
+	DefaultMethod() Method
+	// WashSaleWindow returns the number of days before and after a loss
+	// that a purchase counts as a replacement, zero if losses are never
+	// disallowed by replacement purchases
+	WashSaleWindow() int
+	// LongTerm returns true if shares held from start until end qualify
+	// for long term treatment
+	LongTerm(start time.Time, end time.Time) bool
+	// TaxYear returns the tax year a date falls in, named by the calendar
+	// year it ends in
+	TaxYear(date time.Time) int
+	// Currency returns the currency gains are computed in
+	Currency() string
+}
+
+// US applies the rules of the United States: FIFO unless lots are
+// identified, a 30 day wash sale window and long term treatment for shares
+// held more than a year
+type US struct{}
+
+// Name returns US
+func (US) Name() string {
+	return "US"
+}
+
+// DefaultMethod returns FIFO, the IRS default when lots aren't identified
+func (US) DefaultMethod() Method {
+	return FIFO
+}
+
+// WashSaleWindow returns 30 days
+func (US) WashSaleWindow() int {
+	return 30
+}
+
+// LongTerm returns true if the shares were sold after the anniversary of
+// the day they were acquired
+func (US) LongTerm(start time.Time, end time.Time) bool {
+	return end.After(start.AddDate(1, 0, 0))
+}
+
+// TaxYear returns the calendar year
+func (US) TaxYear(date time.Time) int {
+	return date.Year()
+}
+
+// Currency returns USD
+func (US) Currency() string {
+	return "USD"
+}
+
+// jurisdictions holds the supported jurisdictions keyed by name
+var jurisdictions = map[string]Jurisdiction{
+	"US": US{},
+	"UK": UK{},
+	"CA": Canada{},
+}
+
+// LookupJurisdiction returns the jurisdiction with the given name. blank
+// names return the US.
+func LookupJurisdiction(name string) (Jurisdiction, error) {
+	if strings.TrimSpace(name) == "" {
+		return US{}, nil
+	}
+	j, ok := jurisdictions[strings.ToUpper(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported tax jurisdiction %q", name)
+	}
+	return j, nil
+}
@@ -0,0 +1,305 @@
+package lots
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/trade"
+)
+
+// Lot is an open tax lot: a quantity of a symbol opened by a single
+// transaction, along with whatever cost basis remains with it.
+type Lot struct {
+	Account  string
+	Symbol   string
+	OpenDate time.Time
+	// HoldingStart is when the holding period of the lot began, which is
+	// earlier than OpenDate when a wash sale tacked on the holding period
+	// of the shares it replaced
+	HoldingStart time.Time
+	OpeningID    string        // id of the transaction that opened the lot
+	Quantity     *big.Float    // remaining open quantity, negative for short lots
+	Cost         *big.Float    // cash paid to open the remaining quantity, negative for short lots which received cash
+	Adjustments  []*Adjustment // changes made to the cost since the lot was opened
+	Opening      *trade.Trade  `json:"-"` // transaction that opened the lot
+}
+
+// Adjustment is a change to the cost basis of a lot after it was opened
+type Adjustment struct {
+	Date   time.Time
+	Amount *big.Float // change in cost, positive amounts increase the cost basis
+	Reason string
+	// Loss is the loss disallowed by a wash sale and added to the lot, nil
+	// for other adjustments
+	Loss *RealizedGain `json:"-"`
+}
+
+// RealizedGain is the gain or loss from closing all or part of a lot
+type RealizedGain struct {
+	Account   string
+	Symbol    string
+	Quantity  *big.Float // quantity closed, always positive
+	Short     bool       // true if a short lot was closed
+	OpenDate  time.Time
+	CloseDate time.Time
+	// HoldingStart is when the holding period of the closed lot began
+	HoldingStart time.Time
+	Proceeds     *big.Float // cash received, from the sale for long lots or the short sale for short lots
+	Basis        *big.Float // cash paid, to buy a long lot or to cover a short lot
+	Gain         *big.Float // proceeds less basis
+	// Disallowed is the part of a loss disallowed by the wash sale rule,
+	// as a positive amount. it has been added to the basis of the
+	// replacement lots.
+	Disallowed *big.Float
+	// PermanentlyDisallowed is the part of Disallowed replaced by purchases
+	// in retirement accounts, which can never be recovered
+	PermanentlyDisallowed *big.Float
+	Lot                   *Lot         `json:"-"` // lot closed, as it was before closing
+	Closing               *trade.Trade `json:"-"` // transaction that closed the lot
+
+	rules Jurisdiction // tax rules the gain was realized under, nil for the US
+}
+
+// LongTerm returns true if the closed lot was held long enough for long
+// term treatment under the jurisdiction's rules, counted from the start of
+// its holding period. short sales are always short term.
+func (g *RealizedGain) LongTerm() bool {
+	return !g.Short && g.jurisdiction().LongTerm(g.HoldingStart, g.CloseDate)
+}
+
+// TaxYear returns the tax year the gain was realized in
+func (g *RealizedGain) TaxYear() int {
+	return g.jurisdiction().TaxYear(g.CloseDate)
+}
+
+// jurisdiction returns the tax rules the gain was realized under
+func (g *RealizedGain) jurisdiction() Jurisdiction {
+	if g.rules == nil {
+		return US{}
+	}
+	return g.rules
+}
+
+// Result holds the lots left open and the gains realized after matching
+// every transaction
+type Result struct {
+	Open     []*Lot
+	Realized []*RealizedGain
+}
+
+// lotKey identifies the lots that can be matched against each other,
+// which are lots of the same symbol in the same account
+func lotKey(account string, symbol string) string {
+	return account + "|" + symbol
+}
+
+// affectsLots returns true if the transaction opens or closes lots
+func affectsLots(t *trade.Trade) bool {
+	switch t.Type {
+	case trade.Buy, trade.Sell, trade.Maturity, trade.Expiration, trade.Assignment, trade.Exercise,
+		trade.StockDividend, trade.SpinOff, trade.TransferIn, trade.TransferOut:
+		return t.Quantity != nil && t.Quantity.Sign() != 0
+	}
+	return false
+}
+
+// isBasisAdjustment returns true if the transaction changes the cost basis
+// of open lots without changing their quantity, eg the basis a parent
+// company gives up in a spin-off
+func isBasisAdjustment(t *trade.Trade) bool {
+	return t.Type == trade.SpinOff && t.Quantity != nil && t.Quantity.Sign() == 0
+}
+
+// Affects returns true if the transaction opens, closes or adjusts lots and
+// needs to be applied to an Engine
+func Affects(t *trade.Trade) bool {
+	return affectsLots(t) || isBasisAdjustment(t)
+}
+
+// Ordered returns the transactions that open, close or adjust lots, in the
+// date order they need to be applied to an Engine
+func Ordered(trans []*trade.Trade) []*trade.Trade {
+	ordered := make([]*trade.Trade, 0, len(trans))
+	for i := 0; i < len(trans); i++ {
+		if Affects(trans[i]) {
+			ordered = append(ordered, trans[i])
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Date.Before(ordered[j].Date)
+	})
+	return ordered
+}
+
+// Match replays the transactions in date order, opening lots on purchases
+// (or short sales) and closing them on sales (or buys to cover) using the
+// lot selection method of each account. nil options match every account
+// FIFO.
+//
+// a transaction that closes more than the open quantity opens a new lot in
+// the opposite direction with the remainder, so a sale larger than the long
+// position leaves a short lot behind.
+func Match(trans []*trade.Trade, opts *Options) *Result {
+	e := NewEngine(opts)
+	ordered := Ordered(trans)
+	for i := 0; i < len(ordered); i++ {
+		e.Apply(ordered[i])
+	}
+	return &Result{
+		Open:     e.OpenLots(),
+		Realized: e.Realized(),
+	}
+}
+
+// OpenAsOf returns the lots that were open at the end of the given date
+func OpenAsOf(trans []*trade.Trade, opts *Options, asOf time.Time) []*Lot {
+	e := NewEngine(opts)
+	ordered := Ordered(trans)
+	end := EndOfDate(asOf)
+	for i := 0; i < len(ordered) && ordered[i].Date.Before(end); i++ {
+		e.Apply(ordered[i])
+	}
+	return e.OpenLots()
+}
+
+// EndOfDate returns the start of the day after a date, which the
+// transactions made at any time on the date are before
+func EndOfDate(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()).AddDate(0, 0, 1)
+}
+
+// closingTrade tracks the part of a closing transaction that hasn't been
+// matched against open lots yet
+type closingTrade struct {
+	t         *trade.Trade
+	remaining *big.Float // quantity left to match, with the same sign as the transaction
+	amount    *big.Float // cash of the transaction attributable to the remaining quantity
+}
+
+// closeLot closes as much of the lot as the remaining quantity allows, up to
+// limit if it isn't nil, and returns the gain realized.
+func (c *closingTrade) closeLot(lot *Lot, limit *big.Float) *RealizedGain {
+	closed := minAbs(lot.Quantity, c.remaining)
+	if limit != nil {
+		closed = minAbs(closed, limit)
+	}
+
+	// portion of the transaction's cash and the lot's cost for the closed quantity
+	tradeShare := share(c.amount, closed, c.remaining)
+	lotShare := share(lot.Cost, closed, lot.Quantity)
+
+	g := newRealizedGain(lot, c.t, closed, tradeShare, lotShare)
+	c.amount = c.amount.Sub(c.amount, tradeShare)
+	lot.Cost = lot.Cost.Sub(lot.Cost, lotShare)
+	if lot.Quantity.Sign() > 0 {
+		lot.Quantity = lot.Quantity.Sub(lot.Quantity, closed)
+		c.remaining = c.remaining.Add(c.remaining, closed)
+	} else {
+		lot.Quantity = lot.Quantity.Add(lot.Quantity, closed)
+		c.remaining = c.remaining.Sub(c.remaining, closed)
+	}
+	// rounding error left behind is closed out rather than kept as a lot
+	if isDust(lot.Quantity) {
+		lot.Quantity.SetInt64(0)
+	}
+	if isDust(c.remaining) {
+		c.remaining.SetInt64(0)
+	}
+	return g
+}
+
+// removeClosed returns the lots that still have an open quantity
+func removeClosed(lots []*Lot) []*Lot {
+	results := lots[:0]
+	for i := 0; i < len(lots); i++ {
+		if lots[i].Quantity.Sign() != 0 && !isDust(lots[i].Quantity) {
+			results = append(results, lots[i])
+		}
+	}
+	return results
+}
+
+// findLot returns the index of the lot opened by the transaction with the
+// given id, or -1 if it isn't open
+func findLot(lots []*Lot, openingID string) int {
+	for i := 0; i < len(lots); i++ {
+		if lots[i].Opening != nil && lots[i].Opening.ID == openingID {
+			return i
+		}
+	}
+	return -1
+}
+
+// newRealizedGain builds the realized gain of closing a quantity of a lot.
+// tradeCash is the cash of the closing transaction and lotCost the cost of
+// the lot attributed to the quantity closed.
+func newRealizedGain(lot *Lot, closing *trade.Trade, quantity *big.Float, tradeCash *big.Float, lotCost *big.Float) *RealizedGain {
+	g := RealizedGain{
+		Lot:          lot.copy(),
+		Account:      lot.Account,
+		Symbol:       lot.Symbol,
+		Quantity:     big.NewFloat(0.0).Copy(quantity),
+		Short:        lot.Quantity.Sign() < 0,
+		OpenDate:     lot.OpenDate,
+		HoldingStart: lot.HoldingStart,
+		Disallowed:   big.NewFloat(0.0),
+		CloseDate:    closing.Date,
+		Closing:      closing,
+	}
+	g.PermanentlyDisallowed = big.NewFloat(0.0)
+	if g.Short {
+		// shorts receive cash when opened and pay cash when covered
+		g.Proceeds = big.NewFloat(0.0).Neg(lotCost)
+		g.Basis = big.NewFloat(0.0).Neg(tradeCash)
+	} else {
+		g.Proceeds = big.NewFloat(0.0).Copy(tradeCash)
+		g.Basis = big.NewFloat(0.0).Copy(lotCost)
+	}
+	g.Gain = big.NewFloat(0.0).Sub(g.Proceeds, g.Basis)
+	return &g
+}
+
+// dustQuantity is the largest quantity left open after closing a lot that
+// is rounding error rather than a position, and is closed out
+var dustQuantity = big.NewFloat(1e-12)
+
+// isDust returns true if a quantity is too small to be an open position
+func isDust(q *big.Float) bool {
+	return q.Sign() != 0 && new(big.Float).Abs(q).Cmp(dustQuantity) < 0
+}
+
+// precision returns the largest precision of the values, at least the 53
+// bits of a float64, so the quantities of crypto parsed with more bits
+// aren't rounded by the arithmetic on them
+func precision(values ...*big.Float) uint {
+	prec := uint(53)
+	for i := 0; i < len(values); i++ {
+		if values[i].Prec() > prec {
+			prec = values[i].Prec()
+		}
+	}
+	return prec
+}
+
+// minAbs returns the smaller of the absolute values of a and b
+func minAbs(a *big.Float, b *big.Float) *big.Float {
+	prec := precision(a, b)
+	absA := new(big.Float).SetPrec(prec).Abs(a)
+	absB := new(big.Float).SetPrec(prec).Abs(b)
+	if absA.Cmp(absB) < 0 {
+		return absA
+	}
+	return absB
+}
+
+// share returns the portion of total attributable to part out of whole,
+// eg the cost of 30 shares out of a lot of 100
+func share(total *big.Float, part *big.Float, whole *big.Float) *big.Float {
+	if whole.Sign() == 0 {
+		return big.NewFloat(0.0)
+	}
+	prec := precision(total, part, whole)
+	s := new(big.Float).SetPrec(prec).Quo(part, new(big.Float).SetPrec(prec).Abs(whole))
+	return s.Mul(s, total)
+}
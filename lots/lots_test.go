@@ -0,0 +1,121 @@
+package lots
+
+import (
+	"math/big"
+	"strconv"
+	"testing"
+
+	"github.com/stonks/trade"
+)
+
+// row is a transaction written the way it appears in a TDA transaction
+// log, along with the account it was made in
+type row struct {
+	account, date, description, quantity, symbol, price, amount string
+}
+
+// newTrades parses the rows as TDA transactions, numbering their ids from 1
+func newTrades(t *testing.T, rows []row) []*trade.Trade {
+	trans := make([]*trade.Trade, 0, len(rows))
+	for i := 0; i < len(rows); i++ {
+		r := rows[i]
+		id := strconv.Itoa(i + 1)
+		tr, err := trade.NewTradeTDA([]string{r.date, id, r.description, r.quantity, r.symbol, r.price, "0", r.amount})
+		if err != nil {
+			t.Fatalf("row %d: %v", i+1, err)
+		}
+		tr.Account = r.account
+		if tr.Account == "" {
+			tr.Account = "taxable"
+		}
+		trans = append(trans, tr)
+	}
+	return trans
+}
+
+// equal returns true if the number is within rounding of want
+func equal(got *big.Float, want float64) bool {
+	if got == nil {
+		return false
+	}
+	diff := new(big.Float).Sub(got, big.NewFloat(want))
+	return diff.Abs(diff).Cmp(big.NewFloat(1e-6)) < 0
+}
+
+// buysThenSale buys 10 shares three times at different costs, then sells 10
+var buysThenSale = []row{
+	{"", "01/04/2021", "Bought 10 AAPL @ 100", "10", "AAPL", "100", "-1000"},
+	{"", "02/01/2021", "Bought 10 AAPL @ 150", "10", "AAPL", "150", "-1500"},
+	{"", "03/01/2021", "Bought 10 AAPL @ 110", "10", "AAPL", "110", "-1100"},
+	{"", "04/01/2021", "Sold 10 AAPL @ 130", "10", "AAPL", "130", "1300"},
+}
+
+func TestMatchMethod(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      *Options
+		wantBasis float64
+	}{
+		{"nil options", nil, 1000},
+		{"FIFO", &Options{Method: FIFO}, 1000},
+		{"LIFO", &Options{Method: LIFO}, 1100},
+		{"HIFO", &Options{Method: HIFO}, 1500},
+		{"AVERAGE", &Options{Method: AverageCost}, 1200},
+		{"US default", &Options{Jurisdiction: US{}}, 1000},
+		{"UK default", &Options{Jurisdiction: UK{}}, 1200},
+		{"Canada default", &Options{Jurisdiction: Canada{}}, 1200},
+		{"account method", &Options{Method: FIFO, Accounts: map[string]Method{"taxable": HIFO}}, 1500},
+		{"asset class method", &Options{Method: FIFO, AssetClasses: map[trade.AssetClass]Method{trade.Equity: LIFO}}, 1100},
+		{"account over asset class", &Options{
+			Accounts:     map[string]Method{"taxable": FIFO},
+			AssetClasses: map[trade.AssetClass]Method{trade.Equity: LIFO},
+		}, 1000},
+		{"specific lots", &Options{Method: FIFO, SpecificLots: map[string][]*LotSelection{
+			"4": {{OpeningID: "2", Quantity: big.NewFloat(10)}},
+		}}, 1500},
+	}
+	for i := 0; i < len(tests); i++ {
+		tt := tests[i]
+		result := Match(newTrades(t, buysThenSale), tt.opts)
+		if len(result.Realized) != 1 {
+			t.Errorf("%s: realized %d gains, want 1", tt.name, len(result.Realized))
+			continue
+		}
+		if got := result.Realized[0].Basis; !equal(got, tt.wantBasis) {
+			t.Errorf("%s: basis = %v, want %v", tt.name, got, tt.wantBasis)
+		}
+		open := big.NewFloat(0.0)
+		for j := 0; j < len(result.Open); j++ {
+			open = open.Add(open, result.Open[j].Quantity)
+		}
+		if !equal(open, 20) {
+			t.Errorf("%s: open quantity = %v, want 20", tt.name, open)
+		}
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Method
+		wantErr bool
+	}{
+		{"", "", false},
+		{"FIFO", FIFO, false},
+		{" hifo ", HIFO, false},
+		{"average", AverageCost, false},
+		{"FIFOO", "", true},
+		{"specific", "", true},
+	}
+	for i := 0; i < len(tests); i++ {
+		tt := tests[i]
+		got, err := ParseMethod(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMethod(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMethod(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package lots
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/stonks/trade"
+)
+
+// Method is the lot selection method used to decide which open lots a
+// closing transaction is matched against
+type Method string
+
+const (
+	FIFO        Method = "FIFO"    // first in first out, the oldest lot closes first
+	LIFO        Method = "LIFO"    // last in first out, the newest lot closes first
+	AverageCost Method = "AVERAGE" // every lot carries the average cost, oldest lot closes first
+	HIFO        Method = "HIFO"    // highest in first out, the lot with the highest cost per unit closes first
+)
+
+// methods are the supported lot selection methods
+var methods = map[Method]bool{
+	FIFO:        true,
+	LIFO:        true,
+	AverageCost: true,
+	HIFO:        true,
+}
+
+// ParseMethod returns the lot selection method with the given name. blank
+// names return a blank method, which leaves the method to the
+// jurisdiction.
+func ParseMethod(name string) (Method, error) {
+	m := Method(strings.ToUpper(strings.TrimSpace(name)))
+	if m == "" || methods[m] {
+		return m, nil
+	}
+	return "", fmt.Errorf("unsupported cost basis method %q", name)
+}
+
+// Options controls how transactions are matched against open lots
+type Options struct {
+	Method   Method            // lot selection method for accounts without one of their own, defaults to the jurisdiction's
+	Accounts map[string]Method // lot selection method per account name
+	// AssetClasses holds the lot selection method per asset class for
+	// accounts without a method of their own, eg HIFO for crypto
+	AssetClasses map[trade.AssetClass]Method
+	// WashSale enables wash sale detection when set
+	WashSale *WashSaleRule
+	// Jurisdiction supplies the tax rules gains are computed under,
+	// defaults to the US
+	Jurisdiction Jurisdiction
+	// SpecificLots designates the lots closed by particular closing
+	// transactions, keyed by the id of the closing transaction
+	SpecificLots map[string][]*LotSelection
+	// RetirementAccounts are tax advantaged accounts such as IRAs, whose
+	// gains, dividends and interest aren't taxed
+	RetirementAccounts map[string]bool
+}
+
+// Taxable returns true if the gains and income of an account are taxed,
+// false for retirement accounts
+func (o *Options) Taxable(account string) bool {
+	return o == nil || !o.RetirementAccounts[account]
+}
+
+// LotSelection designates a quantity of the lot opened by a transaction
+// to be closed, as reported on a broker's trade confirmation
+type LotSelection struct {
+	OpeningID string     // id of the transaction that opened the lot
+	Quantity  *big.Float // quantity of the lot to close
+}
+
+// methodFor returns the lot selection method used for an asset class in an
+// account. a method set for the account takes precedence over one set for
+// the asset class.
+func (o *Options) methodFor(account string, class trade.AssetClass) Method {
+	if o == nil {
+		return o.jurisdiction().DefaultMethod()
+	}
+	if m, ok := o.Accounts[account]; ok && m != "" {
+		return m
+	}
+	if m, ok := o.AssetClasses[class]; ok && m != "" {
+		return m
+	}
+	if o.Method != "" {
+		return o.Method
+	}
+	return o.jurisdiction().DefaultMethod()
+}
+
+// jurisdiction returns the tax rules gains are computed under
+func (o *Options) jurisdiction() Jurisdiction {
+	if o == nil || o.Jurisdiction == nil {
+		return US{}
+	}
+	return o.Jurisdiction
+}
+
+// washSaleRule returns the wash sale rule, or nil if wash sales aren't
+// being detected. rules without a window use the jurisdiction's.
+func (o *Options) washSaleRule() *WashSaleRule {
+	if o == nil || o.WashSale == nil {
+		return nil
+	}
+	rule := *o.WashSale
+	rule.retirementAccounts = o.RetirementAccounts
+	if rule.WindowDays == 0 {
+		rule.WindowDays = o.jurisdiction().WashSaleWindow()
+	}
+	return &rule
+}
+
+// specificLots returns the lots designated to be closed by a transaction
+func (o *Options) specificLots(closingID string) []*LotSelection {
+	if o == nil || closingID == "" {
+		return nil
+	}
+	return o.SpecificLots[closingID]
+}
+
+// LoadSpecificLots reads specific lot designations from a csv file where
+// each row is the id of a closing transaction, the id of the transaction
+// that opened the lot it closes and the quantity of that lot closed. a
+// closing transaction that closes several lots has a row for each.
+func LoadSpecificLots(path string) (map[string][]*LotSelection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	results := make(map[string][]*LotSelection)
+	r := csv.NewReader(f)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		quantity, _, err := big.ParseFloat(strings.TrimSpace(record[2]), 10, 53, big.ToNearestEven)
+		if err != nil {
+			// skip the header row, if any
+			continue
+		}
+		closingID := strings.TrimSpace(record[0])
+		results[closingID] = append(results[closingID], &LotSelection{
+			OpeningID: strings.TrimSpace(record[1]),
+			Quantity:  quantity.Abs(quantity),
+		})
+	}
+	return results, nil
+}
+
+// selectLot returns the index of the open lot to close next
+func selectLot(lots []*Lot, method Method) int {
+	switch method {
+	case LIFO:
+		return len(lots) - 1
+	case HIFO:
+		return highestCostLot(lots)
+	}
+	return 0
+}
+
+// highestCostLot returns the index of the long lot with the highest cost
+// per unit, the oldest one winning ties. short lots have no cost to rank
+// by, so the oldest short lot is returned.
+func highestCostLot(lots []*Lot) int {
+	best := 0
+	var bestCost *big.Float
+	for i := 0; i < len(lots); i++ {
+		lot := lots[i]
+		if lot.Quantity.Sign() <= 0 {
+			return 0
+		}
+		unitCost := big.NewFloat(0.0).Quo(lot.Cost, lot.Quantity)
+		if bestCost == nil || unitCost.Cmp(bestCost) > 0 {
+			best = i
+			bestCost = unitCost
+		}
+	}
+	return best
+}
+
+// averageCosts restates the cost of every open lot at the average cost
+// per unit across all of them. the holding period of each lot is kept,
+// which is how brokers report average cost for mutual funds.
+func averageCosts(lots []*Lot) {
+	if len(lots) < 2 {
+		return
+	}
+	quantity := big.NewFloat(0.0)
+	cost := big.NewFloat(0.0)
+	for i := 0; i < len(lots); i++ {
+		quantity = quantity.Add(quantity, lots[i].Quantity)
+		cost = cost.Add(cost, lots[i].Cost)
+	}
+	if quantity.Sign() == 0 {
+		return
+	}
+	for i := 0; i < len(lots); i++ {
+		lot := lots[i]
+		lot.Cost = share(cost, big.NewFloat(0.0).Abs(lot.Quantity), quantity)
+	}
+}
@@ -0,0 +1,276 @@
+package lots
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/trade"
+)
+
+// UK applies the rules of the United Kingdom: shares of a company are
+// pooled at their average cost, there is no long term rate, and the tax
+// year runs from April 6 to April 5. disposals are matched by MatchUK
+// rather than against individual lots.
+type UK struct{}
+
+// Name returns UK
+func (UK) Name() string {
+	return "UK"
+}
+
+// DefaultMethod returns AverageCost, the closest lot method to a Section
+// 104 pool
+func (UK) DefaultMethod() Method {
+	return AverageCost
+}
+
+// WashSaleWindow returns zero. the bed and breakfast rule changes which
+// shares a disposal is matched against rather than disallowing the loss.
+func (UK) WashSaleWindow() int {
+	return 0
+}
+
+// LongTerm returns false, gains are taxed the same however long shares
+// were held
+func (UK) LongTerm(start time.Time, end time.Time) bool {
+	return false
+}
+
+// TaxYear returns the calendar year the tax year ends in, so 6 April 2023
+// to 5 April 2024 is 2024
+func (UK) TaxYear(date time.Time) int {
+	if date.Month() > time.April || (date.Month() == time.April && date.Day() >= 6) {
+		return date.Year() + 1
+	}
+	return date.Year()
+}
+
+// Currency returns GBP, as gains are computed in pounds sterling
+func (UK) Currency() string {
+	return "GBP"
+}
+
+// UKMatchRule identifies which rule matched a disposal to acquisitions
+type UKMatchRule string
+
+const (
+	SameDay         UKMatchRule = "SAME_DAY"          // acquisitions on the day of the disposal
+	BedAndBreakfast UKMatchRule = "BED_AND_BREAKFAST" // acquisitions in the 30 days after the disposal
+	Section104      UKMatchRule = "SECTION_104"       // the pool of every other acquisition, at average cost
+	// Unmatched is the part of a disposal no acquisition was found for, eg
+	// a short sale or shares bought before the transaction history starts.
+	// it is reported at no cost so it can't pass unnoticed.
+	Unmatched UKMatchRule = "UNMATCHED"
+)
+
+// bedAndBreakfastDays is how many days after a disposal acquisitions are
+// matched against it
+const bedAndBreakfastDays = 30
+
+// Disposal is the part of a sale matched by one of the UK share matching
+// rules
+type Disposal struct {
+	Account  string
+	Symbol   string
+	Date     time.Time
+	Rule     UKMatchRule
+	Acquired time.Time // date of the matched acquisition, zero for the Section 104 pool
+	Quantity *big.Float
+	Proceeds *big.Float
+	Cost     *big.Float
+	Gain     *big.Float
+	TaxYear  int // UK tax year, named by the calendar year it ends in
+}
+
+// Pool is the Section 104 holding of a symbol
+type Pool struct {
+	Symbol   string
+	Quantity *big.Float
+	Cost     *big.Float // allowable cost of the shares in the pool
+}
+
+// UKResult holds the disposals matched under the UK rules and the pools
+// left afterwards
+type UKResult struct {
+	Disposals []*Disposal
+	Pools     []*Pool
+}
+
+// ukDay combines every acquisition or every disposal of a symbol on a day,
+// which the UK rules treat as a single transaction
+type ukDay struct {
+	account   string
+	date      time.Time
+	quantity  *big.Float // always positive
+	amount    *big.Float // cost of acquisitions or proceeds of disposals, always positive
+	remaining *big.Float // quantity not matched by the same day or bed and breakfast rules
+}
+
+// unitAmount returns the amount per share of the day's transactions
+func (d *ukDay) unitAmount() *big.Float {
+	return big.NewFloat(0.0).Quo(d.amount, d.quantity)
+}
+
+// MatchUK matches the disposals of each symbol against acquisitions using
+// the UK share matching rules, in order: acquisitions on the same day, then
+// acquisitions in the following 30 days (bed and breakfasting), then the
+// Section 104 pool of all other acquisitions at average cost. shares are
+// pooled per symbol across every account. disposals of more shares than
+// were acquired are reported as Unmatched for the excess.
+func MatchUK(trans []*trade.Trade) *UKResult {
+	acquisitions := make(map[string][]*ukDay)
+	disposals := make(map[string][]*ukDay)
+	symbols := make([]string, 0)
+	ordered := Ordered(trans)
+	for i := 0; i < len(ordered); i++ {
+		t := ordered[i]
+		// guard clause: moving shares between accounts doesn't dispose of them
+		if !affectsLots(t) || t.Type == trade.TransferIn || t.Type == trade.TransferOut {
+			continue
+		}
+		if _, ok := acquisitions[t.Symbol]; !ok {
+			symbols = append(symbols, t.Symbol)
+			acquisitions[t.Symbol] = make([]*ukDay, 0)
+			disposals[t.Symbol] = make([]*ukDay, 0)
+		}
+		if t.Quantity.Sign() > 0 {
+			acquisitions[t.Symbol] = addToDay(acquisitions[t.Symbol], t)
+		} else {
+			disposals[t.Symbol] = addToDay(disposals[t.Symbol], t)
+		}
+	}
+	sort.Strings(symbols)
+
+	result := UKResult{Disposals: make([]*Disposal, 0), Pools: make([]*Pool, 0)}
+	for i := 0; i < len(symbols); i++ {
+		pool := matchSymbolUK(symbols[i], acquisitions[symbols[i]], disposals[symbols[i]], &result)
+		if pool.Quantity.Sign() > 0 {
+			result.Pools = append(result.Pools, pool)
+		}
+	}
+	sort.SliceStable(result.Disposals, func(i, j int) bool {
+		return result.Disposals[i].Date.Before(result.Disposals[j].Date)
+	})
+	return &result
+}
+
+// addToDay adds the transaction to the day it was made on, days being in
+// date order
+func addToDay(days []*ukDay, t *trade.Trade) []*ukDay {
+	quantity := new(big.Float).SetPrec(precision(t.Quantity)).Abs(t.Quantity)
+	amount := big.NewFloat(0.0).Abs(t.Amount)
+	if len(days) > 0 && sameUKDay(days[len(days)-1].date, t.Date) {
+		d := days[len(days)-1]
+		d.quantity = d.quantity.Add(d.quantity, quantity)
+		d.amount = d.amount.Add(d.amount, amount)
+		d.remaining = d.remaining.Add(d.remaining, quantity)
+		return days
+	}
+	return append(days, &ukDay{
+		account:   t.Account,
+		date:      t.Date,
+		quantity:  quantity,
+		amount:    amount,
+		remaining: big.NewFloat(0.0).Copy(quantity),
+	})
+}
+
+// sameUKDay returns true if both times are on the same calendar day
+func sameUKDay(a time.Time, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+// matchSymbolUK matches the disposals of a symbol, adding them to the
+// result, and returns the Section 104 pool left afterwards
+func matchSymbolUK(symbol string, acquisitions []*ukDay, disposals []*ukDay, result *UKResult) *Pool {
+	// the same day and bed and breakfast rules don't depend on the pool,
+	// so are applied to every disposal first
+	for i := 0; i < len(disposals); i++ {
+		d := disposals[i]
+		for j := 0; j < len(acquisitions) && d.remaining.Sign() > 0; j++ {
+			if acquisitions[j].remaining.Sign() > 0 && sameUKDay(acquisitions[j].date, d.date) {
+				result.Disposals = append(result.Disposals, matchUK(symbol, d, acquisitions[j], SameDay))
+			}
+		}
+	}
+	for i := 0; i < len(disposals); i++ {
+		d := disposals[i]
+		last := d.date.AddDate(0, 0, bedAndBreakfastDays)
+		for j := 0; j < len(acquisitions) && d.remaining.Sign() > 0; j++ {
+			a := acquisitions[j]
+			if a.remaining.Sign() > 0 && a.date.After(d.date) && !sameUKDay(a.date, d.date) && !a.date.After(last) {
+				result.Disposals = append(result.Disposals, matchUK(symbol, d, a, BedAndBreakfast))
+			}
+		}
+	}
+
+	// whatever is left goes in or comes out of the pool in date order,
+	// acquisitions on a day joining the pool before that day's disposal
+	pool := Pool{Symbol: symbol, Quantity: big.NewFloat(0.0), Cost: big.NewFloat(0.0)}
+	j := 0
+	for i := 0; i < len(disposals); i++ {
+		d := disposals[i]
+		for ; j < len(acquisitions) && !acquisitions[j].date.After(d.date); j++ {
+			pool.add(acquisitions[j])
+		}
+		// guard clause: already matched by the same day or bed and breakfast rules
+		if d.remaining.Sign() <= 0 || isDust(d.remaining) {
+			continue
+		}
+		if pool.Quantity.Sign() > 0 {
+			quantity := minAbs(d.remaining, pool.Quantity)
+			cost := share(pool.Cost, quantity, pool.Quantity)
+			result.Disposals = append(result.Disposals, newDisposal(symbol, d, Section104, quantity, cost))
+			pool.Quantity = pool.Quantity.Sub(pool.Quantity, quantity)
+			pool.Cost = pool.Cost.Sub(pool.Cost, cost)
+			d.remaining = d.remaining.Sub(d.remaining, quantity)
+		}
+		if d.remaining.Sign() > 0 && !isDust(d.remaining) {
+			quantity := big.NewFloat(0.0).Copy(d.remaining)
+			result.Disposals = append(result.Disposals, newDisposal(symbol, d, Unmatched, quantity, big.NewFloat(0.0)))
+			d.remaining = d.remaining.SetInt64(0)
+		}
+	}
+	for ; j < len(acquisitions); j++ {
+		pool.add(acquisitions[j])
+	}
+	return &pool
+}
+
+// newDisposal returns the part of a day's disposals matched by a rule
+// against shares of the given cost
+func newDisposal(symbol string, d *ukDay, rule UKMatchRule, quantity *big.Float, cost *big.Float) *Disposal {
+	proceeds := big.NewFloat(0.0).Mul(quantity, d.unitAmount())
+	return &Disposal{
+		Account:  d.account,
+		Symbol:   symbol,
+		Date:     d.date,
+		Rule:     rule,
+		Quantity: quantity,
+		Proceeds: proceeds,
+		Cost:     cost,
+		Gain:     big.NewFloat(0.0).Sub(proceeds, cost),
+		TaxYear:  UK{}.TaxYear(d.date),
+	}
+}
+
+// add adds the unmatched part of a day's acquisitions to the pool
+func (p *Pool) add(a *ukDay) {
+	if a.remaining.Sign() <= 0 {
+		return
+	}
+	p.Quantity = p.Quantity.Add(p.Quantity, a.remaining)
+	p.Cost = p.Cost.Add(p.Cost, share(a.amount, a.remaining, a.quantity))
+}
+
+// matchUK matches as much of the disposal as the acquisition has left
+func matchUK(symbol string, d *ukDay, a *ukDay, rule UKMatchRule) *Disposal {
+	quantity := minAbs(d.remaining, a.remaining)
+	cost := big.NewFloat(0.0).Mul(quantity, a.unitAmount())
+	d.remaining = d.remaining.Sub(d.remaining, quantity)
+	a.remaining = a.remaining.Sub(a.remaining, quantity)
+	disposal := newDisposal(symbol, d, rule, quantity, cost)
+	disposal.Acquired = a.date
+	return disposal
+}
@@ -0,0 +1,111 @@
+package lots
+
+import "testing"
+
+// wantDisposal is the rule, quantity and cost expected of a disposal
+type wantDisposal struct {
+	rule     UKMatchRule
+	quantity float64
+	cost     float64
+}
+
+func TestMatchUK(t *testing.T) {
+	tests := []struct {
+		name          string
+		rows          []row
+		wantDisposals []wantDisposal
+		wantPool      float64 // quantity left in the pool
+	}{
+		{
+			name: "same day",
+			rows: []row{
+				{"", "01/04/2021", "Bought 10 BP @ 100", "10", "BP", "100", "-1000"},
+				{"", "03/01/2021", "Sold 10 BP @ 120", "10", "BP", "120", "1200"},
+				{"", "03/01/2021", "Bought 10 BP @ 110", "10", "BP", "110", "-1100"},
+			},
+			wantDisposals: []wantDisposal{{SameDay, 10, 1100}},
+			wantPool:      10,
+		},
+		{
+			name: "bed and breakfast",
+			rows: []row{
+				{"", "01/04/2021", "Bought 10 BP @ 100", "10", "BP", "100", "-1000"},
+				{"", "03/01/2021", "Sold 10 BP @ 120", "10", "BP", "120", "1200"},
+				{"", "03/20/2021", "Bought 10 BP @ 115", "10", "BP", "115", "-1150"},
+			},
+			wantDisposals: []wantDisposal{{BedAndBreakfast, 10, 1150}},
+			wantPool:      10,
+		},
+		{
+			name: "bought after 30 days",
+			rows: []row{
+				{"", "01/04/2021", "Bought 10 BP @ 100", "10", "BP", "100", "-1000"},
+				{"", "03/01/2021", "Sold 10 BP @ 120", "10", "BP", "120", "1200"},
+				{"", "04/01/2021", "Bought 10 BP @ 115", "10", "BP", "115", "-1150"},
+			},
+			wantDisposals: []wantDisposal{{Section104, 10, 1000}},
+			wantPool:      10,
+		},
+		{
+			name: "pooled at average cost",
+			rows: []row{
+				{"", "01/04/2021", "Bought 10 BP @ 100", "10", "BP", "100", "-1000"},
+				{"", "02/01/2021", "Bought 10 BP @ 200", "10", "BP", "200", "-2000"},
+				{"", "03/01/2021", "Sold 10 BP @ 120", "10", "BP", "120", "1200"},
+			},
+			wantDisposals: []wantDisposal{{Section104, 10, 1500}},
+			wantPool:      10,
+		},
+		{
+			name: "rules in order",
+			rows: []row{
+				{"", "01/04/2021", "Bought 10 BP @ 100", "10", "BP", "100", "-1000"},
+				{"", "03/01/2021", "Sold 15 BP @ 120", "15", "BP", "120", "1800"},
+				{"", "03/01/2021", "Bought 5 BP @ 110", "5", "BP", "110", "-550"},
+				{"", "03/10/2021", "Bought 5 BP @ 115", "5", "BP", "115", "-575"},
+			},
+			wantDisposals: []wantDisposal{{SameDay, 5, 550}, {BedAndBreakfast, 5, 575}, {Section104, 5, 500}},
+			wantPool:      5,
+		},
+		{
+			name: "nothing held",
+			rows: []row{
+				{"", "03/01/2021", "Sold 10 BP @ 120", "10", "BP", "120", "1200"},
+			},
+			wantDisposals: []wantDisposal{{Unmatched, 10, 0}},
+		},
+		{
+			name: "more sold than held",
+			rows: []row{
+				{"", "01/04/2021", "Bought 5 BP @ 100", "5", "BP", "100", "-500"},
+				{"", "03/01/2021", "Sold 10 BP @ 120", "10", "BP", "120", "1200"},
+			},
+			wantDisposals: []wantDisposal{{Section104, 5, 500}, {Unmatched, 5, 0}},
+		},
+	}
+	for i := 0; i < len(tests); i++ {
+		tt := tests[i]
+		result := MatchUK(newTrades(t, tt.rows))
+		if len(result.Disposals) != len(tt.wantDisposals) {
+			t.Errorf("%s: %d disposals, want %d", tt.name, len(result.Disposals), len(tt.wantDisposals))
+			continue
+		}
+		for j := 0; j < len(tt.wantDisposals); j++ {
+			d, want := result.Disposals[j], tt.wantDisposals[j]
+			if d.Rule != want.rule || !equal(d.Quantity, want.quantity) || !equal(d.Cost, want.cost) {
+				t.Errorf("%s: disposal %d = %v of %v costing %v, want %v of %v costing %v", tt.name, j,
+					d.Rule, d.Quantity, d.Cost, want.rule, want.quantity, want.cost)
+			}
+			if !equal(d.Proceeds, 120*want.quantity) {
+				t.Errorf("%s: disposal %d proceeds = %v, want %v", tt.name, j, d.Proceeds, 120*want.quantity)
+			}
+		}
+		pooled := 0.0
+		if len(result.Pools) > 0 {
+			pooled, _ = result.Pools[0].Quantity.Float64()
+		}
+		if pooled != tt.wantPool {
+			t.Errorf("%s: pool = %v, want %v", tt.name, pooled, tt.wantPool)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package lots
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/stonks/trade"
+)
+
+// WashSaleRule configures detection of wash sales: losses realized on a
+// sale when substantially identical securities are bought within a window
+// around it. the disallowed loss is added to the basis of the replacement
+// lot, and the holding period of the sold lot is added to the replacement's.
+type WashSaleRule struct {
+	WindowDays int // days before and after the sale a purchase counts as a replacement, 30 in the US
+	// IncludeOptions treats options on the same underlying as substantially
+	// identical to the shares, and shares as identical to the options
+	IncludeOptions bool
+	// CrossAccount matches losses against replacement purchases in any
+	// account rather than only the account the loss was realized in
+	CrossAccount bool
+
+	// retirementAccounts are the RetirementAccounts of the options. losses
+	// realized in them aren't deductible so are never washed, and a loss
+	// replaced by a purchase in one is disallowed permanently since the
+	// basis of the replacement doesn't matter for tax
+	retirementAccounts map[string]bool
+}
+
+// WashSale is the part of a loss disallowed by a replacement lot opened
+// within the window of the sale
+type WashSale struct {
+	Loss        *RealizedGain
+	Replacement *Lot
+	Quantity    *big.Float // share equivalents of the sale the replacement matched
+	Disallowed  *big.Float // loss disallowed, as a positive amount
+	// Permanent is true if the replacement was bought in a retirement
+	// account, so the loss can never be recovered
+	Permanent bool
+}
+
+// pendingLoss is a loss that still has some quantity that could be washed
+// by a purchase made after the sale
+type pendingLoss struct {
+	gain      *RealizedGain
+	remaining *big.Float // share equivalents of the sale not yet matched to a replacement
+	perUnit   *big.Float // loss per share equivalent, as a positive amount
+	held      time.Duration
+}
+
+// washSales holds the wash sale state of an engine
+type washSales struct {
+	rule    *WashSaleRule
+	pending []*pendingLoss
+	used    map[*Lot]*big.Float // share equivalents of each lot already used as a replacement
+	found   []*WashSale         // found by the last transaction applied
+}
+
+// newWashSales returns the wash sale state for a rule, or nil if wash sales
+// aren't being detected
+func newWashSales(rule *WashSaleRule) *washSales {
+	if rule == nil || rule.WindowDays <= 0 {
+		return nil
+	}
+	return &washSales{
+		rule:    rule,
+		pending: make([]*pendingLoss, 0),
+		used:    make(map[*Lot]*big.Float),
+	}
+}
+
+// window returns the length of the wash sale window
+func (w *washSales) window() time.Duration {
+	return time.Duration(w.rule.WindowDays) * 24 * time.Hour
+}
+
+// multiplier returns the share equivalents controlled by one unit of the
+// transaction's instrument
+func multiplier(t *trade.Trade) *big.Float {
+	if t == nil || t.Instrument == nil || t.Instrument.Multiplier == nil {
+		return big.NewFloat(1.0)
+	}
+	return t.Instrument.Multiplier
+}
+
+// identical returns true if a purchase of the replacement transaction is
+// substantially identical to the security sold by the sale transaction
+func (w *washSales) identical(sale *trade.Trade, replacement *trade.Trade) bool {
+	if sale.Symbol == replacement.Symbol {
+		return true
+	}
+	if !w.rule.IncludeOptions || sale.Instrument == nil || replacement.Instrument == nil {
+		return false
+	}
+	return sale.Instrument.Underlying == replacement.Instrument.Underlying
+}
+
+// available returns the share equivalents of a lot that haven't been used
+// to replace a sold lot yet
+func (w *washSales) available(lot *Lot) *big.Float {
+	equivalents := big.NewFloat(0.0).Abs(lot.Quantity)
+	equivalents = equivalents.Mul(equivalents, multiplier(lot.Opening))
+	if used, ok := w.used[lot]; ok {
+		equivalents = equivalents.Sub(equivalents, used)
+	}
+	return equivalents
+}
+
+// apply washes as much of a pending loss as a replacement lot can absorb,
+// adding the disallowed loss to the replacement's basis. a lot only partly
+// matched is split first, so the basis and holding period of the shares
+// left over aren't changed. apply returns the lot the shares left over are
+// in, nil if there are none.
+func (w *washSales) apply(e *Engine, p *pendingLoss, lot *Lot) *Lot {
+	available := w.available(lot)
+	if available.Sign() <= 0 || p.remaining.Sign() <= 0 {
+		return lot
+	}
+	matched := minAbs(available, p.remaining)
+	kept := big.NewFloat(0.0).Quo(matched, multiplier(lot.Opening))
+	if used := w.used[lot]; used != nil {
+		kept = kept.Add(kept, big.NewFloat(0.0).Quo(used, multiplier(lot.Opening)))
+	}
+	rest := e.split(lot, kept)
+	disallowed := big.NewFloat(0.0).Mul(matched, p.perUnit)
+	p.gain.Disallowed = p.gain.Disallowed.Add(p.gain.Disallowed, disallowed)
+	w.found = append(w.found, &WashSale{
+		Loss:        p.gain,
+		Replacement: lot,
+		Quantity:    matched,
+		Disallowed:  disallowed,
+		Permanent:   w.rule.retirementAccounts[lot.Account],
+	})
+
+	if w.rule.retirementAccounts[lot.Account] {
+		// guard clause: the loss is lost for good rather than deferred
+		p.gain.PermanentlyDisallowed = p.gain.PermanentlyDisallowed.Add(p.gain.PermanentlyDisallowed, disallowed)
+	} else {
+		e.Adjust(lot, &Adjustment{
+			Date:   p.gain.CloseDate,
+			Amount: disallowed,
+			Reason: "wash sale of " + p.gain.Symbol + " in " + p.gain.Account,
+			Loss:   p.gain,
+		})
+		if lot.HoldingStart.After(lot.OpenDate.Add(-p.held)) {
+			lot.HoldingStart = lot.OpenDate.Add(-p.held)
+		}
+	}
+
+	p.remaining = p.remaining.Sub(p.remaining, matched)
+	used := w.used[lot]
+	if used == nil {
+		used = big.NewFloat(0.0)
+	}
+	w.used[lot] = used.Add(used, matched)
+	if rest == nil && w.available(lot).Sign() > 0 {
+		return lot
+	}
+	return rest
+}
+
+// isReplacement returns true if the lot could replace the shares sold at a
+// loss: a long lot of an identical security, opened within the window, in
+// the same account, and not the lot that was sold
+func (w *washSales) isReplacement(p *pendingLoss, lot *Lot) bool {
+	g := p.gain
+	if lot.Quantity.Sign() <= 0 || lot.Opening == nil {
+		return false
+	}
+	if lot.Account != g.Account && !w.rule.CrossAccount {
+		return false
+	}
+	if g.Lot != nil && lot.Opening == g.Lot.Opening {
+		return false
+	}
+	if !w.identical(g.Closing, lot.Opening) {
+		return false
+	}
+	diff := lot.OpenDate.Sub(g.CloseDate)
+	return diff >= -w.window() && diff <= w.window()
+}
+
+// afterApply checks the results of applying a transaction for wash sales.
+// losses realized by the transaction are matched against replacement lots
+// already open, and a lot opened by the transaction is matched against
+// losses realized in the window before it.
+func (w *washSales) afterApply(e *Engine, t *trade.Trade, realized []*RealizedGain) {
+	// drop losses whose window has passed
+	active := w.pending[:0]
+	for i := 0; i < len(w.pending); i++ {
+		p := w.pending[i]
+		if p.remaining.Sign() > 0 && t.Date.Sub(p.gain.CloseDate) <= w.window() {
+			active = append(active, p)
+		}
+	}
+	w.pending = active
+
+	// a purchase after a loss replaces it, wherever it was made
+	if t.Type == trade.Buy {
+		opened := e.LotsFor(t.Account, t.Symbol)
+		for i := 0; i < len(opened); i++ {
+			if opened[i].Opening != t {
+				continue
+			}
+			// each loss replaces the shares the losses before it left over
+			replacement := opened[i]
+			for j := 0; j < len(w.pending) && replacement != nil; j++ {
+				if w.isReplacement(w.pending[j], replacement) {
+					replacement = w.apply(e, w.pending[j], replacement)
+				}
+			}
+		}
+	}
+
+	// a loss is replaced by purchases made before it that are still open
+	for i := 0; i < len(realized); i++ {
+		g := realized[i]
+		if g.Short || g.Gain.Sign() >= 0 || w.rule.retirementAccounts[g.Account] {
+			continue
+		}
+		soldEquivalents := big.NewFloat(0.0).Mul(g.Quantity, multiplier(g.Closing))
+		p := pendingLoss{
+			gain:      g,
+			remaining: soldEquivalents,
+			perUnit:   big.NewFloat(0.0).Quo(big.NewFloat(0.0).Neg(g.Gain), soldEquivalents),
+			held:      g.CloseDate.Sub(g.HoldingStart),
+		}
+		open := e.OpenLots()
+		for j := 0; j < len(open) && p.remaining.Sign() > 0; j++ {
+			if w.isReplacement(&p, open[j]) {
+				w.apply(e, &p, open[j])
+			}
+		}
+		if p.remaining.Sign() > 0 {
+			w.pending = append(w.pending, &p)
+		}
+	}
+}
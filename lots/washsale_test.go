@@ -0,0 +1,159 @@
+package lots
+
+import (
+	"testing"
+	"time"
+)
+
+// lossOn buys 10 shares at 100 and sells them the next June at 80, a loss
+// of 200
+var lossOn = []row{
+	{"", "01/04/2021", "Bought 10 AAPL @ 100", "10", "AAPL", "100", "-1000"},
+	{"", "06/01/2021", "Sold 10 AAPL @ 80", "10", "AAPL", "80", "800"},
+}
+
+// withRows returns the loss followed by more rows
+func withRows(rows ...row) []row {
+	return append(append([]row{}, lossOn...), rows...)
+}
+
+// wantLot is the quantity and cost expected of an open lot
+type wantLot struct {
+	account  string
+	quantity float64
+	cost     float64
+}
+
+func TestWashSale(t *testing.T) {
+	tests := []struct {
+		name            string
+		rows            []row
+		rule            WashSaleRule
+		retirement      map[string]bool
+		wantDisallowed  float64
+		wantPermanently float64
+		wantLots        []wantLot
+	}{
+		{
+			name:           "replaced after the sale",
+			rows:           withRows(row{"", "06/15/2021", "Bought 10 AAPL @ 85", "10", "AAPL", "85", "-850"}),
+			wantDisallowed: 200,
+			wantLots:       []wantLot{{"taxable", 10, 1050}},
+		},
+		{
+			name: "replaced before the sale",
+			rows: []row{
+				{"", "01/04/2021", "Bought 10 AAPL @ 100", "10", "AAPL", "100", "-1000"},
+				{"", "05/20/2021", "Bought 10 AAPL @ 85", "10", "AAPL", "85", "-850"},
+				{"", "06/01/2021", "Sold 10 AAPL @ 80", "10", "AAPL", "80", "800"},
+			},
+			wantDisallowed: 200,
+			wantLots:       []wantLot{{"taxable", 10, 1050}},
+		},
+		{
+			name:           "partly replaced",
+			rows:           withRows(row{"", "06/15/2021", "Bought 5 AAPL @ 85", "5", "AAPL", "85", "-425"}),
+			wantDisallowed: 100,
+			wantLots:       []wantLot{{"taxable", 5, 525}},
+		},
+		{
+			name:           "replacement partly matched",
+			rows:           withRows(row{"", "06/15/2021", "Bought 15 AAPL @ 85", "15", "AAPL", "85", "-1275"}),
+			wantDisallowed: 200,
+			wantLots:       []wantLot{{"taxable", 10, 1050}, {"taxable", 5, 425}},
+		},
+		{
+			name:     "bought after the window",
+			rows:     withRows(row{"", "07/15/2021", "Bought 10 AAPL @ 85", "10", "AAPL", "85", "-850"}),
+			wantLots: []wantLot{{"taxable", 10, 850}},
+		},
+		{
+			name:     "different symbol",
+			rows:     withRows(row{"", "06/15/2021", "Bought 10 MSFT @ 85", "10", "MSFT", "85", "-850"}),
+			wantLots: []wantLot{{"taxable", 10, 850}},
+		},
+		{
+			name:     "other account",
+			rows:     withRows(row{"joint", "06/15/2021", "Bought 10 AAPL @ 85", "10", "AAPL", "85", "-850"}),
+			wantLots: []wantLot{{"joint", 10, 850}},
+		},
+		{
+			name:           "other account across accounts",
+			rows:           withRows(row{"joint", "06/15/2021", "Bought 10 AAPL @ 85", "10", "AAPL", "85", "-850"}),
+			rule:           WashSaleRule{CrossAccount: true},
+			wantDisallowed: 200,
+			wantLots:       []wantLot{{"joint", 10, 1050}},
+		},
+		{
+			name:            "replaced in an IRA",
+			rows:            withRows(row{"IRA", "06/15/2021", "Bought 10 AAPL @ 85", "10", "AAPL", "85", "-850"}),
+			rule:            WashSaleRule{CrossAccount: true},
+			retirement:      map[string]bool{"IRA": true},
+			wantDisallowed:  200,
+			wantPermanently: 200,
+			wantLots:        []wantLot{{"IRA", 10, 850}},
+		},
+		{
+			name: "loss in an IRA",
+			rows: []row{
+				{"IRA", "01/04/2021", "Bought 10 AAPL @ 100", "10", "AAPL", "100", "-1000"},
+				{"IRA", "06/01/2021", "Sold 10 AAPL @ 80", "10", "AAPL", "80", "800"},
+				{"IRA", "06/15/2021", "Bought 10 AAPL @ 85", "10", "AAPL", "85", "-850"},
+			},
+			retirement: map[string]bool{"IRA": true},
+			wantLots:   []wantLot{{"IRA", 10, 850}},
+		},
+	}
+	for i := 0; i < len(tests); i++ {
+		tt := tests[i]
+		rule := tt.rule
+		opts := &Options{WashSale: &rule, RetirementAccounts: tt.retirement}
+		result := Match(newTrades(t, tt.rows), opts)
+
+		var loss *RealizedGain
+		for j := 0; j < len(result.Realized); j++ {
+			if result.Realized[j].Gain.Sign() < 0 {
+				loss = result.Realized[j]
+			}
+		}
+		if loss == nil {
+			t.Errorf("%s: no loss realized", tt.name)
+			continue
+		}
+		if !equal(loss.Disallowed, tt.wantDisallowed) {
+			t.Errorf("%s: disallowed = %v, want %v", tt.name, loss.Disallowed, tt.wantDisallowed)
+		}
+		if !equal(loss.PermanentlyDisallowed, tt.wantPermanently) {
+			t.Errorf("%s: permanently disallowed = %v, want %v", tt.name, loss.PermanentlyDisallowed, tt.wantPermanently)
+		}
+		if len(result.Open) != len(tt.wantLots) {
+			t.Errorf("%s: %d open lots, want %d", tt.name, len(result.Open), len(tt.wantLots))
+			continue
+		}
+		for j := 0; j < len(tt.wantLots); j++ {
+			lot, want := result.Open[j], tt.wantLots[j]
+			if lot.Account != want.account || !equal(lot.Quantity, want.quantity) || !equal(lot.Cost, want.cost) {
+				t.Errorf("%s: lot %d = %v %v costing %v, want %v %v costing %v", tt.name, j,
+					lot.Account, lot.Quantity, lot.Cost, want.account, want.quantity, want.cost)
+			}
+		}
+	}
+}
+
+func TestWashSaleHoldingPeriod(t *testing.T) {
+	rows := withRows(row{"", "06/15/2021", "Bought 15 AAPL @ 85", "15", "AAPL", "85", "-1275"})
+	result := Match(newTrades(t, rows), &Options{WashSale: &WashSaleRule{}})
+	if len(result.Open) != 2 {
+		t.Fatalf("%d open lots, want 2", len(result.Open))
+	}
+
+	// the replacement takes on the 148 days the sold shares were held
+	bought := time.Date(2021, time.June, 15, 0, 0, 0, 0, time.UTC)
+	held := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC).Sub(time.Date(2021, time.January, 4, 0, 0, 0, 0, time.UTC))
+	if want := bought.Add(-held); !result.Open[0].HoldingStart.Equal(want) {
+		t.Errorf("replacement holding start = %v, want %v", result.Open[0].HoldingStart, want)
+	}
+	if !result.Open[1].HoldingStart.Equal(bought) {
+		t.Errorf("unmatched holding start = %v, want %v", result.Open[1].HoldingStart, bought)
+	}
+}
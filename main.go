@@ -3,12 +3,20 @@ package main
 import (
 	"encoding/csv"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/big"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
+	"github.com/stonks/books"
+	"github.com/stonks/lots"
+	"github.com/stonks/projection"
+	"github.com/stonks/query"
+	"github.com/stonks/quotes"
 	"github.com/stonks/trade"
 )
 
@@ -18,8 +26,236 @@ import (
 // what file(s) to read transactions from, etc..
 type config struct {
 	TransactionsFile string `json:"transactionsFile"`
+	// Accounts lists the transaction file of each account to analyze.
+	// when blank, TransactionsFile is loaded as a single account.
+	Accounts []accountConfig `json:"accounts"`
+	// GroupByAccount adds the results for each individual account to
+	// the output in addition to the results across all accounts
+	GroupByAccount bool `json:"groupByAccount"`
+	// ExcludedAssetClasses lists asset classes (eg MUTUAL_FUND) whose
+	// transactions are left out of the trading statistics
+	ExcludedAssetClasses []trade.AssetClass `json:"excludedAssetClasses"`
+	// DividendOverrides maps a symbol to the tax class its dividends
+	// should be reported as, eg {"AAPL": "QUALIFIED"}
+	DividendOverrides map[string]trade.DividendClass `json:"dividendOverrides"`
+	// TagRulesFile is a json file of rules used to tag transactions
+	TagRulesFile string `json:"tagRulesFile"`
+	// TagsFile is a csv file of transaction ids and the tags to attach to them
+	TagsFile string `json:"tagsFile"`
+	// FilterTags limits the analysis to transactions with one of the tags
+	FilterTags []string `json:"filterTags"`
+	// GroupByTag adds the results for each tag to the output
+	GroupByTag bool `json:"groupByTag"`
+	// GroupByPeriod adds the results for each month, quarter, year or
+	// fiscal year to the output
+	GroupByPeriod projection.Bucketing `json:"groupByPeriod"`
+	// FiscalYearStart is the month and day (MM-DD) fiscal years start on
+	FiscalYearStart string `json:"fiscalYearStart"`
+	// JournalFile is a json file of trade journal entries
+	JournalFile string `json:"journalFile"`
+	// SymbolRenames maps old ticker symbols to the symbol they were
+	// renamed to, eg {"FB": "META"}
+	SymbolRenames map[string]string `json:"symbolRenames"`
+	// Splits lists the stock splits to restate earlier transactions for
+	Splits []*trade.Split `json:"splits"`
+	// SpinOffs lists the spin-offs to allocate cost basis for
+	SpinOffs []*trade.SpinOffEvent `json:"spinOffs"`
+	// CostBasisMethod is the lot selection method used to match sales
+	// against purchases, one of FIFO, LIFO or AVERAGE
+	CostBasisMethod lots.Method `json:"costBasisMethod"`
+	// AssetClassCostBasisMethods overrides the cost basis method per
+	// asset class, eg {"CRYPTO": "HIFO"}
+	AssetClassCostBasisMethods map[trade.AssetClass]lots.Method `json:"assetClassCostBasisMethods"`
+	// SpecificLotsFile is a csv file designating the lots closed by
+	// particular sales, overriding the cost basis method for them
+	SpecificLotsFile string `json:"specificLotsFile"`
+	// WashSale enables wash sale detection when set
+	WashSale *washSaleConfig `json:"washSale"`
+	// Jurisdiction is the country whose tax rules gains are computed
+	// under. defaults to US
+	Jurisdiction string `json:"jurisdiction"`
+	// Form8949File is where Form 8949 is written, as a pdf if the path
+	// ends in .pdf and csv otherwise
+	Form8949File string `json:"form8949File"`
+	// TXFFile is where realized gains are written in the Tax Exchange
+	// Format for import into tax software
+	TXFFile string `json:"txfFile"`
+	// LedgerFile is where the transactions are written as ledger-cli
+	// journal entries
+	LedgerFile string `json:"ledgerFile"`
+	// BeancountFile is where the transactions are written as beancount
+	// journal entries
+	BeancountFile string `json:"beancountFile"`
+	// GnuCashFile is where the transactions are written for import into
+	// GnuCash, as a qif file if the path ends in .qif, otherwise as csv
+	GnuCashFile string `json:"gnuCashFile"`
+	// BookAccounts names the accounts the ledger, beancount and GnuCash
+	// entries are posted to, defaulting those left blank
+	BookAccounts *books.Accounts `json:"bookAccounts"`
+	// ReturnPeriods are the periods returns are measured over. defaults
+	// to month to date, year to date, one and three years and since
+	// inception
+	ReturnPeriods []*returnPeriodConfig `json:"returnPeriods"`
+	// DrawdownChartFile is where the equity curve and drawdown chart is
+	// written as an svg, when there's a price history
+	DrawdownChartFile string `json:"drawdownChartFile"`
+	// LeaderboardSize is how many of the best and worst round trips are
+	// listed, 10 by default
+	LeaderboardSize int `json:"leaderboardSize"`
+	// RollingWindows are the lengths in days of the windows rolling P/L,
+	// win rate and volume are computed over, 30, 90 and 365 by default
+	RollingWindows []int `json:"rollingWindows"`
+	// SymbolMetadataFile is a csv of the sector, industry and asset class
+	// of symbols, used to report the allocation of the open positions
+	SymbolMetadataFile string `json:"symbolMetadataFile"`
+	// ConcentrationThreshold is the percent of the portfolio an allocation
+	// is flagged as concentrated above, 25 by default
+	ConcentrationThreshold float64 `json:"concentrationThreshold"`
+	// HarvestThreshold is the smallest unrealized loss a lot is listed as
+	// a tax-loss harvesting candidate for
+	HarvestThreshold float64 `json:"harvestThreshold"`
+	// Projections names the projections to run instead of the full report
+	Projections []string `json:"projections"`
+	// SnapshotFile is where the state of the projections is saved after a
+	// run, to resume from on the next one
+	SnapshotFile string `json:"snapshotFile"`
+	// Plugins are projections run out of process, which can be named in
+	// Projections like the built in ones
+	Plugins []projection.Plugin `json:"plugins"`
+	// GroupBy is the dimension the projections are run along, eg SYMBOL
+	GroupBy projection.Dimension `json:"groupBy"`
+	// Benchmark is the symbol returns are compared to, SPY by default
+	Benchmark string `json:"benchmark"`
+	// BenchmarkChartFile is where the chart of the portfolio's growth
+	// against the benchmark is written as an svg
+	BenchmarkChartFile string `json:"benchmarkChartFile"`
+	// RiskFreeRate is the annual risk free rate in percent the Sharpe and
+	// Sortino ratios are measured against
+	RiskFreeRate float64 `json:"riskFreeRate"`
+	// ExpirationCalendarFile is where the expiration dates of the open
+	// options are written as an iCalendar file
+	ExpirationCalendarFile string `json:"expirationCalendarFile"`
+	// Form1099BFile is a csv of the sales a broker reported on Form 1099-B
+	// to reconcile the realized gains against
+	Form1099BFile string `json:"form1099BFile"`
+	// Form1099BAccount is the account the 1099-B was issued for, for
+	// 1099-B files without an account column
+	Form1099BAccount string `json:"form1099BAccount"`
+	// EstimatedTax configures the tax rates used to estimate quarterly
+	// tax payments, which are only estimated when set
+	EstimatedTax *projection.TaxRates `json:"estimatedTax"`
+	// Quotes configures where current prices come from to value open
+	// positions. unrealized gains are only computed when set
+	Quotes *quotesConfig `json:"quotes"`
+	// StatementBalances are cash balances from broker statements to
+	// reconcile the reconstructed cash balance against
+	StatementBalances []*statementBalanceConfig `json:"statementBalances"`
+	// AsOf is the date in YYYY-MM-DD format everything is reported as of,
+	// leaving out later transactions. defaults to today. the --as-of flag
+	// overrides it
+	AsOf string `json:"asOf"`
+	// BaseCurrency is the currency every amount is reported in, defaults
+	// to the currency of the jurisdiction
+	BaseCurrency string `json:"baseCurrency"`
+	// FXRatesFile is a csv file of exchange rates used to convert
+	// transactions to the base currency
+	FXRatesFile string `json:"fxRatesFile"`
+	// Output is the format results are written in, json by default. the
+	// --output flag overrides it
+	Output outputFormat `json:"output"`
+	// OutDir is the directory the output formats writing files write
+	// them to, reports by default. the --out-dir flag overrides it
+	OutDir string `json:"outDir"`
+	// Columns limits the table output to the columns named, eg Symbol and
+	// Gain. the --columns flag overrides it
+	Columns []string `json:"columns"`
+	// Color is when the table output is colorized: auto, the default, when
+	// writing to a terminal, always or never. the --color flag overrides it
+	Color colorMode `json:"color"`
+	// Template is the path of a text/template the template output renders
+	// the results with. setting it makes template the default output
+	Template string `json:"template"`
+	// Metrics are custom aggregations of the transactions, eg
+	// sum(Amount) where Type == "DIVIDEND" group by month
+	Metrics []*metricConfig `json:"metrics"`
+
+	statements []*projection.StatementBalance // parsed StatementBalances
+	journal    trade.TradeJournal             // loaded from JournalFile
+	bucketer   *projection.Bucketer           // parsed GroupByPeriod
+	metrics    []*query.Metric                // parsed Metrics
+}
+
+// washSaleConfig configures the wash sale rule
+type washSaleConfig struct {
+	// WindowDays is the number of days before and after a loss that a
+	// purchase counts as a replacement. defaults to the jurisdiction's
+	WindowDays int `json:"windowDays"`
+	// IncludeOptions treats options on the same underlying as
+	// substantially identical to the shares
+	IncludeOptions bool `json:"includeOptions"`
+	// CrossAccount matches losses against purchases in every account
+	CrossAccount bool `json:"crossAccount"`
 }
 
+// statementBalanceConfig is a cash balance from a broker statement
+type statementBalanceConfig struct {
+	Account string  `json:"account"` // blank for the balance across every account
+	Date    string  `json:"date"`    // statement date in YYYY-MM-DD format
+	Balance float64 `json:"balance"`
+}
+
+// metricConfig is a named custom metric
+type metricConfig struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"` // see query.Metric for the syntax
+}
+
+// returnPeriodConfig is a named period returns are measured over
+type returnPeriodConfig struct {
+	Name string `json:"name"`
+	From string `json:"from"` // first day in YYYY-MM-DD format
+	To   string `json:"to"`   // last day in YYYY-MM-DD format, defaults to the as of date
+}
+
+// quotesConfig configures the quote provider used to price positions
+type quotesConfig struct {
+	// Provider is where quotes come from, either stooq for delayed quotes
+	// from stooq.com or file for prices read from File
+	Provider string `json:"provider"`
+	// File is a csv of symbol and price rows, for the file provider
+	File string `json:"file"`
+	// HistoryFile is a csv of date, symbol and closing price rows, for
+	// the file provider
+	HistoryFile string `json:"historyFile"`
+	// Suffix is the market suffix stooq.com expects on symbols, defaults
+	// to .us
+	Suffix string `json:"suffix"`
+}
+
+// accountConfig identifies an account and the file its transactions are
+// read from
+type accountConfig struct {
+	Name             string `json:"name"`
+	TransactionsFile string `json:"transactionsFile"`
+	// Format is the broker format of the transactions file, one of the
+	// keys of transactionParsers. defaults to tda
+	Format string `json:"format"`
+	// CostBasisMethod overrides the lot selection method for the account
+	CostBasisMethod lots.Method `json:"costBasisMethod"`
+	// Retirement marks a tax advantaged account such as an IRA
+	Retirement bool `json:"retirement"`
+}
+
+// transactionParsers maps the format of a transactions file to the
+// function used to parse each of its rows
+var transactionParsers = map[string]func([]string) (*trade.Trade, error){
+	"tda":      trade.NewTradeTDA,
+	"coinbase": trade.NewTradeCoinbase,
+}
+
+// defaultAccount is the name given to the account loaded from
+// TransactionsFile when no accounts are configured
+const defaultAccount = "default"
 
 // newConfig returns a new instance of a
 // config struct with default values populated
@@ -51,37 +287,114 @@ func getConfigs() (*config, error) {
 	return config, err
 }
 
-// loadTransactions loads the csv transactions from
-// the file specified in the configs.
+// loadTransactions loads the csv transactions of every account
+// specified in the configs.
 func loadTransactions(c *config) ([]*trade.Trade, error) {
-	csvFile, err := os.Open(c.TransactionsFile)
+	accounts := c.Accounts
+	if len(accounts) == 0 {
+		accounts = []accountConfig{{Name: defaultAccount, TransactionsFile: c.TransactionsFile}}
+	}
+
+	var transactions []*trade.Trade
+	for i := 0; i < len(accounts); i++ {
+		accountTransactions, err := loadTransactionsFile(accounts[i])
+		if err != nil {
+			return nil, fmt.Errorf("account %v: %v", accounts[i].Name, err)
+		}
+		transactions = append(transactions, accountTransactions...)
+	}
+	return transactions, nil
+}
+
+// loadTransactionsFile loads the csv transactions of a single account
+// from its transactions file.
+func loadTransactionsFile(a accountConfig) ([]*trade.Trade, error) {
+	format := a.Format
+	if format == "" {
+		format = "tda"
+	}
+	parse, ok := transactionParsers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown transactions file format %q", a.Format)
+	}
+
+	csvFile, err := os.Open(a.TransactionsFile)
 	if err != nil {
 		return nil, err
 	}
 	defer csvFile.Close()
 
 	csvReader := csv.NewReader(csvFile)
+	// some brokers start their reports with a preamble of a different width
+	csvReader.FieldsPerRecord = -1
 
-	var transactions []*trade.Trade
+	var rows []*trade.Trade
 	for {
 		record, err := csvReader.Read()
 		if err == io.EOF {
 			break
 		}
 
-		// skip first row
-		if record[0] == "DATE" || record[0] == "***END OF FILE***" {
+		// skip header rows and preamble lines
+		if record[0] == "DATE" || record[0] == "***END OF FILE***" || record[0] == "Timestamp" || len(record) == 1 {
 			continue
 		}
-		nextTransaction, err := trade.NewTradeTDA(record)
+		nextTransaction, err := parse(record)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Skipping invalid transaction due to: %v\n", err)
+			continue
+		}
+		nextTransaction.Account = a.Name
+		rows = append(rows, nextTransaction)
+	}
+	oldestFirst(rows)
+
+	var transactions []*trade.Trade
+	for i := 0; i < len(rows); i++ {
+		transactions = append(transactions, rows[i])
+		// a crypto convert is also the purchase of the asset converted to
+		if bought := rows[i].ConvertedTo; bought != nil {
+			bought.Account = a.Name
+			transactions = append(transactions, bought)
 		}
-		transactions = append(transactions, nextTransaction)
 	}
 	return transactions, nil
 }
 
+// oldestFirst reverses the rows of a file listed newest first, as TDA
+// exports are. rows only carry the day they were made, so the order in
+// the file is the only record of which of the same day's trades came
+// first, and a sale must replay after the buy that opened it.
+func oldestFirst(rows []*trade.Trade) {
+	// guard clause: already oldest first, or all made the same day
+	if len(rows) < 2 || !rows[0].Date.After(rows[len(rows)-1].Date) {
+		return
+	}
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+}
+
+// filterTradingTransactions returns the transactions that count towards
+// trading statistics. money market sweeps are always excluded along with
+// any asset classes excluded in the configs.
+func filterTradingTransactions(c *config, trans []*trade.Trade) []*trade.Trade {
+	excluded := make(map[trade.AssetClass]bool)
+	for i := 0; i < len(c.ExcludedAssetClasses); i++ {
+		excluded[c.ExcludedAssetClasses[i]] = true
+	}
+
+	results := make([]*trade.Trade, 0, len(trans))
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if t.IsCashEquivalent() || excluded[t.Instrument.Class] {
+			continue
+		}
+		results = append(results, t)
+	}
+	return results
+}
+
 // groupSymbols organizes a list of transactions by their symbol.
 // the function returns a map whose key's are the symbol and the
 // value is a list of pointers to transactions with the same symbol.
@@ -121,15 +434,15 @@ func groupRelatedSymbols(groupedTransactions map[string][]*trade.Trade) (results
 
 	for s := range groupedTransactions {
 		// added to make sure anything with only options tickers still gets grouped
-		parsedSymbol := strings.Split(s, " ")[0]
+		parsedSymbol := trade.GetUnderlyingSymbol(s)
 		if results[parsedSymbol] != nil {
 			continue
 		}
 		// fmt.Fprintf(os.Stdout, "Parsed symbol: %v\n",parsedSymbol)
 		relatedSymbols := make([]string, 0)
 		for symbol := range groupedTransactions {
-			isRelatedOption := strings.HasPrefix(symbol, parsedSymbol+" ")
 			isRelatedUnderlying := strings.Compare(symbol, parsedSymbol) == 0
+			isRelatedOption := !isRelatedUnderlying && trade.GetUnderlyingSymbol(symbol) == parsedSymbol
 
 			if isRelatedOption || isRelatedUnderlying  {
 				// fmt.Fprintf(os.Stdout, "\t%v: %v\n", s,symbol)
@@ -147,6 +460,7 @@ func groupRelatedSymbols(groupedTransactions map[string][]*trade.Trade) (results
 type CostBasis struct {
 	Symbol           string         // ticker symbol
 	Position         *big.Float     // total open position of this cost basis excluding related positions
+	Short            bool           // true if the open position is short, i.e. opened by a sell short
 	PL               *big.Float     // total profit/loss of this position excluding P/L from related positions
 	EffPL            *big.Float     // the total profit/loss of this position including P/L from related positions
 	Transactions     []*trade.Trade // list of transactions for this symbol
@@ -176,6 +490,8 @@ func newCostBasis(symbol string, trans []*trade.Trade) *CostBasis {
 		}
 	}
 	e.Position = openPos
+	// a short position has sold more than it has bought, leaving a negative position
+	e.Short = openPos.Sign() < 0
 	e.PL = totalPl
 	e.EffPL = totalPl.Copy(totalPl)
 
@@ -319,12 +635,768 @@ func newTransactionStats(cb []*CostBasis) *TransactionStats {
 	return &ts
 }
 
+// statsProjection runs the effective cost basis of each underlying as a
+// projection, so it can be picked from the configs like the others
+type statsProjection struct {
+	c     *config
+	trans []*trade.Trade
+}
+
+// Name returns stats
+func (s *statsProjection) Name() string {
+	return "stats"
+}
+
+// Apply collects the transaction
+func (s *statsProjection) Apply(t *trade.Trade) {
+	s.trans = append(s.trans, t)
+}
+
+// Result returns the transaction stats of the transactions applied
+func (s *statsProjection) Result() interface{} {
+	groupedSymbols := groupSymbols(filterTradingTransactions(s.c, s.trans))
+	return newTransactionStats(getEffectiveCostBasis(groupRelatedSymbols(groupedSymbols), groupedSymbols))
+}
+
+// runProjections runs the projections named in the configs and prints
+// their results keyed by name
+func runProjections(c *config, opts *lots.Options, transactions []*trade.Trade) error {
+	provider, err := quoteProvider(c)
+	if err != nil {
+		return err
+	}
+	projection.Register("stats", func(s *projection.Settings) projection.Projection {
+		return &statsProjection{c: c, trans: make([]*trade.Trade, 0)}
+	})
+	for i := 0; i < len(c.Plugins); i++ {
+		if err := projection.RegisterPlugin(c.Plugins[i]); err != nil {
+			return err
+		}
+	}
+	settings := projection.Settings{
+		Lots:                 opts,
+		AsOf:                 asOfDate(c),
+		ExcludedAssetClasses: c.ExcludedAssetClasses,
+		Quotes:               provider,
+		GroupBy:              c.GroupBy,
+	}
+	if c.GroupBy != "" {
+		results, err := projection.Run(c.Projections, &settings, transactions)
+		if err != nil {
+			return err
+		}
+		return writeOutput(c, results)
+	}
+	var snap *projection.Snapshot
+	if c.SnapshotFile != "" {
+		if snap, err = projection.LoadSnapshot(c.SnapshotFile); err != nil {
+			return err
+		}
+	}
+	results, next, err := projection.Resume(c.Projections, &settings, transactions, snap)
+	if err != nil {
+		return err
+	}
+	if c.SnapshotFile != "" {
+		if err := writeFile(c.SnapshotFile, next.Write); err != nil {
+			return err
+		}
+	}
+	return writeOutput(c, results)
+}
+
+// report is the document written to stdout, holding the results of
+// every analysis run over the transactions
+type report struct {
+	Stats    *TransactionStats
+	Interest *projection.InterestSummary
+	// Dividends holds the dividend income per symbol and month
+	Dividends *projection.DividendIncome
+	// YieldOnCost holds the dividend yield of each holding on its cost basis
+	YieldOnCost *projection.YieldOnCost
+	Fees     *projection.FeeAudit
+	Realized *projection.RealizedPL
+	TaxLots  *projection.TaxLots
+	// Positions holds the positions open as of the configured date
+	Positions *projection.Positions
+	// Cash holds the cash balance over time
+	Cash *projection.CashBalances
+	// Contributions totals the deposits and withdrawals per account and year
+	Contributions *projection.Contributions
+	// RoundTrips pairs the entries and exits of every trade
+	RoundTrips *projection.RoundTrips
+	// Performance holds the win rate, profit factor and other statistics
+	// of the closed round trips
+	Performance *projection.PerformanceStats
+	// HoldingPeriods is the distribution of how long round trips were held
+	HoldingPeriods *projection.HoldingPeriods
+	// Tilt flags outsized trades entered during losing streaks
+	Tilt *projection.TiltAnalysis
+	// Leaderboard lists the best and worst round trips
+	Leaderboard *projection.TradeLeaderboard
+	// Activity breaks trades and P/L down by weekday and hour
+	Activity *projection.ActivityStats
+	// Volume is how much was traded per underlying
+	Volume *projection.TradeVolume
+	// Rolling holds the P/L, win rate and volume over trailing windows
+	Rolling *projection.RollingStats
+	// DayTrades lists day trades and flags pattern day trading
+	DayTrades *projection.PatternDayTrading
+	// OptionStrategies groups option legs into the strategies they make up
+	OptionStrategies *projection.OptionStrategies
+	// OptionRolls chains rolled option positions into campaigns
+	OptionRolls *projection.OptionRolls
+	// Premium summarizes the option premium collected and paid
+	Premium *projection.PremiumIncome
+	// CoveredCalls combines covered calls with the shares they're written on
+	CoveredCalls *projection.CoveredCalls
+	// ShortOptions lists the open short options and their obligations
+	ShortOptions *projection.ShortOptionExposure
+	// Expirations lists the upcoming expirations of the open options
+	Expirations *projection.ExpirationCalendar
+	// Unrealized values the open positions at current prices
+	Unrealized *projection.UnrealizedPL `json:",omitempty"`
+	// Harvest lists the open lots whose losses could be harvested
+	Harvest *projection.HarvestCandidates `json:",omitempty"`
+	// SymbolLeaderboard ranks the underlying symbols by realized and
+	// unrealized P/L
+	SymbolLeaderboard *projection.SymbolLeaderboard
+	// Allocation breaks the open positions down by sector, industry and
+	// asset class
+	Allocation *projection.Allocation
+	// Sizing holds each position's share of the portfolio and the size of
+	// the positions taken in each symbol
+	Sizing *projection.PositionSizing
+	// NAV is the daily value of the portfolio
+	NAV *projection.NAVSeries `json:",omitempty"`
+	// Drawdown is the equity curve and its drawdowns
+	Drawdown *projection.Drawdown `json:",omitempty"`
+	// Turnover holds the portfolio turnover of each year
+	Turnover *projection.PortfolioTurnover `json:",omitempty"`
+	// TWR holds the time weighted return of each return period
+	TWR *projection.TimeWeightedReturns `json:",omitempty"`
+	// MWR holds the money weighted return (XIRR) of each return period
+	// across every account, and AccountMWR those of each account
+	MWR        *projection.MoneyWeightedReturns            `json:",omitempty"`
+	AccountMWR map[string]*projection.MoneyWeightedReturns `json:",omitempty"`
+	// Benchmark compares the returns to the benchmark symbol
+	Benchmark *projection.Benchmark `json:",omitempty"`
+	// Beta holds the beta and correlation of the daily returns to the
+	// benchmark
+	Beta *projection.Beta `json:",omitempty"`
+	// Risk holds the volatility, Sharpe and Sortino ratios of the daily
+	// returns
+	Risk *projection.RiskRatios `json:",omitempty"`
+	// ScheduleD summarizes the capital gains of each tax year
+	ScheduleD *projection.ScheduleD
+	// WashSaleCarryover follows the losses deferred by wash sales across
+	// tax years, when wash sales are being detected
+	WashSaleCarryover *projection.WashSaleCarryover `json:",omitempty"`
+	// UKGains holds capital gains under the UK share matching rules, when
+	// the jurisdiction is the UK
+	UKGains *projection.UKCapitalGains `json:",omitempty"`
+	// CanadaGains holds capital gains under the Canadian ACB rules, when
+	// the jurisdiction is Canada
+	CanadaGains *projection.CanadaCapitalGains `json:",omitempty"`
+	// EstimatedTax holds the estimated quarterly tax payments
+	EstimatedTax *projection.EstimatedTax `json:",omitempty"`
+	// Reconciliation compares the realized gains to the broker's 1099-B
+	Reconciliation *projection.Reconciliation `json:",omitempty"`
+	// Metrics holds the values of the custom metrics in the configs
+	Metrics []*query.MetricResult `json:",omitempty"`
+	// Accounts holds the results for each individual account when
+	// grouping by account
+	Accounts map[string]*report `json:",omitempty"`
+	// Tags holds the results for the transactions with each tag when
+	// grouping by tag
+	Tags map[string]*report `json:",omitempty"`
+	// Periods holds the results for the transactions made in each period
+	// when grouping by period
+	Periods map[string]*report `json:",omitempty"`
+}
+
+// newReport runs every analysis over the transactions. when the configs
+// group by account, each account is also analyzed on its own.
+func newReport(c *config, opts *lots.Options, transactions []*trade.Trade) *report {
+	tradingTransactions := filterTradingTransactions(c, transactions)
+	groupedSymbols := groupSymbols(tradingTransactions)
+
+	relatedSymbols := groupRelatedSymbols(groupedSymbols)
+
+	cb := getEffectiveCostBasis(relatedSymbols, groupedSymbols)
+	r := report{
+		Stats:    newTransactionStats(cb),
+		Interest: projection.NewInterestSummary(transactions),
+		Realized: projection.NewRealizedPL(tradingTransactions, opts),
+		TaxLots:  projection.NewTaxLots(tradingTransactions, opts),
+	}
+	r.Fees = projection.NewFeeAudit(transactions, r.Realized)
+	r.Dividends = projection.NewDividendIncome(transactions, asOfDate(c))
+	r.ScheduleD = projection.NewScheduleD(tradingTransactions, opts)
+	if opts.WashSale != nil {
+		r.WashSaleCarryover = projection.NewWashSaleCarryover(tradingTransactions, opts)
+	}
+	r.Positions = projection.NewPositions(tradingTransactions, opts, asOfDate(c))
+	r.YieldOnCost = projection.NewYieldOnCost(r.Dividends, r.Positions)
+	r.ShortOptions = projection.NewShortOptionExposure(r.Positions)
+	r.Expirations = projection.NewExpirationCalendar(r.Positions)
+	r.Cash = projection.NewCashBalances(transactions, c.statements)
+	r.Contributions = projection.NewContributions(transactions)
+	r.RoundTrips = projection.NewRoundTrips(tradingTransactions, asOfDate(c))
+	r.RoundTrips.Annotate(c.journal)
+	r.Performance = projection.NewPerformanceStats(r.RoundTrips)
+	r.HoldingPeriods = projection.NewHoldingPeriods(r.RoundTrips)
+	r.Tilt = projection.NewTiltAnalysis(r.RoundTrips)
+	leaderboardSize := c.LeaderboardSize
+	if leaderboardSize <= 0 {
+		leaderboardSize = projection.DefaultLeaderboardSize
+	}
+	r.Leaderboard = projection.NewTradeLeaderboard(r.RoundTrips, leaderboardSize)
+	r.Activity = projection.NewActivityStats(tradingTransactions, r.RoundTrips)
+	r.Volume = projection.NewTradeVolume(tradingTransactions)
+	rollingWindows := c.RollingWindows
+	if len(rollingWindows) == 0 {
+		rollingWindows = projection.DefaultRollingWindows
+	}
+	r.Rolling = projection.NewRollingStats(tradingTransactions, r.RoundTrips, rollingWindows)
+	r.DayTrades = projection.NewPatternDayTrading(tradingTransactions)
+	r.OptionStrategies = projection.NewOptionStrategies(tradingTransactions, opts)
+	r.OptionRolls = projection.NewOptionRolls(r.RoundTrips)
+	r.Premium = projection.NewPremiumIncome(tradingTransactions, opts)
+	r.CoveredCalls = projection.NewCoveredCalls(tradingTransactions, opts, r.OptionStrategies)
+	if opts.Jurisdiction != nil {
+		switch opts.Jurisdiction.Name() {
+		case "UK":
+			r.UKGains = projection.NewUKCapitalGains(tradingTransactions, opts)
+		case "CA":
+			r.CanadaGains = projection.NewCanadaCapitalGains(tradingTransactions, opts)
+		}
+	}
+	if c.EstimatedTax != nil {
+		r.EstimatedTax = projection.NewEstimatedTax(transactions, opts, c.EstimatedTax)
+	}
+	for i := 0; i < len(c.metrics); i++ {
+		r.Metrics = append(r.Metrics, c.metrics[i].Evaluate(transactions))
+	}
+
+	sub := subReportConfigs(c)
+	if c.GroupByAccount {
+		r.Accounts = make(map[string]*report)
+		byAccount := projection.GroupByAccount(transactions)
+		for account, accountTransactions := range byAccount {
+			r.Accounts[account] = newReport(sub, opts, accountTransactions)
+		}
+	}
+
+	if c.GroupByTag {
+		r.Tags = make(map[string]*report)
+		byTag := projection.GroupByTag(transactions)
+		for tag, tagTransactions := range byTag {
+			r.Tags[tag] = newReport(sub, opts, tagTransactions)
+		}
+	}
+
+	if c.bucketer != nil {
+		r.Periods = make(map[string]*report)
+		// gains are matched against every earlier purchase, not just the
+		// ones made in the period
+		realized := projection.RealizedByPeriod(tradingTransactions, opts, c.bucketer)
+		byPeriod := projection.GroupByPeriod(transactions, c.bucketer)
+		for period, periodTransactions := range byPeriod {
+			pr := newReport(sub, opts, periodTransactions)
+			if realized[period] != nil {
+				pr.Realized = realized[period]
+			}
+			r.Periods[period] = pr
+		}
+	}
+	return &r
+}
+
+// subReportConfigs returns the configs the account, tag and period
+// reports are built with. every grouping is turned off so the sub-reports
+// don't nest, and everything else is shared with the top-level report.
+func subReportConfigs(c *config) *config {
+	sub := *c
+	sub.GroupByAccount = false
+	sub.GroupByTag = false
+	sub.bucketer = nil
+	return &sub
+}
+
+// asOfDateFormat is the format of the as of date in the configs
+const asOfDateFormat = "2006-01-02"
+
+// asOfDate returns the date positions are reported as of. the as of date
+// must already have been validated by parseAsOf.
+func asOfDate(c *config) time.Time {
+	if c.AsOf == "" {
+		return time.Now()
+	}
+	asOf, _ := time.Parse(asOfDateFormat, c.AsOf)
+	return asOf
+}
+
+// parseAsOf applies the --as-of flag to the configs and checks the date
+// is valid
+func parseAsOf(c *config, flagValue string) error {
+	if flagValue != "" {
+		c.AsOf = flagValue
+	}
+	if c.AsOf == "" {
+		return nil
+	}
+	_, err := time.Parse(asOfDateFormat, c.AsOf)
+	return err
+}
+
+// parseBaseCurrency defaults the base currency to the currency of the
+// jurisdiction, so gains are computed in the currency they are taxed in
+func parseBaseCurrency(c *config) error {
+	if c.BaseCurrency != "" {
+		return nil
+	}
+	jurisdiction, err := lots.LookupJurisdiction(c.Jurisdiction)
+	if err != nil {
+		return err
+	}
+	c.BaseCurrency = jurisdiction.Currency()
+	return nil
+}
+
+// parseGroupByPeriod parses the period results are grouped by in the
+// configs, if any
+func parseGroupByPeriod(c *config) error {
+	if c.GroupByPeriod == "" {
+		return nil
+	}
+	b, err := projection.NewBucketer(c.GroupByPeriod, c.FiscalYearStart)
+	if err != nil {
+		return err
+	}
+	c.bucketer = b
+	return nil
+}
+
+// parseMetrics parses the custom metrics in the configs
+func parseMetrics(c *config) error {
+	c.metrics = make([]*query.Metric, 0, len(c.Metrics))
+	for i := 0; i < len(c.Metrics); i++ {
+		m, err := query.ParseMetric(c.Metrics[i].Name, c.Metrics[i].Expression)
+		if err != nil {
+			return fmt.Errorf("metric %q: %v", c.Metrics[i].Name, err)
+		}
+		c.metrics = append(c.metrics, m)
+	}
+	return nil
+}
+
+// parseStatementBalances parses the statement balances in the configs
+func parseStatementBalances(c *config) error {
+	c.statements = make([]*projection.StatementBalance, 0, len(c.StatementBalances))
+	for i := 0; i < len(c.StatementBalances); i++ {
+		s := c.StatementBalances[i]
+		date, err := time.Parse(asOfDateFormat, s.Date)
+		if err != nil {
+			return err
+		}
+		c.statements = append(c.statements, &projection.StatementBalance{
+			Account: s.Account,
+			Date:    date,
+			Balance: big.NewFloat(s.Balance),
+		})
+	}
+	return nil
+}
+
+// quoteProvider returns the quote provider specified in the configs, or
+// nil if positions aren't being priced
+func quoteProvider(c *config) (quotes.Provider, error) {
+	if c.Quotes == nil {
+		return nil, nil
+	}
+	// positions as of a past date are valued at the closes on that date
+	if c.AsOf != "" {
+		history, err := priceHistory(c)
+		if err != nil {
+			return nil, err
+		}
+		if history != nil {
+			return quotes.NewCached(quotes.NewHistorical(history, asOfDate(c))), nil
+		}
+	}
+	switch c.Quotes.Provider {
+	case "file":
+		f, err := quotes.LoadFile(c.Quotes.File)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	case "stooq", "":
+		suffix := c.Quotes.Suffix
+		if suffix == "" {
+			suffix = ".us"
+		}
+		return quotes.NewCached(quotes.NewStooq(suffix)), nil
+	}
+	return nil, fmt.Errorf("unsupported quote provider %q", c.Quotes.Provider)
+}
+
+// inception returns the date of the first transaction
+func inception(transactions []*trade.Trade) time.Time {
+	var first time.Time
+	for i := 0; i < len(transactions); i++ {
+		if first.IsZero() || transactions[i].Date.Before(first) {
+			first = transactions[i].Date
+		}
+	}
+	return first
+}
+
+// returnPeriods returns the periods returns are measured over, as
+// specified in the configs
+func returnPeriods(c *config, inception time.Time) ([]*projection.ReturnPeriod, error) {
+	if len(c.ReturnPeriods) == 0 {
+		return projection.StandardPeriods(inception, asOfDate(c)), nil
+	}
+	periods := make([]*projection.ReturnPeriod, 0, len(c.ReturnPeriods))
+	for i := 0; i < len(c.ReturnPeriods); i++ {
+		p := projection.ReturnPeriod{Name: c.ReturnPeriods[i].Name, To: asOfDate(c)}
+		var err error
+		if p.From, err = time.Parse(asOfDateFormat, c.ReturnPeriods[i].From); err != nil {
+			return nil, fmt.Errorf("return period %q: %v", p.Name, err)
+		}
+		if c.ReturnPeriods[i].To != "" {
+			if p.To, err = time.Parse(asOfDateFormat, c.ReturnPeriods[i].To); err != nil {
+				return nil, fmt.Errorf("return period %q: %v", p.Name, err)
+			}
+		}
+		periods = append(periods, &p)
+	}
+	return periods, nil
+}
+
+// priceHistory returns the price history specified in the configs, or nil
+// if there isn't one
+func priceHistory(c *config) (quotes.History, error) {
+	if c.Quotes == nil {
+		return nil, nil
+	}
+	switch c.Quotes.Provider {
+	case "file":
+		if c.Quotes.HistoryFile == "" {
+			return nil, nil
+		}
+		h, err := quotes.LoadHistoryFile(c.Quotes.HistoryFile)
+		if err != nil {
+			return nil, err
+		}
+		return h, nil
+	case "stooq", "":
+		suffix := c.Quotes.Suffix
+		if suffix == "" {
+			suffix = ".us"
+		}
+		return quotes.NewStooqHistory(suffix), nil
+	}
+	return nil, fmt.Errorf("unsupported quote provider %q", c.Quotes.Provider)
+}
+
+// lotOptions returns the options used to match transactions against open
+// lots, as specified in the configs
+func lotOptions(c *config) (*lots.Options, error) {
+	jurisdiction, err := lots.LookupJurisdiction(c.Jurisdiction)
+	if err != nil {
+		return nil, err
+	}
+	method, err := lots.ParseMethod(string(c.CostBasisMethod))
+	if err != nil {
+		return nil, err
+	}
+	opts := lots.Options{
+		Method:             method,
+		Accounts:           make(map[string]lots.Method),
+		AssetClasses:       make(map[trade.AssetClass]lots.Method),
+		Jurisdiction:       jurisdiction,
+		RetirementAccounts: make(map[string]bool),
+	}
+	for class, name := range c.AssetClassCostBasisMethods {
+		m, err := lots.ParseMethod(string(name))
+		if err != nil {
+			return nil, err
+		}
+		opts.AssetClasses[class] = m
+	}
+	for i := 0; i < len(c.Accounts); i++ {
+		a := c.Accounts[i]
+		m, err := lots.ParseMethod(string(a.CostBasisMethod))
+		if err != nil {
+			return nil, err
+		}
+		if m != "" {
+			opts.Accounts[a.Name] = m
+		}
+		if a.Retirement {
+			opts.RetirementAccounts[a.Name] = true
+		}
+	}
+	if c.SpecificLotsFile != "" {
+		specificLots, err := lots.LoadSpecificLots(c.SpecificLotsFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.SpecificLots = specificLots
+	}
+	if c.WashSale != nil {
+		opts.WashSale = &lots.WashSaleRule{
+			WindowDays:     c.WashSale.WindowDays,
+			IncludeOptions: c.WashSale.IncludeOptions,
+			CrossAccount:   c.WashSale.CrossAccount,
+		}
+	}
+	return &opts, nil
+}
+
+// writeFile creates the file at path and writes it with write
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeTaxForms writes Form 8949 and the TXF export to the files
+// specified in the configs
+func writeTaxForms(c *config, opts *lots.Options, transactions []*trade.Trade) error {
+	form := projection.NewForm8949(filterTradingTransactions(c, transactions), opts)
+	if c.Form8949File != "" {
+		write := form.WriteCSV
+		if strings.HasSuffix(strings.ToLower(c.Form8949File), ".pdf") {
+			write = form.WritePDF
+		}
+		if err := writeFile(c.Form8949File, write); err != nil {
+			return err
+		}
+	}
+	if c.TXFFile != "" {
+		return writeFile(c.TXFFile, func(w io.Writer) error {
+			return form.WriteTXF(w, time.Now())
+		})
+	}
+	return nil
+}
+
+// writeBooks writes the transactions as ledger, beancount and GnuCash
+// journal entries to the files specified in the configs
+func writeBooks(c *config, opts *lots.Options, transactions []*trade.Trade) error {
+	journal := books.NewJournal(transactions, filterTradingTransactions(c, transactions), opts, c.BookAccounts, c.BaseCurrency)
+	if c.LedgerFile != "" {
+		if err := writeFile(c.LedgerFile, journal.WriteLedger); err != nil {
+			return err
+		}
+	}
+	if c.BeancountFile != "" {
+		if err := writeFile(c.BeancountFile, journal.WriteBeancount); err != nil {
+			return err
+		}
+	}
+	if c.GnuCashFile != "" {
+		write := journal.WriteGnuCashCSV
+		if strings.HasSuffix(strings.ToLower(c.GnuCashFile), ".qif") {
+			write = journal.WriteQIF
+		}
+		return writeFile(c.GnuCashFile, write)
+	}
+	return nil
+}
+
+// tagTransactions attaches tags to the transactions from the tag rules
+// and tags files specified in the configs.
+func tagTransactions(c *config, transactions []*trade.Trade) error {
+	if c.TagRulesFile != "" {
+		rules, err := trade.LoadTagRules(c.TagRulesFile)
+		if err != nil {
+			return err
+		}
+		trade.ApplyTagRules(transactions, rules)
+	}
+	if c.TagsFile != "" {
+		if err := trade.ApplyTagsFile(transactions, c.TagsFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runGains runs the gains command, printing the gains realized so far this
+// tax year and the lots about to turn long term
+func runGains(c *config, opts *lots.Options, transactions []*trade.Trade, args []string) error {
+	flags := flag.NewFlagSet("gains", flag.ExitOnError)
+	ytd := flags.Bool("ytd", false, "report the gains realized so far this tax year")
+	within := flags.Int("within", projection.DefaultLongTermWindow, "days ahead to list open lots turning long term")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	// guard clause: year to date is the only report so far
+	if !*ytd {
+		return fmt.Errorf("usage: gains --ytd [--within days]")
+	}
+	provider, err := quoteProvider(c)
+	if err != nil {
+		return err
+	}
+	gains := projection.NewYearToDateGains(filterTradingTransactions(c, transactions), opts, asOfDate(c), provider, *within)
+	return writeOutput(c, gains)
+}
+
+// statementFile is the name of the statement written to the output
+// directory when the statement command isn't given a file
+const statementFile = "statement.pdf"
+
+// runStatement runs the statement command, writing a pdf statement of the
+// period chosen by the command line arguments
+func runStatement(c *config, opts *lots.Options, transactions []*trade.Trade, args []string) error {
+	flags := flag.NewFlagSet("statement", flag.ExitOnError)
+	from := flags.String("from", "", "first day of the period in YYYY-MM-DD format, defaults to the start of the year")
+	to := flags.String("to", "", "last day of the period in YYYY-MM-DD format, defaults to the as of date")
+	file := flags.String("file", "", "path to write the pdf to, defaults to statement.pdf in the output directory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	period := projection.DateRange{To: asOfDate(c)}
+	if *to != "" {
+		date, err := time.Parse(asOfDateFormat, *to)
+		if err != nil {
+			return err
+		}
+		period.To = date
+	}
+	period.From = time.Date(period.To.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	if *from != "" {
+		date, err := time.Parse(asOfDateFormat, *from)
+		if err != nil {
+			return err
+		}
+		period.From = date
+	}
+	// guard clause: the period has to run forwards
+	if period.To.Before(period.From) {
+		return fmt.Errorf("the period ends on %s, before it starts on %s", period.To.Format(asOfDateFormat), period.From.Format(asOfDateFormat))
+	}
+
+	path := *file
+	if path == "" {
+		if err := os.MkdirAll(c.OutDir, 0755); err != nil {
+			return err
+		}
+		path = filepath.Join(c.OutDir, statementFile)
+	}
+	statement := projection.NewStatement(transactions, filterTradingTransactions(c, transactions), opts, period)
+	return writeFile(path, statement.WritePDF)
+}
+
+// runWhatIf prints the headline stats with and without the trades chosen
+// by the command line arguments
+func runWhatIf(c *config, opts *lots.Options, transactions []*trade.Trade, args []string) error {
+	flags := flag.NewFlagSet("whatif", flag.ExitOnError)
+	symbols := flags.String("symbols", "", "comma separated symbols to leave out, with their options")
+	strategies := flags.String("strategies", "", "comma separated option strategies to leave out, eg IRON_CONDOR")
+	between := flags.String("between", "", "comma separated date ranges in YYYY-MM-DD:YYYY-MM-DD format whose round trips are left out")
+	exclude := flags.String("exclude", "", "condition on the transactions to leave out")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	// guard clause: something has to be left out
+	if *symbols == "" && *strategies == "" && *between == "" && *exclude == "" {
+		return fmt.Errorf("usage: whatif [--symbols list] [--strategies list] [--between ranges] [--exclude condition]")
+	}
+
+	e := projection.Exclusion{}
+	if *symbols != "" {
+		names := strings.Split(*symbols, ",")
+		for i := 0; i < len(names); i++ {
+			e.Symbols = append(e.Symbols, strings.TrimSpace(names[i]))
+		}
+	}
+	if *strategies != "" {
+		names := strings.Split(*strategies, ",")
+		for i := 0; i < len(names); i++ {
+			e.Strategies = append(e.Strategies, projection.StrategyType(strings.ToUpper(strings.TrimSpace(names[i]))))
+		}
+	}
+	if *between != "" {
+		ranges := strings.Split(*between, ",")
+		for i := 0; i < len(ranges); i++ {
+			dates := strings.Split(ranges[i], ":")
+			if len(dates) != 2 {
+				return fmt.Errorf("invalid date range %q", ranges[i])
+			}
+			from, err := time.Parse(asOfDateFormat, strings.TrimSpace(dates[0]))
+			if err != nil {
+				return err
+			}
+			to, err := time.Parse(asOfDateFormat, strings.TrimSpace(dates[1]))
+			if err != nil {
+				return err
+			}
+			e.Ranges = append(e.Ranges, projection.DateRange{From: from, To: to})
+		}
+	}
+	if *exclude != "" {
+		condition, err := query.ParseCondition(*exclude)
+		if err != nil {
+			return err
+		}
+		e.Where = condition.Match
+	}
+
+	w := projection.NewWhatIf(filterTradingTransactions(c, transactions), opts, asOfDate(c), &e)
+	return writeOutput(c, w)
+}
+
 func main() {
+	asOf := flag.String("as-of", "", "date in YYYY-MM-DD format to replay the transactions through and report as of, defaults to today")
+	output := flag.String("output", "", "format results are written in: json, csv, xlsx, html, markdown, table, chart, png, svg, template or jsonl")
+	outDir := flag.String("out-dir", "", "directory the csv, xlsx, html, png and svg outputs write files to, reports by default")
+	columns := flag.String("columns", "", "comma separated columns the table output is limited to, eg Symbol,Gain")
+	color := flag.String("color", "", "when the table output is colorized: auto, always or never")
+	where := flag.String("where", "", "condition transactions must meet to be analyzed, eg 'symbol == \"AAPL\" && date >= \"2023-01-01\"'")
+	flag.Parse()
+
 	configs, err := getConfigs()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config.json: %v\n", err)
 		fmt.Printf("Using default configurations\n")
 	}
+	if err := parseAsOf(configs, *asOf); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing as of date: %v", err)
+		os.Exit(1)
+	}
+	if err := parseOutput(configs, *output, *outDir, *columns, *color); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing output format: %v", err)
+		os.Exit(1)
+	}
+	if err := parseBaseCurrency(configs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing base currency: %v", err)
+		os.Exit(1)
+	}
+	if err := parseStatementBalances(configs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing statement balances: %v", err)
+		os.Exit(1)
+	}
+	if err := parseGroupByPeriod(configs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing group by period: %v", err)
+		os.Exit(1)
+	}
+	if err := parseMetrics(configs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing metrics: %v", err)
+		os.Exit(1)
+	}
 
 	transactions, err := loadTransactions(configs)
 	if err != nil {
@@ -332,17 +1404,220 @@ func main() {
 		os.Exit(1)
 	}
 
-	groupedSymbols := groupSymbols(transactions)
+	rates := trade.FXRates{}
+	if configs.FXRatesFile != "" {
+		if rates, err = trade.LoadFXRates(configs.FXRatesFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading exchange rates: %v", err)
+			os.Exit(1)
+		}
+	}
+	if err := trade.ConvertToBase(transactions, configs.BaseCurrency, rates); err != nil {
+		fmt.Fprintf(os.Stderr, "Error converting to %v: %v", configs.BaseCurrency, err)
+		os.Exit(1)
+	}
 
-	relatedSymbols := groupRelatedSymbols(groupedSymbols)
+	trade.ApplySymbolRenames(transactions, configs.SymbolRenames)
+	trade.ApplyDividendOverrides(transactions, configs.DividendOverrides)
 
-	cb := getEffectiveCostBasis(relatedSymbols, groupedSymbols)
-	stats := newTransactionStats(cb)
-	jsonStats, err := json.Marshal(stats)
+	if err := trade.ApplySplits(transactions, configs.Splits); err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying splits: %v", err)
+		os.Exit(1)
+	}
+	transactions, err = trade.ApplySpinOffs(transactions, configs.SpinOffs)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error serializing output: %v", err)
-		os.Exit(2)
+		fmt.Fprintf(os.Stderr, "Error applying spin-offs: %v", err)
+		os.Exit(1)
+	}
+	trade.LinkOptionEvents(transactions)
+	trade.DetectReinvestments(transactions)
+
+	if err := tagTransactions(configs, transactions); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading tags: %v", err)
+		os.Exit(1)
+	}
+	transactions = projection.FilterByTags(transactions, configs.FilterTags)
+	if configs.AsOf != "" {
+		transactions = projection.FilterThrough(transactions, asOfDate(configs))
+	}
+	if *where != "" {
+		condition, err := query.ParseCondition(*where)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing where condition: %v", err)
+			os.Exit(1)
+		}
+		transactions = condition.Filter(transactions)
+	}
+
+	if configs.JournalFile != "" {
+		j, err := trade.LoadTradeJournal(configs.JournalFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading journal: %v", err)
+			os.Exit(1)
+		}
+		j.Annotate(transactions)
+		configs.journal = j
+	}
+
+	opts, err := lotOptions(configs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error configuring cost basis: %v", err)
+		os.Exit(1)
 	}
 
-	fmt.Fprintf(os.Stdout, "%v", string(jsonStats))
+	if flag.Arg(0) == "gains" {
+		if err := runGains(configs, opts, transactions, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running gains: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "whatif" {
+		if err := runWhatIf(configs, opts, transactions, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running what-if: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "statement" {
+		if err := runStatement(configs, opts, transactions, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing statement: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "tui" {
+		if err := runTUI(configs, opts, transactions, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running tui: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if configs.Form8949File != "" || configs.TXFFile != "" {
+		if err := writeTaxForms(configs, opts, transactions); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing tax forms: %v", err)
+			os.Exit(1)
+		}
+	}
+	if configs.LedgerFile != "" || configs.BeancountFile != "" || configs.GnuCashFile != "" {
+		if err := writeBooks(configs, opts, transactions); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing books: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	if configs.Output == jsonlOutput {
+		if err := writeEvents(os.Stdout, configs, opts, transactions); err != nil {
+			fmt.Fprintf(os.Stderr, "Error streaming events: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(configs.Projections) > 0 {
+		if err := runProjections(configs, opts, transactions); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running projections: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	r := newReport(configs, opts, transactions)
+	provider, err := quoteProvider(configs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading quotes: %v", err)
+		os.Exit(1)
+	}
+	if provider != nil {
+		r.Unrealized = projection.NewUnrealizedPL(r.Positions, provider)
+		r.Contributions.SetValue(big.NewFloat(0.0).Add(r.Cash.Current, r.Unrealized.MarketValue))
+		harvestThreshold := configs.HarvestThreshold
+		if harvestThreshold <= 0 {
+			harvestThreshold = projection.DefaultHarvestThreshold
+		}
+		r.Harvest = projection.NewHarvestCandidates(filterTradingTransactions(configs, transactions), r.Positions, provider, opts, harvestThreshold)
+	}
+	r.SymbolLeaderboard = projection.NewSymbolLeaderboard(filterTradingTransactions(configs, transactions), r.Realized, r.Unrealized)
+	metadata := make(map[string]*projection.SymbolMetadata)
+	if configs.SymbolMetadataFile != "" {
+		metadata, err = projection.LoadSymbolMetadata(configs.SymbolMetadataFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading symbol metadata: %v", err)
+			os.Exit(1)
+		}
+	}
+	threshold := configs.ConcentrationThreshold
+	if threshold <= 0 {
+		threshold = projection.DefaultConcentrationThreshold
+	}
+	r.Allocation = projection.NewAllocation(r.Positions, r.Unrealized, metadata, threshold)
+	r.Sizing = projection.NewPositionSizing(r.Positions, r.Unrealized, r.Cash, r.RoundTrips)
+	history, err := priceHistory(configs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading price history: %v", err)
+		os.Exit(1)
+	}
+	if history != nil {
+		r.NAV = projection.NewNAVSeries(transactions, history, asOfDate(configs))
+		r.Drawdown = projection.NewDrawdown(r.NAV)
+		if len(r.NAV.Points) > 0 {
+			r.Contributions.SetValue(r.NAV.Points[len(r.NAV.Points)-1].NAV)
+		}
+		r.Turnover = projection.NewPortfolioTurnover(filterTradingTransactions(configs, transactions), r.NAV)
+		periods, err := returnPeriods(configs, inception(transactions))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing return periods: %v", err)
+			os.Exit(1)
+		}
+		r.TWR = projection.NewTimeWeightedReturns(r.NAV, periods)
+		r.MWR = projection.NewMoneyWeightedReturns(r.NAV, periods)
+		byAccount := projection.GroupByAccount(transactions)
+		if len(byAccount) > 1 {
+			r.AccountMWR = make(map[string]*projection.MoneyWeightedReturns)
+			for account, accountTransactions := range byAccount {
+				nav := projection.NewNAVSeries(accountTransactions, history, asOfDate(configs))
+				r.AccountMWR[account] = projection.NewMoneyWeightedReturns(nav, periods)
+			}
+		}
+		if configs.DrawdownChartFile != "" {
+			if err := writeFile(configs.DrawdownChartFile, r.Drawdown.WriteSVG); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing drawdown chart: %v", err)
+				os.Exit(1)
+			}
+		}
+		benchmark := configs.Benchmark
+		if benchmark == "" {
+			benchmark = projection.DefaultBenchmark
+		}
+		r.Benchmark = projection.NewBenchmark(r.NAV, history, benchmark, periods)
+		r.Beta = projection.NewBeta(r.NAV, r.Positions, history, benchmark)
+		r.Risk = projection.NewRiskRatios(r.NAV, configs.RiskFreeRate)
+		if configs.BenchmarkChartFile != "" {
+			if err := writeFile(configs.BenchmarkChartFile, r.Benchmark.WriteSVG); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing benchmark chart: %v", err)
+				os.Exit(1)
+			}
+		}
+	}
+	if configs.Form1099BFile != "" {
+		reported, err := projection.Load1099B(configs.Form1099BFile, configs.Form1099BAccount)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading 1099-B: %v", err)
+			os.Exit(1)
+		}
+		r.Reconciliation = projection.NewReconciliation(filterTradingTransactions(configs, transactions), opts, reported)
+	}
+	if configs.ExpirationCalendarFile != "" {
+		err := writeFile(configs.ExpirationCalendarFile, func(w io.Writer) error {
+			return r.Expirations.WriteICal(w, time.Now())
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing expiration calendar: %v", err)
+			os.Exit(1)
+		}
+	}
+	if err := writeOutput(configs, r); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v", err)
+		os.Exit(2)
+	}
 }
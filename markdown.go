@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/stonks/table"
+)
+
+// markdownSummary names the tables of the report summarized in markdown,
+// in order. tables the report doesn't have, such as the unrealized P/L
+// without quotes, are left out.
+var markdownSummary = []string{
+	"performance_summary",
+	"realized_summary",
+	"realized_by_symbol",
+	"realized_by_year",
+	"unrealized_summary",
+	"positions",
+	"unrealized_positions",
+	"leaderboard_best",
+	"leaderboard_worst",
+}
+
+// writeMarkdown writes the results as markdown, for pasting into trade
+// journals and gists. the report is summarized by its positions, P/L and
+// best and worst trades, anything else is written as all of its tables.
+func writeMarkdown(w io.Writer, results interface{}) error {
+	tables := table.Tables(results)
+	if _, ok := results.(*report); ok {
+		byName := make(map[string]*table.Table, len(tables))
+		for i := 0; i < len(tables); i++ {
+			byName[tables[i].Name] = tables[i]
+		}
+		tables = make([]*table.Table, 0, len(markdownSummary))
+		for i := 0; i < len(markdownSummary); i++ {
+			if t := byName[markdownSummary[i]]; t != nil {
+				tables = append(tables, t)
+			}
+		}
+	}
+	if _, err := io.WriteString(w, "# Transaction analysis\n"); err != nil {
+		return err
+	}
+	for i := 0; i < len(tables); i++ {
+		if _, err := fmt.Fprintf(w, "\n## %s\n\n", markdownTitle(tables[i].Name)); err != nil {
+			return err
+		}
+		// guard clause: a table without rows is noted rather than drawn
+		if len(tables[i].Rows) == 0 {
+			if _, err := io.WriteString(w, "None\n"); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tables[i].WriteMarkdown(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdownTitle turns the snake case name of a table into a heading, eg
+// realized_by_symbol into Realized by symbol
+func markdownTitle(name string) string {
+	title := strings.Replace(name, "_", " ", -1)
+	if title == "" {
+		return title
+	}
+	return strings.ToUpper(title[:1]) + title[1:]
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/stonks/table"
+	"github.com/stonks/xlsx"
+)
+
+// outputFormat is the format results are written in
+type outputFormat string
+
+const (
+	// jsonOutput writes the results to stdout as a single indented json
+	// document
+	jsonOutput outputFormat = "json"
+	// csvOutput writes a csv file of each result, and of each list in it,
+	// to the output directory
+	csvOutput outputFormat = "csv"
+	// xlsxOutput writes a workbook with a sheet of each result's tables
+	// to the output directory
+	xlsxOutput outputFormat = "xlsx"
+	// htmlOutput writes a single html file with charts and sortable
+	// tables to the output directory
+	htmlOutput outputFormat = "html"
+	// markdownOutput writes a summary of tables to stdout as markdown
+	markdownOutput outputFormat = "markdown"
+	// tableOutput writes aligned tables to stdout for reading in a
+	// terminal, optionally colorized and limited to chosen columns
+	tableOutput outputFormat = "table"
+	// chartOutput draws charts of the report in the terminal
+	chartOutput outputFormat = "chart"
+	// pngOutput and svgOutput write image files of the charts of the
+	// report to the output directory, for embedding in other documents
+	pngOutput outputFormat = "png"
+	svgOutput outputFormat = "svg"
+	// templateOutput renders the results to stdout with the text/template
+	// the configs name
+	templateOutput outputFormat = "template"
+	// jsonlOutput streams the transactions and the lot closes and wash
+	// sales they cause to stdout as json lines, instead of the report
+	jsonlOutput outputFormat = "jsonl"
+)
+
+// defaultOutDir is where files are written by the formats that write them
+// when the configs don't say
+const defaultOutDir = "reports"
+
+// workbookFile is the name of the workbook written to the output directory
+const workbookFile = "report.xlsx"
+
+// parseOutput applies the --output, --out-dir, --columns and --color
+// flags to the configs and checks the format is supported
+func parseOutput(c *config, format string, outDir string, columns string, color string) error {
+	if format != "" {
+		c.Output = outputFormat(format)
+	}
+	if outDir != "" {
+		c.OutDir = outDir
+	}
+	if c.OutDir == "" {
+		c.OutDir = defaultOutDir
+	}
+	if columns != "" {
+		c.Columns = strings.Split(columns, ",")
+	}
+	if color != "" {
+		c.Color = colorMode(color)
+	}
+	if err := parseColor(c); err != nil {
+		return err
+	}
+	if c.Output == "" && c.Template != "" {
+		c.Output = templateOutput
+	}
+	switch c.Output {
+	case templateOutput:
+		if c.Template == "" {
+			return fmt.Errorf("the template output needs a template in the configs")
+		}
+		return nil
+	case "", jsonOutput, csvOutput, xlsxOutput, htmlOutput, markdownOutput, tableOutput, chartOutput, pngOutput, svgOutput, jsonlOutput:
+		return nil
+	}
+	return fmt.Errorf("unsupported output format %q", c.Output)
+}
+
+// writeOutput writes the results in the output format of the configs.
+// results is the report, the results of the projections keyed by name or
+// the result of a command.
+func writeOutput(c *config, results interface{}) error {
+	switch c.Output {
+	case csvOutput:
+		return writeTables(c.OutDir, table.Tables(results))
+	case xlsxOutput:
+		return writeWorkbook(c.OutDir, table.Sections(results))
+	case htmlOutput:
+		return writeHTML(c.OutDir, results)
+	case markdownOutput:
+		return writeMarkdown(os.Stdout, results)
+	case tableOutput:
+		return writeTerminal(os.Stdout, c, results)
+	case chartOutput:
+		return writeCharts(os.Stdout, c, results)
+	case pngOutput, svgOutput:
+		return writeImages(c.OutDir, c.Output, results)
+	case templateOutput:
+		return writeTemplate(os.Stdout, c.Template, results)
+	case jsonlOutput:
+		return fmt.Errorf("the jsonl output streams the transactions rather than the results of a command")
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// writeTables writes each table to a csv file named after it in the
+// directory, creating the directory if needed
+func writeTables(dir string, tables []*table.Table) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i := 0; i < len(tables); i++ {
+		if err := writeFile(filepath.Join(dir, tables[i].Name+".csv"), tables[i].WriteCSV); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeWorkbook writes a workbook of the results to the directory, with
+// a sheet for each result holding its tables one under another
+func writeWorkbook(dir string, sections []*table.Section) error {
+	w := xlsx.NewWorkbook()
+	for i := 0; i < len(sections); i++ {
+		tables := sections[i].Tables
+		// guard clause: nothing to show for the result
+		if len(tables) == 0 {
+			continue
+		}
+		sheet := w.AddSheet(sections[i].Name)
+		for j := 0; j < len(tables); j++ {
+			if j > 0 {
+				sheet.AddBlank()
+			}
+			sheet.AddTitle(tables[j].Name)
+			sheet.AddHeader(tables[j].Header)
+			first := sheet.Rows()
+			for k := 0; k < len(tables[j].Rows); k++ {
+				sheet.AddRow(tables[j].Rows[k], tables[j].Numeric)
+			}
+			for k := 0; k < len(tables[j].Header); k++ {
+				if tables[j].Numeric[k] && isPLColumn(tables[j].Header[k]) {
+					sheet.ColorBySign(k, first, sheet.Rows()-1)
+				}
+			}
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, workbookFile), w.Write)
+}
+
+// plWords are the words naming the columns of profits and losses
+var plWords = []string{"PL", "Gain", "Loss", "Realized", "Unrealized", "Impact", "Return", "Profit"}
+
+// isPLColumn returns true if the column holds profits and losses, which
+// are colored by their sign
+func isPLColumn(name string) bool {
+	// nested columns are named after their last field
+	name = name[strings.LastIndex(name, ".")+1:]
+	for i := 0; i < len(plWords); i++ {
+		if strings.Contains(name, plWords[i]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+// Package pdf writes simple text documents in the Portable Document Format
+// using only the standard library. every page is set in a single monospaced
+// font so tables line up by padding their columns with spaces.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	pageWidth    = 792 // US letter in landscape, in points
+	pageHeight   = 612
+	margin       = 36
+	fontSize     = 8
+	lineHeight   = 10
+	linesPerPage = (pageHeight - 2*margin) / lineHeight
+)
+
+// Document is a PDF document made up of pages of text lines
+type Document struct {
+	// Footer is printed at the bottom of every page with its page number,
+	// unless blank
+	Footer string
+	pages  [][]string
+}
+
+// NewDocument returns an empty document
+func NewDocument() *Document {
+	return &Document{pages: make([][]string, 0)}
+}
+
+// AddPage adds the lines as a new page, continuing on more pages if there
+// are more lines than fit on one
+func (d *Document) AddPage(lines []string) {
+	for len(lines) > linesPerPage {
+		d.pages = append(d.pages, lines[:linesPerPage])
+		lines = lines[linesPerPage:]
+	}
+	d.pages = append(d.pages, lines)
+}
+
+// AddTable adds the rows as new pages, repeating the heading lines at the
+// top of each page the rows continue on
+func (d *Document) AddTable(heading []string, rows []string) {
+	perPage := linesPerPage - len(heading)
+	// guard clause: a heading too long to leave room for rows
+	if perPage <= 0 {
+		d.AddPage(append(append(make([]string, 0), heading...), rows...))
+		return
+	}
+	for {
+		n := perPage
+		if n > len(rows) {
+			n = len(rows)
+		}
+		page := append(append(make([]string, 0, len(heading)+n), heading...), rows[:n]...)
+		d.pages = append(d.pages, page)
+		rows = rows[n:]
+		if len(rows) == 0 {
+			return
+		}
+	}
+}
+
+// escape escapes the characters with special meaning in a PDF string
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s)
+}
+
+// content returns the content stream that draws the lines of a page, and
+// the footer below them if there is one
+func content(lines []string, footer string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", fontSize, lineHeight, margin, pageHeight-margin-fontSize)
+	for i := 0; i < len(lines); i++ {
+		fmt.Fprintf(&b, "(%s) '\n", escape(lines[i]))
+	}
+	b.WriteString("ET\n")
+	if footer != "" {
+		fmt.Fprintf(&b, "BT\n/F1 %d Tf\n%d %d Td\n(%s) Tj\nET\n", fontSize, margin, margin/2, escape(footer))
+	}
+	return b.Bytes()
+}
+
+// Write writes the document to w
+func (d *Document) Write(w io.Writer) error {
+	pages := d.pages
+	if len(pages) == 0 {
+		pages = [][]string{{}}
+	}
+
+	// objects are numbered from 1: the catalog, the page tree, the font,
+	// then a page and its content stream for each page
+	objects := make([]string, 0, 3+2*len(pages))
+	kids := make([]string, len(pages))
+	for i := 0; i < len(pages); i++ {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+	)
+	for i := 0; i < len(pages); i++ {
+		footer := ""
+		if d.Footer != "" {
+			footer = fmt.Sprintf("%s - Page %d of %d", d.Footer, i+1, len(pages))
+		}
+		stream := content(pages[i], footer)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pageWidth, pageHeight, 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(stream), stream),
+		)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i := 0; i < len(objects); i++ {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, objects[i])
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for i := 0; i < len(offsets); i++ {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offsets[i])
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,76 @@
+package projection
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/stonks/trade"
+)
+
+// ActivityBucket counts the trades made and the P/L of the round trips
+// closed in a day of the week or hour of the day
+type ActivityBucket struct {
+	Name       string
+	Trades     int // purchases and sales made
+	RoundTrips int // round trips closed
+	PL         *big.Float
+}
+
+// ActivityStats breaks trading activity down by when it happened
+type ActivityStats struct {
+	ByWeekday []*ActivityBucket // Monday through Sunday
+	// ByHour has a bucket per hour of the day, and is only reported when
+	// the broker exports the time of transactions
+	ByHour []*ActivityBucket `json:",omitempty"`
+}
+
+// NewActivityStats counts the trades and totals the P/L of the closed
+// round trips per day of the week and hour of the day. round trips are
+// counted when they close.
+func NewActivityStats(trans []*trade.Trade, trips *RoundTrips) *ActivityStats {
+	a := ActivityStats{ByWeekday: make([]*ActivityBucket, 7)}
+	for i := 0; i < 7; i++ {
+		// time.Weekday starts on Sunday
+		a.ByWeekday[i] = &ActivityBucket{Name: time.Weekday((i + 1) % 7).String(), PL: big.NewFloat(0.0)}
+	}
+	hours := make([]*ActivityBucket, 24)
+	for i := 0; i < 24; i++ {
+		hours[i] = &ActivityBucket{Name: fmt.Sprintf("%02d:00", i), PL: big.NewFloat(0.0)}
+	}
+
+	timed := false
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if !t.IsTrade() {
+			continue
+		}
+		a.ByWeekday[weekdayIndex(t.Date)].Trades++
+		hours[t.Date.Hour()].Trades++
+		timed = timed || hasTime(t.Date)
+	}
+	for i := 0; i < len(trips.Closed); i++ {
+		trip := trips.Closed[i]
+		d := a.ByWeekday[weekdayIndex(trip.Close)]
+		d.RoundTrips++
+		d.PL = d.PL.Add(d.PL, trip.PL)
+		h := hours[trip.Close.Hour()]
+		h.RoundTrips++
+		h.PL = h.PL.Add(h.PL, trip.PL)
+	}
+	if timed {
+		a.ByHour = hours
+	}
+	return &a
+}
+
+// weekdayIndex returns the index of the date's day of the week, starting
+// from Monday
+func weekdayIndex(date time.Time) int {
+	return (int(date.Weekday()) + 6) % 7
+}
+
+// hasTime returns true if the date has a time of day other than midnight
+func hasTime(date time.Time) bool {
+	return date.Hour() != 0 || date.Minute() != 0 || date.Second() != 0
+}
@@ -0,0 +1,194 @@
+package projection
+
+import (
+	"encoding/csv"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/stonks/trade"
+)
+
+// DefaultConcentrationThreshold is the percent of the portfolio above which
+// an allocation is flagged as concentrated by default
+const DefaultConcentrationThreshold = 25.0
+
+// unknownAllocation names the sector and industry of symbols without
+// metadata
+const unknownAllocation = "UNKNOWN"
+
+// SymbolMetadata classifies a symbol for allocation reporting
+type SymbolMetadata struct {
+	Sector     string
+	Industry   string
+	AssetClass trade.AssetClass // blank to use the class the symbol was traded as
+}
+
+// LoadSymbolMetadata reads symbol metadata from a csv file of symbol,
+// sector, industry and asset class rows. the asset class is optional, and
+// a header row is skipped if its first column is "symbol".
+func LoadSymbolMetadata(path string) (map[string]*SymbolMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	results := make(map[string]*SymbolMetadata)
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		symbol := column(record, 0)
+		// guard clause: skip blank and header rows
+		if symbol == "" || strings.EqualFold(symbol, "symbol") {
+			continue
+		}
+		results[symbol] = &SymbolMetadata{
+			Sector:     column(record, 1),
+			Industry:   column(record, 2),
+			AssetClass: trade.AssetClass(strings.ToUpper(column(record, 3))),
+		}
+	}
+	return results, nil
+}
+
+// AllocationSlice is the part of the portfolio in a sector, industry,
+// asset class or symbol
+type AllocationSlice struct {
+	Name    string
+	Value   *big.Float
+	Percent *big.Float // percent of the total value
+	// Concentrated is true when the slice is a larger percent of the
+	// portfolio than the concentration threshold
+	Concentrated bool
+}
+
+// Allocation breaks the open positions down by sector, industry and asset
+// class
+type Allocation struct {
+	Value        *big.Float // absolute value of every open position
+	BySector     []*AllocationSlice
+	ByIndustry   []*AllocationSlice
+	ByAssetClass []*AllocationSlice
+	BySymbol     []*AllocationSlice
+	// Concentrated lists the slices over the concentration threshold, eg
+	// "SECTOR TECHNOLOGY"
+	Concentrated []string
+}
+
+// NewAllocation totals the value of the open positions per sector,
+// industry, asset class and symbol and flags the slices over the
+// threshold percent. positions are valued at their market value when
+// unrealized has a price for them and at cost otherwise, and short
+// positions count by their absolute value. options and futures are
+// classified by the metadata of their underlying.
+func NewAllocation(positions *Positions, unrealized *UnrealizedPL, metadata map[string]*SymbolMetadata, threshold float64) *Allocation {
+	a := Allocation{Value: big.NewFloat(0.0), Concentrated: make([]string, 0)}
+	priced := make(map[string]*big.Float)
+	if unrealized != nil {
+		for i := 0; i < len(unrealized.Positions); i++ {
+			p := unrealized.Positions[i]
+			priced[p.Account+"|"+p.Symbol] = p.MarketValue
+		}
+	}
+
+	bySector := make(map[string]*big.Float)
+	byIndustry := make(map[string]*big.Float)
+	byAssetClass := make(map[string]*big.Float)
+	bySymbol := make(map[string]*big.Float)
+	for i := 0; i < len(positions.Positions); i++ {
+		p := positions.Positions[i]
+		value := p.Cost
+		if v, ok := priced[p.Account+"|"+p.Symbol]; ok {
+			value = v
+		}
+		value = big.NewFloat(0.0).Abs(value)
+
+		instrument := trade.NewInstrument(p.Symbol)
+		if len(p.Lots) > 0 && p.Lots[0].Opening != nil && p.Lots[0].Opening.Instrument != nil {
+			instrument = p.Lots[0].Opening.Instrument
+		}
+		sector, industry, class := unknownAllocation, unknownAllocation, string(instrument.Class)
+		m := metadata[p.Symbol]
+		if m == nil {
+			m = metadata[instrument.Underlying]
+		}
+		if m != nil {
+			if m.Sector != "" {
+				sector = m.Sector
+			}
+			if m.Industry != "" {
+				industry = m.Industry
+			}
+			if m.AssetClass != "" {
+				class = string(m.AssetClass)
+			}
+		}
+		addAllocation(bySector, sector, value)
+		addAllocation(byIndustry, industry, value)
+		addAllocation(byAssetClass, class, value)
+		addAllocation(bySymbol, p.Symbol, value)
+		a.Value = a.Value.Add(a.Value, value)
+	}
+
+	a.BySector = allocationSlices(bySector, a.Value, threshold)
+	a.ByIndustry = allocationSlices(byIndustry, a.Value, threshold)
+	a.ByAssetClass = allocationSlices(byAssetClass, a.Value, threshold)
+	a.BySymbol = allocationSlices(bySymbol, a.Value, threshold)
+	a.Concentrated = append(a.Concentrated, concentrated("SECTOR", a.BySector)...)
+	a.Concentrated = append(a.Concentrated, concentrated("INDUSTRY", a.ByIndustry)...)
+	a.Concentrated = append(a.Concentrated, concentrated("ASSET_CLASS", a.ByAssetClass)...)
+	a.Concentrated = append(a.Concentrated, concentrated("SYMBOL", a.BySymbol)...)
+	return &a
+}
+
+// addAllocation adds a value to the total of a slice
+func addAllocation(totals map[string]*big.Float, name string, value *big.Float) {
+	if totals[name] == nil {
+		totals[name] = big.NewFloat(0.0)
+	}
+	totals[name] = totals[name].Add(totals[name], value)
+}
+
+// allocationSlices returns the totals as percents of the whole, largest
+// first, flagging those over the threshold percent
+func allocationSlices(totals map[string]*big.Float, whole *big.Float, threshold float64) []*AllocationSlice {
+	results := make([]*AllocationSlice, 0, len(totals))
+	for name, value := range totals {
+		percent := percentOf(value, whole)
+		results = append(results, &AllocationSlice{
+			Name:         name,
+			Value:        value,
+			Percent:      percent,
+			Concentrated: percent.Cmp(big.NewFloat(threshold)) > 0,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if c := results[i].Value.Cmp(results[j].Value); c != 0 {
+			return c > 0
+		}
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
+
+// concentrated returns the names of the concentrated slices, prefixed by
+// the dimension they're in
+func concentrated(dimension string, slices []*AllocationSlice) []string {
+	results := make([]string, 0)
+	for i := 0; i < len(slices); i++ {
+		if slices[i].Concentrated {
+			results = append(results, dimension+" "+slices[i].Name)
+		}
+	}
+	return results
+}
@@ -0,0 +1,116 @@
+package projection
+
+import (
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/stonks/chart"
+	"github.com/stonks/quotes"
+)
+
+// DefaultBenchmark is the symbol the portfolio is compared to by default
+const DefaultBenchmark = "SPY"
+
+// BenchmarkPeriod compares the time weighted return of the portfolio to
+// the return of the benchmark over a period, as percents
+type BenchmarkPeriod struct {
+	Name      string
+	From      time.Time
+	To        time.Time
+	Portfolio *big.Float
+	Benchmark *big.Float
+	Alpha     *big.Float // portfolio return less benchmark return
+}
+
+// RelativePoint is the growth of 100 in the portfolio and in the benchmark
+// at the end of a day
+type RelativePoint struct {
+	Date      time.Time
+	Portfolio *big.Float
+	Benchmark *big.Float
+}
+
+// Benchmark compares the portfolio's performance to a benchmark symbol
+type Benchmark struct {
+	Symbol   string
+	Periods  []*BenchmarkPeriod
+	Relative []*RelativePoint // days the benchmark has a price for
+}
+
+// NewBenchmark compares the time weighted return of the value series to
+// the price return of the benchmark over each period, and tracks the
+// growth of 100 in both from the first day. periods the benchmark has no
+// prices for are left out.
+func NewBenchmark(nav *NAVSeries, history quotes.History, symbol string, periods []*ReturnPeriod) *Benchmark {
+	b := Benchmark{
+		Symbol:   symbol,
+		Periods:  make([]*BenchmarkPeriod, 0, len(periods)),
+		Relative: make([]*RelativePoint, 0, len(nav.Points)),
+	}
+	twr := NewTimeWeightedReturns(nav, periods)
+	for i := 0; i < len(twr.Periods); i++ {
+		p := twr.Periods[i]
+		// the benchmark starts from the close before the period began, as
+		// the portfolio does
+		first, _ := pointRange(nav.Points, &ReturnPeriod{From: p.From, To: p.To})
+		startDate := p.From
+		if first > 0 {
+			startDate = nav.Points[first-1].Date
+		}
+		start, err := history.Close(symbol, startDate)
+		if err != nil {
+			continue
+		}
+		end, err := history.Close(symbol, p.To)
+		if err != nil {
+			continue
+		}
+		ret := big.NewFloat(0.0).Quo(end, start)
+		ret = ret.Sub(ret, big.NewFloat(1.0))
+		ret = ret.Mul(ret, big.NewFloat(100))
+		b.Periods = append(b.Periods, &BenchmarkPeriod{
+			Name:      p.Name,
+			From:      p.From,
+			To:        p.To,
+			Portfolio: p.Return,
+			Benchmark: ret,
+			Alpha:     big.NewFloat(0.0).Sub(p.Return, ret),
+		})
+	}
+
+	index := growthIndex(nav.Points)
+	var base *big.Float
+	for i := 0; i < len(nav.Points); i++ {
+		price, err := history.Close(symbol, nav.Points[i].Date)
+		if err != nil {
+			continue
+		}
+		if base == nil {
+			base = big.NewFloat(0.0).Quo(price, index[i])
+		}
+		relative := big.NewFloat(0.0).Quo(price, base)
+		b.Relative = append(b.Relative, &RelativePoint{
+			Date:      nav.Points[i].Date,
+			Portfolio: big.NewFloat(0.0).Mul(index[i], big.NewFloat(100)),
+			Benchmark: relative.Mul(relative, big.NewFloat(100)),
+		})
+	}
+	return &b
+}
+
+// WriteSVG charts the growth of 100 in the portfolio against the benchmark
+func (b *Benchmark) WriteSVG(w io.Writer) error {
+	portfolio := chart.Line{Name: "Portfolio", Points: make([]chart.Point, len(b.Relative))}
+	benchmark := chart.Line{Name: b.Symbol, Points: make([]chart.Point, len(b.Relative))}
+	for i := 0; i < len(b.Relative); i++ {
+		p, _ := b.Relative[i].Portfolio.Float64()
+		bm, _ := b.Relative[i].Benchmark.Float64()
+		portfolio.Points[i] = chart.Point{X: b.Relative[i].Date, Y: p}
+		benchmark.Points[i] = chart.Point{X: b.Relative[i].Date, Y: bm}
+	}
+	return chart.WriteSVG(w, &chart.Chart{
+		Title: "Growth of 100 vs " + b.Symbol,
+		Lines: []*chart.Line{&portfolio, &benchmark},
+	})
+}
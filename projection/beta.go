@@ -0,0 +1,156 @@
+package projection
+
+import (
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/stonks/quotes"
+)
+
+// PositionBeta is the beta of an open position to the benchmark and how
+// much of the portfolio's beta it contributes
+type PositionBeta struct {
+	Account      string
+	Symbol       string
+	Weight       *big.Float // market value as a fraction of the portfolio value
+	Beta         *big.Float
+	Contribution *big.Float // weight times beta
+}
+
+// Beta holds the beta and correlation of the portfolio's daily returns to
+// those of a benchmark
+type Beta struct {
+	Benchmark   string
+	Days        int        // daily returns the statistics were computed from
+	Beta        *big.Float `json:",omitempty"`
+	Correlation *big.Float `json:",omitempty"`
+	Positions   []*PositionBeta
+	// Unpriced lists the positions without enough price history to
+	// compute their beta
+	Unpriced []string
+}
+
+// NewBeta regresses the daily returns of the value series, excluding
+// deposits and withdrawals, against the daily price returns of the
+// benchmark. the positions open at the end of the series are also
+// regressed against the benchmark over the same days and weighted by
+// their share of the portfolio value on the last day.
+func NewBeta(nav *NAVSeries, positions *Positions, history quotes.History, symbol string) *Beta {
+	b := Beta{
+		Benchmark: symbol,
+		Positions: make([]*PositionBeta, 0, len(positions.Positions)),
+		Unpriced:  make([]string, 0),
+	}
+	// guard clause: no returns to compare
+	if len(nav.Points) < 2 {
+		return &b
+	}
+
+	dates := make([]time.Time, len(nav.Points))
+	for i := 0; i < len(nav.Points); i++ {
+		dates[i] = nav.Points[i].Date
+	}
+	benchmark := dailyReturns(closes(history, symbol, dates))
+	index := growthIndex(nav.Points)
+	portfolio := make([]float64, len(index))
+	for i := 0; i < len(index); i++ {
+		portfolio[i], _ = index[i].Float64()
+	}
+	beta, correlation, days := regress(dailyReturns(portfolio), benchmark)
+	b.Days = days
+	if days > 1 {
+		b.Beta = big.NewFloat(beta)
+		b.Correlation = big.NewFloat(correlation)
+	}
+
+	last := nav.Points[len(nav.Points)-1]
+	for i := 0; i < len(positions.Positions); i++ {
+		p := positions.Positions[i]
+		prices := closes(history, p.Symbol, dates)
+		positionBeta, _, positionDays := regress(dailyReturns(prices), benchmark)
+		if positionDays < 2 || math.IsNaN(prices[len(prices)-1]) || last.NAV.Sign() == 0 {
+			b.Unpriced = append(b.Unpriced, p.Symbol)
+			continue
+		}
+		price := big.NewFloat(prices[len(prices)-1])
+		value := big.NewFloat(0.0)
+		for j := 0; j < len(p.Lots); j++ {
+			value = value.Add(value, marketValue(p.Lots[j], price))
+		}
+		weight := big.NewFloat(0.0).Quo(value, last.NAV)
+		b.Positions = append(b.Positions, &PositionBeta{
+			Account:      p.Account,
+			Symbol:       p.Symbol,
+			Weight:       weight,
+			Beta:         big.NewFloat(positionBeta),
+			Contribution: big.NewFloat(0.0).Mul(weight, big.NewFloat(positionBeta)),
+		})
+	}
+	return &b
+}
+
+// closes returns the close of the symbol on or before each date, NaN for
+// dates before its price history starts
+func closes(history quotes.History, symbol string, dates []time.Time) []float64 {
+	results := make([]float64, len(dates))
+	for i := 0; i < len(dates); i++ {
+		price, err := history.Close(symbol, dates[i])
+		if err != nil {
+			results[i] = math.NaN()
+			continue
+		}
+		results[i], _ = price.Float64()
+	}
+	return results
+}
+
+// dailyReturns returns the fractional change of each value from the one
+// before it. the first return, and any without both values, are NaN
+func dailyReturns(values []float64) []float64 {
+	results := make([]float64, len(values))
+	for i := 0; i < len(values); i++ {
+		if i == 0 || values[i-1] == 0 {
+			results[i] = math.NaN()
+			continue
+		}
+		results[i] = values[i]/values[i-1] - 1
+	}
+	return results
+}
+
+// regress returns the beta and correlation of y to x over the entries
+// where both are numbers, along with how many there were. beta and
+// correlation are zero if either series doesn't vary.
+func regress(y []float64, x []float64) (float64, float64, int) {
+	n := 0
+	meanX, meanY := 0.0, 0.0
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if math.IsNaN(x[i]) || math.IsNaN(y[i]) {
+			continue
+		}
+		n++
+		meanX += x[i]
+		meanY += y[i]
+	}
+	// guard clause: nothing to regress
+	if n == 0 {
+		return 0, 0, 0
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	covariance, varianceX, varianceY := 0.0, 0.0, 0.0
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if math.IsNaN(x[i]) || math.IsNaN(y[i]) {
+			continue
+		}
+		covariance += (x[i] - meanX) * (y[i] - meanY)
+		varianceX += (x[i] - meanX) * (x[i] - meanX)
+		varianceY += (y[i] - meanY) * (y[i] - meanY)
+	}
+	if varianceX == 0 || varianceY == 0 {
+		return 0, 0, n
+	}
+	return covariance / varianceX, covariance / math.Sqrt(varianceX*varianceY), n
+}
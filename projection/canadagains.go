@@ -0,0 +1,68 @@
+package projection
+
+import (
+	"math/big"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// CanadaTaxYear totals the dispositions of a tax year the way they're
+// reported on T5008 slips and Schedule 3
+type CanadaTaxYear struct {
+	Year         int
+	Dispositions []*lots.Disposition
+	Proceeds     *big.Float // total proceeds of disposition, T5008 box 21
+	ACB          *big.Float // total adjusted cost base, T5008 box 20
+	Outlays      *big.Float // commissions and fees paid on the sales
+	Gain         *big.Float // proceeds less ACB and outlays
+	// SuperficialLosses are the losses denied by the superficial loss rule
+	SuperficialLosses *big.Float
+	// Net is the capital gain or loss after adding back superficial losses
+	Net *big.Float
+}
+
+// CanadaCapitalGains reports capital gains under the Canadian adjusted cost
+// base rules per tax year, along with the ACB of the shares still held
+type CanadaCapitalGains struct {
+	Years    []*CanadaTaxYear // in year order
+	Holdings []*lots.Holding
+}
+
+// NewCanadaCapitalGains computes the disposition of every sale at its
+// adjusted cost base and totals them per tax year. shares held in
+// retirement accounts, such as an RRSP or TFSA, aren't part of the ACB.
+func NewCanadaCapitalGains(trans []*trade.Trade, opts *lots.Options) *CanadaCapitalGains {
+	result := lots.MatchCanada(taxableTransactions(trans, opts))
+	g := CanadaCapitalGains{Years: make([]*CanadaTaxYear, 0), Holdings: result.Holdings}
+	byYear := make(map[int]*CanadaTaxYear)
+	for i := 0; i < len(result.Dispositions); i++ {
+		d := result.Dispositions[i]
+		year := lots.Canada{}.TaxYear(d.Date)
+		y := byYear[year]
+		if y == nil {
+			y = &CanadaTaxYear{
+				Year:              year,
+				Dispositions:      make([]*lots.Disposition, 0),
+				Proceeds:          big.NewFloat(0.0),
+				ACB:               big.NewFloat(0.0),
+				Outlays:           big.NewFloat(0.0),
+				Gain:              big.NewFloat(0.0),
+				SuperficialLosses: big.NewFloat(0.0),
+				Net:               big.NewFloat(0.0),
+			}
+			byYear[year] = y
+			// dispositions are in date order so years are too
+			g.Years = append(g.Years, y)
+		}
+		y.Dispositions = append(y.Dispositions, d)
+		y.Proceeds = y.Proceeds.Add(y.Proceeds, d.Proceeds)
+		y.ACB = y.ACB.Add(y.ACB, d.ACB)
+		y.Outlays = y.Outlays.Add(y.Outlays, d.Outlays)
+		y.Gain = y.Gain.Add(y.Gain, d.Gain)
+		y.SuperficialLosses = y.SuperficialLosses.Add(y.SuperficialLosses, d.SuperficialLoss)
+		y.Net = y.Net.Add(y.Net, d.Gain)
+		y.Net = y.Net.Add(y.Net, d.SuperficialLoss)
+	}
+	return &g
+}
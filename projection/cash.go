@@ -0,0 +1,117 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/trade"
+)
+
+// CashBalance is the cash balance at the end of a day with activity
+type CashBalance struct {
+	Date    time.Time
+	Change  *big.Float // net cash moved by the day's transactions
+	Balance *big.Float
+}
+
+// StatementBalance is a cash balance from a broker statement to check the
+// reconstructed balance against
+type StatementBalance struct {
+	Account string // account the balance is for, blank for every account
+	Date    time.Time
+	Balance *big.Float
+}
+
+// CashReconciliation compares a statement balance to the reconstructed
+// balance at the end of the statement date
+type CashReconciliation struct {
+	Account    string
+	Date       time.Time
+	Statement  *big.Float
+	Computed   *big.Float
+	Difference *big.Float // statement less computed
+	Reconciled bool       // true if the difference is no more than rounding
+}
+
+// CashBalances reconstructs the cash balance over time from the amount of
+// every transaction
+type CashBalances struct {
+	Series          []*CashBalance        // one entry per day with activity, in date order
+	Current         *big.Float            // balance after the last transaction
+	Reconciliations []*CashReconciliation `json:",omitempty"`
+}
+
+// movesCash returns true if the transaction changes the cash balance.
+// purchases and redemptions of money market sweeps only move cash in or
+// out of an equivalent, so they're left out.
+func movesCash(t *trade.Trade) bool {
+	if t.Amount == nil || t.Amount.Sign() == 0 {
+		return false
+	}
+	return !t.IsCashEquivalent() || t.Type == trade.Dividend || t.Type == trade.Interest
+}
+
+// NewCashBalances accumulates the amounts of trades, fees, dividends,
+// interest and transfers in date order into a daily cash balance, and
+// checks it against the statement balance of each account in trans
+func NewCashBalances(trans []*trade.Trade, statements []*StatementBalance) *CashBalances {
+	ordered := make([]*trade.Trade, 0, len(trans))
+	for i := 0; i < len(trans); i++ {
+		if movesCash(trans[i]) {
+			ordered = append(ordered, trans[i])
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Date.Before(ordered[j].Date)
+	})
+
+	c := CashBalances{
+		Series:          make([]*CashBalance, 0),
+		Current:         big.NewFloat(0.0),
+		Reconciliations: make([]*CashReconciliation, 0, len(statements)),
+	}
+	for i := 0; i < len(ordered); i++ {
+		t := ordered[i]
+		if len(c.Series) == 0 || !sameDay(c.Series[len(c.Series)-1].Date, t.Date) {
+			c.Series = append(c.Series, &CashBalance{Date: t.Date, Change: big.NewFloat(0.0), Balance: big.NewFloat(0.0).Copy(c.Current)})
+		}
+		day := c.Series[len(c.Series)-1]
+		day.Change = day.Change.Add(day.Change, t.Amount)
+		day.Balance = day.Balance.Add(day.Balance, t.Amount)
+		c.Current = c.Current.Add(c.Current, t.Amount)
+	}
+
+	accounts := make(map[string]bool)
+	for i := 0; i < len(trans); i++ {
+		accounts[trans[i].Account] = true
+	}
+	for i := 0; i < len(statements); i++ {
+		s := statements[i]
+		// guard clause: the statement is for an account not being analyzed
+		if s.Account != "" && !accounts[s.Account] {
+			continue
+		}
+		computed := big.NewFloat(0.0)
+		for j := 0; j < len(ordered) && !ordered[j].Date.After(s.Date); j++ {
+			if s.Account == "" || ordered[j].Account == s.Account {
+				computed = computed.Add(computed, ordered[j].Amount)
+			}
+		}
+		r := CashReconciliation{
+			Account:   s.Account,
+			Date:      s.Date,
+			Statement: s.Balance,
+			Computed:  computed,
+		}
+		r.Difference = big.NewFloat(0.0).Sub(r.Statement, computed)
+		r.Reconciled = !exceedsTolerance(r.Difference)
+		c.Reconciliations = append(c.Reconciliations, &r)
+	}
+	return &c
+}
+
+// sameDay returns true if both times are on the same calendar day
+func sameDay(a time.Time, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
@@ -0,0 +1,120 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/stonks/trade"
+)
+
+// ContributionYear totals the cash moved in and out of an account in a year
+type ContributionYear struct {
+	Account     string
+	Year        int
+	Deposits    *big.Float
+	Withdrawals *big.Float // as a positive amount
+	Net         *big.Float // deposits less withdrawals
+}
+
+// Contributions summarizes the deposits and withdrawals made, and splits
+// the current value of the portfolio into the money put in and the growth
+// earned on it
+type Contributions struct {
+	Years       []*ContributionYear // by account, then year
+	Deposits    *big.Float
+	Withdrawals *big.Float
+	// Net is the net contribution since inception
+	Net *big.Float
+	// Value is the current value of the portfolio and Growth the part of
+	// it that wasn't contributed, both nil until the value is known
+	Value  *big.Float `json:",omitempty"`
+	Growth *big.Float `json:",omitempty"`
+
+	byYear map[string]*ContributionYear
+}
+
+// NewContributions totals the deposits and withdrawals of each account per
+// year. journals between accounts count as a withdrawal from one and a
+// deposit to the other.
+func NewContributions(trans []*trade.Trade) *Contributions {
+	c := newContributions()
+	for i := 0; i < len(trans); i++ {
+		c.add(trans[i])
+	}
+	c.sort()
+	return c
+}
+
+// newContributions returns contributions with zero totals
+func newContributions() *Contributions {
+	return &Contributions{
+		Years:       make([]*ContributionYear, 0),
+		Deposits:    big.NewFloat(0.0),
+		Withdrawals: big.NewFloat(0.0),
+		Net:         big.NewFloat(0.0),
+		byYear:      make(map[string]*ContributionYear),
+	}
+}
+
+// contributionKey identifies the contributions of an account in a year
+func contributionKey(account string, year int) string {
+	return account + "|" + strconv.Itoa(year)
+}
+
+// add counts a transaction towards the contributions
+func (c *Contributions) add(t *trade.Trade) {
+	// guard clause: only money moved in or out is a contribution
+	if !t.IsExternalCashFlow() || t.Amount == nil || t.Amount.Sign() == 0 {
+		return
+	}
+	key := contributionKey(t.Account, t.Date.Year())
+	y := c.byYear[key]
+	if y == nil {
+		y = &ContributionYear{
+			Account:     t.Account,
+			Year:        t.Date.Year(),
+			Deposits:    big.NewFloat(0.0),
+			Withdrawals: big.NewFloat(0.0),
+			Net:         big.NewFloat(0.0),
+		}
+		c.byYear[key] = y
+		c.Years = append(c.Years, y)
+	}
+	if t.Amount.Sign() > 0 {
+		y.Deposits = y.Deposits.Add(y.Deposits, t.Amount)
+		c.Deposits = c.Deposits.Add(c.Deposits, t.Amount)
+	} else {
+		withdrawn := big.NewFloat(0.0).Neg(t.Amount)
+		y.Withdrawals = y.Withdrawals.Add(y.Withdrawals, withdrawn)
+		c.Withdrawals = c.Withdrawals.Add(c.Withdrawals, withdrawn)
+	}
+	y.Net = y.Net.Add(y.Net, t.Amount)
+	c.Net = c.Net.Add(c.Net, t.Amount)
+}
+
+// sort orders the years by account, then year
+func (c *Contributions) sort() {
+	sort.Slice(c.Years, func(i, j int) bool {
+		if c.Years[i].Account != c.Years[j].Account {
+			return c.Years[i].Account < c.Years[j].Account
+		}
+		return c.Years[i].Year < c.Years[j].Year
+	})
+}
+
+// index rebuilds the lookup of the years after the contributions have
+// been restored from a snapshot
+func (c *Contributions) index() {
+	c.byYear = make(map[string]*ContributionYear, len(c.Years))
+	for i := 0; i < len(c.Years); i++ {
+		c.byYear[contributionKey(c.Years[i].Account, c.Years[i].Year)] = c.Years[i]
+	}
+}
+
+// SetValue splits the current value of the portfolio into the net
+// contributions and the growth on them
+func (c *Contributions) SetValue(value *big.Float) {
+	c.Value = big.NewFloat(0.0).Copy(value)
+	c.Growth = big.NewFloat(0.0).Sub(value, c.Net)
+}
@@ -0,0 +1,145 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// CoveredCallPosition is the combined result of writing covered calls on a
+// stock position in an account
+type CoveredCallPosition struct {
+	Account    string
+	Underlying string
+	Calls      int // covered calls written
+	Assigned   int // covered calls assigned
+	// CallPL is the realized gain or loss of the calls themselves. the
+	// premium of assigned calls is moved to the sale of the shares, so
+	// AssignmentPremium holds it instead
+	CallPL            *big.Float
+	AssignmentPremium *big.Float
+	// AssignmentGains are the gains realized on the shares called away,
+	// including the premium of the calls
+	AssignmentGains *big.Float
+	// CallIncome is the call P/L plus the premium of assigned calls
+	CallIncome *big.Float
+	// CoveredCost is the average cost basis of the shares covering each
+	// call when it was written, and YieldEnhancement the call income as a
+	// percent of it
+	CoveredCost      *big.Float
+	YieldEnhancement *big.Float
+}
+
+// CoveredCalls reports the results of writing covered calls
+type CoveredCalls struct {
+	Positions       []*CoveredCallPosition // ordered by account then underlying
+	CallIncome      *big.Float
+	AssignmentGains *big.Float
+}
+
+// NewCoveredCalls links the covered calls among the option strategies to
+// the shares they were written against, and totals the call income, the
+// gains of shares called away and the yield the calls added on the cost of
+// the shares
+func NewCoveredCalls(trans []*trade.Trade, opts *lots.Options, strategies *OptionStrategies) *CoveredCalls {
+	c := CoveredCalls{
+		Positions:       make([]*CoveredCallPosition, 0),
+		CallIncome:      big.NewFloat(0.0),
+		AssignmentGains: big.NewFloat(0.0),
+	}
+	byKey := make(map[string]*CoveredCallPosition)
+	covering := make(map[string]*big.Float) // total cost of the shares covering each call
+	calls := make(map[string]bool)          // account and symbol of every covered call
+	for i := 0; i < len(strategies.Strategies); i++ {
+		s := strategies.Strategies[i]
+		if s.Type != CoveredCall {
+			continue
+		}
+		key := s.Account + "|" + s.Underlying
+		p := byKey[key]
+		if p == nil {
+			p = &CoveredCallPosition{
+				Account:           s.Account,
+				Underlying:        s.Underlying,
+				CallPL:            big.NewFloat(0.0),
+				AssignmentPremium: big.NewFloat(0.0),
+				AssignmentGains:   big.NewFloat(0.0),
+				CallIncome:        big.NewFloat(0.0),
+				CoveredCost:       big.NewFloat(0.0),
+				YieldEnhancement:  big.NewFloat(0.0),
+			}
+			byKey[key] = p
+			covering[key] = big.NewFloat(0.0)
+			c.Positions = append(c.Positions, p)
+		}
+		p.Calls++
+		p.CallPL = p.CallPL.Add(p.CallPL, s.PL)
+		calls[s.Account+"|"+strings.TrimSpace(s.Legs[0].Symbol)] = true
+		covering[key] = covering[key].Add(covering[key], coveringCost(trans, opts, s))
+	}
+
+	// shares called away by the covered calls
+	realized := lots.Match(trans, opts).Realized
+	assignments := make(map[*trade.Trade]bool)
+	for i := 0; i < len(realized); i++ {
+		g := realized[i]
+		if !calls[g.Account+"|"+g.Closing.DeliveredFrom] {
+			continue
+		}
+		p := byKey[g.Account+"|"+g.Symbol]
+		if p == nil {
+			continue
+		}
+		p.AssignmentGains = p.AssignmentGains.Add(p.AssignmentGains, g.Gain)
+		if !assignments[g.Closing] {
+			assignments[g.Closing] = true
+			p.Assigned++
+			if g.Closing.PremiumAdjustment != nil {
+				p.AssignmentPremium = p.AssignmentPremium.Add(p.AssignmentPremium, g.Closing.PremiumAdjustment)
+			}
+		}
+	}
+
+	for i := 0; i < len(c.Positions); i++ {
+		p := c.Positions[i]
+		p.CallIncome = p.CallIncome.Add(p.CallPL, p.AssignmentPremium)
+		p.CoveredCost = p.CoveredCost.Quo(covering[p.Account+"|"+p.Underlying], big.NewFloat(float64(p.Calls)))
+		p.YieldEnhancement = percentOf(p.CallIncome, p.CoveredCost)
+		c.CallIncome = c.CallIncome.Add(c.CallIncome, p.CallIncome)
+		c.AssignmentGains = c.AssignmentGains.Add(c.AssignmentGains, p.AssignmentGains)
+	}
+	sort.Slice(c.Positions, func(i, j int) bool {
+		if c.Positions[i].Account != c.Positions[j].Account {
+			return c.Positions[i].Account < c.Positions[j].Account
+		}
+		return c.Positions[i].Underlying < c.Positions[j].Underlying
+	})
+	return &c
+}
+
+// coveringCost returns the cost basis of the shares covering a covered
+// call at the end of the day it was written, at the average cost of the
+// shares held in the account
+func coveringCost(trans []*trade.Trade, opts *lots.Options, s *OptionStrategy) *big.Float {
+	leg := s.Legs[0]
+	shares := big.NewFloat(0.0).Abs(leg.Quantity)
+	shares = shares.Mul(shares, trade.NewInstrument(leg.Symbol).Multiplier)
+
+	held := big.NewFloat(0.0)
+	cost := big.NewFloat(0.0)
+	open := lots.OpenAsOf(trans, opts, s.Opened)
+	for i := 0; i < len(open); i++ {
+		if open[i].Account == s.Account && open[i].Symbol == s.Underlying {
+			held = held.Add(held, open[i].Quantity)
+			cost = cost.Add(cost, open[i].Cost)
+		}
+	}
+	if held.Sign() <= 0 {
+		return big.NewFloat(0.0)
+	}
+	perShare := big.NewFloat(0.0).Quo(cost, held)
+	return perShare.Mul(perShare, shares)
+}
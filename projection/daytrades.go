@@ -0,0 +1,138 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// PatternDayTradeLimit is the number of day trades within five business
+// days that makes a margin account a pattern day trader
+const PatternDayTradeLimit = 4
+
+// DayTrade is a position opened and closed on the same day
+type DayTrade struct {
+	Account  string
+	Symbol   string
+	Date     time.Time
+	Quantity *big.Float // quantity opened and closed that day, always positive
+}
+
+// DayTradeWindow is five business days with at least the pattern day
+// trade limit of day trades in an account
+type DayTradeWindow struct {
+	Account   string
+	From      time.Time
+	To        time.Time
+	DayTrades int
+}
+
+// PatternDayTrading lists the day trades made and the rolling five
+// business day windows at or over the pattern day trader limit
+type PatternDayTrading struct {
+	DayTrades []*DayTrade       // in date order
+	Flagged   []*DayTradeWindow // ordered by account then end date
+}
+
+// NewPatternDayTrading detects day trades, where a trade closes all or part
+// of a position in the same symbol opened earlier the same day in the same
+// account, and flags each five business day window with four or more. a
+// closing trade counts as one day trade no matter how many opening trades
+// it closes. holidays are counted as business days.
+func NewPatternDayTrading(trans []*trade.Trade) *PatternDayTrading {
+	p := PatternDayTrading{DayTrades: make([]*DayTrade, 0), Flagged: make([]*DayTradeWindow, 0)}
+	ordered := make([]*trade.Trade, 0, len(trans))
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if t.IsTrade() && !t.IsCashEquivalent() && t.Quantity != nil && t.Quantity.Sign() != 0 {
+			ordered = append(ordered, t)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Date.Before(ordered[j].Date)
+	})
+
+	position := make(map[string]*big.Float)    // open quantity per account and symbol
+	openedToday := make(map[string]*big.Float) // quantity of it opened today
+	opened := make(map[string]time.Time)       // the day openedToday was opened on
+	for i := 0; i < len(ordered); i++ {
+		t := ordered[i]
+		symbol := strings.TrimSpace(t.Symbol)
+		key := t.Account + "|" + symbol
+		if position[key] == nil {
+			position[key] = big.NewFloat(0.0)
+		}
+		if openedToday[key] == nil || !sameDay(opened[key], t.Date) {
+			openedToday[key] = big.NewFloat(0.0)
+			opened[key] = t.Date
+		}
+
+		open := position[key]
+		today := openedToday[key]
+		if open.Sign() == 0 || open.Sign() == t.Quantity.Sign() {
+			// adds to the position
+			openedToday[key] = today.Add(today, t.Quantity)
+		} else {
+			// closes against what was opened today first
+			closed := minAbsFloat(t.Quantity, today)
+			if today.Sign() != 0 && closed.Sign() != 0 {
+				p.DayTrades = append(p.DayTrades, &DayTrade{Account: t.Account, Symbol: symbol, Date: t.Date, Quantity: closed})
+				if today.Sign() > 0 {
+					today = today.Sub(today, closed)
+				} else {
+					today = today.Add(today, closed)
+				}
+			}
+			// whatever closes past flat opens a new position today
+			if big.NewFloat(0.0).Abs(t.Quantity).Cmp(big.NewFloat(0.0).Abs(open)) > 0 {
+				today = big.NewFloat(0.0).Add(open, t.Quantity)
+			}
+			openedToday[key] = today
+		}
+		position[key] = open.Add(open, t.Quantity)
+	}
+
+	counted := make(map[string]bool)
+	for i := 0; i < len(p.DayTrades); i++ {
+		d := p.DayTrades[i]
+		key := d.Account + "|" + d.Date.Format("2006-01-02")
+		// guard clause: one window per account per day
+		if counted[key] {
+			continue
+		}
+		counted[key] = true
+		from := businessDaysBefore(d.Date, 4)
+		w := DayTradeWindow{Account: d.Account, From: from, To: d.Date}
+		to := lots.EndOfDate(d.Date)
+		for j := 0; j < len(p.DayTrades); j++ {
+			o := p.DayTrades[j]
+			if o.Account == d.Account && !o.Date.Before(from) && o.Date.Before(to) {
+				w.DayTrades++
+			}
+		}
+		if w.DayTrades >= PatternDayTradeLimit {
+			p.Flagged = append(p.Flagged, &w)
+		}
+	}
+	sort.SliceStable(p.Flagged, func(i, j int) bool {
+		return p.Flagged[i].Account < p.Flagged[j].Account
+	})
+	return &p
+}
+
+// businessDaysBefore returns the start of the day n weekdays before the
+// date
+func businessDaysBefore(date time.Time, n int) time.Time {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	for n > 0 {
+		day = day.AddDate(0, 0, -1)
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			n--
+		}
+	}
+	return day
+}
@@ -0,0 +1,155 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// MonthlyDividends holds the dividends received in a calendar month
+type MonthlyDividends struct {
+	Month  string // calendar month in YYYY-MM format
+	Amount *big.Float
+}
+
+// SymbolDividends holds the dividends paid by a single symbol
+type SymbolDividends struct {
+	Symbol               string
+	Payments             int
+	Total                *big.Float
+	TrailingTwelveMonths *big.Float // dividends received in the year up to the as of date
+	// ForwardAnnual estimates the dividends of the next year from the
+	// last payment per share, paid as often as in the past year, on the
+	// shares held now
+	ForwardAnnual *big.Float
+	Months        []*MonthlyDividends // ordered by month
+}
+
+// DividendIncome reports the dividends received per symbol and per month
+type DividendIncome struct {
+	AsOf                 time.Time
+	Months               []*MonthlyDividends // ordered by month
+	Symbols              []*SymbolDividends  // ordered by symbol
+	Total                *big.Float
+	TrailingTwelveMonths *big.Float
+	ForwardAnnual        *big.Float
+}
+
+// NewDividendIncome totals the dividends received up to the as of date per
+// symbol and per month, along with the trailing twelve months and an
+// estimate of the next twelve months from the positions still held
+func NewDividendIncome(trans []*trade.Trade, asOf time.Time) *DividendIncome {
+	d := DividendIncome{
+		AsOf:                 asOf,
+		Months:               make([]*MonthlyDividends, 0),
+		Symbols:              make([]*SymbolDividends, 0),
+		Total:                big.NewFloat(0.0),
+		TrailingTwelveMonths: big.NewFloat(0.0),
+		ForwardAnnual:        big.NewFloat(0.0),
+	}
+	trailingStart := asOf.AddDate(-1, 0, 0)
+	byMonth := make(map[string]*MonthlyDividends)
+	bySymbol := make(map[string]*SymbolDividends)
+	symbolMonths := make(map[string]map[string]*MonthlyDividends)
+	// the last payment of each symbol and how many it paid in the past year
+	lastPayment := make(map[string]*trade.Trade)
+	trailingPayments := make(map[string]int)
+
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		// guard clause: only dividends paid up to the as of date count
+		if t.Type != trade.Dividend || t.Amount == nil || !t.Date.Before(lots.EndOfDate(asOf)) {
+			continue
+		}
+		symbol := strings.TrimSpace(t.Symbol)
+
+		month := t.Date.Format("2006-01")
+		m := byMonth[month]
+		if m == nil {
+			m = &MonthlyDividends{Month: month, Amount: big.NewFloat(0.0)}
+			byMonth[month] = m
+			d.Months = append(d.Months, m)
+		}
+		m.Amount = m.Amount.Add(m.Amount, t.Amount)
+
+		s := bySymbol[symbol]
+		if s == nil {
+			s = &SymbolDividends{
+				Symbol:               symbol,
+				Total:                big.NewFloat(0.0),
+				TrailingTwelveMonths: big.NewFloat(0.0),
+				ForwardAnnual:        big.NewFloat(0.0),
+				Months:               make([]*MonthlyDividends, 0),
+			}
+			bySymbol[symbol] = s
+			symbolMonths[symbol] = make(map[string]*MonthlyDividends)
+			d.Symbols = append(d.Symbols, s)
+		}
+		sm := symbolMonths[symbol][month]
+		if sm == nil {
+			sm = &MonthlyDividends{Month: month, Amount: big.NewFloat(0.0)}
+			symbolMonths[symbol][month] = sm
+			s.Months = append(s.Months, sm)
+		}
+		sm.Amount = sm.Amount.Add(sm.Amount, t.Amount)
+		s.Payments++
+		s.Total = s.Total.Add(s.Total, t.Amount)
+		d.Total = d.Total.Add(d.Total, t.Amount)
+		if t.Date.After(trailingStart) {
+			s.TrailingTwelveMonths = s.TrailingTwelveMonths.Add(s.TrailingTwelveMonths, t.Amount)
+			d.TrailingTwelveMonths = d.TrailingTwelveMonths.Add(d.TrailingTwelveMonths, t.Amount)
+			trailingPayments[symbol]++
+		}
+		if last := lastPayment[symbol]; last == nil || !t.Date.Before(last.Date) {
+			lastPayment[symbol] = t
+		}
+	}
+
+	for i := 0; i < len(d.Symbols); i++ {
+		s := d.Symbols[i]
+		last := lastPayment[s.Symbol]
+		// shares reinvested from the payment itself shouldn't count
+		paidOn := sharesHeld(trans, s.Symbol, last.Date.AddDate(0, 0, -1))
+		held := sharesHeld(trans, s.Symbol, asOf)
+		// guard clause: no estimate for positions closed or payments
+		// without shares on record
+		if paidOn.Sign() <= 0 || held.Sign() <= 0 {
+			continue
+		}
+		perShare := big.NewFloat(0.0).Quo(last.Amount, paidOn)
+		s.ForwardAnnual = perShare.Mul(perShare, held)
+		s.ForwardAnnual = s.ForwardAnnual.Mul(s.ForwardAnnual, big.NewFloat(float64(trailingPayments[s.Symbol])))
+		d.ForwardAnnual = d.ForwardAnnual.Add(d.ForwardAnnual, s.ForwardAnnual)
+	}
+
+	sort.Slice(d.Months, func(i, j int) bool {
+		return d.Months[i].Month < d.Months[j].Month
+	})
+	sort.Slice(d.Symbols, func(i, j int) bool {
+		return d.Symbols[i].Symbol < d.Symbols[j].Symbol
+	})
+	for i := 0; i < len(d.Symbols); i++ {
+		months := d.Symbols[i].Months
+		sort.Slice(months, func(i, j int) bool {
+			return months[i].Month < months[j].Month
+		})
+	}
+	return &d
+}
+
+// sharesHeld returns the quantity of a symbol held across every account at
+// the end of a date
+func sharesHeld(trans []*trade.Trade, symbol string, date time.Time) *big.Float {
+	held := big.NewFloat(0.0)
+	open := lots.OpenAsOf(trans, nil, date)
+	for i := 0; i < len(open); i++ {
+		if open[i].Symbol == symbol {
+			held = held.Add(held, open[i].Quantity)
+		}
+	}
+	return held
+}
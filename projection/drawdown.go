@@ -0,0 +1,122 @@
+package projection
+
+import (
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/stonks/chart"
+)
+
+// EquityPoint is a point of the equity curve
+type EquityPoint struct {
+	Date time.Time
+	NAV  *big.Float
+	// Index is the growth of 1 invested at the start, with deposits and
+	// withdrawals taken out so they don't count as gains or losses
+	Index    *big.Float
+	Drawdown *big.Float // percent the index is below its previous peak, zero or negative
+}
+
+// UnderwaterPeriod is a stretch of time the index spent below a peak
+type UnderwaterPeriod struct {
+	Peak      time.Time  // last day at the previous high
+	Trough    time.Time  // day of the lowest point
+	Recovered time.Time  // first day back at the previous high, zero if it hasn't recovered
+	Depth     *big.Float // percent drawdown at the trough
+	Days      int        // calendar days from the peak until recovery, or the last day
+}
+
+// Drawdown reports the equity curve and how far and for how long it fell
+// from its peaks
+type Drawdown struct {
+	Equity      []*EquityPoint
+	MaxDrawdown *big.Float // deepest drawdown, as a negative percent
+	// MaxDuration is the most calendar days spent below a peak
+	MaxDuration int
+	Underwater  []*UnderwaterPeriod
+}
+
+// growthIndex returns the growth of 1 over the series, neutralizing the
+// flows of each day by treating them as made at the start of the day
+func growthIndex(points []*NAVPoint) []*big.Float {
+	index := make([]*big.Float, len(points))
+	value := big.NewFloat(1.0)
+	for i := 0; i < len(points); i++ {
+		if i > 0 {
+			// the day's return is the change in value not due to flows
+			start := big.NewFloat(0.0).Add(points[i-1].NAV, points[i].Flows)
+			if start.Sign() > 0 {
+				growth := big.NewFloat(0.0).Quo(points[i].NAV, start)
+				value = big.NewFloat(0.0).Mul(value, growth)
+			}
+		}
+		index[i] = value
+	}
+	return index
+}
+
+// NewDrawdown computes the equity curve of the value series and its
+// drawdowns
+func NewDrawdown(nav *NAVSeries) *Drawdown {
+	d := Drawdown{
+		Equity:      make([]*EquityPoint, 0, len(nav.Points)),
+		MaxDrawdown: big.NewFloat(0.0),
+		Underwater:  make([]*UnderwaterPeriod, 0),
+	}
+	index := growthIndex(nav.Points)
+	peak := big.NewFloat(0.0)
+	var peakDate time.Time
+	var current *UnderwaterPeriod
+	for i := 0; i < len(nav.Points); i++ {
+		p := nav.Points[i]
+		dd := big.NewFloat(0.0)
+		if index[i].Cmp(peak) >= 0 {
+			peak = index[i]
+			peakDate = p.Date
+			if current != nil {
+				current.Recovered = p.Date
+				current.Days = int(p.Date.Sub(current.Peak).Hours() / 24)
+				current = nil
+			}
+		} else {
+			dd = dd.Quo(index[i], peak)
+			dd = dd.Sub(dd, big.NewFloat(1.0))
+			dd = dd.Mul(dd, big.NewFloat(100))
+			if current == nil {
+				current = &UnderwaterPeriod{Peak: peakDate, Trough: p.Date, Depth: dd}
+				d.Underwater = append(d.Underwater, current)
+			}
+			if dd.Cmp(current.Depth) < 0 {
+				current.Trough = p.Date
+				current.Depth = dd
+			}
+			current.Days = int(p.Date.Sub(current.Peak).Hours() / 24)
+		}
+		if dd.Cmp(d.MaxDrawdown) < 0 {
+			d.MaxDrawdown = dd
+		}
+		d.Equity = append(d.Equity, &EquityPoint{Date: p.Date, NAV: p.NAV, Index: index[i], Drawdown: dd})
+	}
+	for i := 0; i < len(d.Underwater); i++ {
+		if d.Underwater[i].Days > d.MaxDuration {
+			d.MaxDuration = d.Underwater[i].Days
+		}
+	}
+	return &d
+}
+
+// WriteSVG charts the equity curve above the drawdown from peaks
+func (d *Drawdown) WriteSVG(w io.Writer) error {
+	equity := chart.Line{Name: "Portfolio value", Points: make([]chart.Point, len(d.Equity))}
+	underwater := chart.Line{Name: "Drawdown", Color: "#d62728", Points: make([]chart.Point, len(d.Equity))}
+	for i := 0; i < len(d.Equity); i++ {
+		nav, _ := d.Equity[i].NAV.Float64()
+		dd, _ := d.Equity[i].Drawdown.Float64()
+		equity.Points[i] = chart.Point{X: d.Equity[i].Date, Y: nav}
+		underwater.Points[i] = chart.Point{X: d.Equity[i].Date, Y: dd}
+	}
+	return chart.WriteSVG(w,
+		&chart.Chart{Title: "Equity curve", Lines: []*chart.Line{&equity}},
+		&chart.Chart{Title: "Drawdown", Unit: "%", Lines: []*chart.Line{&underwater}})
+}
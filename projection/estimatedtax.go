@@ -0,0 +1,311 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// TaxBracket is the marginal rate paid on taxable income above a threshold
+type TaxBracket struct {
+	Threshold float64 `json:"threshold"` // lowest taxable income taxed at the rate
+	Rate      float64 `json:"rate"`      // eg 0.22 for 22%
+}
+
+// TaxRates configures the tax brackets and prior year figures used to
+// estimate quarterly tax payments
+type TaxRates struct {
+	// OrdinaryBrackets tax short term gains, non-qualified dividends and
+	// interest, in order of threshold
+	OrdinaryBrackets []*TaxBracket `json:"ordinaryBrackets"`
+	// LongTermBrackets tax long term gains and qualified dividends, which
+	// are stacked on top of ordinary income
+	LongTermBrackets []*TaxBracket `json:"longTermBrackets"`
+	// OtherIncome is taxable income from outside the accounts such as
+	// wages, after deductions, which investment income is stacked on
+	OtherIncome float64 `json:"otherIncome"`
+	// PriorYearTax is last year's tax on investment income, used for the
+	// prior year safe harbor. zero only uses the current year safe harbor
+	PriorYearTax float64 `json:"priorYearTax"`
+	// HighIncome is true if last year's AGI was over $150,000, which raises
+	// the prior year safe harbor to 110%
+	HighIncome bool `json:"highIncome"`
+}
+
+// capitalLossLimit is the net capital loss deductible against ordinary
+// income each year
+const capitalLossLimit = 3000
+
+// EstimatedTaxQuarter is the estimated tax due for one installment period
+type EstimatedTaxQuarter struct {
+	Quarter   int
+	PeriodEnd time.Time // last day of income counted for the installment
+	DueDate   time.Time
+	// income year to date at the end of the period
+	ShortTermGains     *big.Float
+	LongTermGains      *big.Float
+	OrdinaryDividends  *big.Float
+	QualifiedDividends *big.Float
+	Interest           *big.Float
+	// YTDLiability is the tax on the investment income earned so far
+	YTDLiability *big.Float
+	// Required is the cumulative amount that must be paid by the due date
+	// to avoid an underpayment penalty, the lesser of the regular and the
+	// annualized income installments
+	Required *big.Float
+	Payment  *big.Float // installment due, required less earlier installments
+}
+
+// EstimatedTaxYear holds the estimated payments of a tax year
+type EstimatedTaxYear struct {
+	Year     int
+	Quarters []*EstimatedTaxQuarter
+	// Liability is the tax on the investment income of the whole year
+	Liability *big.Float
+	// SafeHarbor is the annual payment that avoids a penalty, the lesser of
+	// 90% of this year's tax and 100% (or 110%) of last year's
+	SafeHarbor *big.Float
+}
+
+// EstimatedTax estimates the quarterly tax payments due on investment
+// income for each tax year
+type EstimatedTax struct {
+	Years []*EstimatedTaxYear
+}
+
+// installmentPeriods are the month and day each installment period ends,
+// the month and day of its due date, and the factor annualizing the income
+// earned so far
+var installmentPeriods = []struct {
+	endMonth   time.Month
+	endDay     int
+	dueMonth   time.Month
+	dueDay     int
+	annualize  float64
+	percentage float64 // of the annualized tax required by the due date
+}{
+	{time.March, 31, time.April, 15, 4, 0.225},
+	{time.May, 31, time.June, 15, 2.4, 0.45},
+	{time.August, 31, time.September, 15, 1.5, 0.675},
+	{time.December, 31, time.January, 15, 1, 0.9},
+}
+
+// investmentIncome is the taxable income earned by the accounts
+type investmentIncome struct {
+	shortTerm, longTerm, ordinaryDividends, qualifiedDividends, interest *big.Float
+}
+
+// newInvestmentIncome returns income with every amount zeroed out
+func newInvestmentIncome() *investmentIncome {
+	return &investmentIncome{
+		shortTerm:          big.NewFloat(0.0),
+		longTerm:           big.NewFloat(0.0),
+		ordinaryDividends:  big.NewFloat(0.0),
+		qualifiedDividends: big.NewFloat(0.0),
+		interest:           big.NewFloat(0.0),
+	}
+}
+
+// NewEstimatedTax estimates quarterly tax payments from the gains realized
+// by closing lots and the dividends and interest paid, using the safe
+// harbor rules: each installment is the lesser of a quarter of the safe
+// harbor payment or the annualized tax on the income earned so far.
+// retirement accounts aren't taxed, so their income is left out.
+func NewEstimatedTax(trans []*trade.Trade, opts *lots.Options, rates *TaxRates) *EstimatedTax {
+	realized := taxableGains(lots.Match(trans, opts).Realized, opts)
+	trans = taxableTransactions(trans, opts)
+	years := make(map[int]bool)
+	for i := 0; i < len(realized); i++ {
+		years[realized[i].CloseDate.Year()] = true
+	}
+	for i := 0; i < len(trans); i++ {
+		if trans[i].Type == trade.Dividend || trans[i].Type == trade.Interest || trans[i].Type == trade.Coupon {
+			years[trans[i].Date.Year()] = true
+		}
+	}
+
+	e := EstimatedTax{Years: make([]*EstimatedTaxYear, 0, len(years))}
+	for year := range years {
+		e.Years = append(e.Years, newEstimatedTaxYear(year, trans, realized, rates))
+	}
+	sort.Slice(e.Years, func(i, j int) bool {
+		return e.Years[i].Year < e.Years[j].Year
+	})
+	return &e
+}
+
+// newEstimatedTaxYear estimates the installments of a single tax year
+func newEstimatedTaxYear(year int, trans []*trade.Trade, realized []*lots.RealizedGain, rates *TaxRates) *EstimatedTaxYear {
+	y := EstimatedTaxYear{Year: year, Quarters: make([]*EstimatedTaxQuarter, 0, len(installmentPeriods))}
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	y.Liability = rates.tax(incomeBetween(trans, realized, start, start.AddDate(1, 0, 0)))
+
+	y.SafeHarbor = big.NewFloat(0.0).Mul(y.Liability, big.NewFloat(0.9))
+	if rates.PriorYearTax > 0 {
+		prior := big.NewFloat(rates.PriorYearTax)
+		if rates.HighIncome {
+			prior = prior.Mul(prior, big.NewFloat(1.1))
+		}
+		if prior.Cmp(y.SafeHarbor) < 0 {
+			y.SafeHarbor = prior
+		}
+	}
+
+	paid := big.NewFloat(0.0)
+	for i := 0; i < len(installmentPeriods); i++ {
+		p := installmentPeriods[i]
+		q := EstimatedTaxQuarter{
+			Quarter:   i + 1,
+			PeriodEnd: time.Date(year, p.endMonth, p.endDay, 0, 0, 0, 0, time.UTC),
+			DueDate:   time.Date(year, p.dueMonth, p.dueDay, 0, 0, 0, 0, time.UTC),
+		}
+		if p.dueMonth < p.endMonth {
+			q.DueDate = q.DueDate.AddDate(1, 0, 0)
+		}
+		income := incomeBetween(trans, realized, start, q.PeriodEnd.AddDate(0, 0, 1))
+		q.ShortTermGains = income.shortTerm
+		q.LongTermGains = income.longTerm
+		q.OrdinaryDividends = income.ordinaryDividends
+		q.QualifiedDividends = income.qualifiedDividends
+		q.Interest = income.interest
+		q.YTDLiability = rates.tax(income)
+
+		// regular installments are a quarter of the safe harbor each
+		regular := big.NewFloat(0.0).Mul(y.SafeHarbor, big.NewFloat(float64(i+1)/4))
+		annualized := rates.tax(income.scale(p.annualize))
+		annualized = annualized.Mul(annualized, big.NewFloat(p.percentage))
+		q.Required = regular
+		if annualized.Cmp(regular) < 0 {
+			q.Required = annualized
+		}
+
+		q.Payment = big.NewFloat(0.0).Sub(q.Required, paid)
+		if q.Payment.Sign() < 0 {
+			q.Payment = big.NewFloat(0.0)
+		}
+		paid = paid.Add(paid, q.Payment)
+		y.Quarters = append(y.Quarters, &q)
+	}
+	return &y
+}
+
+// incomeBetween totals the investment income earned from start up to but
+// not including end
+func incomeBetween(trans []*trade.Trade, realized []*lots.RealizedGain, start time.Time, end time.Time) *investmentIncome {
+	income := newInvestmentIncome()
+	for i := 0; i < len(realized); i++ {
+		g := realized[i]
+		if g.CloseDate.Before(start) || !g.CloseDate.Before(end) {
+			continue
+		}
+		// disallowed wash sale losses aren't deductible
+		gain := big.NewFloat(0.0).Add(g.Gain, g.Disallowed)
+		if g.LongTerm() {
+			income.longTerm = income.longTerm.Add(income.longTerm, gain)
+		} else {
+			income.shortTerm = income.shortTerm.Add(income.shortTerm, gain)
+		}
+	}
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if t.Date.Before(start) || !t.Date.Before(end) {
+			continue
+		}
+		switch {
+		case t.Type == trade.Interest || t.Type == trade.Coupon:
+			income.interest = income.interest.Add(income.interest, t.Amount)
+		case t.Type == trade.Dividend && t.DividendClass == trade.Qualified:
+			income.qualifiedDividends = income.qualifiedDividends.Add(income.qualifiedDividends, t.Amount)
+		case t.Type == trade.Dividend && t.DividendClass == trade.CapitalGainDistribution:
+			income.longTerm = income.longTerm.Add(income.longTerm, t.Amount)
+		case t.Type == trade.Dividend && t.DividendClass != trade.ReturnOfCapital:
+			income.ordinaryDividends = income.ordinaryDividends.Add(income.ordinaryDividends, t.Amount)
+		}
+	}
+	return income
+}
+
+// scale returns the income multiplied by a factor, used to annualize the
+// income earned part way through the year
+func (i *investmentIncome) scale(factor float64) *investmentIncome {
+	f := big.NewFloat(factor)
+	return &investmentIncome{
+		shortTerm:          big.NewFloat(0.0).Mul(i.shortTerm, f),
+		longTerm:           big.NewFloat(0.0).Mul(i.longTerm, f),
+		ordinaryDividends:  big.NewFloat(0.0).Mul(i.ordinaryDividends, f),
+		qualifiedDividends: big.NewFloat(0.0).Mul(i.qualifiedDividends, f),
+		interest:           big.NewFloat(0.0).Mul(i.interest, f),
+	}
+}
+
+// tax returns the tax owed on the investment income on top of the other
+// income. short and long term gains are netted against each other, and a
+// net capital loss offsets up to $3,000 of ordinary income.
+func (r *TaxRates) tax(income *investmentIncome) *big.Float {
+	st, _ := income.shortTerm.Float64()
+	lt, _ := income.longTerm.Float64()
+	switch {
+	case st < 0 && lt > 0:
+		lt, st = lt+st, 0
+	case lt < 0 && st > 0:
+		st, lt = st+lt, 0
+	}
+	capitalLoss := 0.0
+	if st < 0 || lt < 0 {
+		capitalLoss = -(minFloat(st, 0) + minFloat(lt, 0))
+		if capitalLoss > capitalLossLimit {
+			capitalLoss = capitalLossLimit
+		}
+		st, lt = maxFloat(st, 0), maxFloat(lt, 0)
+	}
+
+	ordinaryDividends, _ := income.ordinaryDividends.Float64()
+	qualifiedDividends, _ := income.qualifiedDividends.Float64()
+	interest, _ := income.interest.Float64()
+
+	base := r.OtherIncome
+	ordinary := maxFloat(base+st+ordinaryDividends+interest-capitalLoss, 0)
+	preferential := lt + qualifiedDividends
+
+	with := bracketTax(r.OrdinaryBrackets, 0, ordinary) + bracketTax(r.LongTermBrackets, ordinary, ordinary+preferential)
+	without := bracketTax(r.OrdinaryBrackets, 0, base)
+	return big.NewFloat(maxFloat(with-without, 0))
+}
+
+// bracketTax returns the tax on the slice of taxable income from low to
+// high, taxed at the marginal rates of the brackets
+func bracketTax(brackets []*TaxBracket, low float64, high float64) float64 {
+	tax := 0.0
+	for i := 0; i < len(brackets); i++ {
+		bottom := brackets[i].Threshold
+		top := high
+		if i+1 < len(brackets) {
+			top = brackets[i+1].Threshold
+		}
+		from := maxFloat(bottom, low)
+		to := minFloat(top, high)
+		if to > from {
+			tax += (to - from) * brackets[i].Rate
+		}
+	}
+	return tax
+}
+
+// minFloat returns the smaller of a and b
+func minFloat(a float64, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxFloat returns the larger of a and b
+func maxFloat(a float64, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,114 @@
+package projection
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stonks/trade"
+)
+
+// icalDateFormat is the format of all day dates in an iCalendar file
+const icalDateFormat = "20060102"
+
+// ExpiringOption is an open option position expiring on a date
+type ExpiringOption struct {
+	Account    string
+	Symbol     string
+	Underlying string
+	OptionType trade.OptionType
+	Strike     *big.Float
+	Quantity   *big.Float // negative for short positions
+}
+
+// ExpirationDate lists the open options expiring on a date
+type ExpirationDate struct {
+	Date             time.Time
+	DaysToExpiration int
+	Options          []*ExpiringOption
+}
+
+// ExpirationCalendar lists the upcoming expiration dates of the open option
+// positions
+type ExpirationCalendar struct {
+	AsOf  time.Time
+	Dates []*ExpirationDate // nearest first
+}
+
+// NewExpirationCalendar groups the open option positions by the date they
+// expire, leaving out any that expired before the positions' date
+func NewExpirationCalendar(positions *Positions) *ExpirationCalendar {
+	c := ExpirationCalendar{AsOf: positions.AsOf, Dates: make([]*ExpirationDate, 0)}
+	asOf := time.Date(positions.AsOf.Year(), positions.AsOf.Month(), positions.AsOf.Day(), 0, 0, 0, 0, time.UTC)
+	byDate := make(map[string]*ExpirationDate)
+	for i := 0; i < len(positions.Positions); i++ {
+		p := positions.Positions[i]
+		instrument := trade.NewInstrument(p.Symbol)
+		if len(p.Lots) > 0 && p.Lots[0].Opening != nil && p.Lots[0].Opening.Instrument != nil {
+			instrument = p.Lots[0].Opening.Instrument
+		}
+		// guard clause: only options that haven't expired yet
+		if instrument.Class != trade.Option || instrument.Expiration.Before(asOf) {
+			continue
+		}
+		key := instrument.Expiration.Format(icalDateFormat)
+		d := byDate[key]
+		if d == nil {
+			d = &ExpirationDate{
+				Date:             instrument.Expiration,
+				DaysToExpiration: int(instrument.Expiration.Sub(asOf).Hours() / 24),
+				Options:          make([]*ExpiringOption, 0),
+			}
+			byDate[key] = d
+			c.Dates = append(c.Dates, d)
+		}
+		d.Options = append(d.Options, &ExpiringOption{
+			Account:    p.Account,
+			Symbol:     p.Symbol,
+			Underlying: instrument.Underlying,
+			OptionType: instrument.OptionType,
+			Strike:     instrument.Strike,
+			Quantity:   p.Quantity,
+		})
+	}
+	sort.Slice(c.Dates, func(i, j int) bool {
+		return c.Dates[i].Date.Before(c.Dates[j].Date)
+	})
+	return &c
+}
+
+// WriteICal writes the calendar as an iCalendar file with an all day event
+// on each expiration date listing the options expiring. created is the
+// time recorded as when the events were made.
+func (c *ExpirationCalendar) WriteICal(w io.Writer, created time.Time) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//stonks//option expirations//EN\r\n")
+	for i := 0; i < len(c.Dates); i++ {
+		d := c.Dates[i]
+		day := d.Date.Format(icalDateFormat)
+		symbols := make([]string, len(d.Options))
+		for j := 0; j < len(d.Options); j++ {
+			o := d.Options[j]
+			symbols[j] = fmt.Sprintf("%s %s %s", o.Quantity.Text('f', -1), strings.TrimSpace(o.Symbol), o.Account)
+		}
+		fmt.Fprintf(out, "BEGIN:VEVENT\r\n")
+		fmt.Fprintf(out, "UID:expiration-%s@stonks\r\n", day)
+		fmt.Fprintf(out, "DTSTAMP:%s\r\n", created.UTC().Format("20060102T150405Z"))
+		fmt.Fprintf(out, "DTSTART;VALUE=DATE:%s\r\n", day)
+		fmt.Fprintf(out, "DTEND;VALUE=DATE:%s\r\n", d.Date.AddDate(0, 0, 1).Format(icalDateFormat))
+		fmt.Fprintf(out, "SUMMARY:%d option positions expire\r\n", len(d.Options))
+		fmt.Fprintf(out, "DESCRIPTION:%s\r\n", icalEscape(strings.Join(symbols, "\n")))
+		fmt.Fprintf(out, "END:VEVENT\r\n")
+	}
+	fmt.Fprintf(out, "END:VCALENDAR\r\n")
+	return out.Flush()
+}
+
+// icalEscape escapes text for an iCalendar property value
+func icalEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
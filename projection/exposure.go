@@ -0,0 +1,82 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/trade"
+)
+
+// ShortOption is an open short option position
+type ShortOption struct {
+	Account          string
+	Symbol           string
+	Underlying       string
+	OptionType       trade.OptionType
+	Contracts        *big.Float // contracts written, always positive
+	Strike           *big.Float
+	Expiration       time.Time
+	DaysToExpiration int
+	// Obligation is the notional value of the shares that would have to be
+	// bought (puts) or delivered (calls) at the strike if assigned
+	Obligation *big.Float
+	Premium    *big.Float // premium received for the open contracts
+}
+
+// ShortOptionExposure lists the short options still open
+type ShortOptionExposure struct {
+	AsOf    time.Time
+	Options []*ShortOption // nearest expiration first
+	// PutObligation and CallObligation total the notional assignment
+	// obligations of the short puts and calls
+	PutObligation  *big.Float
+	CallObligation *big.Float
+}
+
+// NewShortOptionExposure lists the short option positions open as of the
+// positions' date with their strike, expiration and what assignment would
+// oblige
+func NewShortOptionExposure(positions *Positions) *ShortOptionExposure {
+	e := ShortOptionExposure{
+		AsOf:           positions.AsOf,
+		Options:        make([]*ShortOption, 0),
+		PutObligation:  big.NewFloat(0.0),
+		CallObligation: big.NewFloat(0.0),
+	}
+	asOf := time.Date(positions.AsOf.Year(), positions.AsOf.Month(), positions.AsOf.Day(), 0, 0, 0, 0, time.UTC)
+	for i := 0; i < len(positions.Positions); i++ {
+		p := positions.Positions[i]
+		instrument := trade.NewInstrument(p.Symbol)
+		if len(p.Lots) > 0 && p.Lots[0].Opening != nil && p.Lots[0].Opening.Instrument != nil {
+			instrument = p.Lots[0].Opening.Instrument
+		}
+		// guard clause: only short options oblige anything
+		if instrument.Class != trade.Option || p.Quantity.Sign() >= 0 || instrument.Strike == nil {
+			continue
+		}
+		contracts := big.NewFloat(0.0).Abs(p.Quantity)
+		o := ShortOption{
+			Account:          p.Account,
+			Symbol:           p.Symbol,
+			Underlying:       instrument.Underlying,
+			OptionType:       instrument.OptionType,
+			Contracts:        contracts,
+			Strike:           instrument.Strike,
+			Expiration:       instrument.Expiration,
+			DaysToExpiration: int(instrument.Expiration.Sub(asOf).Hours() / 24),
+			Obligation:       instrument.Notional(contracts, instrument.Strike),
+			Premium:          big.NewFloat(0.0).Neg(p.Cost),
+		}
+		if o.OptionType == trade.Put {
+			e.PutObligation = e.PutObligation.Add(e.PutObligation, o.Obligation)
+		} else {
+			e.CallObligation = e.CallObligation.Add(e.CallObligation, o.Obligation)
+		}
+		e.Options = append(e.Options, &o)
+	}
+	sort.SliceStable(e.Options, func(i, j int) bool {
+		return e.Options[i].Expiration.Before(e.Options[j].Expiration)
+	})
+	return &e
+}
@@ -0,0 +1,171 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/stonks/trade"
+)
+
+// FeeTotals totals each kind of fee charged
+type FeeTotals struct {
+	Commission    *big.Float
+	RegFee        *big.Float
+	ADRFee        *big.Float
+	RedemptionFee *big.Float
+	AccountFee    *big.Float
+	NetworkFee    *big.Float
+	Total         *big.Float // commission plus every fee component
+}
+
+// BrokerFees totals each kind of fee charged by a single broker
+type BrokerFees struct {
+	Broker string
+	*FeeTotals
+}
+
+// MonthFees totals the fees charged in a calendar month
+type MonthFees struct {
+	Month string // YYYY-MM
+	*FeeTotals
+}
+
+// YearFees totals the fees charged in a calendar year
+type YearFees struct {
+	Year int
+	*FeeTotals
+}
+
+// SymbolFees totals the fees charged trading a symbol
+type SymbolFees struct {
+	Symbol string
+	*FeeTotals
+}
+
+// FeeAudit breaks down where money is spent on commissions and fees,
+// per broker, month, year and symbol
+type FeeAudit struct {
+	Brokers  []*BrokerFees // fee totals per broker ordered by broker name
+	ByMonth  []*MonthFees  // ordered by month
+	ByYear   []*YearFees   // ordered by year
+	BySymbol []*SymbolFees // ordered by symbol, leaving out fees not charged on a symbol
+	Total    *FeeTotals
+	// FeeDrag is the total fees as a percent of the gross profits, the sum
+	// of every realized gain before losses
+	FeeDrag *big.Float
+}
+
+// newFeeTotals returns a new instance of fee totals with every amount
+// zeroed out
+func newFeeTotals() *FeeTotals {
+	return &FeeTotals{
+		Commission:    big.NewFloat(0.0),
+		RegFee:        big.NewFloat(0.0),
+		ADRFee:        big.NewFloat(0.0),
+		RedemptionFee: big.NewFloat(0.0),
+		AccountFee:    big.NewFloat(0.0),
+		NetworkFee:    big.NewFloat(0.0),
+		Total:         big.NewFloat(0.0),
+	}
+}
+
+// add accumulates the commission and fees of a transaction into the totals
+func (b *FeeTotals) add(t *trade.Trade) {
+	if t.Commission != nil {
+		b.Commission = b.Commission.Add(b.Commission, t.Commission)
+		b.Total = b.Total.Add(b.Total, t.Commission)
+	}
+	f := t.Fees
+	if f == nil {
+		return
+	}
+	b.RegFee = b.RegFee.Add(b.RegFee, f.RegFee)
+	b.ADRFee = b.ADRFee.Add(b.ADRFee, f.ADRFee)
+	b.RedemptionFee = b.RedemptionFee.Add(b.RedemptionFee, f.RedemptionFee)
+	b.AccountFee = b.AccountFee.Add(b.AccountFee, f.AccountFee)
+	b.NetworkFee = b.NetworkFee.Add(b.NetworkFee, f.NetworkFee)
+	b.Total = b.Total.Add(b.Total, f.Total())
+}
+
+// NewFeeAudit totals the commissions and each fee component charged on
+// the transactions, grouped by the broker that charged them, by month, by
+// year and by symbol, and compares the total to the gross profits
+// realized.
+func NewFeeAudit(trans []*trade.Trade, realized *RealizedPL) *FeeAudit {
+	byBroker := make(map[string]*BrokerFees)
+	byMonth := make(map[string]*MonthFees)
+	byYear := make(map[int]*YearFees)
+	bySymbol := make(map[string]*SymbolFees)
+	a := FeeAudit{
+		Brokers:  make([]*BrokerFees, 0),
+		ByMonth:  make([]*MonthFees, 0),
+		ByYear:   make([]*YearFees, 0),
+		BySymbol: make([]*SymbolFees, 0),
+		Total:    newFeeTotals(),
+		FeeDrag:  big.NewFloat(0.0),
+	}
+
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		b := byBroker[t.Broker]
+		if b == nil {
+			b = &BrokerFees{Broker: t.Broker, FeeTotals: newFeeTotals()}
+			byBroker[t.Broker] = b
+			a.Brokers = append(a.Brokers, b)
+		}
+		b.add(t)
+
+		month := t.Date.Format("2006-01")
+		m := byMonth[month]
+		if m == nil {
+			m = &MonthFees{Month: month, FeeTotals: newFeeTotals()}
+			byMonth[month] = m
+			a.ByMonth = append(a.ByMonth, m)
+		}
+		m.add(t)
+
+		y := byYear[t.Date.Year()]
+		if y == nil {
+			y = &YearFees{Year: t.Date.Year(), FeeTotals: newFeeTotals()}
+			byYear[t.Date.Year()] = y
+			a.ByYear = append(a.ByYear, y)
+		}
+		y.add(t)
+
+		if symbol := strings.TrimSpace(t.Symbol); symbol != "" {
+			s := bySymbol[symbol]
+			if s == nil {
+				s = &SymbolFees{Symbol: symbol, FeeTotals: newFeeTotals()}
+				bySymbol[symbol] = s
+				a.BySymbol = append(a.BySymbol, s)
+			}
+			s.add(t)
+		}
+		a.Total.add(t)
+	}
+
+	grossProfit := big.NewFloat(0.0)
+	for i := 0; i < len(realized.Transactions); i++ {
+		if g := realized.Transactions[i].Gain; g.Sign() > 0 {
+			grossProfit = grossProfit.Add(grossProfit, g)
+		}
+	}
+	if grossProfit.Sign() > 0 {
+		a.FeeDrag = percentOf(a.Total.Total, grossProfit)
+	}
+
+	sort.Slice(a.Brokers, func(i, j int) bool {
+		return a.Brokers[i].Broker < a.Brokers[j].Broker
+	})
+	sort.Slice(a.ByMonth, func(i, j int) bool {
+		return a.ByMonth[i].Month < a.ByMonth[j].Month
+	})
+	sort.Slice(a.ByYear, func(i, j int) bool {
+		return a.ByYear[i].Year < a.ByYear[j].Year
+	})
+	sort.Slice(a.BySymbol, func(i, j int) bool {
+		return a.BySymbol[i].Symbol < a.BySymbol[j].Symbol
+	})
+	return &a
+}
@@ -0,0 +1,212 @@
+package projection
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/pdf"
+	"github.com/stonks/trade"
+)
+
+// WashSaleCode is the Form 8949 adjustment code for a loss disallowed by
+// the wash sale rule
+const WashSaleCode = "W"
+
+// form8949DateFormat is the date format used on Form 8949
+const form8949DateFormat = "01/02/2006"
+
+// Form8949Row is a line of Form 8949 for a single closed lot
+type Form8949Row struct {
+	Account     string
+	Symbol      string
+	Description string // column (a), eg "100 AAPL"
+	Acquired    time.Time
+	Sold        time.Time
+	Proceeds    *big.Float
+	Basis       *big.Float
+	Code        string     // adjustment codes, W for wash sales
+	Adjustment  *big.Float // amount added to the gain, the disallowed loss for wash sales
+	Gain        *big.Float // gain or loss after adjustments
+	LongTerm    bool
+	TaxYear     int
+}
+
+// Form8949 lists every closed lot for reporting on Form 8949, short term
+// lots (part I) before long term lots (part II), each in order of sale
+type Form8949 struct {
+	Rows []*Form8949Row
+}
+
+// NewForm8949 matches closing transactions against open lots and builds a
+// Form 8949 line for each lot closed in a taxable account
+func NewForm8949(trans []*trade.Trade, opts *lots.Options) *Form8949 {
+	return newForm8949(taxableGains(lots.Match(trans, opts).Realized, opts))
+}
+
+// taxableGains returns the gains realized in taxable accounts, leaving out
+// those of retirement accounts
+func taxableGains(realized []*lots.RealizedGain, opts *lots.Options) []*lots.RealizedGain {
+	results := make([]*lots.RealizedGain, 0, len(realized))
+	for i := 0; i < len(realized); i++ {
+		if opts.Taxable(realized[i].Account) {
+			results = append(results, realized[i])
+		}
+	}
+	return results
+}
+
+// taxableTransactions returns the transactions made in taxable accounts,
+// leaving out those of retirement accounts
+func taxableTransactions(trans []*trade.Trade, opts *lots.Options) []*trade.Trade {
+	results := make([]*trade.Trade, 0, len(trans))
+	for i := 0; i < len(trans); i++ {
+		if opts.Taxable(trans[i].Account) {
+			results = append(results, trans[i])
+		}
+	}
+	return results
+}
+
+// newForm8949 builds the Form 8949 lines of closed lots
+func newForm8949(realized []*lots.RealizedGain) *Form8949 {
+	f := Form8949{Rows: make([]*Form8949Row, 0, len(realized))}
+	for i := 0; i < len(realized); i++ {
+		g := realized[i]
+		row := Form8949Row{
+			Account:     g.Account,
+			Symbol:      g.Symbol,
+			Description: g.Quantity.Text('f', -1) + " " + g.Symbol,
+			Acquired:    g.OpenDate,
+			Sold:        g.CloseDate,
+			Proceeds:    big.NewFloat(0.0).Copy(g.Proceeds),
+			Basis:       big.NewFloat(0.0).Copy(g.Basis),
+			Adjustment:  big.NewFloat(0.0),
+			Gain:        big.NewFloat(0.0).Copy(g.Gain),
+			LongTerm:    g.LongTerm(),
+			TaxYear:     g.TaxYear(),
+		}
+		if g.Disallowed.Sign() > 0 {
+			row.Code = WashSaleCode
+			row.Adjustment = row.Adjustment.Add(row.Adjustment, g.Disallowed)
+			row.Gain = row.Gain.Add(row.Gain, g.Disallowed)
+		}
+		f.Rows = append(f.Rows, &row)
+	}
+	sort.SliceStable(f.Rows, func(i, j int) bool {
+		if f.Rows[i].LongTerm != f.Rows[j].LongTerm {
+			return !f.Rows[i].LongTerm
+		}
+		return f.Rows[i].Sold.Before(f.Rows[j].Sold)
+	})
+	return &f
+}
+
+// term returns the holding period category of the row
+func (r *Form8949Row) term() string {
+	if r.LongTerm {
+		return "LONG"
+	}
+	return "SHORT"
+}
+
+// columns returns the row's values in the order of the Form 8949 columns
+func (r *Form8949Row) columns() []string {
+	return []string{
+		r.Description,
+		r.Acquired.Format(form8949DateFormat),
+		r.Sold.Format(form8949DateFormat),
+		r.Proceeds.Text('f', 2),
+		r.Basis.Text('f', 2),
+		r.Code,
+		r.Adjustment.Text('f', 2),
+		r.Gain.Text('f', 2),
+	}
+}
+
+// WriteCSV writes the form as csv, one row per closed lot
+func (f *Form8949) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	header := []string{"Term", "Account", "Description", "Date Acquired", "Date Sold", "Proceeds", "Cost Basis", "Code", "Adjustment", "Gain or Loss"}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for i := 0; i < len(f.Rows); i++ {
+		r := f.Rows[i]
+		record := append([]string{r.term(), r.Account}, r.columns()...)
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WritePDF writes the form as a pdf, with part I listing the short term
+// lots and part II the long term lots of each tax year
+func (f *Form8949) WritePDF(w io.Writer) error {
+	doc := pdf.NewDocument()
+	format := "%-28s %-10s %-10s %14s %14s %-4s %12s %14s"
+	header := fmt.Sprintf(format, "(a) Description", "(b) Acq.", "(c) Sold", "(d) Proceeds", "(e) Basis", "(f)", "(g) Adj.", "(h) Gain")
+
+	years := make([]int, 0)
+	seen := make(map[int]bool)
+	for i := 0; i < len(f.Rows); i++ {
+		if !seen[f.Rows[i].TaxYear] {
+			seen[f.Rows[i].TaxYear] = true
+			years = append(years, f.Rows[i].TaxYear)
+		}
+	}
+	sort.Ints(years)
+
+	for i := 0; i < len(years); i++ {
+		parts := []struct {
+			title    string
+			longTerm bool
+		}{
+			{"Part I - Short-Term", false},
+			{"Part II - Long-Term", true},
+		}
+		for j := 0; j < len(parts); j++ {
+			lines := []string{
+				fmt.Sprintf("Form 8949 Sales and Other Dispositions of Capital Assets - Tax Year %d", years[i]),
+				parts[j].title,
+				"",
+				header,
+				strings.Repeat("-", len(header)),
+			}
+			proceeds := big.NewFloat(0.0)
+			basis := big.NewFloat(0.0)
+			adjustment := big.NewFloat(0.0)
+			gain := big.NewFloat(0.0)
+			count := 0
+			for k := 0; k < len(f.Rows); k++ {
+				r := f.Rows[k]
+				if r.TaxYear != years[i] || r.LongTerm != parts[j].longTerm {
+					continue
+				}
+				c := r.columns()
+				lines = append(lines, fmt.Sprintf(format, c[0], c[1], c[2], c[3], c[4], c[5], c[6], c[7]))
+				proceeds = proceeds.Add(proceeds, r.Proceeds)
+				basis = basis.Add(basis, r.Basis)
+				adjustment = adjustment.Add(adjustment, r.Adjustment)
+				gain = gain.Add(gain, r.Gain)
+				count++
+			}
+			// guard clause: skip parts with nothing to report
+			if count == 0 {
+				continue
+			}
+			lines = append(lines,
+				strings.Repeat("-", len(header)),
+				fmt.Sprintf(format, "Totals", "", "", proceeds.Text('f', 2), basis.Text('f', 2), "", adjustment.Text('f', 2), gain.Text('f', 2)))
+			doc.AddPage(lines)
+		}
+	}
+	return doc.Write(w)
+}
@@ -0,0 +1,145 @@
+package projection
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/stonks/analytics"
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// GroupByAccount organizes a list of transactions by the account they
+// were made in. the key of the returned map is the account name.
+func GroupByAccount(trans []*trade.Trade) map[string][]*trade.Trade {
+	results := make(map[string][]*trade.Trade)
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		results[t.Account] = append(results[t.Account], t)
+	}
+	return results
+}
+
+// GroupByTag organizes a list of transactions by their tags. the key of
+// the returned map is the tag, transactions with multiple tags appear
+// under each of them and untagged transactions are left out.
+func GroupByTag(trans []*trade.Trade) map[string][]*trade.Trade {
+	results := make(map[string][]*trade.Trade)
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		for j := 0; j < len(t.Tags); j++ {
+			results[t.Tags[j]] = append(results[t.Tags[j]], t)
+		}
+	}
+	return results
+}
+
+// FilterByTags returns the transactions tagged with at least one of the
+// given tags. every transaction is returned when no tags are given.
+func FilterByTags(trans []*trade.Trade, tags []string) []*trade.Trade {
+	if len(tags) == 0 {
+		return trans
+	}
+	results := make([]*trade.Trade, 0)
+	for i := 0; i < len(trans); i++ {
+		for j := 0; j < len(tags); j++ {
+			if trans[i].HasTag(tags[j]) {
+				results = append(results, trans[i])
+				break
+			}
+		}
+	}
+	return results
+}
+
+// Dimension is what transactions are grouped by
+type Dimension string
+
+const (
+	SymbolDimension     Dimension = "SYMBOL"
+	UnderlyingDimension Dimension = "UNDERLYING"
+	AccountDimension    Dimension = "ACCOUNT"
+	TagDimension        Dimension = "TAG"
+	MonthDimension      Dimension = "MONTH"
+	QuarterDimension    Dimension = "QUARTER"
+	YearDimension       Dimension = "YEAR"
+	// StrategyDimension groups option trades by the type of strategy they
+	// were opened as part of, eg IRON_CONDOR
+	StrategyDimension Dimension = "STRATEGY"
+)
+
+// GroupBy organizes a list of transactions along a dimension. the key of
+// the returned map is the symbol, underlying, account, tag, period or
+// strategy type. transactions with multiple tags appear under each of
+// them, and untagged transactions and trades that aren't part of an option
+// strategy are left out of the tag and strategy groups.
+func GroupBy(trans []*trade.Trade, by Dimension, opts *lots.Options) (map[string][]*trade.Trade, error) {
+	switch by {
+	case AccountDimension:
+		return GroupByAccount(trans), nil
+	case TagDimension:
+		return GroupByTag(trans), nil
+	case MonthDimension, QuarterDimension, YearDimension:
+		b, err := NewBucketer(Bucketing(by), "")
+		if err != nil {
+			return nil, err
+		}
+		return GroupByPeriod(trans, b), nil
+	case StrategyDimension:
+		return groupByStrategy(trans, opts), nil
+	case SymbolDimension:
+		return analytics.GroupBy(trans, analytics.Symbol).ByKey, nil
+	case UnderlyingDimension:
+		return analytics.GroupBy(trans, analytics.Underlying).ByKey, nil
+	}
+	return nil, fmt.Errorf("unknown dimension %q", by)
+}
+
+// groupByStrategy organizes option trades by the type of strategy they
+// opened or closed legs of
+func groupByStrategy(trans []*trade.Trade, opts *lots.Options) map[string][]*trade.Trade {
+	strategies := NewOptionStrategies(trans, opts)
+	// the strategy type of each opening leg, by account and id
+	types := make(map[string]StrategyType)
+	for i := 0; i < len(strategies.Strategies); i++ {
+		s := strategies.Strategies[i]
+		for j := 0; j < len(s.Legs); j++ {
+			types[s.Account+"|"+s.Legs[j].ID] = s.Type
+		}
+	}
+	// trades closing a leg belong to the strategy of the leg
+	closing := make(map[*trade.Trade]StrategyType)
+	matched := lots.Match(trans, opts)
+	for i := 0; i < len(matched.Realized); i++ {
+		g := matched.Realized[i]
+		if t, ok := types[g.Lot.Account+"|"+g.Lot.OpeningID]; ok && g.Closing != nil {
+			closing[g.Closing] = t
+		}
+	}
+
+	results := make(map[string][]*trade.Trade)
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		strategy, ok := types[t.Account+"|"+t.ID]
+		if !ok {
+			strategy, ok = closing[t]
+		}
+		if ok {
+			results[string(strategy)] = append(results[string(strategy)], t)
+		}
+	}
+	return results
+}
+
+// FilterThrough returns the transactions made on or before the date, so
+// results can be replayed as they stood at the end of that day
+func FilterThrough(trans []*trade.Trade, date time.Time) []*trade.Trade {
+	end := startOfDay(date).AddDate(0, 0, 1)
+	results := make([]*trade.Trade, 0, len(trans))
+	for i := 0; i < len(trans); i++ {
+		if trans[i].Date.Before(end) {
+			results = append(results, trans[i])
+		}
+	}
+	return results
+}
@@ -0,0 +1,144 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/quotes"
+	"github.com/stonks/trade"
+)
+
+// DefaultHarvestThreshold is the smallest unrealized loss, in the base
+// currency, a lot is listed as a harvesting candidate for
+const DefaultHarvestThreshold = 100.0
+
+// HarvestLot is an open lot at an unrealized loss
+type HarvestLot struct {
+	Account     string
+	Symbol      string
+	Opened      time.Time
+	Quantity    *big.Float
+	Cost        *big.Float
+	Price       *big.Float
+	MarketValue *big.Float
+	Loss        *big.Float // unrealized loss, as a positive amount
+	LongTerm    bool       // true if selling now would realize a long term loss
+	// Replacement is the id of the most recent purchase that would wash a
+	// sale of the lot, and SafeAfter the first day it could be sold
+	// without a wash sale. blank for candidates.
+	Replacement string `json:",omitempty"`
+	SafeAfter   time.Time
+}
+
+// HarvestCandidates lists the open lots whose losses could be harvested
+type HarvestCandidates struct {
+	AsOf       time.Time
+	Threshold  *big.Float
+	Candidates []*HarvestLot // largest loss first
+	Loss       *big.Float    // total loss of the candidates
+	// Blocked holds the lots at a loss that would be washed by a purchase
+	// made within the wash sale window before the as of date
+	Blocked []*HarvestLot
+}
+
+// NewHarvestCandidates values the open long lots at the provider's prices
+// and lists those with an unrealized loss of at least threshold. lots of a
+// security bought again within the wash sale window are listed as blocked
+// rather than as candidates, since selling them now would disallow the
+// loss. lots in retirement accounts are left out as their losses aren't
+// deductible.
+func NewHarvestCandidates(trans []*trade.Trade, positions *Positions, provider quotes.Provider, opts *lots.Options, threshold float64) *HarvestCandidates {
+	h := HarvestCandidates{
+		AsOf:       positions.AsOf,
+		Threshold:  big.NewFloat(threshold),
+		Candidates: make([]*HarvestLot, 0),
+		Loss:       big.NewFloat(0.0),
+		Blocked:    make([]*HarvestLot, 0),
+	}
+	var rules lots.Jurisdiction = lots.US{}
+	rule := lots.WashSaleRule{}
+	if opts != nil {
+		if opts.Jurisdiction != nil {
+			rules = opts.Jurisdiction
+		}
+		if opts.WashSale != nil {
+			rule = *opts.WashSale
+		}
+	}
+	if rule.WindowDays == 0 {
+		rule.WindowDays = rules.WashSaleWindow()
+	}
+	windowStart := h.AsOf.AddDate(0, 0, -rule.WindowDays)
+
+	for i := 0; i < len(positions.Positions); i++ {
+		p := positions.Positions[i]
+		// guard clause: short positions and retirement accounts can't harvest
+		if p.Quantity.Sign() <= 0 || !opts.Taxable(p.Account) {
+			continue
+		}
+		price, err := provider.Quote(p.Symbol)
+		if err != nil {
+			continue
+		}
+		for j := 0; j < len(p.Lots); j++ {
+			lot := p.Lots[j]
+			value := marketValue(lot, price)
+			loss := big.NewFloat(0.0).Sub(lot.Cost, value)
+			if loss.Cmp(h.Threshold) < 0 || loss.Sign() <= 0 {
+				continue
+			}
+			l := HarvestLot{
+				Account:     lot.Account,
+				Symbol:      lot.Symbol,
+				Opened:      lot.OpenDate,
+				Quantity:    big.NewFloat(0.0).Copy(lot.Quantity),
+				Cost:        big.NewFloat(0.0).Copy(lot.Cost),
+				Price:       price,
+				MarketValue: value,
+				Loss:        loss,
+				LongTerm:    rules.LongTerm(lot.HoldingStart, h.AsOf),
+			}
+			if replacement := lastReplacement(trans, lot, &rule, windowStart, h.AsOf); replacement != nil {
+				l.Replacement = replacement.ID
+				l.SafeAfter = replacement.Date.AddDate(0, 0, rule.WindowDays+1)
+				h.Blocked = append(h.Blocked, &l)
+				continue
+			}
+			h.Candidates = append(h.Candidates, &l)
+			h.Loss = h.Loss.Add(h.Loss, loss)
+		}
+	}
+	sort.SliceStable(h.Candidates, func(i, j int) bool {
+		return h.Candidates[i].Loss.Cmp(h.Candidates[j].Loss) > 0
+	})
+	sort.SliceStable(h.Blocked, func(i, j int) bool {
+		return h.Blocked[i].SafeAfter.Before(h.Blocked[j].SafeAfter)
+	})
+	return &h
+}
+
+// lastReplacement returns the latest purchase made in the window that
+// would replace the lot if it were sold, or nil if there isn't one. the
+// purchase that opened the lot doesn't replace it.
+func lastReplacement(trans []*trade.Trade, lot *lots.Lot, rule *lots.WashSaleRule, from time.Time, to time.Time) *trade.Trade {
+	var last *trade.Trade
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if t.Type != trade.Buy || t == lot.Opening || t.Date.Before(from) || t.Date.After(to) {
+			continue
+		}
+		if t.Account != lot.Account && !rule.CrossAccount {
+			continue
+		}
+		identical := t.Symbol == lot.Symbol
+		if !identical && rule.IncludeOptions && t.Instrument != nil && lot.Opening != nil && lot.Opening.Instrument != nil {
+			identical = t.Instrument.Underlying == lot.Opening.Instrument.Underlying
+		}
+		if identical && (last == nil || t.Date.After(last.Date)) {
+			last = t
+		}
+	}
+	return last
+}
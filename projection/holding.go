@@ -0,0 +1,98 @@
+package projection
+
+import (
+	"math/big"
+	"time"
+)
+
+// holdingBuckets are the upper bounds of the holding period buckets and
+// their names. the last bucket has no upper bound.
+var holdingBuckets = []struct {
+	name string
+	max  time.Duration
+}{
+	{"under 5 minutes", 5 * time.Minute},
+	{"5 to 60 minutes", time.Hour},
+	{"1 to 24 hours", 24 * time.Hour},
+	{"1 to 7 days", 7 * 24 * time.Hour},
+	{"1 to 4 weeks", 28 * 24 * time.Hour},
+	{"1 to 3 months", 91 * 24 * time.Hour},
+	{"3 to 12 months", 365 * 24 * time.Hour},
+	{"over a year", 0},
+}
+
+// HoldingBucket counts the round trips held for a range of time
+type HoldingBucket struct {
+	Name     string
+	Trades   int
+	Winners  int
+	Losers   int
+	WinnerPL *big.Float
+	LoserPL  *big.Float
+}
+
+// HoldingPeriods is the distribution of how long round trips were held,
+// split by winners and losers
+type HoldingPeriods struct {
+	Buckets []*HoldingBucket // ordered from the shortest holding periods
+	// AverageWinnerDays and AverageLoserDays are the average days winning
+	// and losing round trips were held
+	AverageWinnerDays *big.Float
+	AverageLoserDays  *big.Float
+}
+
+// NewHoldingPeriods buckets the closed round trips by how long they were
+// held. round trips of transactions with only a date, and no time, that
+// open and close on the same day fall in the shortest bucket.
+func NewHoldingPeriods(trips *RoundTrips) *HoldingPeriods {
+	h := HoldingPeriods{
+		Buckets:           make([]*HoldingBucket, len(holdingBuckets)),
+		AverageWinnerDays: big.NewFloat(0.0),
+		AverageLoserDays:  big.NewFloat(0.0),
+	}
+	for i := 0; i < len(holdingBuckets); i++ {
+		h.Buckets[i] = &HoldingBucket{
+			Name:     holdingBuckets[i].name,
+			WinnerPL: big.NewFloat(0.0),
+			LoserPL:  big.NewFloat(0.0),
+		}
+	}
+
+	winners, losers := 0, 0
+	winnerDays, loserDays := 0.0, 0.0
+	for i := 0; i < len(trips.Closed); i++ {
+		trip := trips.Closed[i]
+		held := trip.Close.Sub(trip.Open)
+		b := h.Buckets[holdingBucket(held)]
+		b.Trades++
+		switch trip.PL.Sign() {
+		case 1:
+			b.Winners++
+			b.WinnerPL = b.WinnerPL.Add(b.WinnerPL, trip.PL)
+			winners++
+			winnerDays += held.Hours() / 24
+		case -1:
+			b.Losers++
+			b.LoserPL = b.LoserPL.Add(b.LoserPL, trip.PL)
+			losers++
+			loserDays += held.Hours() / 24
+		}
+	}
+	if winners > 0 {
+		h.AverageWinnerDays = big.NewFloat(winnerDays / float64(winners))
+	}
+	if losers > 0 {
+		h.AverageLoserDays = big.NewFloat(loserDays / float64(losers))
+	}
+	return &h
+}
+
+// holdingBucket returns the index of the bucket a holding period falls in
+func holdingBucket(held time.Duration) int {
+	for i := 0; i < len(holdingBuckets)-1; i++ {
+		if held < holdingBuckets[i].max {
+			return i
+		}
+	}
+	return len(holdingBuckets) - 1
+}
@@ -0,0 +1,80 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/stonks/trade"
+)
+
+// MonthlyInterest holds the interest totals for a single calendar month
+type MonthlyInterest struct {
+	Month      string     // calendar month in YYYY-MM format
+	Earned     *big.Float // credit interest paid to the account
+	MarginCost *big.Float // margin interest charged to the account, as a positive amount
+	Net        *big.Float // interest earned less margin cost
+}
+
+// InterestSummary compares the interest earned on cash balances against
+// the cost of margin borrowing
+type InterestSummary struct {
+	Months          []*MonthlyInterest // per month totals ordered by month
+	TotalEarned     *big.Float         // credit interest earned across all months
+	TotalMarginCost *big.Float         // margin interest paid across all months
+	Net             *big.Float         // total earned less total margin cost
+}
+
+// newMonthlyInterest returns a new instance of monthly interest totals
+// with all amounts zeroed out
+func newMonthlyInterest(month string) *MonthlyInterest {
+	return &MonthlyInterest{
+		Month:      month,
+		Earned:     big.NewFloat(0.0),
+		MarginCost: big.NewFloat(0.0),
+		Net:        big.NewFloat(0.0),
+	}
+}
+
+// NewInterestSummary totals up interest and margin interest transactions
+// by the month they were posted.
+func NewInterestSummary(trans []*trade.Trade) *InterestSummary {
+	byMonth := make(map[string]*MonthlyInterest)
+	s := InterestSummary{
+		Months:          make([]*MonthlyInterest, 0),
+		TotalEarned:     big.NewFloat(0.0),
+		TotalMarginCost: big.NewFloat(0.0),
+		Net:             big.NewFloat(0.0),
+	}
+
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		// guard clause: only interest transactions count
+		if t.Type != trade.Interest && t.Type != trade.MarginInterest {
+			continue
+		}
+
+		month := t.Date.Format("2006-01")
+		m := byMonth[month]
+		if m == nil {
+			m = newMonthlyInterest(month)
+			byMonth[month] = m
+			s.Months = append(s.Months, m)
+		}
+
+		if t.Type == trade.Interest {
+			m.Earned = m.Earned.Add(m.Earned, t.Amount)
+			s.TotalEarned = s.TotalEarned.Add(s.TotalEarned, t.Amount)
+		} else {
+			// margin interest is a debit, flip the sign to report it as a cost
+			m.MarginCost = m.MarginCost.Sub(m.MarginCost, t.Amount)
+			s.TotalMarginCost = s.TotalMarginCost.Sub(s.TotalMarginCost, t.Amount)
+		}
+		m.Net = m.Net.Add(m.Net, t.Amount)
+		s.Net = s.Net.Add(s.Net, t.Amount)
+	}
+
+	sort.Slice(s.Months, func(i, j int) bool {
+		return s.Months[i].Month < s.Months[j].Month
+	})
+	return &s
+}
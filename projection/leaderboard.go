@@ -0,0 +1,86 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+)
+
+// DefaultLeaderboardSize is how many round trips are listed at each end of
+// the leaderboard by default
+const DefaultLeaderboardSize = 10
+
+// LeaderboardEntry is a round trip ranked on the leaderboard
+type LeaderboardEntry struct {
+	Rank     int
+	ID       string
+	Account  string
+	Symbol   string
+	Open     time.Time
+	Close    time.Time
+	PL       *big.Float
+	Return   *big.Float
+	Notes    string `json:",omitempty"` // notes from the journal entry of the round trip
+	Strategy string `json:",omitempty"`
+}
+
+// TradeLeaderboard lists the best and worst closed round trips by P/L and
+// by percent return
+type TradeLeaderboard struct {
+	Best        []*LeaderboardEntry // highest P/L first
+	Worst       []*LeaderboardEntry // lowest P/L first
+	BestReturn  []*LeaderboardEntry // highest return first
+	WorstReturn []*LeaderboardEntry // lowest return first
+}
+
+// NewTradeLeaderboard ranks the closed round trips and lists the top and
+// bottom n by P/L and by return
+func NewTradeLeaderboard(trips *RoundTrips, n int) *TradeLeaderboard {
+	byPL := make([]*RoundTrip, len(trips.Closed))
+	copy(byPL, trips.Closed)
+	sort.SliceStable(byPL, func(i, j int) bool {
+		return byPL[i].PL.Cmp(byPL[j].PL) > 0
+	})
+	byReturn := make([]*RoundTrip, len(trips.Closed))
+	copy(byReturn, trips.Closed)
+	sort.SliceStable(byReturn, func(i, j int) bool {
+		return byReturn[i].Return.Cmp(byReturn[j].Return) > 0
+	})
+	return &TradeLeaderboard{
+		Best:        leaders(byPL, n, false),
+		Worst:       leaders(byPL, n, true),
+		BestReturn:  leaders(byReturn, n, false),
+		WorstReturn: leaders(byReturn, n, true),
+	}
+}
+
+// leaders returns the first n ranked round trips, or the last n starting
+// from the end when reversed
+func leaders(ranked []*RoundTrip, n int, reversed bool) []*LeaderboardEntry {
+	if n > len(ranked) {
+		n = len(ranked)
+	}
+	results := make([]*LeaderboardEntry, n)
+	for i := 0; i < n; i++ {
+		trip := ranked[i]
+		if reversed {
+			trip = ranked[len(ranked)-1-i]
+		}
+		e := LeaderboardEntry{
+			Rank:    i + 1,
+			ID:      trip.ID,
+			Account: trip.Account,
+			Symbol:  trip.Symbol,
+			Open:    trip.Open,
+			Close:   trip.Close,
+			PL:      trip.PL,
+			Return:  trip.Return,
+		}
+		if trip.Journal != nil {
+			e.Notes = trip.Journal.Notes
+			e.Strategy = trip.Journal.Strategy
+		}
+		results[i] = &e
+	}
+	return results
+}
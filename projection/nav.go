@@ -0,0 +1,117 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/quotes"
+	"github.com/stonks/trade"
+)
+
+// NAVPoint is the value of the portfolio at the end of a day
+type NAVPoint struct {
+	Date     time.Time
+	Cash     *big.Float
+	Holdings *big.Float // market value of the open positions
+	NAV      *big.Float // cash plus holdings
+	// Flows are the deposits less withdrawals made on the day, which
+	// change the value without being a return
+	Flows *big.Float
+}
+
+// NAVSeries is the daily value of the portfolio
+type NAVSeries struct {
+	Points []*NAVPoint // one per weekday from the first transaction, in date order
+	// Unpriced lists the symbols missing from the price history on some
+	// days, which are valued at the price they last traded at instead
+	Unpriced []string
+}
+
+// NewNAVSeries replays the transactions day by day up to end, valuing the
+// positions open at the end of each weekday at their historical close and
+// adding the cash balance
+func NewNAVSeries(trans []*trade.Trade, history quotes.History, end time.Time) *NAVSeries {
+	ordered := make([]*trade.Trade, len(trans))
+	copy(ordered, trans)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Date.Before(ordered[j].Date)
+	})
+	n := NAVSeries{Points: make([]*NAVPoint, 0), Unpriced: make([]string, 0)}
+	// guard clause: nothing to value
+	if len(ordered) == 0 {
+		return &n
+	}
+
+	e := lots.NewEngine(nil)
+	cash := big.NewFloat(0.0)
+	lastPrice := make(map[string]*big.Float)
+	unpriced := make(map[string]bool)
+	next := 0
+	flows := big.NewFloat(0.0)
+	first := ordered[0].Date
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC); !day.After(end); day = day.AddDate(0, 0, 1) {
+		endOfDay := day.AddDate(0, 0, 1)
+		for ; next < len(ordered) && ordered[next].Date.Before(endOfDay); next++ {
+			t := ordered[next]
+			if movesCash(t) {
+				cash = cash.Add(cash, t.Amount)
+			}
+			if t.IsExternalCashFlow() && t.Amount != nil {
+				flows = flows.Add(flows, t.Amount)
+			}
+			if t.Price != nil && t.Price.Sign() > 0 {
+				lastPrice[t.Symbol] = t.Price
+			}
+			if t.IsCashEquivalent() {
+				// sweeps are part of the cash balance
+				continue
+			}
+			if lots.Affects(t) {
+				e.Apply(t)
+			}
+		}
+		// weekends are only replayed, values and the flows made over the
+		// weekend are reported on weekdays
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+
+		p := NAVPoint{Date: day, Cash: big.NewFloat(0.0).Copy(cash), Holdings: big.NewFloat(0.0), Flows: flows}
+		open := e.OpenLots()
+		for i := 0; i < len(open); i++ {
+			lot := open[i]
+			price, err := history.Close(lot.Symbol, day)
+			if err != nil {
+				price = lastPrice[lot.Symbol]
+				if price == nil {
+					price = big.NewFloat(0.0)
+				}
+				if !unpriced[lot.Symbol] {
+					unpriced[lot.Symbol] = true
+					n.Unpriced = append(n.Unpriced, lot.Symbol)
+				}
+			}
+			p.Holdings = p.Holdings.Add(p.Holdings, marketValue(lot, price))
+		}
+		p.NAV = big.NewFloat(0.0).Add(p.Cash, p.Holdings)
+		n.Points = append(n.Points, &p)
+		flows = big.NewFloat(0.0)
+	}
+	sort.Strings(n.Unpriced)
+	return &n
+}
+
+// marketValue returns the value of a lot at a price, negative for short lots
+func marketValue(lot *lots.Lot, price *big.Float) *big.Float {
+	instrument := trade.NewInstrument(lot.Symbol)
+	if lot.Opening != nil && lot.Opening.Instrument != nil {
+		instrument = lot.Opening.Instrument
+	}
+	value := instrument.Notional(lot.Quantity, price)
+	if lot.Quantity.Sign() < 0 {
+		value = value.Neg(value)
+	}
+	return value
+}
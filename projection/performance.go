@@ -0,0 +1,158 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+)
+
+// TradeStats are the performance statistics of a set of closed round trips
+type TradeStats struct {
+	Trades      int
+	Wins        int
+	Losses      int        // round trips that broke even are neither wins nor losses
+	WinRate     *big.Float // percent of round trips that were wins
+	AverageWin  *big.Float
+	AverageLoss *big.Float // as a negative amount
+	// ProfitFactor is gross profits over gross losses, nil if there
+	// weren't any losses
+	ProfitFactor *big.Float `json:",omitempty"`
+	Expectancy   *big.Float // average P/L per round trip
+	LargestWin   *big.Float
+	LargestLoss  *big.Float
+	// MaxWinStreak and MaxLossStreak are the most wins and losses in a
+	// row, in the order the round trips were closed
+	MaxWinStreak  int
+	MaxLossStreak int
+	// RTrades is how many round trips had an initial risk in the journal,
+	// and RExpectancy their average R-multiple
+	RTrades     int
+	RExpectancy *big.Float `json:",omitempty"`
+
+	rTotal      *big.Float
+	grossProfit *big.Float
+	grossLoss   *big.Float
+	total       *big.Float
+	winStreak   int
+	lossStreak  int
+}
+
+// SymbolTradeStats are the performance statistics of a symbol's round trips
+type SymbolTradeStats struct {
+	Symbol string
+	Stats  *TradeStats
+}
+
+// PerformanceStats reports how profitable trading was, overall and per
+// symbol
+type PerformanceStats struct {
+	Overall  *TradeStats
+	BySymbol []*SymbolTradeStats // ordered by symbol
+}
+
+// NewPerformanceStats computes the win rate, average win and loss, profit
+// factor, expectancy, largest win and loss and longest streaks of the
+// closed round trips
+func NewPerformanceStats(trips *RoundTrips) *PerformanceStats {
+	p := PerformanceStats{
+		Overall:  newTradeStats(),
+		BySymbol: make([]*SymbolTradeStats, 0),
+	}
+	bySymbol := make(map[string]*SymbolTradeStats)
+	for i := 0; i < len(trips.Closed); i++ {
+		trip := trips.Closed[i]
+		p.Overall.add(trip)
+		s := bySymbol[trip.Symbol]
+		if s == nil {
+			s = &SymbolTradeStats{Symbol: trip.Symbol, Stats: newTradeStats()}
+			bySymbol[trip.Symbol] = s
+			p.BySymbol = append(p.BySymbol, s)
+		}
+		s.Stats.add(trip)
+	}
+
+	p.Overall.finish()
+	for i := 0; i < len(p.BySymbol); i++ {
+		p.BySymbol[i].Stats.finish()
+	}
+	sort.Slice(p.BySymbol, func(i, j int) bool {
+		return p.BySymbol[i].Symbol < p.BySymbol[j].Symbol
+	})
+	return &p
+}
+
+// newTradeStats returns the statistics of no round trips
+func newTradeStats() *TradeStats {
+	return &TradeStats{
+		WinRate:     big.NewFloat(0.0),
+		AverageWin:  big.NewFloat(0.0),
+		AverageLoss: big.NewFloat(0.0),
+		Expectancy:  big.NewFloat(0.0),
+		LargestWin:  big.NewFloat(0.0),
+		LargestLoss: big.NewFloat(0.0),
+		grossProfit: big.NewFloat(0.0),
+		grossLoss:   big.NewFloat(0.0),
+		total:       big.NewFloat(0.0),
+		rTotal:      big.NewFloat(0.0),
+	}
+}
+
+// add counts a closed round trip. round trips must be added in the order
+// they were closed for the streaks to be right.
+func (s *TradeStats) add(trip *RoundTrip) {
+	s.Trades++
+	s.total = s.total.Add(s.total, trip.PL)
+	if trip.RMultiple != nil {
+		s.RTrades++
+		s.rTotal = s.rTotal.Add(s.rTotal, trip.RMultiple)
+	}
+	switch trip.PL.Sign() {
+	case 1:
+		s.Wins++
+		s.grossProfit = s.grossProfit.Add(s.grossProfit, trip.PL)
+		if trip.PL.Cmp(s.LargestWin) > 0 {
+			s.LargestWin = trip.PL
+		}
+		s.winStreak++
+		s.lossStreak = 0
+	case -1:
+		s.Losses++
+		s.grossLoss = s.grossLoss.Add(s.grossLoss, trip.PL)
+		if trip.PL.Cmp(s.LargestLoss) < 0 {
+			s.LargestLoss = trip.PL
+		}
+		s.lossStreak++
+		s.winStreak = 0
+	default:
+		// breaking even ends either streak
+		s.winStreak = 0
+		s.lossStreak = 0
+	}
+	if s.winStreak > s.MaxWinStreak {
+		s.MaxWinStreak = s.winStreak
+	}
+	if s.lossStreak > s.MaxLossStreak {
+		s.MaxLossStreak = s.lossStreak
+	}
+}
+
+// finish computes the averages and ratios once every round trip is added
+func (s *TradeStats) finish() {
+	// guard clause: nothing to average
+	if s.Trades == 0 {
+		return
+	}
+	trades := big.NewFloat(float64(s.Trades))
+	s.WinRate = big.NewFloat(0.0).Quo(big.NewFloat(float64(s.Wins)), trades)
+	s.WinRate = s.WinRate.Mul(s.WinRate, big.NewFloat(100))
+	s.Expectancy = big.NewFloat(0.0).Quo(s.total, trades)
+	if s.RTrades > 0 {
+		s.RExpectancy = big.NewFloat(0.0).Quo(s.rTotal, big.NewFloat(float64(s.RTrades)))
+	}
+	if s.Wins > 0 {
+		s.AverageWin = big.NewFloat(0.0).Quo(s.grossProfit, big.NewFloat(float64(s.Wins)))
+	}
+	if s.Losses > 0 {
+		s.AverageLoss = big.NewFloat(0.0).Quo(s.grossLoss, big.NewFloat(float64(s.Losses)))
+		s.ProfitFactor = big.NewFloat(0.0).Quo(s.grossProfit, big.NewFloat(0.0).Neg(s.grossLoss))
+	}
+}
@@ -0,0 +1,98 @@
+package projection
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// Bucketing is the length of the periods results are grouped into
+type Bucketing string
+
+const (
+	Monthly   Bucketing = "MONTH"
+	Quarterly Bucketing = "QUARTER"
+	Yearly    Bucketing = "YEAR"
+	// FiscalYear groups by a year starting on a configured day, named by
+	// the calendar year it ends in
+	FiscalYear Bucketing = "FISCAL_YEAR"
+)
+
+// Bucketer assigns dates to the period they fall in
+type Bucketer struct {
+	By Bucketing
+	// FiscalYearStart is the month and day fiscal years start on, only
+	// the month and day are used
+	FiscalYearStart time.Time
+}
+
+// NewBucketer returns a bucketer for the bucketing. fiscalYearStart is the
+// month and day (MM-DD) fiscal years start on, and defaults to January 1st.
+func NewBucketer(by Bucketing, fiscalYearStart string) (*Bucketer, error) {
+	b := Bucketer{By: by, FiscalYearStart: time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	switch by {
+	case Monthly, Quarterly, Yearly:
+	case FiscalYear:
+		if fiscalYearStart != "" {
+			start, err := time.Parse("01-02", fiscalYearStart)
+			if err != nil {
+				return nil, err
+			}
+			b.FiscalYearStart = start
+		}
+	default:
+		return nil, fmt.Errorf("unknown period %q", by)
+	}
+	return &b, nil
+}
+
+// Bucket returns the name of the period the date falls in, eg 2023-01 for
+// months, 2023-Q1 for quarters, 2023 for years and FY2024 for fiscal years
+func (b *Bucketer) Bucket(date time.Time) string {
+	switch b.By {
+	case Monthly:
+		return date.Format("2006-01")
+	case Quarterly:
+		return fmt.Sprintf("%d-Q%d", date.Year(), (int(date.Month())-1)/3+1)
+	case FiscalYear:
+		start := time.Date(date.Year(), b.FiscalYearStart.Month(), b.FiscalYearStart.Day(), 0, 0, 0, 0, date.Location())
+		year := date.Year()
+		// a fiscal year starting after January 1st ends the next calendar year
+		if !date.Before(start) && !start.Equal(time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, date.Location())) {
+			year++
+		}
+		return fmt.Sprintf("FY%d", year)
+	}
+	return fmt.Sprintf("%d", date.Year())
+}
+
+// GroupByPeriod organizes a list of transactions by the period they were
+// made in. the key of the returned map is the name of the period.
+func GroupByPeriod(trans []*trade.Trade, b *Bucketer) map[string][]*trade.Trade {
+	results := make(map[string][]*trade.Trade)
+	for i := 0; i < len(trans); i++ {
+		period := b.Bucket(trans[i].Date)
+		results[period] = append(results[period], trans[i])
+	}
+	return results
+}
+
+// RealizedByPeriod matches every transaction against open lots and totals
+// the gains realized in each period, so lots opened in an earlier period
+// keep their cost basis. the key of the returned map is the name of the
+// period the lots were closed in.
+func RealizedByPeriod(trans []*trade.Trade, opts *lots.Options, b *Bucketer) map[string]*RealizedPL {
+	realized := lots.Match(trans, opts).Realized
+	byPeriod := make(map[string][]*lots.RealizedGain)
+	for i := 0; i < len(realized); i++ {
+		period := b.Bucket(realized[i].CloseDate)
+		byPeriod[period] = append(byPeriod[period], realized[i])
+	}
+	results := make(map[string]*RealizedPL)
+	for period, gains := range byPeriod {
+		results[period] = newRealizedPL(gains)
+	}
+	return results
+}
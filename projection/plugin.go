@@ -0,0 +1,120 @@
+package projection
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/stonks/trade"
+)
+
+// Plugin is a projection run out of process, so analytics can be added
+// without changing the code. the command is started when the first
+// transaction is applied and sent each transaction as a line of json on
+// its stdin, in date order. once stdin is closed it writes its result to
+// stdout as a single json value. anything it writes to stderr is passed
+// through, and exiting with a non-zero status fails the run.
+type Plugin struct {
+	Name    string   `json:"name"`
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+}
+
+// RegisterPlugin adds a plugin to the registry under its name. plugins
+// can't replace a projection already registered, since the projections
+// that depend on it would be fed the plugin's results.
+func RegisterPlugin(p Plugin) error {
+	if _, ok := registry[p.Name]; ok {
+		return fmt.Errorf("plugin %q: a projection named %q is already registered", p.Name, p.Name)
+	}
+	Register(p.Name, func(s *Settings) Projection {
+		return &pluginProjection{plugin: p}
+	})
+	return nil
+}
+
+// PluginError is the result of a plugin that failed
+type PluginError struct {
+	Plugin string
+	Err    error
+}
+
+// Error describes the failure
+func (e *PluginError) Error() string {
+	return fmt.Sprintf("plugin %q: %v", e.Plugin, e.Err)
+}
+
+// pluginProjection feeds the transactions to a running plugin
+type pluginProjection struct {
+	plugin Plugin
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout bytes.Buffer
+	enc    *json.Encoder
+	err    error // first error starting or writing to the plugin
+}
+
+// Name returns the name of the plugin
+func (p *pluginProjection) Name() string {
+	return p.plugin.Name
+}
+
+// start starts the plugin, unless it is running already or failed to
+func (p *pluginProjection) start() {
+	if p.cmd != nil || p.err != nil {
+		return
+	}
+	p.cmd = exec.Command(p.plugin.Command, p.plugin.Args...)
+	p.cmd.Stdout = &p.stdout
+	p.cmd.Stderr = os.Stderr
+	if p.stdin, p.err = p.cmd.StdinPipe(); p.err != nil {
+		return
+	}
+	p.enc = json.NewEncoder(p.stdin)
+	p.err = p.cmd.Start()
+}
+
+// Apply writes the transaction to the plugin's stdin
+func (p *pluginProjection) Apply(t *trade.Trade) {
+	p.start()
+	if p.err == nil {
+		p.err = p.enc.Encode(t)
+	}
+}
+
+// Result closes the plugin's stdin and returns the json it wrote to
+// stdout, or a PluginError if it failed
+func (p *pluginProjection) Result() interface{} {
+	p.start()
+	// guard clause: the plugin never started
+	if p.cmd == nil || p.cmd.Process == nil {
+		return &PluginError{Plugin: p.plugin.Name, Err: p.err}
+	}
+	p.stdin.Close()
+	// a plugin exiting early fails writing to it, but its exit status says
+	// more about why
+	if err := p.cmd.Wait(); err != nil {
+		return &PluginError{Plugin: p.plugin.Name, Err: err}
+	}
+	if p.err != nil {
+		return &PluginError{Plugin: p.plugin.Name, Err: p.err}
+	}
+	out := bytes.TrimSpace(p.stdout.Bytes())
+	if !json.Valid(out) {
+		return &PluginError{Plugin: p.plugin.Name, Err: fmt.Errorf("invalid json result %q", truncate(string(out), 80))}
+	}
+	return json.RawMessage(out)
+}
+
+// truncate shortens a string to at most n bytes for an error message
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,48 @@
+package projection
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// Position is the open quantity of a symbol in an account
+type Position struct {
+	Account     string
+	Symbol      string
+	Quantity    *big.Float  // negative for short positions
+	Cost        *big.Float  // total cost basis of the open lots
+	AverageCost *big.Float  // cost per unit
+	Lots        []*lots.Lot // open lots ordered by open date
+}
+
+// Positions lists the positions open at the end of a date
+type Positions struct {
+	AsOf      time.Time
+	Positions []*Position // ordered by account then symbol
+}
+
+// NewPositions replays the transactions made up to the end of the as of
+// date and reports the positions left open
+func NewPositions(trans []*trade.Trade, opts *lots.Options, asOf time.Time) *Positions {
+	grouped := newTaxLots(lots.OpenAsOf(trans, opts, asOf))
+	p := Positions{AsOf: asOf, Positions: make([]*Position, 0, len(grouped.Positions))}
+	for i := 0; i < len(grouped.Positions); i++ {
+		s := grouped.Positions[i]
+		position := Position{
+			Account:     s.Account,
+			Symbol:      s.Symbol,
+			Quantity:    s.Quantity,
+			Cost:        s.Cost,
+			AverageCost: big.NewFloat(0.0),
+			Lots:        s.Lots,
+		}
+		if s.Quantity.Sign() != 0 {
+			position.AverageCost = position.AverageCost.Quo(s.Cost, s.Quantity)
+		}
+		p.Positions = append(p.Positions, &position)
+	}
+	return &p
+}
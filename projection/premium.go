@@ -0,0 +1,142 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// PremiumTotals totals the option premium traded and what became of the
+// short options
+type PremiumTotals struct {
+	Collected *big.Float // premium received selling options
+	Paid      *big.Float // premium paid buying options, as a positive amount
+	Net       *big.Float // collected less paid
+	// Kept is the premium of short options that expired worthless
+	Kept              *big.Float
+	ExpiredContracts  *big.Float
+	AssignedContracts *big.Float // short contracts assigned
+	Assignments       int
+	ClosedEarly       int // short option positions bought back before expiring
+}
+
+// MonthPremium totals the option premium of a calendar month
+type MonthPremium struct {
+	Month string // YYYY-MM
+	*PremiumTotals
+}
+
+// UnderlyingPremium totals the premium of the options on an underlying
+type UnderlyingPremium struct {
+	Underlying string
+	*PremiumTotals
+}
+
+// PremiumIncome summarizes the premium collected and paid trading options
+// and how the short options ended
+type PremiumIncome struct {
+	Total        *PremiumTotals
+	ByMonth      []*MonthPremium      // ordered by month
+	ByUnderlying []*UnderlyingPremium // ordered by underlying
+}
+
+// newPremiumTotals returns premium totals with every amount zeroed out
+func newPremiumTotals() *PremiumTotals {
+	return &PremiumTotals{
+		Collected:         big.NewFloat(0.0),
+		Paid:              big.NewFloat(0.0),
+		Net:               big.NewFloat(0.0),
+		Kept:              big.NewFloat(0.0),
+		ExpiredContracts:  big.NewFloat(0.0),
+		AssignedContracts: big.NewFloat(0.0),
+	}
+}
+
+// addTrade adds the premium of an option purchase or sale
+func (p *PremiumTotals) addTrade(t *trade.Trade) {
+	if t.Amount.Sign() > 0 {
+		p.Collected = p.Collected.Add(p.Collected, t.Amount)
+	} else {
+		p.Paid = p.Paid.Sub(p.Paid, t.Amount)
+	}
+	p.Net = p.Net.Add(p.Net, t.Amount)
+}
+
+// addOutcome counts how a short option lot was closed
+func (p *PremiumTotals) addOutcome(g *lots.RealizedGain) {
+	switch g.Closing.Type {
+	case trade.Expiration:
+		p.Kept = p.Kept.Add(p.Kept, g.Proceeds)
+		p.ExpiredContracts = p.ExpiredContracts.Add(p.ExpiredContracts, g.Quantity)
+	case trade.Assignment:
+		p.AssignedContracts = p.AssignedContracts.Add(p.AssignedContracts, g.Quantity)
+		p.Assignments++
+	default:
+		p.ClosedEarly++
+	}
+}
+
+// NewPremiumIncome totals the premium of every option purchase and sale per
+// month and per underlying, along with the premium kept on short options
+// that expired and the short options that were assigned. outcomes are
+// counted in the month the short option was closed.
+func NewPremiumIncome(trans []*trade.Trade, opts *lots.Options) *PremiumIncome {
+	p := PremiumIncome{
+		Total:        newPremiumTotals(),
+		ByMonth:      make([]*MonthPremium, 0),
+		ByUnderlying: make([]*UnderlyingPremium, 0),
+	}
+	byMonth := make(map[string]*MonthPremium)
+	byUnderlying := make(map[string]*UnderlyingPremium)
+	totals := func(instrument *trade.Instrument, month string) (*MonthPremium, *UnderlyingPremium) {
+		m := byMonth[month]
+		if m == nil {
+			m = &MonthPremium{Month: month, PremiumTotals: newPremiumTotals()}
+			byMonth[month] = m
+			p.ByMonth = append(p.ByMonth, m)
+		}
+		u := byUnderlying[instrument.Underlying]
+		if u == nil {
+			u = &UnderlyingPremium{Underlying: instrument.Underlying, PremiumTotals: newPremiumTotals()}
+			byUnderlying[instrument.Underlying] = u
+			p.ByUnderlying = append(p.ByUnderlying, u)
+		}
+		return m, u
+	}
+
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		// guard clause: only option purchases and sales are premium
+		if t.Instrument == nil || t.Instrument.Class != trade.Option || !t.IsTrade() || t.Amount == nil {
+			continue
+		}
+		m, u := totals(t.Instrument, t.Date.Format("2006-01"))
+		m.addTrade(t)
+		u.addTrade(t)
+		p.Total.addTrade(t)
+	}
+
+	realized := lots.Match(trans, opts).Realized
+	for i := 0; i < len(realized); i++ {
+		g := realized[i]
+		instrument := g.Closing.Instrument
+		// guard clause: only short options have an outcome to count
+		if !g.Short || instrument == nil || instrument.Class != trade.Option {
+			continue
+		}
+		m, u := totals(instrument, g.CloseDate.Format("2006-01"))
+		m.addOutcome(g)
+		u.addOutcome(g)
+		p.Total.addOutcome(g)
+	}
+
+	sort.Slice(p.ByMonth, func(i, j int) bool {
+		return p.ByMonth[i].Month < p.ByMonth[j].Month
+	})
+	sort.Slice(p.ByUnderlying, func(i, j int) bool {
+		return p.ByUnderlying[i].Underlying < p.ByUnderlying[j].Underlying
+	})
+	return &p
+}
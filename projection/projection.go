@@ -0,0 +1,332 @@
+package projection
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/quotes"
+	"github.com/stonks/trade"
+)
+
+// Projection builds a result from transactions applied to it one at a
+// time, in date order. projections are registered by name so the ones to
+// run can be picked without changing code.
+type Projection interface {
+	// Name returns the name the projection is registered and reported by
+	Name() string
+	// Apply adds a transaction to the projection
+	Apply(t *trade.Trade)
+	// Result returns what the projection has built from the transactions
+	// applied so far. a result that is an error fails the run.
+	Result() interface{}
+}
+
+// Dependent is a projection built on the results of other projections,
+// eg the performance statistics of the round trips. the projections it
+// depends on are run along with it, once however many depend on them, and
+// their results handed to it before its own result is asked for.
+type Dependent interface {
+	// Depends returns the names of the projections it needs the results of
+	Depends() []string
+	// Use gives the projection the result of one of its dependencies
+	Use(name string, result interface{})
+}
+
+// Settings holds what projections are configured with
+type Settings struct {
+	Lots *lots.Options
+	AsOf time.Time // date positions are reported as of
+	// ExcludedAssetClasses are left out of trading statistics, along with
+	// money market sweeps
+	ExcludedAssetClasses []trade.AssetClass
+	// Quotes provides current prices, nil if there aren't any
+	Quotes quotes.Provider
+	// GroupBy is the dimension projections are run along, each group of
+	// transactions projected on its own. blank to project them all at once
+	GroupBy Dimension
+}
+
+// Factory returns a new projection for the settings
+type Factory func(s *Settings) Projection
+
+// Register adds a projection to the registry under a name, replacing any
+// projection already registered under it
+func Register(name string, f Factory) {
+	registry[name] = f
+}
+
+// Registered returns the names of the registered projections in
+// alphabetical order
+func Registered() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns a new projection of the registered name
+func New(name string, s *Settings) (Projection, error) {
+	f, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown projection %q", name)
+	}
+	return f(s), nil
+}
+
+// pipelineBuffer is how many transactions can be queued for a projection
+// before sending more waits for it to catch up
+const pipelineBuffer = 1024
+
+// Run applies the transactions in date order to a new projection of each
+// name and returns their results keyed by name. each projection runs in a
+// worker of its own, fed the transactions over a channel, so projections
+// are built in parallel in a single pass over the transactions.
+// projections must not change the transactions applied to them. when the
+// settings group by a dimension, the result of each name is a map of the
+// result of each group instead, keyed by the group.
+func Run(names []string, s *Settings, trans []*trade.Trade) (map[string]interface{}, error) {
+	if s.GroupBy == "" {
+		results, _, err := Resume(names, s, trans, nil)
+		return results, err
+	}
+	groups, err := GroupBy(trans, s.GroupBy, s.Lots)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]interface{}, len(names))
+	byGroup := make(map[string]map[string]interface{}, len(names))
+	for i := 0; i < len(names); i++ {
+		byGroup[names[i]] = make(map[string]interface{}, len(groups))
+		results[names[i]] = byGroup[names[i]]
+	}
+	for group, groupTransactions := range groups {
+		groupResults, _, err := Resume(names, s, groupTransactions, nil)
+		if err != nil {
+			return nil, err
+		}
+		for name, result := range groupResults {
+			byGroup[name][group] = result
+		}
+	}
+	return results, nil
+}
+
+// newProjections returns a new projection of each name and of every
+// projection they depend on, each dependency ahead of the projections
+// that depend on it. it returns an error if the dependencies form a cycle.
+func newProjections(names []string, s *Settings) ([]Projection, error) {
+	byName := make(map[string]Projection)
+	pending := make([]string, len(names))
+	copy(pending, names)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if _, ok := byName[name]; ok {
+			continue
+		}
+		p, err := New(name, s)
+		if err != nil {
+			return nil, err
+		}
+		byName[name] = p
+		pending = append(pending, dependencies(p)...)
+	}
+
+	// depth first, each projection after what it depends on
+	ordered := make([]Projection, 0, len(byName))
+	state := make(map[string]int) // 1 while visiting, 2 once ordered
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("projection %q depends on itself", name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		p := byName[name]
+		depends := dependencies(p)
+		for i := 0; i < len(depends); i++ {
+			if err := visit(depends[i]); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		ordered = append(ordered, p)
+		return nil
+	}
+	for i := 0; i < len(names); i++ {
+		if err := visit(names[i]); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// dependencies returns the names of the projections a projection depends
+// on, if any
+func dependencies(p Projection) []string {
+	if d, ok := p.(Dependent); ok {
+		return d.Depends()
+	}
+	return nil
+}
+
+// byDate returns the transactions in date order
+func byDate(trans []*trade.Trade) []*trade.Trade {
+	ordered := make([]*trade.Trade, len(trans))
+	copy(ordered, trans)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Date.Before(ordered[j].Date)
+	})
+	return ordered
+}
+
+// feed applies the ordered transactions to the projections in parallel,
+// each in a worker fed over a channel, and returns their results. once a
+// projection has every transaction it waits for the results of the
+// projections it depends on. skip returns true for the transactions a
+// projection has already applied.
+func feed(projections []Projection, ordered []*trade.Trade, skip func(p int, t *trade.Trade) bool) []interface{} {
+	index := make(map[string]int, len(projections))
+	for i := 0; i < len(projections); i++ {
+		index[projections[i].Name()] = i
+	}
+	feeds := make([]chan *trade.Trade, len(projections))
+	done := make([]chan bool, len(projections))
+	results := make([]interface{}, len(projections))
+	var wg sync.WaitGroup
+	for i := 0; i < len(projections); i++ {
+		feeds[i] = make(chan *trade.Trade, pipelineBuffer)
+		done[i] = make(chan bool)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for t := range feeds[i] {
+				projections[i].Apply(t)
+			}
+			if d, ok := projections[i].(Dependent); ok {
+				depends := d.Depends()
+				for j := 0; j < len(depends); j++ {
+					k := index[depends[j]]
+					<-done[k]
+					d.Use(depends[j], results[k])
+				}
+			}
+			results[i] = projections[i].Result()
+			close(done[i])
+		}(i)
+	}
+	for i := 0; i < len(ordered); i++ {
+		for j := 0; j < len(feeds); j++ {
+			if !skip(j, ordered[i]) {
+				feeds[j] <- ordered[i]
+			}
+		}
+	}
+	for i := 0; i < len(feeds); i++ {
+		close(feeds[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// trading returns the transactions that count towards trading statistics
+func (s *Settings) trading(trans []*trade.Trade) []*trade.Trade {
+	results := make([]*trade.Trade, 0, len(trans))
+	for i := 0; i < len(trans); i++ {
+		if s.isTrading(trans[i]) {
+			results = append(results, trans[i])
+		}
+	}
+	return results
+}
+
+// isTrading returns true if the transaction counts towards trading
+// statistics
+func (s *Settings) isTrading(t *trade.Trade) bool {
+	if t.IsCashEquivalent() {
+		return false
+	}
+	for i := 0; i < len(s.ExcludedAssetClasses); i++ {
+		if t.Instrument != nil && t.Instrument.Class == s.ExcludedAssetClasses[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// batch adapts an analysis of the whole list of transactions to a
+// Projection by collecting the transactions applied to it and running the
+// analysis when the result is asked for
+type batch struct {
+	name     string
+	settings *Settings
+	trans    []*trade.Trade
+	analyze  func(trans []*trade.Trade, s *Settings) interface{}
+}
+
+// batchOf returns a factory of projections running the analysis
+func batchOf(name string, analyze func(trans []*trade.Trade, s *Settings) interface{}) Factory {
+	return func(s *Settings) Projection {
+		return &batch{name: name, settings: s, trans: make([]*trade.Trade, 0), analyze: analyze}
+	}
+}
+
+// Name returns the name of the projection
+func (b *batch) Name() string {
+	return b.name
+}
+
+// Apply collects the transaction for the analysis
+func (b *batch) Apply(t *trade.Trade) {
+	b.trans = append(b.trans, t)
+}
+
+// Result runs the analysis over the transactions applied so far
+func (b *batch) Result() interface{} {
+	return b.analyze(b.trans, b.settings)
+}
+
+// derived adapts an analysis of the transactions and the results of other
+// projections to a Projection
+type derived struct {
+	batch
+	depends []string
+	results map[string]interface{}
+	build   func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{}
+}
+
+// derivedOf returns a factory of projections running the analysis on the
+// results of the projections it depends on
+func derivedOf(name string, depends []string, build func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{}) Factory {
+	return func(s *Settings) Projection {
+		return &derived{
+			batch:   batch{name: name, settings: s, trans: make([]*trade.Trade, 0)},
+			depends: depends,
+			results: make(map[string]interface{}, len(depends)),
+			build:   build,
+		}
+	}
+}
+
+// Depends returns the names of the projections the analysis needs
+func (d *derived) Depends() []string {
+	return d.depends
+}
+
+// Use keeps the result of a dependency for the analysis
+func (d *derived) Use(name string, result interface{}) {
+	d.results[name] = result
+}
+
+// Result runs the analysis over the transactions applied so far and the
+// results of the dependencies
+func (d *derived) Result() interface{} {
+	return d.build(d.trans, d.settings, d.results)
+}
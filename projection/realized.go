@@ -0,0 +1,169 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// ClosingGain is the realized gain or loss of a single closing transaction,
+// which may have closed several lots
+type ClosingGain struct {
+	Account     string
+	Symbol      string
+	Date        time.Time
+	Description string
+	Quantity    *big.Float // quantity closed across every matched lot
+	Proceeds    *big.Float
+	Basis       *big.Float
+	Gain        *big.Float
+	Term        *TermGain
+	Disallowed  *big.Float // loss disallowed by wash sales, as a positive amount
+	// PermanentlyDisallowed is the part of Disallowed replaced in a
+	// retirement account, which won't be recovered when the replacement is sold
+	PermanentlyDisallowed *big.Float
+	Lots                  []*lots.RealizedGain // the lots closed by the transaction
+}
+
+// TermGain splits a realized gain or loss by holding period. lots held
+// for more than a year, including any holding period tacked on by a wash
+// sale, are long term.
+type TermGain struct {
+	ShortTerm *big.Float
+	LongTerm  *big.Float
+}
+
+// newTermGain returns a zero gain split
+func newTermGain() *TermGain {
+	return &TermGain{ShortTerm: big.NewFloat(0.0), LongTerm: big.NewFloat(0.0)}
+}
+
+// add adds the gain of a closed lot to the split
+func (t *TermGain) add(g *lots.RealizedGain) {
+	if g.LongTerm() {
+		t.LongTerm = t.LongTerm.Add(t.LongTerm, g.Gain)
+	} else {
+		t.ShortTerm = t.ShortTerm.Add(t.ShortTerm, g.Gain)
+	}
+}
+
+// SymbolGain is the realized gain or loss of a symbol
+type SymbolGain struct {
+	Symbol string
+	Gain   *big.Float
+	Term   *TermGain
+}
+
+// YearGain is the realized gain or loss of a calendar year
+type YearGain struct {
+	Year int
+	Gain *big.Float
+	Term *TermGain
+}
+
+// RealizedPL reports the gains and losses realized by closing lots
+type RealizedPL struct {
+	Transactions []*ClosingGain // per closing transaction, in date order
+	BySymbol     []*SymbolGain  // per symbol, ordered by symbol
+	ByYear       []*YearGain    // per calendar year the lots were closed in
+	Total        *big.Float
+	Term         *TermGain  // total split by holding period
+	Disallowed   *big.Float // losses disallowed by wash sales
+	// PermanentlyDisallowed is the part of Disallowed that was replaced in
+	// retirement accounts and is lost rather than deferred
+	PermanentlyDisallowed *big.Float
+}
+
+// NewRealizedPL matches closing transactions against open lots and totals
+// the realized gains per closing transaction, per symbol and per year, each
+// split into short and long term.
+func NewRealizedPL(trans []*trade.Trade, opts *lots.Options) *RealizedPL {
+	return newRealizedPL(lots.Match(trans, opts).Realized)
+}
+
+// newRealizedPL totals up the realized gains of closed lots
+func newRealizedPL(realized []*lots.RealizedGain) *RealizedPL {
+	p := RealizedPL{
+		Transactions: make([]*ClosingGain, 0),
+		BySymbol:     make([]*SymbolGain, 0),
+		ByYear:       make([]*YearGain, 0),
+		Total:        big.NewFloat(0.0),
+		Term:         newTermGain(),
+		Disallowed:   big.NewFloat(0.0),
+	}
+	p.PermanentlyDisallowed = big.NewFloat(0.0)
+	byClosing := make(map[*trade.Trade]*ClosingGain)
+	bySymbol := make(map[string]*SymbolGain)
+	byYear := make(map[int]*YearGain)
+
+	for i := 0; i < len(realized); i++ {
+		g := realized[i]
+
+		c := byClosing[g.Closing]
+		if c == nil {
+			c = &ClosingGain{
+				Account:     g.Account,
+				Symbol:      g.Symbol,
+				Date:        g.CloseDate,
+				Description: strings.TrimSpace(g.Closing.Description),
+				Quantity:    big.NewFloat(0.0),
+				Proceeds:    big.NewFloat(0.0),
+				Basis:       big.NewFloat(0.0),
+				Gain:        big.NewFloat(0.0),
+				Term:        newTermGain(),
+				Disallowed:  big.NewFloat(0.0),
+				Lots:        make([]*lots.RealizedGain, 0),
+			}
+			c.PermanentlyDisallowed = big.NewFloat(0.0)
+			byClosing[g.Closing] = c
+			p.Transactions = append(p.Transactions, c)
+		}
+		c.Quantity = c.Quantity.Add(c.Quantity, g.Quantity)
+		c.Proceeds = c.Proceeds.Add(c.Proceeds, g.Proceeds)
+		c.Basis = c.Basis.Add(c.Basis, g.Basis)
+		c.Gain = c.Gain.Add(c.Gain, g.Gain)
+		c.Term.add(g)
+		c.Disallowed = c.Disallowed.Add(c.Disallowed, g.Disallowed)
+		c.PermanentlyDisallowed = c.PermanentlyDisallowed.Add(c.PermanentlyDisallowed, g.PermanentlyDisallowed)
+		c.Lots = append(c.Lots, g)
+
+		s := bySymbol[g.Symbol]
+		if s == nil {
+			s = &SymbolGain{Symbol: g.Symbol, Gain: big.NewFloat(0.0), Term: newTermGain()}
+			bySymbol[g.Symbol] = s
+			p.BySymbol = append(p.BySymbol, s)
+		}
+		s.Gain = s.Gain.Add(s.Gain, g.Gain)
+		s.Term.add(g)
+
+		year := g.CloseDate.Year()
+		y := byYear[year]
+		if y == nil {
+			y = &YearGain{Year: year, Gain: big.NewFloat(0.0), Term: newTermGain()}
+			byYear[year] = y
+			p.ByYear = append(p.ByYear, y)
+		}
+		y.Gain = y.Gain.Add(y.Gain, g.Gain)
+		y.Term.add(g)
+
+		p.Total = p.Total.Add(p.Total, g.Gain)
+		p.Term.add(g)
+		p.Disallowed = p.Disallowed.Add(p.Disallowed, g.Disallowed)
+		p.PermanentlyDisallowed = p.PermanentlyDisallowed.Add(p.PermanentlyDisallowed, g.PermanentlyDisallowed)
+	}
+
+	sort.SliceStable(p.Transactions, func(i, j int) bool {
+		return p.Transactions[i].Date.Before(p.Transactions[j].Date)
+	})
+	sort.Slice(p.BySymbol, func(i, j int) bool {
+		return p.BySymbol[i].Symbol < p.BySymbol[j].Symbol
+	})
+	sort.Slice(p.ByYear, func(i, j int) bool {
+		return p.ByYear[i].Year < p.ByYear[j].Year
+	})
+	return &p
+}
@@ -0,0 +1,329 @@
+package projection
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// Form1099BLine is a sale reported by a broker on Form 1099-B
+type Form1099BLine struct {
+	Account     string // account the sale was made in, blank if not known
+	Symbol      string
+	Sold        time.Time
+	Proceeds    *big.Float
+	Basis       *big.Float
+	WashSale    *big.Float // wash sale loss disallowed, zero if not reported
+	LongTerm    bool
+	Description string // as reported by the broker
+}
+
+// form1099BDateFormats are the date formats accepted in a 1099-B csv
+var form1099BDateFormats = []string{"01/02/2006", "2006-01-02", "1/2/2006", "01/02/06"}
+
+// form1099BColumns maps each field of a 1099-B line to the text that
+// identifies its column in the header row, checked in order
+var form1099BColumns = map[string][]string{
+	"account":     {"ACCOUNT"},
+	"symbol":      {"SYMBOL", "TICKER"},
+	"description": {"DESCRIPTION"},
+	"sold":        {"DATE SOLD", "SOLD", "DISPOSED"},
+	"proceeds":    {"PROCEEDS"},
+	"basis":       {"COST", "BASIS"},
+	"wash":        {"WASH"},
+	"term":        {"TERM"},
+}
+
+// Load1099B reads the sales reported on a 1099-B from a csv file. the
+// first row must be a header naming the columns, which are found by name:
+// the date sold, proceeds and cost basis are required, along with either a
+// symbol column or a description starting with the quantity and symbol, eg
+// "100 SH AAPL". wash sale, short or long term and account columns are
+// optional. account is the account the 1099-B was issued for, for the
+// lines without an account column.
+func Load1099B(path string, account string) ([]*Form1099BLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for field, names := range form1099BColumns {
+		columns[field] = findColumn(header, names)
+	}
+	if columns["sold"] < 0 || columns["proceeds"] < 0 || columns["basis"] < 0 {
+		return nil, fmt.Errorf("1099-B csv needs date sold, proceeds and cost basis columns")
+	}
+	if columns["symbol"] < 0 && columns["description"] < 0 {
+		return nil, fmt.Errorf("1099-B csv needs a symbol or description column")
+	}
+
+	results := make([]*Form1099BLine, 0)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		sold, err := parse1099BDate(column(record, columns["sold"]))
+		if err != nil {
+			// skip subtotal and blank rows
+			continue
+		}
+		line := Form1099BLine{
+			Account:     column(record, columns["account"]),
+			Description: column(record, columns["description"]),
+			Sold:        sold,
+			Proceeds:    parseAmount(column(record, columns["proceeds"])),
+			Basis:       parseAmount(column(record, columns["basis"])),
+			WashSale:    parseAmount(column(record, columns["wash"])),
+			LongTerm:    strings.Contains(strings.ToUpper(column(record, columns["term"])), "LONG"),
+		}
+		if line.Account == "" {
+			line.Account = account
+		}
+		line.Symbol = column(record, columns["symbol"])
+		if line.Symbol == "" {
+			line.Symbol = symbolFromDescription(line.Description)
+		}
+		results = append(results, &line)
+	}
+	return results, nil
+}
+
+// findColumn returns the index of the first header containing one of the
+// names, or -1 if there isn't one
+func findColumn(header []string, names []string) int {
+	for i := 0; i < len(names); i++ {
+		for j := 0; j < len(header); j++ {
+			if strings.Contains(strings.ToUpper(header[j]), names[i]) {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// column returns the trimmed value of the record's column, or blank if the
+// column is missing
+func column(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// parse1099BDate parses a date in any of the accepted formats
+func parse1099BDate(s string) (time.Time, error) {
+	var err error
+	for i := 0; i < len(form1099BDateFormats); i++ {
+		var t time.Time
+		if t, err = time.Parse(form1099BDateFormats[i], s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseAmount parses a dollar amount, ignoring thousands separators and
+// currency symbols. amounts in parentheses are negative. blank or invalid
+// amounts default to zero.
+func parseAmount(s string) *big.Float {
+	cleaned := strings.NewReplacer(",", "", "$", "", " ", "").Replace(s)
+	negative := strings.HasPrefix(cleaned, "(") && strings.HasSuffix(cleaned, ")")
+	cleaned = strings.Trim(cleaned, "()")
+	f, _, err := big.ParseFloat(cleaned, 10, 53, big.ToNearestEven)
+	if err != nil {
+		return big.NewFloat(0.0)
+	}
+	if negative {
+		f = f.Neg(f)
+	}
+	return f
+}
+
+// symbolFromDescription returns the symbol from a 1099-B description such
+// as "100 SH AAPL" or "100.000 AAPL APPLE INC", skipping the quantity
+func symbolFromDescription(description string) string {
+	fields := strings.Fields(strings.ToUpper(description))
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if f == "SH" || f == "SHS" || f == "SHARES" {
+			continue
+		}
+		if _, _, err := big.ParseFloat(strings.Replace(f, ",", "", -1), 10, 53, big.ToNearestEven); err == nil {
+			continue
+		}
+		return f
+	}
+	return ""
+}
+
+// ReconciliationStatus describes how a sale compares to the 1099-B
+type ReconciliationStatus string
+
+const (
+	Matched         ReconciliationStatus = "MATCHED"
+	Mismatched      ReconciliationStatus = "MISMATCHED"        // proceeds or basis differ
+	MissingFrom1099 ReconciliationStatus = "MISSING_FROM_1099" // sale found in the transactions but not on the 1099-B
+	MissingSale     ReconciliationStatus = "MISSING_SALE"      // sale on the 1099-B not found in the transactions
+)
+
+// reconciliationTolerance is the largest difference in dollars ignored as
+// rounding when comparing amounts
+const reconciliationTolerance = 0.01
+
+// ReconciliationLine compares the sales of a symbol on a date computed
+// from the transactions to those reported on the 1099-B
+type ReconciliationLine struct {
+	Account          string // blank when the 1099-B doesn't say
+	Symbol           string
+	Sold             time.Time
+	Status           ReconciliationStatus
+	Proceeds         *big.Float // computed from the transactions
+	ReportedProceeds *big.Float // reported on the 1099-B
+	ProceedsDiff     *big.Float // reported less computed
+	Basis            *big.Float
+	ReportedBasis    *big.Float
+	BasisDiff        *big.Float
+	WashSale         *big.Float
+	ReportedWashSale *big.Float
+	WashSaleDiff     *big.Float
+}
+
+// Reconciliation compares the realized gains computed from the transactions
+// to a broker's 1099-B, sale by sale
+type Reconciliation struct {
+	Lines      []*ReconciliationLine // ordered by date sold, symbol then account
+	Mismatches int                   // lines that aren't matched
+}
+
+// NewReconciliation matches closing transactions against open lots and
+// compares the resulting sales in taxable accounts to the lines of a
+// 1099-B
+func NewReconciliation(trans []*trade.Trade, opts *lots.Options, reported []*Form1099BLine) *Reconciliation {
+	return newReconciliation(NewForm8949(trans, opts), reported)
+}
+
+// reconciliationKey identifies the sales of a symbol on a date in an
+// account. sales are compared by date and symbol rather than per lot since
+// brokers often combine the lots closed by a sale into one line.
+func reconciliationKey(account string, symbol string, sold time.Time) string {
+	return account + "|" + symbol + "|" + sold.Format("2006-01-02")
+}
+
+// newReconciliation compares the rows of Form 8949 to the 1099-B lines.
+// brokers issue a 1099-B per account, so only the sales of the accounts
+// the 1099-B lines are in are compared. lines without an account are
+// compared to the sales of the accounts not named by any line.
+func newReconciliation(f *Form8949, reported []*Form1099BLine) *Reconciliation {
+	rec := Reconciliation{Lines: make([]*ReconciliationLine, 0)}
+	byKey := make(map[string]*ReconciliationLine)
+	line := func(account string, symbol string, sold time.Time) *ReconciliationLine {
+		key := reconciliationKey(account, symbol, sold)
+		l := byKey[key]
+		if l == nil {
+			l = &ReconciliationLine{
+				Account:          account,
+				Symbol:           symbol,
+				Sold:             sold,
+				Proceeds:         big.NewFloat(0.0),
+				ReportedProceeds: big.NewFloat(0.0),
+				Basis:            big.NewFloat(0.0),
+				ReportedBasis:    big.NewFloat(0.0),
+				WashSale:         big.NewFloat(0.0),
+				ReportedWashSale: big.NewFloat(0.0),
+			}
+			byKey[key] = l
+			rec.Lines = append(rec.Lines, l)
+		}
+		return l
+	}
+
+	accounts := make(map[string]bool)
+	for i := 0; i < len(reported); i++ {
+		accounts[reported[i].Account] = true
+	}
+	if len(reported) == 0 {
+		accounts[""] = true
+	}
+	computed := make(map[*ReconciliationLine]bool)
+	for i := 0; i < len(f.Rows); i++ {
+		r := f.Rows[i]
+		account := r.Account
+		if !accounts[account] {
+			// guard clause: reported on the 1099-B of another account
+			if !accounts[""] {
+				continue
+			}
+			account = ""
+		}
+		l := line(account, r.Symbol, r.Sold)
+		l.Proceeds = l.Proceeds.Add(l.Proceeds, r.Proceeds)
+		l.Basis = l.Basis.Add(l.Basis, r.Basis)
+		l.WashSale = l.WashSale.Add(l.WashSale, r.Adjustment)
+		computed[l] = true
+	}
+	onForm := make(map[*ReconciliationLine]bool)
+	for i := 0; i < len(reported); i++ {
+		r := reported[i]
+		l := line(r.Account, r.Symbol, r.Sold)
+		l.ReportedProceeds = l.ReportedProceeds.Add(l.ReportedProceeds, r.Proceeds)
+		l.ReportedBasis = l.ReportedBasis.Add(l.ReportedBasis, r.Basis)
+		l.ReportedWashSale = l.ReportedWashSale.Add(l.ReportedWashSale, r.WashSale)
+		onForm[l] = true
+	}
+
+	for i := 0; i < len(rec.Lines); i++ {
+		l := rec.Lines[i]
+		l.ProceedsDiff = big.NewFloat(0.0).Sub(l.ReportedProceeds, l.Proceeds)
+		l.BasisDiff = big.NewFloat(0.0).Sub(l.ReportedBasis, l.Basis)
+		l.WashSaleDiff = big.NewFloat(0.0).Sub(l.ReportedWashSale, l.WashSale)
+		switch {
+		case !onForm[l]:
+			l.Status = MissingFrom1099
+		case !computed[l]:
+			l.Status = MissingSale
+		case exceedsTolerance(l.ProceedsDiff) || exceedsTolerance(l.BasisDiff) || exceedsTolerance(l.WashSaleDiff):
+			l.Status = Mismatched
+		default:
+			l.Status = Matched
+		}
+		if l.Status != Matched {
+			rec.Mismatches++
+		}
+	}
+
+	sort.SliceStable(rec.Lines, func(i, j int) bool {
+		if !rec.Lines[i].Sold.Equal(rec.Lines[j].Sold) {
+			return rec.Lines[i].Sold.Before(rec.Lines[j].Sold)
+		}
+		if rec.Lines[i].Symbol != rec.Lines[j].Symbol {
+			return rec.Lines[i].Symbol < rec.Lines[j].Symbol
+		}
+		return rec.Lines[i].Account < rec.Lines[j].Account
+	})
+	return &rec
+}
+
+// exceedsTolerance returns true if a difference is more than rounding
+func exceedsTolerance(diff *big.Float) bool {
+	return big.NewFloat(0.0).Abs(diff).Cmp(big.NewFloat(reconciliationTolerance)) > 0
+}
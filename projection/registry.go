@@ -0,0 +1,92 @@
+package projection
+
+import (
+	"github.com/stonks/trade"
+)
+
+// registry holds the factory of every projection that can be run by name,
+// keyed by the name. projections built on the lots, positions or round
+// trips depend on the projection building them rather than matching the
+// lots again.
+var registry = map[string]Factory{
+	"interest": batchOf("interest", func(trans []*trade.Trade, s *Settings) interface{} {
+		return NewInterestSummary(trans)
+	}),
+	"dividends": batchOf("dividends", func(trans []*trade.Trade, s *Settings) interface{} {
+		return NewDividendIncome(trans, s.AsOf)
+	}),
+	"yieldOnCost": derivedOf("yieldOnCost", []string{"dividends", "positions"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		return NewYieldOnCost(results["dividends"].(*DividendIncome), results["positions"].(*Positions))
+	}),
+	"fees": derivedOf("fees", []string{"realized"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		return NewFeeAudit(trans, results["realized"].(*RealizedPL))
+	}),
+	"realized": batchOf("realized", func(trans []*trade.Trade, s *Settings) interface{} {
+		return NewRealizedPL(s.trading(trans), s.Lots)
+	}),
+	"taxLots": batchOf("taxLots", func(trans []*trade.Trade, s *Settings) interface{} {
+		return NewTaxLots(s.trading(trans), s.Lots)
+	}),
+	"scheduleD": batchOf("scheduleD", func(trans []*trade.Trade, s *Settings) interface{} {
+		return NewScheduleD(s.trading(trans), s.Lots)
+	}),
+	"washSaleCarryover": batchOf("washSaleCarryover", func(trans []*trade.Trade, s *Settings) interface{} {
+		return NewWashSaleCarryover(s.trading(trans), s.Lots)
+	}),
+	"positions": batchOf("positions", func(trans []*trade.Trade, s *Settings) interface{} {
+		return NewPositions(s.trading(trans), s.Lots, s.AsOf)
+	}),
+	"shortOptions": derivedOf("shortOptions", []string{"positions"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		return NewShortOptionExposure(results["positions"].(*Positions))
+	}),
+	"expirations": derivedOf("expirations", []string{"positions"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		return NewExpirationCalendar(results["positions"].(*Positions))
+	}),
+	"unrealized": derivedOf("unrealized", []string{"positions"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		if s.Quotes == nil {
+			return nil
+		}
+		return NewUnrealizedPL(results["positions"].(*Positions), s.Quotes)
+	}),
+	"cash": batchOf("cash", func(trans []*trade.Trade, s *Settings) interface{} {
+		return NewCashBalances(trans, nil)
+	}),
+	"contributions": newContributionsProjection,
+	"roundTrips": batchOf("roundTrips", func(trans []*trade.Trade, s *Settings) interface{} {
+		return NewRoundTrips(s.trading(trans), s.AsOf)
+	}),
+	"performance": derivedOf("performance", []string{"roundTrips"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		return NewPerformanceStats(results["roundTrips"].(*RoundTrips))
+	}),
+	"holdingPeriods": derivedOf("holdingPeriods", []string{"roundTrips"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		return NewHoldingPeriods(results["roundTrips"].(*RoundTrips))
+	}),
+	"tilt": derivedOf("tilt", []string{"roundTrips"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		return NewTiltAnalysis(results["roundTrips"].(*RoundTrips))
+	}),
+	"leaderboard": derivedOf("leaderboard", []string{"roundTrips"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		return NewTradeLeaderboard(results["roundTrips"].(*RoundTrips), DefaultLeaderboardSize)
+	}),
+	"optionRolls": derivedOf("optionRolls", []string{"roundTrips"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		return NewOptionRolls(results["roundTrips"].(*RoundTrips))
+	}),
+	"activity": derivedOf("activity", []string{"roundTrips"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		return NewActivityStats(s.trading(trans), results["roundTrips"].(*RoundTrips))
+	}),
+	"rolling": derivedOf("rolling", []string{"roundTrips"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		return NewRollingStats(s.trading(trans), results["roundTrips"].(*RoundTrips), DefaultRollingWindows)
+	}),
+	"volume": newVolumeProjection,
+	"dayTrades": batchOf("dayTrades", func(trans []*trade.Trade, s *Settings) interface{} {
+		return NewPatternDayTrading(s.trading(trans))
+	}),
+	"optionStrategies": batchOf("optionStrategies", func(trans []*trade.Trade, s *Settings) interface{} {
+		return NewOptionStrategies(s.trading(trans), s.Lots)
+	}),
+	"premium": batchOf("premium", func(trans []*trade.Trade, s *Settings) interface{} {
+		return NewPremiumIncome(s.trading(trans), s.Lots)
+	}),
+	"coveredCalls": derivedOf("coveredCalls", []string{"optionStrategies"}, func(trans []*trade.Trade, s *Settings, results map[string]interface{}) interface{} {
+		return NewCoveredCalls(s.trading(trans), s.Lots, results["optionStrategies"].(*OptionStrategies))
+	}),
+}
@@ -0,0 +1,108 @@
+package projection
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// ReturnPeriod is a span of time returns are measured over
+type ReturnPeriod struct {
+	Name string
+	From time.Time // first day of the period
+	To   time.Time // last day of the period
+}
+
+// StandardPeriods returns the month to date, year to date, one year, three
+// year and since inception periods ending on asOf
+func StandardPeriods(inception time.Time, asOf time.Time) []*ReturnPeriod {
+	day := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+	return []*ReturnPeriod{
+		{Name: "MTD", From: time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC), To: day},
+		{Name: "YTD", From: time.Date(day.Year(), time.January, 1, 0, 0, 0, 0, time.UTC), To: day},
+		{Name: "1Y", From: day.AddDate(-1, 0, 1), To: day},
+		{Name: "3Y", From: day.AddDate(-3, 0, 1), To: day},
+		{Name: "Inception", From: inception, To: day},
+	}
+}
+
+// PeriodReturn is the return over a period
+type PeriodReturn struct {
+	Name   string
+	From   time.Time // first day with a value in the period
+	To     time.Time // last day with a value in the period
+	Return *big.Float
+	// Annualized is the return compounded per year, for periods longer
+	// than a year. it's nil for shorter periods
+	Annualized *big.Float `json:",omitempty"`
+}
+
+// TimeWeightedReturns holds the time weighted return of each period
+type TimeWeightedReturns struct {
+	Periods []*PeriodReturn
+}
+
+// NewTimeWeightedReturns computes the time weighted return over each
+// period from the value series. the return of each day excludes the
+// deposits and withdrawals made, so contributions don't count as
+// performance. the periods are clipped to the days in the series, and
+// periods without any are left out.
+func NewTimeWeightedReturns(nav *NAVSeries, periods []*ReturnPeriod) *TimeWeightedReturns {
+	r := TimeWeightedReturns{Periods: make([]*PeriodReturn, 0, len(periods))}
+	index := growthIndex(nav.Points)
+	for i := 0; i < len(periods); i++ {
+		first, last := pointRange(nav.Points, periods[i])
+		// guard clause: no values in the period
+		if first < 0 {
+			continue
+		}
+		// the period starts from the value at the end of the day before it
+		// if there is one
+		start := index[first]
+		if first > 0 {
+			start = index[first-1]
+		}
+		ret := big.NewFloat(0.0).Quo(index[last], start)
+		ret = ret.Sub(ret, big.NewFloat(1.0))
+		p := PeriodReturn{
+			Name:   periods[i].Name,
+			From:   nav.Points[first].Date,
+			To:     nav.Points[last].Date,
+			Return: big.NewFloat(0.0).Mul(ret, big.NewFloat(100)),
+		}
+		p.Annualized = annualize(ret, p.From, p.To)
+		r.Periods = append(r.Periods, &p)
+	}
+	return &r
+}
+
+// pointRange returns the indexes of the first and last points in the
+// period, or -1 if none are
+func pointRange(points []*NAVPoint, period *ReturnPeriod) (int, int) {
+	first := sort.Search(len(points), func(i int) bool {
+		return !points[i].Date.Before(period.From)
+	})
+	last := sort.Search(len(points), func(i int) bool {
+		return points[i].Date.After(period.To)
+	}) - 1
+	if first >= len(points) || last < first {
+		return -1, -1
+	}
+	return first, last
+}
+
+// annualize returns a fractional return over the dates compounded per year
+// as a percent, or nil if the dates are no more than a year apart
+func annualize(ret *big.Float, from time.Time, to time.Time) *big.Float {
+	years := to.Sub(from).Hours() / 24 / 365.25
+	if years <= 1 {
+		return nil
+	}
+	r, _ := ret.Float64()
+	// guard clause: a total loss stays a total loss
+	if r <= -1 {
+		return big.NewFloat(-100)
+	}
+	return big.NewFloat((math.Pow(1+r, 1/years) - 1) * 100)
+}
@@ -0,0 +1,84 @@
+package projection
+
+import (
+	"math"
+	"math/big"
+)
+
+// TradingDaysPerYear is how many daily returns make up a year when
+// annualizing
+const TradingDaysPerYear = 252
+
+// RiskRatios holds the volatility and risk adjusted returns of the daily
+// returns of the portfolio, annualized
+type RiskRatios struct {
+	Days         int        // daily returns measured
+	RiskFreeRate *big.Float // annual percent
+	Return       *big.Float // average daily return, annualized percent
+	Volatility   *big.Float // standard deviation of the daily returns, annualized percent
+	// DownsideDeviation is the annualized deviation of the daily returns
+	// below the risk free rate, in percent
+	DownsideDeviation *big.Float
+	// Sharpe and Sortino are the return in excess of the risk free rate
+	// per unit of volatility and of downside deviation, nil when either
+	// is zero
+	Sharpe  *big.Float `json:",omitempty"`
+	Sortino *big.Float `json:",omitempty"`
+}
+
+// NewRiskRatios computes the volatility, Sharpe and Sortino ratios of the
+// daily returns of the value series, leaving deposits and withdrawals out
+// of the returns. riskFreeRate is the annual risk free rate in percent.
+func NewRiskRatios(nav *NAVSeries, riskFreeRate float64) *RiskRatios {
+	r := RiskRatios{
+		RiskFreeRate:      big.NewFloat(riskFreeRate),
+		Return:            big.NewFloat(0.0),
+		Volatility:        big.NewFloat(0.0),
+		DownsideDeviation: big.NewFloat(0.0),
+	}
+	index := growthIndex(nav.Points)
+	values := make([]float64, len(index))
+	for i := 0; i < len(index); i++ {
+		values[i], _ = index[i].Float64()
+	}
+	returns := make([]float64, 0, len(values))
+	daily := dailyReturns(values)
+	for i := 0; i < len(daily); i++ {
+		if !math.IsNaN(daily[i]) {
+			returns = append(returns, daily[i])
+		}
+	}
+	r.Days = len(returns)
+	// guard clause: a deviation needs at least two returns
+	if len(returns) < 2 {
+		return &r
+	}
+
+	riskFree := riskFreeRate / 100 / TradingDaysPerYear
+	mean := 0.0
+	for i := 0; i < len(returns); i++ {
+		mean += returns[i]
+	}
+	mean /= float64(len(returns))
+	variance, downside := 0.0, 0.0
+	for i := 0; i < len(returns); i++ {
+		variance += (returns[i] - mean) * (returns[i] - mean)
+		if excess := returns[i] - riskFree; excess < 0 {
+			downside += excess * excess
+		}
+	}
+	stdev := math.Sqrt(variance / float64(len(returns)-1))
+	downsideDeviation := math.Sqrt(downside / float64(len(returns)))
+
+	scale := math.Sqrt(TradingDaysPerYear)
+	r.Return = big.NewFloat(mean * TradingDaysPerYear * 100)
+	r.Volatility = big.NewFloat(stdev * scale * 100)
+	r.DownsideDeviation = big.NewFloat(downsideDeviation * scale * 100)
+	if stdev > 0 {
+		r.Sharpe = big.NewFloat((mean - riskFree) / stdev * scale)
+	}
+	if downsideDeviation > 0 {
+		r.Sortino = big.NewFloat((mean - riskFree) / downsideDeviation * scale)
+	}
+	return &r
+}
@@ -0,0 +1,132 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/trade"
+)
+
+// DefaultRollingWindows are the lengths in days of the windows rolling
+// statistics are computed over
+var DefaultRollingWindows = []int{30, 90, 365}
+
+// RollingPoint holds the statistics of the window ending on a day
+type RollingPoint struct {
+	Date    time.Time  // last day of the window
+	Trades  int        // round trips closed in the window
+	Wins    int        // round trips closed with a gain
+	WinRate *big.Float // percent of the round trips closed that were wins
+	PL      *big.Float // P/L of the round trips closed
+	Volume  *big.Float // notional dollar volume traded
+}
+
+// RollingWindow holds the statistics of a window rolled over each day
+// with activity
+type RollingWindow struct {
+	Days   int
+	Points []*RollingPoint // in date order
+}
+
+// RollingStats reports the P/L, win rate and volume over trailing windows,
+// showing how they trend rather than only their lifetime totals
+type RollingStats struct {
+	Windows []*RollingWindow // in order of length
+}
+
+// rollingEvent is a round trip closed or a trade made on a day
+type rollingEvent struct {
+	date     time.Time
+	closed   bool // true for a round trip closed, false for a trade
+	win      bool
+	pl       *big.Float
+	notional *big.Float
+}
+
+// NewRollingStats computes the statistics of each window ending on each
+// day a round trip was closed or a trade made. a window of 30 days ending
+// on a day covers it and the 29 days before.
+func NewRollingStats(trans []*trade.Trade, trips *RoundTrips, windows []int) *RollingStats {
+	events := make([]*rollingEvent, 0, len(trips.Closed)+len(trans))
+	for i := 0; i < len(trips.Closed); i++ {
+		trip := trips.Closed[i]
+		events = append(events, &rollingEvent{date: startOfDay(trip.Close), closed: true, win: trip.PL.Sign() > 0, pl: trip.PL})
+	}
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if !t.IsTrade() {
+			continue
+		}
+		instrument := t.Instrument
+		if instrument == nil {
+			instrument = trade.NewInstrument(t.Symbol)
+		}
+		quantity := big.NewFloat(0.0)
+		if t.Quantity != nil {
+			quantity = quantity.Abs(t.Quantity)
+		}
+		events = append(events, &rollingEvent{date: startOfDay(t.Date), notional: instrument.Notional(quantity, t.Price)})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].date.Before(events[j].date)
+	})
+
+	lengths := make([]int, len(windows))
+	copy(lengths, windows)
+	sort.Ints(lengths)
+	r := RollingStats{Windows: make([]*RollingWindow, 0, len(lengths))}
+	for i := 0; i < len(lengths); i++ {
+		// guard clause: a window needs at least a day
+		if lengths[i] <= 0 {
+			continue
+		}
+		w := RollingWindow{Days: lengths[i], Points: make([]*RollingPoint, 0)}
+		start := 0
+		for end := 0; end < len(events); end++ {
+			// one point per day, once every event of the day is in
+			if end+1 < len(events) && events[end+1].date.Equal(events[end].date) {
+				continue
+			}
+			first := events[end].date.AddDate(0, 0, 1-lengths[i])
+			for events[start].date.Before(first) {
+				start++
+			}
+			w.Points = append(w.Points, newRollingPoint(events[end].date, events[start:end+1]))
+		}
+		r.Windows = append(r.Windows, &w)
+	}
+	return &r
+}
+
+// newRollingPoint totals the events in a window
+func newRollingPoint(date time.Time, events []*rollingEvent) *RollingPoint {
+	p := RollingPoint{
+		Date:    date,
+		WinRate: big.NewFloat(0.0),
+		PL:      big.NewFloat(0.0),
+		Volume:  big.NewFloat(0.0),
+	}
+	for i := 0; i < len(events); i++ {
+		e := events[i]
+		if !e.closed {
+			p.Volume = p.Volume.Add(p.Volume, e.notional)
+			continue
+		}
+		p.Trades++
+		if e.win {
+			p.Wins++
+		}
+		p.PL = p.PL.Add(p.PL, e.pl)
+	}
+	if p.Trades > 0 {
+		p.WinRate = p.WinRate.Quo(big.NewFloat(float64(p.Wins)), big.NewFloat(float64(p.Trades)))
+		p.WinRate = p.WinRate.Mul(p.WinRate, big.NewFloat(100))
+	}
+	return &p
+}
+
+// startOfDay drops the time of day from a date
+func startOfDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,120 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/trade"
+)
+
+// RolledPosition is one option position in a campaign of rolls
+type RolledPosition struct {
+	ID     string // id of the round trip
+	Symbol string
+	Open   time.Time
+	Close  time.Time // zero while the position is still open
+	PL     *big.Float
+}
+
+// RollCampaign is an option position rolled to a different strike or
+// expiration one or more times, followed from the first position opened
+// to the last
+type RollCampaign struct {
+	ID         string // id of the first round trip
+	Account    string
+	Underlying string
+	OptionType trade.OptionType
+	Short      bool
+	Rolls      int
+	Positions  []*RolledPosition
+	PL         *big.Float // cumulative P/L of every position in the campaign
+	Open       bool       // true while the last position is still open
+}
+
+// OptionRolls lists the campaigns of rolled option positions
+type OptionRolls struct {
+	Campaigns []*RollCampaign // ordered by when the campaign began
+}
+
+// NewOptionRolls detects rolls, where an option round trip is closed and
+// another option of the same type and side on the same underlying is
+// opened in the same account on the same day, and chains them into
+// campaigns so their cumulative P/L is reported together
+func NewOptionRolls(trips *RoundTrips) *OptionRolls {
+	r := OptionRolls{Campaigns: make([]*RollCampaign, 0)}
+	options := make([]*RoundTrip, 0)
+	all := append(append(make([]*RoundTrip, 0), trips.Closed...), trips.Open...)
+	for i := 0; i < len(all); i++ {
+		if all[i].instrument.Class == trade.Option {
+			options = append(options, all[i])
+		}
+	}
+	sort.SliceStable(options, func(i, j int) bool {
+		return options[i].Open.Before(options[j].Open)
+	})
+
+	// campaign of each round trip already chained, keyed by round trip
+	campaigns := make(map[*RoundTrip]*RollCampaign)
+	for i := 0; i < len(options); i++ {
+		trip := options[i]
+		campaign := campaigns[trip]
+		if campaign == nil {
+			campaign = &RollCampaign{
+				ID:         trip.ID,
+				Account:    trip.Account,
+				Underlying: trip.instrument.Underlying,
+				OptionType: trip.instrument.OptionType,
+				Short:      trip.Short,
+				Positions:  make([]*RolledPosition, 0),
+				PL:         big.NewFloat(0.0),
+			}
+			r.Campaigns = append(r.Campaigns, campaign)
+		}
+		campaign.Positions = append(campaign.Positions, &RolledPosition{
+			ID:     trip.ID,
+			Symbol: trip.Symbol,
+			Open:   trip.Open,
+			Close:  trip.Close,
+			PL:     trip.PL,
+		})
+		campaign.PL = campaign.PL.Add(campaign.PL, trip.PL)
+		campaign.Open = trip.Close.IsZero()
+
+		// guard clause: a position still open hasn't been rolled
+		if trip.Close.IsZero() {
+			continue
+		}
+		for j := i + 1; j < len(options); j++ {
+			next := options[j]
+			if campaigns[next] == nil && isRoll(trip, next) {
+				campaigns[next] = campaign
+				campaign.Rolls++
+				break
+			}
+		}
+	}
+
+	// only positions that were rolled make a campaign
+	rolled := make([]*RollCampaign, 0, len(r.Campaigns))
+	for i := 0; i < len(r.Campaigns); i++ {
+		if r.Campaigns[i].Rolls > 0 {
+			rolled = append(rolled, r.Campaigns[i])
+		}
+	}
+	r.Campaigns = rolled
+	return &r
+}
+
+// isRoll returns true if the next round trip rolls the closed one: the
+// same type and side of option on the same underlying in the same
+// account, opened the day the closed one was closed at a different strike
+// or expiration
+func isRoll(closed *RoundTrip, next *RoundTrip) bool {
+	return next.Account == closed.Account &&
+		next.Symbol != closed.Symbol &&
+		next.Short == closed.Short &&
+		next.instrument.Underlying == closed.instrument.Underlying &&
+		next.instrument.OptionType == closed.instrument.OptionType &&
+		sameDay(next.Open, closed.Close)
+}
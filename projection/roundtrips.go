@@ -0,0 +1,270 @@
+package projection
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// Fill is a transaction, or the part of one, that entered or exited a
+// round trip
+type Fill struct {
+	ID       string
+	Date     time.Time
+	Quantity *big.Float // positive for buys, negative for sales
+	Price    *big.Float
+	Amount   *big.Float // cash of the part of the transaction in the round trip
+}
+
+// RoundTrip is a trade from the first transaction opening a position in a
+// symbol to the transaction that brings the position back to flat, across
+// however many fills it took to enter and exit
+type RoundTrip struct {
+	// ID is the id of the first transaction of the round trip, which
+	// journal entries about the round trip refer to
+	ID       string
+	Account  string
+	Symbol   string
+	Short    bool
+	Open     time.Time
+	Close    time.Time  // zero while the trip is still open
+	Days     int        // days between the first entry and the last exit, or the as of date
+	Quantity *big.Float // total quantity entered, always positive
+	// MaxQuantity is the largest quantity open at once, always positive
+	MaxQuantity *big.Float
+	EntryPrice  *big.Float // average price of the entries
+	ExitPrice   *big.Float // average price of the exits, zero if there weren't any
+	Cost        *big.Float // cash paid for the entries, or received for short entries
+	PL          *big.Float // cash received less cash paid over every fill
+	Return      *big.Float // PL as a percent of the cost
+	Entries     []*Fill
+	Exits       []*Fill
+	Journal     *trade.Annotation `json:",omitempty"`
+	// Risk is the initial risk of the round trip from its journal entry,
+	// and RMultiple its PL in units of that risk. both are nil without a
+	// risk or stop in the journal.
+	Risk      *big.Float `json:",omitempty"`
+	RMultiple *big.Float `json:",omitempty"`
+
+	position     *big.Float // open quantity, with the sign of the entries
+	entered      *big.Float // sum of entry prices times quantities
+	exitQuantity *big.Float
+	exited       *big.Float // sum of exit prices times quantities
+	instrument   *trade.Instrument
+}
+
+// RoundTrips lists every round trip made
+type RoundTrips struct {
+	Closed []*RoundTrip // ordered by close date
+	Open   []*RoundTrip // trips not back to flat yet, ordered by open date
+}
+
+// NewRoundTrips pairs the transactions that open and close positions into
+// round trips per account and symbol. additions to a position are entries
+// and reductions are exits, and a transaction that takes the position past
+// flat closes the round trip with part of its quantity and opens a new one
+// in the other direction with the rest. transfers between accounts aren't
+// entries or exits.
+func NewRoundTrips(trans []*trade.Trade, asOf time.Time) *RoundTrips {
+	r := RoundTrips{Closed: make([]*RoundTrip, 0), Open: make([]*RoundTrip, 0)}
+	ordered := lots.Ordered(trans)
+	open := make(map[string]*RoundTrip)
+	keys := make([]string, 0)
+	for i := 0; i < len(ordered); i++ {
+		t := ordered[i]
+		// guard clause: only trades change the position of a round trip
+		if t.Type == trade.TransferIn || t.Type == trade.TransferOut || t.IsCashEquivalent() ||
+			t.Quantity == nil || t.Quantity.Sign() == 0 {
+			continue
+		}
+
+		symbol := strings.TrimSpace(t.Symbol)
+		key := t.Account + "|" + symbol
+		remaining := big.NewFloat(0.0).Copy(t.Quantity)
+		amount := big.NewFloat(0.0)
+		if t.Amount != nil {
+			amount = amount.Copy(t.Amount)
+		}
+		for remaining.Sign() != 0 {
+			trip := open[key]
+			if trip == nil {
+				trip = newRoundTrip(t, symbol, remaining.Sign() < 0)
+				open[key] = trip
+				keys = append(keys, key)
+			}
+			if trip.position.Sign() == 0 || trip.position.Sign() == remaining.Sign() {
+				trip.enter(t, remaining, amount)
+				break
+			}
+
+			// the exit can't close more than is open
+			closed := minAbsFloat(remaining, trip.position)
+			if remaining.Sign() < 0 {
+				closed = closed.Neg(closed)
+			}
+			closedAmount := big.NewFloat(0.0).Quo(closed, remaining)
+			closedAmount = closedAmount.Mul(closedAmount, amount)
+			trip.exit(t, closed, closedAmount)
+			remaining = remaining.Sub(remaining, closed)
+			amount = amount.Sub(amount, closedAmount)
+			if trip.position.Sign() == 0 {
+				trip.finish(t.Date)
+				r.Closed = append(r.Closed, trip)
+				delete(open, key)
+			}
+		}
+	}
+
+	for i := 0; i < len(keys); i++ {
+		trip := open[keys[i]]
+		// guard clause: keys of trips reopened after closing repeat
+		if trip == nil {
+			continue
+		}
+		trip.finish(time.Time{})
+		trip.Days = int(asOf.Sub(trip.Open).Hours() / 24)
+		r.Open = append(r.Open, trip)
+		delete(open, keys[i])
+	}
+	sort.SliceStable(r.Closed, func(i, j int) bool {
+		return r.Closed[i].Close.Before(r.Closed[j].Close)
+	})
+	sort.SliceStable(r.Open, func(i, j int) bool {
+		return r.Open[i].Open.Before(r.Open[j].Open)
+	})
+	return &r
+}
+
+// Annotate attaches the journal entry of each round trip to it, and
+// computes the R-multiple of the round trips with an initial risk
+func (r *RoundTrips) Annotate(j trade.TradeJournal) {
+	for i := 0; i < len(r.Closed); i++ {
+		r.Closed[i].annotate(j.Lookup(r.Closed[i].ID))
+	}
+	for i := 0; i < len(r.Open); i++ {
+		r.Open[i].annotate(j.Lookup(r.Open[i].ID))
+	}
+}
+
+// annotate attaches a journal entry to the round trip. the initial risk is
+// the entry's dollar risk, or the distance from the average entry price to
+// the stop over the quantity entered.
+func (r *RoundTrip) annotate(a *trade.Annotation) {
+	r.Journal = a
+	// guard clause: no risk to measure against
+	if a == nil || (a.Risk == 0 && a.Stop == 0) {
+		return
+	}
+	risk := big.NewFloat(math.Abs(a.Risk))
+	if a.Risk == 0 {
+		distance := big.NewFloat(0.0).Sub(r.EntryPrice, big.NewFloat(a.Stop))
+		risk = r.instrument.Notional(r.Quantity, distance.Abs(distance))
+	}
+	// guard clause: a stop at the entry price risks nothing
+	if risk.Sign() == 0 {
+		return
+	}
+	r.Risk = risk
+	r.RMultiple = big.NewFloat(0.0).Quo(r.PL, risk)
+}
+
+// newRoundTrip returns an empty round trip opened by the transaction
+func newRoundTrip(t *trade.Trade, symbol string, short bool) *RoundTrip {
+	instrument := t.Instrument
+	if instrument == nil {
+		instrument = trade.NewInstrument(symbol)
+	}
+	return &RoundTrip{
+		ID:           t.ID,
+		Account:      t.Account,
+		Symbol:       symbol,
+		Short:        short,
+		Open:         t.Date,
+		Quantity:     big.NewFloat(0.0),
+		MaxQuantity:  big.NewFloat(0.0),
+		EntryPrice:   big.NewFloat(0.0),
+		ExitPrice:    big.NewFloat(0.0),
+		Cost:         big.NewFloat(0.0),
+		PL:           big.NewFloat(0.0),
+		Return:       big.NewFloat(0.0),
+		Entries:      make([]*Fill, 0),
+		Exits:        make([]*Fill, 0),
+		position:     big.NewFloat(0.0),
+		entered:      big.NewFloat(0.0),
+		exitQuantity: big.NewFloat(0.0),
+		exited:       big.NewFloat(0.0),
+		instrument:   instrument,
+	}
+}
+
+// enter adds a fill in the direction of the round trip
+func (r *RoundTrip) enter(t *trade.Trade, quantity *big.Float, amount *big.Float) {
+	f := newFill(t, quantity, amount)
+	r.Entries = append(r.Entries, f)
+	size := big.NewFloat(0.0).Abs(quantity)
+	r.position = r.position.Add(r.position, quantity)
+	r.Quantity = r.Quantity.Add(r.Quantity, size)
+	if open := big.NewFloat(0.0).Abs(r.position); open.Cmp(r.MaxQuantity) > 0 {
+		r.MaxQuantity = open
+	}
+	r.entered = r.entered.Add(r.entered, big.NewFloat(0.0).Mul(f.Price, size))
+	r.Cost = r.Cost.Add(r.Cost, big.NewFloat(0.0).Abs(amount))
+	r.PL = r.PL.Add(r.PL, amount)
+}
+
+// exit adds a fill reducing the position of the round trip
+func (r *RoundTrip) exit(t *trade.Trade, quantity *big.Float, amount *big.Float) {
+	f := newFill(t, quantity, amount)
+	r.Exits = append(r.Exits, f)
+	size := big.NewFloat(0.0).Abs(quantity)
+	r.position = r.position.Add(r.position, quantity)
+	r.exitQuantity = r.exitQuantity.Add(r.exitQuantity, size)
+	r.exited = r.exited.Add(r.exited, big.NewFloat(0.0).Mul(f.Price, size))
+	r.PL = r.PL.Add(r.PL, amount)
+}
+
+// finish computes the averages of the round trip once it's closed, or
+// reported as still open when closed is zero
+func (r *RoundTrip) finish(closed time.Time) {
+	r.Close = closed
+	if !closed.IsZero() {
+		r.Days = int(closed.Sub(r.Open).Hours() / 24)
+	}
+	if r.Quantity.Sign() != 0 {
+		r.EntryPrice = r.EntryPrice.Quo(r.entered, r.Quantity)
+	}
+	if r.exitQuantity.Sign() != 0 {
+		r.ExitPrice = r.ExitPrice.Quo(r.exited, r.exitQuantity)
+	}
+	r.Return = percentOf(r.PL, r.Cost)
+}
+
+// newFill returns the fill of a quantity of a transaction
+func newFill(t *trade.Trade, quantity *big.Float, amount *big.Float) *Fill {
+	price := big.NewFloat(0.0)
+	if t.Price != nil {
+		price = price.Copy(t.Price)
+	}
+	return &Fill{
+		ID:       t.ID,
+		Date:     t.Date,
+		Quantity: big.NewFloat(0.0).Copy(quantity),
+		Price:    price,
+		Amount:   big.NewFloat(0.0).Copy(amount),
+	}
+}
+
+// minAbsFloat returns the smaller of the absolute values of a and b
+func minAbsFloat(a *big.Float, b *big.Float) *big.Float {
+	absA := big.NewFloat(0.0).Abs(a)
+	absB := big.NewFloat(0.0).Abs(b)
+	if absA.Cmp(absB) < 0 {
+		return absA
+	}
+	return absB
+}
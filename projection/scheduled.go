@@ -0,0 +1,87 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// ScheduleDLine totals the sales of one holding period category
+type ScheduleDLine struct {
+	Proceeds   *big.Float
+	Basis      *big.Float
+	Adjustment *big.Float // wash sale adjustments, added to the gain
+	Gain       *big.Float // gain or loss after adjustments
+}
+
+// ScheduleDYear summarizes the capital gains of a tax year the way
+// Schedule D does, totaling the short term (part I) and long term
+// (part II) sales listed on Form 8949
+type ScheduleDYear struct {
+	Year      int
+	ShortTerm *ScheduleDLine
+	LongTerm  *ScheduleDLine
+	Net       *big.Float // combined short and long term gain or loss
+}
+
+// ScheduleD holds the Schedule D summary of each tax year lots were closed in
+type ScheduleD struct {
+	Years []*ScheduleDYear // in year order
+}
+
+// NewScheduleD matches closing transactions against open lots and totals
+// the proceeds, basis and gain of the lots closed in taxable accounts in
+// each tax year
+func NewScheduleD(trans []*trade.Trade, opts *lots.Options) *ScheduleD {
+	return newScheduleD(NewForm8949(trans, opts))
+}
+
+// newScheduleDLine returns a line with zero totals
+func newScheduleDLine() *ScheduleDLine {
+	return &ScheduleDLine{
+		Proceeds:   big.NewFloat(0.0),
+		Basis:      big.NewFloat(0.0),
+		Adjustment: big.NewFloat(0.0),
+		Gain:       big.NewFloat(0.0),
+	}
+}
+
+// add adds a Form 8949 row to the line's totals
+func (l *ScheduleDLine) add(r *Form8949Row) {
+	l.Proceeds = l.Proceeds.Add(l.Proceeds, r.Proceeds)
+	l.Basis = l.Basis.Add(l.Basis, r.Basis)
+	l.Adjustment = l.Adjustment.Add(l.Adjustment, r.Adjustment)
+	l.Gain = l.Gain.Add(l.Gain, r.Gain)
+}
+
+// newScheduleD totals the rows of Form 8949 per tax year
+func newScheduleD(f *Form8949) *ScheduleD {
+	s := ScheduleD{Years: make([]*ScheduleDYear, 0)}
+	byYear := make(map[int]*ScheduleDYear)
+	for i := 0; i < len(f.Rows); i++ {
+		r := f.Rows[i]
+		y := byYear[r.TaxYear]
+		if y == nil {
+			y = &ScheduleDYear{
+				Year:      r.TaxYear,
+				ShortTerm: newScheduleDLine(),
+				LongTerm:  newScheduleDLine(),
+				Net:       big.NewFloat(0.0),
+			}
+			byYear[r.TaxYear] = y
+			s.Years = append(s.Years, y)
+		}
+		if r.LongTerm {
+			y.LongTerm.add(r)
+		} else {
+			y.ShortTerm.add(r)
+		}
+		y.Net = y.Net.Add(y.Net, r.Gain)
+	}
+	sort.Slice(s.Years, func(i, j int) bool {
+		return s.Years[i].Year < s.Years[j].Year
+	})
+	return &s
+}
@@ -0,0 +1,109 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+)
+
+// PositionShare is an open position's share of the portfolio value
+type PositionShare struct {
+	Account string
+	Symbol  string
+	Value   *big.Float // market value when priced, cost otherwise
+	Percent *big.Float // percent of the portfolio value
+}
+
+// SymbolSizing summarizes how large the positions taken in a symbol were
+type SymbolSizing struct {
+	Symbol     string
+	RoundTrips int
+	// AverageEntry is the average cost of the entries of a round trip
+	AverageEntry *big.Float
+	// LargestQuantity is the most held at once in a single round trip, and
+	// LargestValue its value at the round trip's average entry price
+	LargestQuantity *big.Float
+	LargestValue    *big.Float
+	LargestOpened   time.Time // when the round trip with the largest position was opened
+}
+
+// PositionSizing reports the size of positions, to review how much risk
+// was taken in any one position
+type PositionSizing struct {
+	// Value is the portfolio value, the absolute value of every open
+	// position plus the cash balance
+	Value     *big.Float
+	Positions []*PositionShare // largest share first
+	Symbols   []*SymbolSizing  // ordered by symbol
+}
+
+// NewPositionSizing computes each open position's share of the portfolio
+// value, and the average and largest position taken in each symbol over
+// every round trip. unrealized may be nil when there are no prices.
+func NewPositionSizing(positions *Positions, unrealized *UnrealizedPL, cash *CashBalances, trips *RoundTrips) *PositionSizing {
+	s := PositionSizing{
+		Value:     big.NewFloat(0.0).Copy(cash.Current),
+		Positions: make([]*PositionShare, 0, len(positions.Positions)),
+		Symbols:   make([]*SymbolSizing, 0),
+	}
+	priced := make(map[string]*big.Float)
+	if unrealized != nil {
+		for i := 0; i < len(unrealized.Positions); i++ {
+			p := unrealized.Positions[i]
+			priced[p.Account+"|"+p.Symbol] = p.MarketValue
+		}
+	}
+	for i := 0; i < len(positions.Positions); i++ {
+		p := positions.Positions[i]
+		value := p.Cost
+		if v, ok := priced[p.Account+"|"+p.Symbol]; ok {
+			value = v
+		}
+		share := PositionShare{Account: p.Account, Symbol: p.Symbol, Value: big.NewFloat(0.0).Abs(value)}
+		s.Value = s.Value.Add(s.Value, share.Value)
+		s.Positions = append(s.Positions, &share)
+	}
+	for i := 0; i < len(s.Positions); i++ {
+		s.Positions[i].Percent = percentOf(s.Positions[i].Value, s.Value)
+	}
+	sort.SliceStable(s.Positions, func(i, j int) bool {
+		return s.Positions[i].Value.Cmp(s.Positions[j].Value) > 0
+	})
+
+	bySymbol := make(map[string]*SymbolSizing)
+	totals := make(map[string]*big.Float)
+	all := make([]*RoundTrip, 0, len(trips.Closed)+len(trips.Open))
+	all = append(all, trips.Closed...)
+	all = append(all, trips.Open...)
+	for i := 0; i < len(all); i++ {
+		trip := all[i]
+		sizing := bySymbol[trip.Symbol]
+		if sizing == nil {
+			sizing = &SymbolSizing{
+				Symbol:          trip.Symbol,
+				AverageEntry:    big.NewFloat(0.0),
+				LargestQuantity: big.NewFloat(0.0),
+				LargestValue:    big.NewFloat(0.0),
+			}
+			bySymbol[trip.Symbol] = sizing
+			totals[trip.Symbol] = big.NewFloat(0.0)
+			s.Symbols = append(s.Symbols, sizing)
+		}
+		sizing.RoundTrips++
+		totals[trip.Symbol] = totals[trip.Symbol].Add(totals[trip.Symbol], trip.Cost)
+		value := trip.instrument.Notional(trip.MaxQuantity, trip.EntryPrice)
+		if value.Cmp(sizing.LargestValue) > 0 {
+			sizing.LargestQuantity = trip.MaxQuantity
+			sizing.LargestValue = value
+			sizing.LargestOpened = trip.Open
+		}
+	}
+	for i := 0; i < len(s.Symbols); i++ {
+		sizing := s.Symbols[i]
+		sizing.AverageEntry = sizing.AverageEntry.Quo(totals[sizing.Symbol], big.NewFloat(float64(sizing.RoundTrips)))
+	}
+	sort.Slice(s.Symbols, func(i, j int) bool {
+		return s.Symbols[i].Symbol < s.Symbols[j].Symbol
+	})
+	return &s
+}
@@ -0,0 +1,255 @@
+package projection
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stonks/trade"
+)
+
+// Snapshotter is a projection whose state can be saved after a run and
+// restored to resume from, applying only the transactions imported since.
+// projections that aren't are rebuilt from every transaction on each run.
+type Snapshotter interface {
+	// Snapshot returns the state of the projection
+	Snapshot() ([]byte, error)
+	// Restore replaces the state of the projection with a snapshot
+	Restore(data []byte) error
+}
+
+// Snapshot holds the state of the projections after a run
+type Snapshot struct {
+	// Through is the date of the last transaction applied, and IDs the ids
+	// of the transactions applied on that day. transactions dated before
+	// it are taken to have been applied already.
+	Through time.Time
+	IDs     []string
+	// Digest is a digest of every transaction applied. transactions
+	// backfilled before Through change it, and the projections are then
+	// rebuilt rather than resumed.
+	Digest string
+	States map[string]json.RawMessage // keyed by projection name
+}
+
+// LoadSnapshot reads a snapshot written by Write. a missing file returns
+// an empty snapshot, so the first run starts from scratch.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Snapshot{States: make(map[string]json.RawMessage)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.States == nil {
+		s.States = make(map[string]json.RawMessage)
+	}
+	return &s, nil
+}
+
+// Write writes the snapshot as json
+func (s *Snapshot) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// applied returns true if the transaction was applied before the snapshot
+// was taken
+func (s *Snapshot) applied(t *trade.Trade) bool {
+	if !sameDay(t.Date, s.Through) {
+		return t.Date.Before(s.Through)
+	}
+	for i := 0; i < len(s.IDs); i++ {
+		if s.IDs[i] == t.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// digest returns a digest of the transactions the snapshot takes to have
+// been applied already
+func (s *Snapshot) digest(trans []*trade.Trade) string {
+	keys := make([]string, 0, len(trans))
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if s.applied(t) {
+			keys = append(keys, t.Date.Format(time.RFC3339Nano)+"|"+t.Account+"|"+t.ID)
+		}
+	}
+	sort.Strings(keys)
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Resume runs the projections like Run, restoring those that can be
+// snapshotted from the snapshot and applying only the transactions after
+// it to them. it returns the results of the named projections, leaving out
+// the projections they depend on, along with a new snapshot. a nil
+// snapshot runs every projection from scratch, as does a snapshot that
+// older transactions have been imported after. the settings' GroupBy is
+// ignored, since grouped projections are rebuilt by Run on each run.
+func Resume(names []string, s *Settings, trans []*trade.Trade, snap *Snapshot) (map[string]interface{}, *Snapshot, error) {
+	projections, err := newProjections(names, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	// a snapshot older transactions were imported after can't be resumed
+	if snap != nil && snap.digest(trans) != snap.Digest {
+		snap = nil
+	}
+	restored := make([]bool, len(projections))
+	for i := 0; snap != nil && i < len(projections); i++ {
+		state, ok := snap.States[projections[i].Name()]
+		snapshotter, canRestore := projections[i].(Snapshotter)
+		if !ok || !canRestore {
+			continue
+		}
+		if err := snapshotter.Restore(state); err != nil {
+			return nil, nil, err
+		}
+		restored[i] = true
+	}
+
+	ordered := byDate(trans)
+	results := feed(projections, ordered, func(p int, t *trade.Trade) bool {
+		return restored[p] && snap.applied(t)
+	})
+
+	next := Snapshot{IDs: make([]string, 0), States: make(map[string]json.RawMessage)}
+	if snap != nil {
+		next.Through = snap.Through
+		next.IDs = append(next.IDs, snap.IDs...)
+	}
+	if len(ordered) > 0 && !ordered[len(ordered)-1].Date.Before(next.Through) {
+		next.Through = ordered[len(ordered)-1].Date
+		next.IDs = next.IDs[:0]
+		for i := 0; i < len(ordered); i++ {
+			if sameDay(ordered[i].Date, next.Through) {
+				next.IDs = append(next.IDs, ordered[i].ID)
+			}
+		}
+	}
+	next.Digest = next.digest(ordered)
+	requested := make(map[string]bool, len(names))
+	for i := 0; i < len(names); i++ {
+		requested[names[i]] = true
+	}
+	byName := make(map[string]interface{}, len(names))
+	for i := 0; i < len(projections); i++ {
+		if err, ok := results[i].(error); ok {
+			return nil, nil, err
+		}
+		if requested[projections[i].Name()] {
+			byName[projections[i].Name()] = results[i]
+		}
+		snapshotter, ok := projections[i].(Snapshotter)
+		if !ok {
+			continue
+		}
+		state, err := snapshotter.Snapshot()
+		if err != nil {
+			return nil, nil, err
+		}
+		next.States[projections[i].Name()] = state
+	}
+	return byName, &next, nil
+}
+
+// volumeProjection builds the trade volume one transaction at a time
+type volumeProjection struct {
+	settings *Settings
+	volume   *TradeVolume
+}
+
+// newVolumeProjection returns a projection of the trade volume
+func newVolumeProjection(s *Settings) Projection {
+	return &volumeProjection{settings: s, volume: newTradeVolume()}
+}
+
+// Name returns volume
+func (p *volumeProjection) Name() string {
+	return "volume"
+}
+
+// Apply counts a trading transaction towards the volume
+func (p *volumeProjection) Apply(t *trade.Trade) {
+	if p.settings.isTrading(t) {
+		p.volume.add(t)
+	}
+}
+
+// Result returns the volume traded so far
+func (p *volumeProjection) Result() interface{} {
+	p.volume.sort()
+	return p.volume
+}
+
+// Snapshot returns the volume as json
+func (p *volumeProjection) Snapshot() ([]byte, error) {
+	return json.Marshal(p.volume)
+}
+
+// Restore replaces the volume with a snapshot
+func (p *volumeProjection) Restore(data []byte) error {
+	v := newTradeVolume()
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	v.index()
+	p.volume = v
+	return nil
+}
+
+// contributionsProjection totals the contributions one transaction at a
+// time
+type contributionsProjection struct {
+	contributions *Contributions
+}
+
+// newContributionsProjection returns a projection of the contributions
+func newContributionsProjection(s *Settings) Projection {
+	return &contributionsProjection{contributions: newContributions()}
+}
+
+// Name returns contributions
+func (p *contributionsProjection) Name() string {
+	return "contributions"
+}
+
+// Apply counts a deposit or withdrawal towards the contributions
+func (p *contributionsProjection) Apply(t *trade.Trade) {
+	p.contributions.add(t)
+}
+
+// Result returns the contributions made so far
+func (p *contributionsProjection) Result() interface{} {
+	p.contributions.sort()
+	return p.contributions
+}
+
+// Snapshot returns the contributions as json
+func (p *contributionsProjection) Snapshot() ([]byte, error) {
+	return json.Marshal(p.contributions)
+}
+
+// Restore replaces the contributions with a snapshot
+func (p *contributionsProjection) Restore(data []byte) error {
+	c := newContributions()
+	if err := json.Unmarshal(data, c); err != nil {
+		return err
+	}
+	c.index()
+	p.contributions = c
+	return nil
+}
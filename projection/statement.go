@@ -0,0 +1,213 @@
+package projection
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/pdf"
+	"github.com/stonks/trade"
+)
+
+// incomeTypes are the transactions reported as income on a statement.
+// margin interest is charged rather than paid, so it is negative.
+var incomeTypes = map[trade.TradeType]bool{
+	trade.Dividend:       true,
+	trade.Interest:       true,
+	trade.Coupon:         true,
+	trade.MarginInterest: true,
+}
+
+// StatementIncome is a dividend or interest payment on a statement
+type StatementIncome struct {
+	Account     string
+	Symbol      string
+	Date        time.Time
+	Type        trade.TradeType
+	Description string
+	Amount      *big.Float
+}
+
+// Statement summarizes the positions, realized gains, income and fees of
+// a period, such as a tax year, to hand to an accountant
+type Statement struct {
+	Period    DateRange
+	Positions *Positions  // open at the end of the period
+	Realized  *RealizedPL // gains of the lots closed during the period
+	Income    []*StatementIncome
+	// IncomeByType totals the income of each type, eg DIVIDEND
+	IncomeByType map[trade.TradeType]*big.Float
+	IncomeTotal  *big.Float
+	Fees         *FeeTotals // charged during the period
+}
+
+// NewStatement reports the period from the start of the first day through
+// the end of the last. trans are every transaction, for the income and
+// fees, and trading those counted as trades, for the positions and gains.
+func NewStatement(trans []*trade.Trade, trading []*trade.Trade, opts *lots.Options, period DateRange) *Statement {
+	s := Statement{
+		Period:       period,
+		Positions:    NewPositions(trading, opts, period.To),
+		Income:       make([]*StatementIncome, 0),
+		IncomeByType: make(map[trade.TradeType]*big.Float),
+		IncomeTotal:  big.NewFloat(0.0),
+		Fees:         newFeeTotals(),
+	}
+
+	// lots closed in the period are matched against every earlier lot
+	closed := make([]*lots.RealizedGain, 0)
+	realized := lots.Match(trading, opts).Realized
+	for i := 0; i < len(realized); i++ {
+		if period.contains(realized[i].CloseDate) {
+			closed = append(closed, realized[i])
+		}
+	}
+	s.Realized = newRealizedPL(closed)
+
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		// guard clause: made outside the period
+		if !period.contains(t.Date) {
+			continue
+		}
+		s.Fees.add(t)
+		if !incomeTypes[t.Type] || t.Amount == nil {
+			continue
+		}
+		s.Income = append(s.Income, &StatementIncome{
+			Account:     t.Account,
+			Symbol:      t.Symbol,
+			Date:        t.Date,
+			Type:        t.Type,
+			Description: strings.TrimSpace(t.Description),
+			Amount:      t.Amount,
+		})
+		if s.IncomeByType[t.Type] == nil {
+			s.IncomeByType[t.Type] = big.NewFloat(0.0)
+		}
+		s.IncomeByType[t.Type] = s.IncomeByType[t.Type].Add(s.IncomeByType[t.Type], t.Amount)
+		s.IncomeTotal = s.IncomeTotal.Add(s.IncomeTotal, t.Amount)
+	}
+	return &s
+}
+
+// money formats an amount with cents
+func money(f *big.Float) string {
+	if f == nil {
+		return ""
+	}
+	return f.Text('f', 2)
+}
+
+// fit cuts text to fit a column of n characters
+func fit(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// WritePDF writes the statement as a pdf, with a summary page followed by
+// the positions, the realized gains, the income and the fees, each
+// continuing over as many pages as it needs under its own heading
+func (s *Statement) WritePDF(w io.Writer) error {
+	title := fmt.Sprintf("Statement for %s through %s", s.Period.From.Format("2006-01-02"), s.Period.To.Format("2006-01-02"))
+	doc := pdf.NewDocument()
+	doc.Footer = title
+	amount := "%-40s %16s"
+
+	positionsCost := big.NewFloat(0.0)
+	for i := 0; i < len(s.Positions.Positions); i++ {
+		positionsCost = positionsCost.Add(positionsCost, s.Positions.Positions[i].Cost)
+	}
+	summary := []string{
+		title,
+		"",
+		"Realized gains",
+		fmt.Sprintf(amount, "  Short term", money(s.Realized.Term.ShortTerm)),
+		fmt.Sprintf(amount, "  Long term", money(s.Realized.Term.LongTerm)),
+		fmt.Sprintf(amount, "  Total", money(s.Realized.Total)),
+		fmt.Sprintf(amount, "  Wash sale losses disallowed", money(s.Realized.Disallowed)),
+		"",
+		"Income",
+	}
+	types := []trade.TradeType{trade.Dividend, trade.Interest, trade.Coupon, trade.MarginInterest}
+	for i := 0; i < len(types); i++ {
+		if total := s.IncomeByType[types[i]]; total != nil {
+			summary = append(summary, fmt.Sprintf(amount, "  "+string(types[i]), money(total)))
+		}
+	}
+	summary = append(summary,
+		fmt.Sprintf(amount, "  Total", money(s.IncomeTotal)),
+		"",
+		"Fees",
+		fmt.Sprintf(amount, "  Commissions", money(s.Fees.Commission)),
+		fmt.Sprintf(amount, "  Other fees", money(new(big.Float).Sub(s.Fees.Total, s.Fees.Commission))),
+		fmt.Sprintf(amount, "  Total", money(s.Fees.Total)),
+		"",
+		fmt.Sprintf("Open positions at %s", s.Period.To.Format("2006-01-02")),
+		fmt.Sprintf(amount, "  Positions", fmt.Sprint(len(s.Positions.Positions))),
+		fmt.Sprintf(amount, "  Cost basis", money(positionsCost)),
+	)
+	doc.AddPage(summary)
+
+	format := "%-16s %-24s %16s %16s %16s"
+	header := fmt.Sprintf(format, "Account", "Symbol", "Quantity", "Cost", "Average Cost")
+	rows := make([]string, 0, len(s.Positions.Positions)+2)
+	for i := 0; i < len(s.Positions.Positions); i++ {
+		p := s.Positions.Positions[i]
+		rows = append(rows, fmt.Sprintf(format, fit(p.Account, 16), fit(p.Symbol, 24), p.Quantity.Text('f', -1), money(p.Cost), money(p.AverageCost)))
+	}
+	rows = append(rows, strings.Repeat("-", len(header)), fmt.Sprintf(format, "Total", "", "", money(positionsCost), ""))
+	doc.AddTable([]string{title, "Open Positions", "", header, strings.Repeat("-", len(header))}, rows)
+
+	format = "%-10s %-12s %-16s %-36s %12s %14s %14s %14s %-5s"
+	header = fmt.Sprintf(format, "Date", "Account", "Symbol", "Description", "Quantity", "Proceeds", "Basis", "Gain", "Term")
+	rows = make([]string, 0)
+	for i := 0; i < len(s.Realized.Transactions); i++ {
+		g := s.Realized.Transactions[i]
+		term := "Short"
+		if g.Term.ShortTerm.Sign() == 0 && g.Term.LongTerm.Sign() != 0 {
+			term = "Long"
+		} else if g.Term.ShortTerm.Sign() != 0 && g.Term.LongTerm.Sign() != 0 {
+			term = "Mixed"
+		}
+		rows = append(rows, fmt.Sprintf(format, g.Date.Format("2006-01-02"), fit(g.Account, 12), fit(g.Symbol, 16), fit(g.Description, 36),
+			g.Quantity.Text('f', -1), money(g.Proceeds), money(g.Basis), money(g.Gain), term))
+	}
+	rows = append(rows, strings.Repeat("-", len(header)), fmt.Sprintf(format, "Total", "", "", "", "", "", "", money(s.Realized.Total), ""))
+	doc.AddTable([]string{title, "Realized Gains", "", header, strings.Repeat("-", len(header))}, rows)
+
+	format = "%-10s %-12s %-16s %-16s %-50s %14s"
+	header = fmt.Sprintf(format, "Date", "Account", "Symbol", "Type", "Description", "Amount")
+	rows = make([]string, 0, len(s.Income)+2)
+	for i := 0; i < len(s.Income); i++ {
+		in := s.Income[i]
+		rows = append(rows, fmt.Sprintf(format, in.Date.Format("2006-01-02"), fit(in.Account, 12), fit(in.Symbol, 16), string(in.Type), fit(in.Description, 50), money(in.Amount)))
+	}
+	rows = append(rows, strings.Repeat("-", len(header)), fmt.Sprintf(format, "Total", "", "", "", "", money(s.IncomeTotal)))
+	doc.AddTable([]string{title, "Income", "", header, strings.Repeat("-", len(header))}, rows)
+
+	fees := []struct {
+		name   string
+		amount *big.Float
+	}{
+		{"Commissions", s.Fees.Commission},
+		{"Regulatory fees", s.Fees.RegFee},
+		{"ADR fees", s.Fees.ADRFee},
+		{"Redemption fees", s.Fees.RedemptionFee},
+		{"Account fees", s.Fees.AccountFee},
+		{"Network fees", s.Fees.NetworkFee},
+	}
+	header = fmt.Sprintf(amount, "Fee", "Amount")
+	rows = make([]string, 0, len(fees)+2)
+	for i := 0; i < len(fees); i++ {
+		rows = append(rows, fmt.Sprintf(amount, fees[i].name, money(fees[i].amount)))
+	}
+	rows = append(rows, strings.Repeat("-", len(header)), fmt.Sprintf(amount, "Total", money(s.Fees.Total)))
+	doc.AddTable([]string{title, "Fees", "", header, strings.Repeat("-", len(header))}, rows)
+	return doc.Write(w)
+}
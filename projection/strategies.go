@@ -0,0 +1,283 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// StrategyType identifies the option strategy a set of legs make up
+type StrategyType string
+
+const (
+	LongCall      StrategyType = "LONG_CALL"
+	LongPut       StrategyType = "LONG_PUT"
+	ShortCall     StrategyType = "SHORT_CALL"
+	ShortPut      StrategyType = "SHORT_PUT"
+	CoveredCall   StrategyType = "COVERED_CALL"
+	Vertical      StrategyType = "VERTICAL"
+	Calendar      StrategyType = "CALENDAR"
+	Diagonal      StrategyType = "DIAGONAL"
+	Straddle      StrategyType = "STRADDLE"
+	Strangle      StrategyType = "STRANGLE"
+	IronCondor    StrategyType = "IRON_CONDOR"
+	IronButterfly StrategyType = "IRON_BUTTERFLY"
+	Custom        StrategyType = "CUSTOM" // any other combination of legs
+)
+
+// StrategyLeg is an option opened as part of a strategy
+type StrategyLeg struct {
+	ID         string
+	Symbol     string
+	Quantity   *big.Float // positive for long legs, negative for short legs
+	OptionType trade.OptionType
+	Strike     *big.Float
+	Expiration time.Time
+}
+
+// OptionStrategy is a set of option legs opened together on an underlying
+type OptionStrategy struct {
+	ID         string // id of the first leg
+	Account    string
+	Underlying string
+	Type       StrategyType
+	Opened     time.Time
+	Legs       []*StrategyLeg
+	Premium    *big.Float // cash received, or paid when negative, opening the legs
+	PL         *big.Float // realized gain or loss of the legs
+	Open       bool       // true while any of the legs are still open
+}
+
+// StrategySummary totals the results of every strategy of a type
+type StrategySummary struct {
+	Type   StrategyType
+	Count  int
+	Wins   int // closed strategies with a gain
+	Losses int // closed strategies with a loss
+	PL     *big.Float
+}
+
+// OptionStrategies reports option trades as the strategies their legs
+// make up rather than leg by leg
+type OptionStrategies struct {
+	Strategies []*OptionStrategy  // ordered by the date they were opened
+	ByType     []*StrategySummary // ordered by type
+}
+
+// NewOptionStrategies groups the option legs opened in the same account on
+// the same underlying at the same time into strategies, classifies them
+// and totals the realized P/L of each strategy type. a short call opened
+// on its own is covered when the account held enough shares of the
+// underlying at the end of the day to deliver on assignment.
+func NewOptionStrategies(trans []*trade.Trade, opts *lots.Options) *OptionStrategies {
+	s := OptionStrategies{
+		Strategies: make([]*OptionStrategy, 0),
+		ByType:     make([]*StrategySummary, 0),
+	}
+	matched := lots.Match(trans, opts)
+
+	// the realized P/L and whether anything is left open, per opening trade
+	pl := make(map[*trade.Trade]*big.Float)
+	open := make(map[*trade.Trade]bool)
+	for i := 0; i < len(matched.Realized); i++ {
+		g := matched.Realized[i]
+		opening := g.Lot.Opening
+		if pl[opening] == nil {
+			pl[opening] = big.NewFloat(0.0)
+		}
+		pl[opening] = pl[opening].Add(pl[opening], g.Gain)
+	}
+	for i := 0; i < len(matched.Open); i++ {
+		open[matched.Open[i].Opening] = true
+	}
+
+	// option trades that opened a lot, grouped by account, underlying and time
+	ordered := lots.Ordered(trans)
+	byKey := make(map[string]*OptionStrategy)
+	for i := 0; i < len(ordered); i++ {
+		t := ordered[i]
+		if t.Instrument == nil || t.Instrument.Class != trade.Option || !t.IsTrade() {
+			continue
+		}
+		_, realized := pl[t]
+		// guard clause: the trade closed a position rather than opening one
+		if !realized && !open[t] {
+			continue
+		}
+		key := t.Account + "|" + t.Instrument.Underlying + "|" + t.Date.String()
+		strategy := byKey[key]
+		if strategy == nil {
+			strategy = &OptionStrategy{
+				ID:         t.ID,
+				Account:    t.Account,
+				Underlying: t.Instrument.Underlying,
+				Opened:     t.Date,
+				Legs:       make([]*StrategyLeg, 0),
+				Premium:    big.NewFloat(0.0),
+				PL:         big.NewFloat(0.0),
+			}
+			byKey[key] = strategy
+			s.Strategies = append(s.Strategies, strategy)
+		}
+		leg := StrategyLeg{
+			ID:         t.ID,
+			Symbol:     t.Symbol,
+			Quantity:   t.Quantity,
+			OptionType: t.Instrument.OptionType,
+			Strike:     t.Instrument.Strike,
+			Expiration: t.Instrument.Expiration,
+		}
+		if leg.Strike == nil {
+			leg.Strike = big.NewFloat(0.0)
+		}
+		strategy.Legs = append(strategy.Legs, &leg)
+		if t.Amount != nil {
+			strategy.Premium = strategy.Premium.Add(strategy.Premium, t.Amount)
+		}
+		if realized {
+			strategy.PL = strategy.PL.Add(strategy.PL, pl[t])
+		}
+		strategy.Open = strategy.Open || open[t]
+	}
+
+	byType := make(map[StrategyType]*StrategySummary)
+	for i := 0; i < len(s.Strategies); i++ {
+		strategy := s.Strategies[i]
+		strategy.Type = classifyStrategy(strategy.Legs)
+		if strategy.Type == ShortCall && covered(trans, opts, strategy) {
+			strategy.Type = CoveredCall
+		}
+
+		summary := byType[strategy.Type]
+		if summary == nil {
+			summary = &StrategySummary{Type: strategy.Type, PL: big.NewFloat(0.0)}
+			byType[strategy.Type] = summary
+			s.ByType = append(s.ByType, summary)
+		}
+		summary.Count++
+		summary.PL = summary.PL.Add(summary.PL, strategy.PL)
+		if !strategy.Open {
+			switch strategy.PL.Sign() {
+			case 1:
+				summary.Wins++
+			case -1:
+				summary.Losses++
+			}
+		}
+	}
+	sort.Slice(s.ByType, func(i, j int) bool {
+		return s.ByType[i].Type < s.ByType[j].Type
+	})
+	return &s
+}
+
+// classifyStrategy returns the strategy the legs make up
+func classifyStrategy(legs []*StrategyLeg) StrategyType {
+	switch len(legs) {
+	case 1:
+		long := legs[0].Quantity.Sign() > 0
+		switch {
+		case legs[0].OptionType == trade.Call && long:
+			return LongCall
+		case legs[0].OptionType == trade.Call:
+			return ShortCall
+		case long:
+			return LongPut
+		}
+		return ShortPut
+	case 2:
+		return classifyTwoLegs(legs[0], legs[1])
+	case 4:
+		return classifyFourLegs(legs)
+	}
+	return Custom
+}
+
+// classifyTwoLegs classifies a strategy of two legs
+func classifyTwoLegs(a *StrategyLeg, b *StrategyLeg) StrategyType {
+	sameSide := a.Quantity.Sign() == b.Quantity.Sign()
+	sameStrike := a.Strike.Cmp(b.Strike) == 0
+	sameExpiration := a.Expiration.Equal(b.Expiration)
+	if a.OptionType != b.OptionType {
+		// a put and a call bought or sold together
+		if !sameSide || !sameExpiration {
+			return Custom
+		}
+		if sameStrike {
+			return Straddle
+		}
+		return Strangle
+	}
+	// spreads buy one contract and sell another of the same type
+	switch {
+	case sameSide:
+		return Custom
+	case sameExpiration && !sameStrike:
+		return Vertical
+	case sameStrike && !sameExpiration:
+		return Calendar
+	case !sameStrike && !sameExpiration:
+		return Diagonal
+	}
+	return Custom
+}
+
+// classifyFourLegs classifies a strategy of four legs, which is an iron
+// condor when it's a put vertical below a call vertical expiring together
+// with the short strikes inside the long ones
+func classifyFourLegs(legs []*StrategyLeg) StrategyType {
+	puts := make([]*StrategyLeg, 0, 2)
+	calls := make([]*StrategyLeg, 0, 2)
+	for i := 0; i < len(legs); i++ {
+		if !legs[i].Expiration.Equal(legs[0].Expiration) {
+			return Custom
+		}
+		if legs[i].OptionType == trade.Put {
+			puts = append(puts, legs[i])
+		} else {
+			calls = append(calls, legs[i])
+		}
+	}
+	if len(puts) != 2 || classifyTwoLegs(puts[0], puts[1]) != Vertical || classifyTwoLegs(calls[0], calls[1]) != Vertical {
+		return Custom
+	}
+	shortPut, longPut := puts[0], puts[1]
+	if shortPut.Quantity.Sign() > 0 {
+		shortPut, longPut = longPut, shortPut
+	}
+	shortCall, longCall := calls[0], calls[1]
+	if shortCall.Quantity.Sign() > 0 {
+		shortCall, longCall = longCall, shortCall
+	}
+	// guard clause: the long legs must be the wings
+	if longPut.Strike.Cmp(shortPut.Strike) >= 0 || longCall.Strike.Cmp(shortCall.Strike) <= 0 ||
+		shortPut.Strike.Cmp(shortCall.Strike) > 0 {
+		return Custom
+	}
+	if shortPut.Strike.Cmp(shortCall.Strike) == 0 {
+		return IronButterfly
+	}
+	return IronCondor
+}
+
+// covered returns true if the account held enough shares of the
+// underlying at the end of the day the short call was opened to deliver
+// them if it's assigned
+func covered(trans []*trade.Trade, opts *lots.Options, strategy *OptionStrategy) bool {
+	leg := strategy.Legs[0]
+	instrument := trade.NewInstrument(leg.Symbol)
+	deliverable := big.NewFloat(0.0).Abs(leg.Quantity)
+	deliverable = deliverable.Mul(deliverable, instrument.Multiplier)
+
+	shares := big.NewFloat(0.0)
+	held := lots.OpenAsOf(trans, opts, strategy.Opened)
+	for i := 0; i < len(held); i++ {
+		if held[i].Account == strategy.Account && held[i].Symbol == strategy.Underlying {
+			shares = shares.Add(shares, held[i].Quantity)
+		}
+	}
+	return shares.Cmp(deliverable) >= 0
+}
@@ -0,0 +1,104 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// SymbolPL is the profit and loss of every position in an underlying
+// symbol, including its options
+type SymbolPL struct {
+	Rank       int
+	Underlying string
+	Realized   *big.Float
+	Unrealized *big.Float // zero without current prices
+	Total      *big.Float // realized plus unrealized
+	// Fees are the commissions and fees paid trading the symbol. they're
+	// already included in the P/L
+	Fees       *big.Float
+	Trades     int        // purchases and sales
+	PLPerTrade *big.Float // total P/L over the number of trades
+}
+
+// SymbolLeaderboard ranks the underlying symbols traded by their total
+// profit and loss
+type SymbolLeaderboard struct {
+	Symbols []*SymbolPL // highest total P/L first
+}
+
+// NewSymbolLeaderboard totals the realized and unrealized P/L, fees and
+// number of trades of each underlying symbol and ranks them. unrealized
+// may be nil when there are no current prices.
+func NewSymbolLeaderboard(trans []*trade.Trade, realized *RealizedPL, unrealized *UnrealizedPL) *SymbolLeaderboard {
+	l := SymbolLeaderboard{Symbols: make([]*SymbolPL, 0)}
+	bySymbol := make(map[string]*SymbolPL)
+	get := func(symbol string) *SymbolPL {
+		underlying := trade.GetUnderlyingSymbol(symbol)
+		s := bySymbol[underlying]
+		if s == nil {
+			s = &SymbolPL{
+				Underlying: underlying,
+				Realized:   big.NewFloat(0.0),
+				Unrealized: big.NewFloat(0.0),
+				Total:      big.NewFloat(0.0),
+				Fees:       big.NewFloat(0.0),
+				PLPerTrade: big.NewFloat(0.0),
+			}
+			bySymbol[underlying] = s
+			l.Symbols = append(l.Symbols, s)
+		}
+		return s
+	}
+
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		// guard clause: only count activity in a symbol
+		if strings.TrimSpace(t.Symbol) == "" || !(t.IsTrade() || lots.Affects(t)) {
+			continue
+		}
+		s := get(t.Symbol)
+		if t.IsTrade() {
+			s.Trades++
+		}
+		if t.Commission != nil {
+			s.Fees = s.Fees.Add(s.Fees, t.Commission)
+		}
+		if t.Fees != nil {
+			s.Fees = s.Fees.Add(s.Fees, t.Fees.Total())
+		}
+	}
+	for i := 0; i < len(realized.Transactions); i++ {
+		g := realized.Transactions[i]
+		s := get(g.Symbol)
+		s.Realized = s.Realized.Add(s.Realized, g.Gain)
+	}
+	if unrealized != nil {
+		for i := 0; i < len(unrealized.Positions); i++ {
+			p := unrealized.Positions[i]
+			s := get(p.Symbol)
+			s.Unrealized = s.Unrealized.Add(s.Unrealized, p.Gain)
+		}
+	}
+
+	for i := 0; i < len(l.Symbols); i++ {
+		s := l.Symbols[i]
+		s.Total = s.Total.Add(s.Realized, s.Unrealized)
+		if s.Trades > 0 {
+			s.PLPerTrade = s.PLPerTrade.Quo(s.Total, big.NewFloat(float64(s.Trades)))
+		}
+	}
+	sort.SliceStable(l.Symbols, func(i, j int) bool {
+		if c := l.Symbols[i].Total.Cmp(l.Symbols[j].Total); c != 0 {
+			return c > 0
+		}
+		return l.Symbols[i].Underlying < l.Symbols[j].Underlying
+	})
+	for i := 0; i < len(l.Symbols); i++ {
+		l.Symbols[i].Rank = i + 1
+	}
+	return &l
+}
@@ -0,0 +1,70 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// SymbolLots holds the open tax lots of a symbol in an account
+type SymbolLots struct {
+	Account  string
+	Symbol   string
+	Quantity *big.Float  // total open quantity across the lots
+	Cost     *big.Float  // total cost basis across the lots
+	Lots     []*lots.Lot // open lots ordered by open date
+}
+
+// TaxLots lists the tax lots that are currently open
+type TaxLots struct {
+	Positions []*SymbolLots // open lots per account and symbol
+}
+
+// NewTaxLots replays the transactions through the lots engine and reports
+// the lots left open, grouped by account and symbol.
+func NewTaxLots(trans []*trade.Trade, opts *lots.Options) *TaxLots {
+	return newTaxLots(lots.Match(trans, opts).Open)
+}
+
+// newTaxLots groups open lots by account and symbol
+func newTaxLots(open []*lots.Lot) *TaxLots {
+	p := TaxLots{
+		Positions: make([]*SymbolLots, 0),
+	}
+	byKey := make(map[string]*SymbolLots)
+	for i := 0; i < len(open); i++ {
+		lot := open[i]
+		key := lot.Account + "|" + lot.Symbol
+		s := byKey[key]
+		if s == nil {
+			s = &SymbolLots{
+				Account:  lot.Account,
+				Symbol:   lot.Symbol,
+				Quantity: big.NewFloat(0.0),
+				Cost:     big.NewFloat(0.0),
+				Lots:     make([]*lots.Lot, 0),
+			}
+			byKey[key] = s
+			p.Positions = append(p.Positions, s)
+		}
+		s.Quantity = s.Quantity.Add(s.Quantity, lot.Quantity)
+		s.Cost = s.Cost.Add(s.Cost, lot.Cost)
+		s.Lots = append(s.Lots, lot)
+	}
+
+	for i := 0; i < len(p.Positions); i++ {
+		positionLots := p.Positions[i].Lots
+		sort.SliceStable(positionLots, func(a, b int) bool {
+			return positionLots[a].OpenDate.Before(positionLots[b].OpenDate)
+		})
+	}
+	sort.Slice(p.Positions, func(i, j int) bool {
+		if p.Positions[i].Account != p.Positions[j].Account {
+			return p.Positions[i].Account < p.Positions[j].Account
+		}
+		return p.Positions[i].Symbol < p.Positions[j].Symbol
+	})
+	return &p
+}
@@ -0,0 +1,124 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+)
+
+// TiltStreakLength is how many losses in a row make a losing streak
+const TiltStreakLength = 3
+
+// TiltSizeMultiple is how many times the average size a trade entered
+// during a losing streak has to be to count as a sign of tilt
+const TiltSizeMultiple = 1.5
+
+// LossStreak is a run of consecutive losing round trips
+type LossStreak struct {
+	Start  time.Time // close of the first loss
+	End    time.Time // close of the last loss
+	Trades int
+	Loss   *big.Float // combined P/L of the losses
+}
+
+// TiltTrade is a round trip entered during a losing streak at an unusually
+// large size, as when revenge trading to win the losses back
+type TiltTrade struct {
+	ID      string
+	Account string
+	Symbol  string
+	Open    time.Time
+	Cost    *big.Float // absolute cost of the entries
+	// AverageCost is the average absolute cost of the round trips entered
+	// before it, and SizeRatio how many times that the trade was
+	SizeRatio   float64
+	AverageCost *big.Float
+	PL          *big.Float
+	Closed      bool
+	Streak      *LossStreak // the streak it was entered during
+}
+
+// TiltAnalysis looks for losing streaks followed by outsized trades
+type TiltAnalysis struct {
+	Streaks []*LossStreak // streaks of at least TiltStreakLength losses, in date order
+	Trades  []*TiltTrade  // in the order they were entered
+	// PL is the combined P/L of the closed tilt trades
+	PL *big.Float
+}
+
+// NewTiltAnalysis replays the round trips in the order they were entered,
+// tracking the losing streak standing at each entry from the trips closed
+// before it. a trip entered during a streak of at least TiltStreakLength
+// losses with a cost of at least TiltSizeMultiple times the average cost
+// of the trips before it is flagged.
+func NewTiltAnalysis(trips *RoundTrips) *TiltAnalysis {
+	a := TiltAnalysis{Streaks: make([]*LossStreak, 0), Trades: make([]*TiltTrade, 0), PL: big.NewFloat(0.0)}
+	closed := make([]*RoundTrip, len(trips.Closed))
+	copy(closed, trips.Closed)
+	sort.SliceStable(closed, func(i, j int) bool {
+		return closed[i].Close.Before(closed[j].Close)
+	})
+	entered := make([]*RoundTrip, 0, len(trips.Closed)+len(trips.Open))
+	entered = append(entered, trips.Closed...)
+	entered = append(entered, trips.Open...)
+	sort.SliceStable(entered, func(i, j int) bool {
+		return entered[i].Open.Before(entered[j].Open)
+	})
+
+	// every streak long enough to count, from the losses in close order
+	var current *LossStreak
+	streakAt := make([]*LossStreak, len(closed)) // streak standing after each close
+	lengthAt := make([]int, len(closed))         // its length at the time
+	for i := 0; i < len(closed); i++ {
+		t := closed[i]
+		if t.PL.Sign() >= 0 {
+			current = nil
+			continue
+		}
+		if current == nil {
+			current = &LossStreak{Start: t.Close, Loss: big.NewFloat(0.0)}
+		}
+		current.End = t.Close
+		current.Trades++
+		current.Loss = current.Loss.Add(current.Loss, t.PL)
+		if current.Trades == TiltStreakLength {
+			a.Streaks = append(a.Streaks, current)
+		}
+		streakAt[i] = current
+		lengthAt[i] = current.Trades
+	}
+
+	next := 0
+	total := big.NewFloat(0.0)
+	for i := 0; i < len(entered); i++ {
+		t := entered[i]
+		for next < len(closed) && !closed[next].Close.After(t.Open) {
+			next++
+		}
+		size := big.NewFloat(0.0).Abs(t.Cost)
+		if next > 0 && lengthAt[next-1] >= TiltStreakLength && total.Sign() > 0 {
+			average := big.NewFloat(0.0).Quo(total, big.NewFloat(float64(i)))
+			ratio, _ := big.NewFloat(0.0).Quo(size, average).Float64()
+			if ratio >= TiltSizeMultiple {
+				tilt := TiltTrade{
+					ID:          t.ID,
+					Account:     t.Account,
+					Symbol:      t.Symbol,
+					Open:        t.Open,
+					Cost:        size,
+					SizeRatio:   ratio,
+					AverageCost: average,
+					PL:          t.PL,
+					Closed:      !t.Close.IsZero(),
+					Streak:      streakAt[next-1],
+				}
+				a.Trades = append(a.Trades, &tilt)
+				if tilt.Closed {
+					a.PL = a.PL.Add(a.PL, t.PL)
+				}
+			}
+		}
+		total = total.Add(total, size)
+	}
+	return &a
+}
@@ -0,0 +1,93 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/stonks/trade"
+)
+
+// YearTurnover is the portfolio turnover of a calendar year
+type YearTurnover struct {
+	Year      int
+	Purchases *big.Float // cash paid for purchases
+	Sales     *big.Float // cash received from sales
+	// AverageValue is the average daily value of the portfolio over the
+	// days of the year in the NAV series
+	AverageValue *big.Float
+	// Turnover is the lesser of the purchases and sales as a percent of
+	// the average value
+	Turnover *big.Float
+}
+
+// PortfolioTurnover holds the turnover of each year traded in
+type PortfolioTurnover struct {
+	Years []*YearTurnover // in year order
+}
+
+// NewPortfolioTurnover totals the purchases and sales made each year and
+// divides the lesser of the two by the average value of the portfolio
+// that year, the way funds report turnover. cash equivalents aren't
+// counted as purchases or sales.
+func NewPortfolioTurnover(trans []*trade.Trade, nav *NAVSeries) *PortfolioTurnover {
+	p := PortfolioTurnover{Years: make([]*YearTurnover, 0)}
+	byYear := make(map[int]*YearTurnover)
+	year := func(y int) *YearTurnover {
+		t := byYear[y]
+		if t == nil {
+			t = &YearTurnover{
+				Year:         y,
+				Purchases:    big.NewFloat(0.0),
+				Sales:        big.NewFloat(0.0),
+				AverageValue: big.NewFloat(0.0),
+				Turnover:     big.NewFloat(0.0),
+			}
+			byYear[y] = t
+			p.Years = append(p.Years, t)
+		}
+		return t
+	}
+
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		// guard clause: only purchases and sales turn the portfolio over
+		if !t.IsTrade() || t.IsCashEquivalent() || t.Amount == nil {
+			continue
+		}
+		y := year(t.Date.Year())
+		amount := big.NewFloat(0.0).Abs(t.Amount)
+		if t.Type == trade.Buy {
+			y.Purchases = y.Purchases.Add(y.Purchases, amount)
+		} else {
+			y.Sales = y.Sales.Add(y.Sales, amount)
+		}
+	}
+
+	totals := make(map[int]*big.Float)
+	days := make(map[int]int)
+	for i := 0; i < len(nav.Points); i++ {
+		n := nav.Points[i]
+		y := n.Date.Year()
+		if totals[y] == nil {
+			totals[y] = big.NewFloat(0.0)
+		}
+		totals[y] = totals[y].Add(totals[y], n.NAV)
+		days[y]++
+	}
+
+	for i := 0; i < len(p.Years); i++ {
+		y := p.Years[i]
+		if days[y.Year] > 0 {
+			y.AverageValue = big.NewFloat(0.0).Quo(totals[y.Year], big.NewFloat(float64(days[y.Year])))
+		}
+		lesser := y.Purchases
+		if y.Sales.Cmp(lesser) < 0 {
+			lesser = y.Sales
+		}
+		y.Turnover = percentOf(lesser, y.AverageValue)
+	}
+	sort.Slice(p.Years, func(i, j int) bool {
+		return p.Years[i].Year < p.Years[j].Year
+	})
+	return &p
+}
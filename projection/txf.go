@@ -0,0 +1,40 @@
+package projection
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+// TXF reference numbers for short and long term sales of securities
+const (
+	txfShortTerm = 321
+	txfLongTerm  = 323
+)
+
+// WriteTXF writes the form's rows in the Tax Exchange Format (version 042)
+// imported by tax software such as TurboTax and H&R Block. each closed lot
+// is a detail record with the description, dates acquired and sold, cost
+// basis and proceeds, followed by the disallowed wash sale loss if any.
+// exported is the date recorded in the file's header.
+func (f *Form8949) WriteTXF(w io.Writer, exported time.Time) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "V042\r\nAstonks\r\nD%s\r\n^\r\n", exported.Format(form8949DateFormat))
+	for i := 0; i < len(f.Rows); i++ {
+		r := f.Rows[i]
+		ref := txfShortTerm
+		if r.LongTerm {
+			ref = txfLongTerm
+		}
+		fmt.Fprintf(out, "TD\r\nN%d\r\nC1\r\nL1\r\n", ref)
+		fmt.Fprintf(out, "P%s\r\n", r.Description)
+		fmt.Fprintf(out, "D%s\r\nD%s\r\n", r.Acquired.Format(form8949DateFormat), r.Sold.Format(form8949DateFormat))
+		fmt.Fprintf(out, "$%s\r\n$%s\r\n", r.Basis.Text('f', 2), r.Proceeds.Text('f', 2))
+		if r.Code == WashSaleCode {
+			fmt.Fprintf(out, "$%s\r\n", r.Adjustment.Text('f', 2))
+		}
+		fmt.Fprintf(out, "^\r\n")
+	}
+	return out.Flush()
+}
@@ -0,0 +1,64 @@
+package projection
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// UKTaxYear totals the disposals of a UK tax year
+type UKTaxYear struct {
+	Year      string // eg 2023/24
+	Disposals []*lots.Disposal
+	Proceeds  *big.Float
+	Cost      *big.Float
+	Gains     *big.Float // total of the disposals at a gain
+	Losses    *big.Float // total of the disposals at a loss, as a positive amount
+	Net       *big.Float // gains less losses
+}
+
+// UKCapitalGains reports capital gains under the UK share matching rules
+// per UK tax year, along with the Section 104 pools still held
+type UKCapitalGains struct {
+	Years []*UKTaxYear // in year order
+	Pools []*lots.Pool
+}
+
+// NewUKCapitalGains matches disposals using the same day, bed and breakfast
+// and Section 104 rules and totals them per tax year. shares held in
+// retirement accounts, such as an ISA or SIPP, aren't pooled.
+func NewUKCapitalGains(trans []*trade.Trade, opts *lots.Options) *UKCapitalGains {
+	result := lots.MatchUK(taxableTransactions(trans, opts))
+	g := UKCapitalGains{Years: make([]*UKTaxYear, 0), Pools: result.Pools}
+	byYear := make(map[int]*UKTaxYear)
+	for i := 0; i < len(result.Disposals); i++ {
+		d := result.Disposals[i]
+		y := byYear[d.TaxYear]
+		if y == nil {
+			y = &UKTaxYear{
+				Year:      fmt.Sprintf("%d/%02d", d.TaxYear-1, d.TaxYear%100),
+				Disposals: make([]*lots.Disposal, 0),
+				Proceeds:  big.NewFloat(0.0),
+				Cost:      big.NewFloat(0.0),
+				Gains:     big.NewFloat(0.0),
+				Losses:    big.NewFloat(0.0),
+				Net:       big.NewFloat(0.0),
+			}
+			byYear[d.TaxYear] = y
+			// disposals are in date order so years are too
+			g.Years = append(g.Years, y)
+		}
+		y.Disposals = append(y.Disposals, d)
+		y.Proceeds = y.Proceeds.Add(y.Proceeds, d.Proceeds)
+		y.Cost = y.Cost.Add(y.Cost, d.Cost)
+		if d.Gain.Sign() >= 0 {
+			y.Gains = y.Gains.Add(y.Gains, d.Gain)
+		} else {
+			y.Losses = y.Losses.Sub(y.Losses, d.Gain)
+		}
+		y.Net = y.Net.Add(y.Net, d.Gain)
+	}
+	return &g
+}
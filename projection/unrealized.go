@@ -0,0 +1,90 @@
+package projection
+
+import (
+	"math/big"
+
+	"github.com/stonks/quotes"
+	"github.com/stonks/trade"
+)
+
+// UnrealizedPosition is the gain or loss of an open position at its
+// current market price
+type UnrealizedPosition struct {
+	Account     string
+	Symbol      string
+	Quantity    *big.Float
+	Cost        *big.Float
+	Price       *big.Float
+	MarketValue *big.Float // negative for short positions
+	Gain        *big.Float // market value less cost
+	Return      *big.Float // gain as a percent of the absolute cost
+}
+
+// UnrealizedPL reports the gains and losses of the positions still open
+type UnrealizedPL struct {
+	Positions   []*UnrealizedPosition
+	MarketValue *big.Float
+	Cost        *big.Float // cost of the positions with a price
+	Gain        *big.Float
+	Return      *big.Float
+	Unpriced    []string // symbols no price could be found for
+}
+
+// NewUnrealizedPL values the positions at the prices of the quote provider
+// and computes their gains. positions without a price are listed as
+// unpriced and excluded from the totals.
+func NewUnrealizedPL(positions *Positions, provider quotes.Provider) *UnrealizedPL {
+	u := UnrealizedPL{
+		Positions:   make([]*UnrealizedPosition, 0, len(positions.Positions)),
+		MarketValue: big.NewFloat(0.0),
+		Cost:        big.NewFloat(0.0),
+		Gain:        big.NewFloat(0.0),
+		Return:      big.NewFloat(0.0),
+		Unpriced:    make([]string, 0),
+	}
+	for i := 0; i < len(positions.Positions); i++ {
+		p := positions.Positions[i]
+		price, err := provider.Quote(p.Symbol)
+		if err != nil {
+			u.Unpriced = append(u.Unpriced, p.Symbol)
+			continue
+		}
+
+		instrument := trade.NewInstrument(p.Symbol)
+		if len(p.Lots) > 0 && p.Lots[0].Opening != nil && p.Lots[0].Opening.Instrument != nil {
+			instrument = p.Lots[0].Opening.Instrument
+		}
+		value := instrument.Notional(p.Quantity, price)
+		if p.Quantity.Sign() < 0 {
+			value = value.Neg(value)
+		}
+
+		position := UnrealizedPosition{
+			Account:     p.Account,
+			Symbol:      p.Symbol,
+			Quantity:    p.Quantity,
+			Cost:        p.Cost,
+			Price:       price,
+			MarketValue: value,
+			Gain:        big.NewFloat(0.0).Sub(value, p.Cost),
+		}
+		position.Return = percentOf(position.Gain, p.Cost)
+		u.Positions = append(u.Positions, &position)
+
+		u.MarketValue = u.MarketValue.Add(u.MarketValue, value)
+		u.Cost = u.Cost.Add(u.Cost, p.Cost)
+		u.Gain = u.Gain.Add(u.Gain, position.Gain)
+	}
+	u.Return = percentOf(u.Gain, u.Cost)
+	return &u
+}
+
+// percentOf returns part as a percent of the absolute value of whole, zero
+// if whole is zero
+func percentOf(part *big.Float, whole *big.Float) *big.Float {
+	if whole.Sign() == 0 {
+		return big.NewFloat(0.0)
+	}
+	p := big.NewFloat(0.0).Quo(part, big.NewFloat(0.0).Abs(whole))
+	return p.Mul(p, big.NewFloat(100))
+}
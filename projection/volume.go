@@ -0,0 +1,118 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/stonks/trade"
+)
+
+// UnderlyingVolume is the trading volume in an underlying symbol and its
+// derivatives
+type UnderlyingVolume struct {
+	Underlying   string
+	Trades       int
+	Buys         int
+	Sells        int
+	Shares       *big.Float // shares or units of the underlying traded directly
+	Contracts    *big.Float // option and futures contracts traded
+	DollarVolume *big.Float // notional value traded, including contract multipliers
+}
+
+// TradeVolume reports how much was traded, in total and per underlying
+type TradeVolume struct {
+	Trades       int
+	Buys         int
+	Sells        int
+	Contracts    *big.Float
+	DollarVolume *big.Float
+	ByUnderlying []*UnderlyingVolume // ordered by dollar volume, highest first
+
+	byUnderlying map[string]*UnderlyingVolume
+}
+
+// NewTradeVolume counts the purchases and sales and totals the contracts
+// and notional dollar volume traded per underlying symbol
+func NewTradeVolume(trans []*trade.Trade) *TradeVolume {
+	v := newTradeVolume()
+	for i := 0; i < len(trans); i++ {
+		v.add(trans[i])
+	}
+	v.sort()
+	return v
+}
+
+// newTradeVolume returns an empty trade volume
+func newTradeVolume() *TradeVolume {
+	return &TradeVolume{
+		Contracts:    big.NewFloat(0.0),
+		DollarVolume: big.NewFloat(0.0),
+		ByUnderlying: make([]*UnderlyingVolume, 0),
+		byUnderlying: make(map[string]*UnderlyingVolume),
+	}
+}
+
+// add counts a transaction towards the volume
+func (v *TradeVolume) add(t *trade.Trade) {
+	// guard clause: only purchases and sales are volume
+	if !t.IsTrade() {
+		return
+	}
+	instrument := t.Instrument
+	if instrument == nil {
+		instrument = trade.NewInstrument(t.Symbol)
+	}
+	u := v.byUnderlying[instrument.Underlying]
+	if u == nil {
+		u = &UnderlyingVolume{
+			Underlying:   instrument.Underlying,
+			Shares:       big.NewFloat(0.0),
+			Contracts:    big.NewFloat(0.0),
+			DollarVolume: big.NewFloat(0.0),
+		}
+		v.byUnderlying[instrument.Underlying] = u
+		v.ByUnderlying = append(v.ByUnderlying, u)
+	}
+
+	quantity := big.NewFloat(0.0)
+	if t.Quantity != nil {
+		quantity = quantity.Abs(t.Quantity)
+	}
+	notional := instrument.Notional(quantity, t.Price)
+	u.Trades++
+	v.Trades++
+	if t.Type == trade.Buy {
+		u.Buys++
+		v.Buys++
+	} else {
+		u.Sells++
+		v.Sells++
+	}
+	if instrument.Class == trade.Option || instrument.Class == trade.Future {
+		u.Contracts = u.Contracts.Add(u.Contracts, quantity)
+		v.Contracts = v.Contracts.Add(v.Contracts, quantity)
+	} else {
+		u.Shares = u.Shares.Add(u.Shares, quantity)
+	}
+	u.DollarVolume = u.DollarVolume.Add(u.DollarVolume, notional)
+	v.DollarVolume = v.DollarVolume.Add(v.DollarVolume, notional)
+}
+
+// sort orders the underlyings by dollar volume, highest first
+func (v *TradeVolume) sort() {
+	sort.SliceStable(v.ByUnderlying, func(i, j int) bool {
+		if c := v.ByUnderlying[i].DollarVolume.Cmp(v.ByUnderlying[j].DollarVolume); c != 0 {
+			return c > 0
+		}
+		return v.ByUnderlying[i].Underlying < v.ByUnderlying[j].Underlying
+	})
+}
+
+// index rebuilds the lookup of the underlyings after the volume has been
+// restored from a snapshot
+func (v *TradeVolume) index() {
+	v.byUnderlying = make(map[string]*UnderlyingVolume, len(v.ByUnderlying))
+	for i := 0; i < len(v.ByUnderlying); i++ {
+		v.byUnderlying[v.ByUnderlying[i].Underlying] = v.ByUnderlying[i]
+	}
+}
@@ -0,0 +1,206 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// CarriedLoss is the part of a wash sale loss still deferred in the basis
+// of its replacement lot at the end of a tax year
+type CarriedLoss struct {
+	Account  string
+	Symbol   string
+	LossDate time.Time // when the loss was realized
+	// ReplacementAccount, ReplacementSymbol and ReplacementOpened identify
+	// the lot the loss was added to
+	ReplacementAccount string
+	ReplacementSymbol  string
+	ReplacementOpened  time.Time
+	Deferred           *big.Float
+}
+
+// WashSaleYear follows the losses deferred by wash sales through a tax year
+type WashSaleYear struct {
+	Year int
+	// CarriedIn is the loss deferred at the end of the previous year
+	CarriedIn *big.Float
+	// Disallowed is the loss realized in the year that was added to the
+	// basis of replacement lots
+	Disallowed *big.Float
+	// PermanentlyDisallowed is the loss realized in the year replaced in
+	// retirement accounts, which is never carried over
+	PermanentlyDisallowed *big.Float
+	// Recovered is the deferred loss released by closing replacement lots
+	// in the year
+	Recovered *big.Float
+	// CarriedOut is the loss still deferred at the end of the year,
+	// including losses realized in December and replaced in January
+	CarriedOut *big.Float
+	Carried    []*CarriedLoss // the losses making up CarriedOut
+}
+
+// WashSaleCarryover follows disallowed wash sale losses across tax years
+type WashSaleCarryover struct {
+	Years    []*WashSaleYear // in year order
+	Deferred *big.Float      // loss still deferred after every transaction
+}
+
+// deferral is a wash sale loss added to the basis of a replacement lot
+type deferral struct {
+	adj      *lots.Adjustment
+	lot      *lots.Lot  // replacement lot when the loss was added
+	quantity *big.Float // quantity of the replacement lot when the loss was added
+	year     int        // tax year the loss was added in
+}
+
+// NewWashSaleCarryover replays the transactions with wash sale detection
+// and reports, for each tax year, the disallowed losses deferred into the
+// following year in the basis of replacement lots still open at year end.
+// a loss realized in December is carried over when it's replaced in
+// January, even though the replacement is bought after the year ends.
+func NewWashSaleCarryover(trans []*trade.Trade, opts *lots.Options) *WashSaleCarryover {
+	w := WashSaleCarryover{Years: make([]*WashSaleYear, 0), Deferred: big.NewFloat(0.0)}
+	ordered := lots.Ordered(trans)
+	// guard clause: nothing realized
+	if len(ordered) == 0 {
+		return &w
+	}
+	var rules lots.Jurisdiction = lots.US{}
+	if opts != nil && opts.Jurisdiction != nil {
+		rules = opts.Jurisdiction
+	}
+
+	e := lots.NewEngine(opts)
+	deferrals := make([]*deferral, 0)
+	seen := make(map[*lots.Adjustment]bool)
+	// remaining deferred amount of each deferral at the end of each year
+	remaining := make(map[int]map[*lots.Adjustment]*big.Float)
+	first := rules.TaxYear(ordered[0].Date)
+	year := first
+	for i := 0; i < len(ordered); i++ {
+		t := ordered[i]
+		for ; year < rules.TaxYear(t.Date); year++ {
+			remaining[year] = deferredAmounts(e.OpenLots(), deferrals)
+		}
+		realized := e.Apply(t)
+		if t.Type != trade.Buy && len(realized) == 0 {
+			// guard clause: no loss realized or replaced
+			continue
+		}
+		open := e.OpenLots()
+		for j := 0; j < len(open); j++ {
+			for k := 0; k < len(open[j].Adjustments); k++ {
+				adj := open[j].Adjustments[k]
+				if adj.Loss == nil || seen[adj] {
+					continue
+				}
+				seen[adj] = true
+				deferrals = append(deferrals, &deferral{
+					adj:      adj,
+					lot:      open[j],
+					quantity: big.NewFloat(0.0).Abs(open[j].Quantity),
+					year:     year,
+				})
+			}
+		}
+	}
+	remaining[year] = deferredAmounts(e.OpenLots(), deferrals)
+
+	byYear := make(map[int]*WashSaleYear)
+	for y := first; y <= year; y++ {
+		byYear[y] = &WashSaleYear{
+			Year:                  y,
+			CarriedIn:             big.NewFloat(0.0),
+			Disallowed:            big.NewFloat(0.0),
+			PermanentlyDisallowed: big.NewFloat(0.0),
+			Recovered:             big.NewFloat(0.0),
+			CarriedOut:            big.NewFloat(0.0),
+			Carried:               make([]*CarriedLoss, 0),
+		}
+	}
+	realized := e.Realized()
+	for i := 0; i < len(realized); i++ {
+		g := realized[i]
+		y := byYear[g.TaxYear()]
+		if y == nil || g.Disallowed.Sign() == 0 {
+			continue
+		}
+		deferred := big.NewFloat(0.0).Sub(g.Disallowed, g.PermanentlyDisallowed)
+		y.Disallowed = y.Disallowed.Add(y.Disallowed, deferred)
+		y.PermanentlyDisallowed = y.PermanentlyDisallowed.Add(y.PermanentlyDisallowed, g.PermanentlyDisallowed)
+	}
+
+	var previous *WashSaleYear
+	for y := first; y <= year; y++ {
+		wy := byYear[y]
+		for i := 0; i < len(deferrals); i++ {
+			d := deferrals[i]
+			if d.adj.Loss.TaxYear() > y {
+				continue
+			}
+			// a loss replaced after the year ended is still deferred in full
+			amount := big.NewFloat(0.0).Copy(d.adj.Amount)
+			if d.year <= y {
+				amount = remaining[y][d.adj]
+			}
+			if amount == nil || amount.Sign() == 0 {
+				continue
+			}
+			wy.CarriedOut = wy.CarriedOut.Add(wy.CarriedOut, amount)
+			wy.Carried = append(wy.Carried, &CarriedLoss{
+				Account:            d.adj.Loss.Account,
+				Symbol:             d.adj.Loss.Symbol,
+				LossDate:           d.adj.Loss.CloseDate,
+				ReplacementAccount: d.lot.Account,
+				ReplacementSymbol:  d.lot.Symbol,
+				ReplacementOpened:  d.lot.OpenDate,
+				Deferred:           amount,
+			})
+		}
+		sort.SliceStable(wy.Carried, func(i, j int) bool {
+			return wy.Carried[i].LossDate.Before(wy.Carried[j].LossDate)
+		})
+		if previous != nil {
+			wy.CarriedIn = big.NewFloat(0.0).Copy(previous.CarriedOut)
+		}
+		wy.Recovered = big.NewFloat(0.0).Add(wy.CarriedIn, wy.Disallowed)
+		wy.Recovered = wy.Recovered.Sub(wy.Recovered, wy.CarriedOut)
+		previous = wy
+		if wy.CarriedIn.Sign() != 0 || wy.Disallowed.Sign() != 0 || wy.PermanentlyDisallowed.Sign() != 0 || wy.CarriedOut.Sign() != 0 {
+			w.Years = append(w.Years, wy)
+		}
+	}
+	w.Deferred = big.NewFloat(0.0).Copy(previous.CarriedOut)
+	return &w
+}
+
+// deferredAmounts returns how much of each deferred loss remains in the
+// basis of the open lots, in proportion to the quantity of the replacement
+// lots still open. lots split by a transfer share their adjustments.
+func deferredAmounts(open []*lots.Lot, deferrals []*deferral) map[*lots.Adjustment]*big.Float {
+	quantities := make(map[*lots.Adjustment]*big.Float, len(deferrals))
+	for i := 0; i < len(deferrals); i++ {
+		quantities[deferrals[i].adj] = deferrals[i].quantity
+	}
+	amounts := make(map[*lots.Adjustment]*big.Float)
+	for i := 0; i < len(open); i++ {
+		for j := 0; j < len(open[i].Adjustments); j++ {
+			adj := open[i].Adjustments[j]
+			quantity, ok := quantities[adj]
+			if !ok || quantity.Sign() == 0 {
+				continue
+			}
+			amount := big.NewFloat(0.0).Quo(big.NewFloat(0.0).Abs(open[i].Quantity), quantity)
+			amount = amount.Mul(amount, adj.Amount)
+			if amounts[adj] == nil {
+				amounts[adj] = big.NewFloat(0.0)
+			}
+			amounts[adj] = amounts[adj].Add(amounts[adj], amount)
+		}
+	}
+	return amounts
+}
@@ -0,0 +1,144 @@
+package projection
+
+import (
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// DateRange is the days from From through To
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// contains returns true if the date falls on a day in the range
+func (r DateRange) contains(date time.Time) bool {
+	day := startOfDay(date)
+	return !day.Before(startOfDay(r.From)) && !day.After(startOfDay(r.To))
+}
+
+// Exclusion chooses the trades left out of a what-if analysis
+type Exclusion struct {
+	// Symbols leaves out the trades in the symbols and the options and
+	// futures on them
+	Symbols []string
+	// Strategies leaves out the option trades opening or closing legs of
+	// strategies of the types
+	Strategies []StrategyType
+	// Ranges leaves out the round trips opened during the ranges, along
+	// with their exits after the range
+	Ranges []DateRange
+	// Where leaves out the transactions it returns true for, nil if unused
+	Where func(t *trade.Trade) bool
+}
+
+// split returns the transactions kept and those left out by the exclusion
+func (e *Exclusion) split(trans []*trade.Trade, opts *lots.Options, asOf time.Time) ([]*trade.Trade, []*trade.Trade) {
+	excluded := make(map[*trade.Trade]bool)
+	if len(e.Strategies) > 0 {
+		byStrategy := groupByStrategy(trans, opts)
+		for i := 0; i < len(e.Strategies); i++ {
+			strategy := byStrategy[string(e.Strategies[i])]
+			for j := 0; j < len(strategy); j++ {
+				excluded[strategy[j]] = true
+			}
+		}
+	}
+	// the fills of the round trips opened in the ranges, by account and id
+	fills := make(map[string]bool)
+	if len(e.Ranges) > 0 {
+		trips := NewRoundTrips(trans, asOf)
+		all := append(append(make([]*RoundTrip, 0), trips.Closed...), trips.Open...)
+		for i := 0; i < len(all); i++ {
+			for j := 0; j < len(e.Ranges); j++ {
+				if !e.Ranges[j].contains(all[i].Open) {
+					continue
+				}
+				for k := 0; k < len(all[i].Entries); k++ {
+					fills[all[i].Account+"|"+all[i].Entries[k].ID] = true
+				}
+				for k := 0; k < len(all[i].Exits); k++ {
+					fills[all[i].Account+"|"+all[i].Exits[k].ID] = true
+				}
+			}
+		}
+	}
+
+	kept := make([]*trade.Trade, 0, len(trans))
+	left := make([]*trade.Trade, 0)
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if excluded[t] || fills[t.Account+"|"+t.ID] || e.excludesSymbol(t) || (e.Where != nil && e.Where(t)) {
+			left = append(left, t)
+		} else {
+			kept = append(kept, t)
+		}
+	}
+	return kept, left
+}
+
+// excludesSymbol returns true if the transaction is in one of the excluded
+// symbols or on one as the underlying
+func (e *Exclusion) excludesSymbol(t *trade.Trade) bool {
+	for i := 0; i < len(e.Symbols); i++ {
+		if strings.EqualFold(t.Symbol, e.Symbols[i]) {
+			return true
+		}
+		if t.Instrument != nil && strings.EqualFold(t.Instrument.Underlying, e.Symbols[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// HeadlineStats are the results a what-if analysis compares
+type HeadlineStats struct {
+	Realized *big.Float // realized P/L
+	Trades   int        // closed round trips
+	WinRate  *big.Float // percent of the round trips that were wins
+	// ProfitFactor is gross profits over gross losses, nil if there
+	// weren't any losses
+	ProfitFactor *big.Float `json:",omitempty"`
+	Expectancy   *big.Float // average P/L per round trip
+}
+
+// newHeadlineStats computes the headline stats of the transactions
+func newHeadlineStats(trans []*trade.Trade, opts *lots.Options, asOf time.Time) *HeadlineStats {
+	realized := NewRealizedPL(trans, opts)
+	overall := NewPerformanceStats(NewRoundTrips(trans, asOf)).Overall
+	return &HeadlineStats{
+		Realized:     realized.Total,
+		Trades:       overall.Trades,
+		WinRate:      overall.WinRate,
+		ProfitFactor: overall.ProfitFactor,
+		Expectancy:   overall.Expectancy,
+	}
+}
+
+// WhatIf compares the headline stats of every trade to those without the
+// excluded trades, quantifying the impact of specific decisions
+type WhatIf struct {
+	Excluded int // transactions left out
+	Actual   *HeadlineStats
+	WhatIf   *HeadlineStats
+	// Impact is the realized P/L the excluded trades contributed, the
+	// actual less the what-if P/L
+	Impact *big.Float
+}
+
+// NewWhatIf recomputes the headline stats of the trading transactions
+// without the trades the exclusion leaves out
+func NewWhatIf(trans []*trade.Trade, opts *lots.Options, asOf time.Time, e *Exclusion) *WhatIf {
+	kept, left := e.split(trans, opts, asOf)
+	w := WhatIf{
+		Excluded: len(left),
+		Actual:   newHeadlineStats(trans, opts, asOf),
+		WhatIf:   newHeadlineStats(kept, opts, asOf),
+	}
+	w.Impact = big.NewFloat(0.0).Sub(w.Actual.Realized, w.WhatIf.Realized)
+	return &w
+}
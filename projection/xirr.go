@@ -0,0 +1,131 @@
+package projection
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"time"
+)
+
+// CashFlow is money invested (negative) or returned (positive) on a date
+type CashFlow struct {
+	Date   time.Time
+	Amount float64
+}
+
+// xirrIterations bounds the search for the rate of return
+const xirrIterations = 200
+
+// npv returns the net present value of the flows at an annual rate, and
+// its derivative with respect to the rate
+func npv(flows []CashFlow, rate float64) (float64, float64) {
+	value, derivative := 0.0, 0.0
+	for i := 0; i < len(flows); i++ {
+		years := flows[i].Date.Sub(flows[0].Date).Hours() / 24 / 365
+		discount := math.Pow(1+rate, years)
+		value += flows[i].Amount / discount
+		derivative -= years * flows[i].Amount / (discount * (1 + rate))
+	}
+	return value, derivative
+}
+
+// XIRR returns the annual rate of return that makes the net present value
+// of irregularly timed cash flows zero. the flows must be in date order and
+// include both investments and returns.
+func XIRR(flows []CashFlow) (float64, error) {
+	positive, negative := false, false
+	for i := 0; i < len(flows); i++ {
+		positive = positive || flows[i].Amount > 0
+		negative = negative || flows[i].Amount < 0
+	}
+	if !positive || !negative {
+		return 0, errors.New("xirr needs both positive and negative cash flows")
+	}
+
+	// newton's method converges quickly from a reasonable guess
+	rate := 0.1
+	for i := 0; i < xirrIterations; i++ {
+		value, derivative := npv(flows, rate)
+		if math.Abs(value) < 1e-7 {
+			return rate, nil
+		}
+		if derivative == 0 {
+			break
+		}
+		next := rate - value/derivative
+		if next <= -1 || math.IsNaN(next) || math.IsInf(next, 0) {
+			break
+		}
+		rate = next
+	}
+
+	// fall back to bisection, the npv falls as the rate rises
+	low, high := -0.9999, 10.0
+	lowValue, _ := npv(flows, low)
+	highValue, _ := npv(flows, high)
+	if lowValue*highValue > 0 {
+		return 0, errors.New("xirr did not converge")
+	}
+	for i := 0; i < xirrIterations; i++ {
+		mid := (low + high) / 2
+		value, _ := npv(flows, mid)
+		if math.Abs(value) < 1e-7 {
+			return mid, nil
+		}
+		if value*lowValue > 0 {
+			low, lowValue = mid, value
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2, nil
+}
+
+// MoneyWeightedReturns holds the money weighted return of each period
+type MoneyWeightedReturns struct {
+	// Periods hold the XIRR of each period as an annual percent, so Return
+	// is already annualized
+	Periods []*PeriodReturn
+}
+
+// NewMoneyWeightedReturns computes the XIRR of each period from the value
+// series: the value at the start of the period counts as invested, each
+// deposit as invested and each withdrawal as returned on its date, and the
+// value at the end of the period as returned. periods without values or
+// without an XIRR are left out.
+func NewMoneyWeightedReturns(nav *NAVSeries, periods []*ReturnPeriod) *MoneyWeightedReturns {
+	m := MoneyWeightedReturns{Periods: make([]*PeriodReturn, 0, len(periods))}
+	for i := 0; i < len(periods); i++ {
+		first, last := pointRange(nav.Points, periods[i])
+		// guard clause: no values in the period
+		if first < 0 {
+			continue
+		}
+
+		flows := make([]CashFlow, 0)
+		if first > 0 {
+			start, _ := nav.Points[first-1].NAV.Float64()
+			flows = append(flows, CashFlow{Date: nav.Points[first-1].Date, Amount: -start})
+		}
+		for j := first; j <= last; j++ {
+			amount, _ := nav.Points[j].Flows.Float64()
+			if amount != 0 {
+				flows = append(flows, CashFlow{Date: nav.Points[j].Date, Amount: -amount})
+			}
+		}
+		end, _ := nav.Points[last].NAV.Float64()
+		flows = append(flows, CashFlow{Date: nav.Points[last].Date, Amount: end})
+
+		rate, err := XIRR(flows)
+		if err != nil {
+			continue
+		}
+		m.Periods = append(m.Periods, &PeriodReturn{
+			Name:   periods[i].Name,
+			From:   nav.Points[first].Date,
+			To:     nav.Points[last].Date,
+			Return: big.NewFloat(rate * 100),
+		})
+	}
+	return &m
+}
@@ -0,0 +1,82 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+)
+
+// HoldingYield is the dividend yield of a holding on what was paid for it
+type HoldingYield struct {
+	Symbol               string
+	Quantity             *big.Float
+	Cost                 *big.Float // cost basis of the open lots across every account
+	TrailingTwelveMonths *big.Float
+	ForwardAnnual        *big.Float
+	// TrailingYield and ForwardYield are the trailing and forward annual
+	// dividends as a percent of the cost basis
+	TrailingYield *big.Float
+	ForwardYield  *big.Float
+}
+
+// YieldOnCost reports the yield on cost of the dividend paying holdings
+type YieldOnCost struct {
+	Holdings      []*HoldingYield // ordered by symbol
+	Cost          *big.Float
+	ForwardAnnual *big.Float
+	ForwardYield  *big.Float
+}
+
+// NewYieldOnCost divides the dividends of each symbol still held by the
+// cost basis of its open lots, which includes any wash sale or return of
+// capital adjustments. short positions don't have a yield.
+func NewYieldOnCost(dividends *DividendIncome, positions *Positions) *YieldOnCost {
+	y := YieldOnCost{
+		Holdings:      make([]*HoldingYield, 0),
+		Cost:          big.NewFloat(0.0),
+		ForwardAnnual: big.NewFloat(0.0),
+		ForwardYield:  big.NewFloat(0.0),
+	}
+	bySymbol := make(map[string]*HoldingYield)
+	for i := 0; i < len(positions.Positions); i++ {
+		p := positions.Positions[i]
+		// guard clause: only long positions have a yield on cost
+		if p.Quantity.Sign() <= 0 {
+			continue
+		}
+		h := bySymbol[p.Symbol]
+		if h == nil {
+			h = &HoldingYield{
+				Symbol:               p.Symbol,
+				Quantity:             big.NewFloat(0.0),
+				Cost:                 big.NewFloat(0.0),
+				TrailingTwelveMonths: big.NewFloat(0.0),
+				ForwardAnnual:        big.NewFloat(0.0),
+			}
+			bySymbol[p.Symbol] = h
+		}
+		h.Quantity = h.Quantity.Add(h.Quantity, p.Quantity)
+		h.Cost = h.Cost.Add(h.Cost, p.Cost)
+	}
+
+	for i := 0; i < len(dividends.Symbols); i++ {
+		s := dividends.Symbols[i]
+		h := bySymbol[s.Symbol]
+		// guard clause: the symbol isn't held anymore
+		if h == nil {
+			continue
+		}
+		h.TrailingTwelveMonths = s.TrailingTwelveMonths
+		h.ForwardAnnual = s.ForwardAnnual
+		h.TrailingYield = percentOf(h.TrailingTwelveMonths, h.Cost)
+		h.ForwardYield = percentOf(h.ForwardAnnual, h.Cost)
+		y.Holdings = append(y.Holdings, h)
+		y.Cost = y.Cost.Add(y.Cost, h.Cost)
+		y.ForwardAnnual = y.ForwardAnnual.Add(y.ForwardAnnual, h.ForwardAnnual)
+	}
+	y.ForwardYield = percentOf(y.ForwardAnnual, y.Cost)
+
+	sort.Slice(y.Holdings, func(i, j int) bool {
+		return y.Holdings[i].Symbol < y.Holdings[j].Symbol
+	})
+	return &y
+}
@@ -0,0 +1,130 @@
+package projection
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/quotes"
+	"github.com/stonks/trade"
+)
+
+// DefaultLongTermWindow is how many days ahead open lots are listed as
+// nearing long term status
+const DefaultLongTermWindow = 60
+
+// NearingLongTerm is an open lot that becomes long term soon
+type NearingLongTerm struct {
+	Account      string
+	Symbol       string
+	Quantity     *big.Float
+	Cost         *big.Float
+	HoldingStart time.Time
+	LongTermOn   time.Time // first day a sale would be long term
+	Days         int       // days from the as of date until LongTermOn
+	// Price and Gain are the current price and unrealized gain of the lot,
+	// nil when there's no quote for it
+	Price *big.Float `json:",omitempty"`
+	Gain  *big.Float `json:",omitempty"`
+}
+
+// YearToDateGains holds the gains realized so far in the current tax year
+// and the open lots about to turn long term, for deciding what to sell
+// before the year ends
+type YearToDateGains struct {
+	Year       int
+	AsOf       time.Time
+	ShortTerm  *big.Float // realized short term gain or loss, after wash sale adjustments
+	LongTerm   *big.Float // realized long term gain or loss, after wash sale adjustments
+	Net        *big.Float
+	Disallowed *big.Float // losses disallowed by wash sales, already added back
+	// NearingLongTerm lists the long lots that turn long term within the
+	// window, soonest first. selling one at a loss before then realizes a
+	// short term loss, waiting on one at a gain makes it long term.
+	NearingLongTerm []*NearingLongTerm
+}
+
+// NewYearToDateGains replays the transactions up to asOf and totals the
+// gains realized in its tax year, then lists the open lots that become
+// long term within window days of asOf. lots are valued with the provider
+// when it isn't nil.
+func NewYearToDateGains(trans []*trade.Trade, opts *lots.Options, asOf time.Time, provider quotes.Provider, window int) *YearToDateGains {
+	var rules lots.Jurisdiction = lots.US{}
+	if opts != nil && opts.Jurisdiction != nil {
+		rules = opts.Jurisdiction
+	}
+	y := YearToDateGains{
+		Year:            rules.TaxYear(asOf),
+		AsOf:            asOf,
+		ShortTerm:       big.NewFloat(0.0),
+		LongTerm:        big.NewFloat(0.0),
+		Net:             big.NewFloat(0.0),
+		Disallowed:      big.NewFloat(0.0),
+		NearingLongTerm: make([]*NearingLongTerm, 0),
+	}
+
+	e := lots.NewEngine(opts)
+	ordered := lots.Ordered(trans)
+	end := lots.EndOfDate(asOf)
+	for i := 0; i < len(ordered) && ordered[i].Date.Before(end); i++ {
+		e.Apply(ordered[i])
+	}
+
+	realized := e.Realized()
+	for i := 0; i < len(realized); i++ {
+		g := realized[i]
+		// guard clause: realized in an earlier tax year, or untaxed
+		if g.TaxYear() != y.Year || !opts.Taxable(g.Account) {
+			continue
+		}
+		gain := big.NewFloat(0.0).Add(g.Gain, g.Disallowed)
+		if g.LongTerm() {
+			y.LongTerm = y.LongTerm.Add(y.LongTerm, gain)
+		} else {
+			y.ShortTerm = y.ShortTerm.Add(y.ShortTerm, gain)
+		}
+		y.Net = y.Net.Add(y.Net, gain)
+		y.Disallowed = y.Disallowed.Add(y.Disallowed, g.Disallowed)
+	}
+
+	open := e.OpenLots()
+	for i := 0; i < len(open); i++ {
+		lot := open[i]
+		// guard clause: short sales are always short term, and lots already
+		// long term aren't waiting on anything
+		if lot.Quantity.Sign() <= 0 || rules.LongTerm(lot.HoldingStart, asOf) || !opts.Taxable(lot.Account) {
+			continue
+		}
+		days := 0
+		for d := 1; d <= window; d++ {
+			if rules.LongTerm(lot.HoldingStart, asOf.AddDate(0, 0, d)) {
+				days = d
+				break
+			}
+		}
+		if days == 0 {
+			continue
+		}
+		n := NearingLongTerm{
+			Account:      lot.Account,
+			Symbol:       lot.Symbol,
+			Quantity:     big.NewFloat(0.0).Copy(lot.Quantity),
+			Cost:         big.NewFloat(0.0).Copy(lot.Cost),
+			HoldingStart: lot.HoldingStart,
+			LongTermOn:   asOf.AddDate(0, 0, days),
+			Days:         days,
+		}
+		if provider != nil {
+			if price, err := provider.Quote(lot.Symbol); err == nil {
+				n.Price = price
+				n.Gain = big.NewFloat(0.0).Sub(marketValue(lot, price), lot.Cost)
+			}
+		}
+		y.NearingLongTerm = append(y.NearingLongTerm, &n)
+	}
+	sort.SliceStable(y.NearingLongTerm, func(i, j int) bool {
+		return y.NearingLongTerm[i].Days < y.NearingLongTerm[j].Days
+	})
+	return &y
+}
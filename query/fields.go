@@ -0,0 +1,217 @@
+package query
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/stonks/trade"
+)
+
+// dateLayout is the layout dates are written in within expressions
+const dateLayout = "2006-01-02"
+
+// value is the value of a field, literal or expression. exactly one of the
+// members is set, by kind.
+type value struct {
+	kind   valueKind
+	str    string
+	number *big.Float
+	date   time.Time
+	truth  bool
+	list   []value
+}
+
+// valueKind is the type of a value
+type valueKind int
+
+const (
+	kindNone valueKind = iota // a field the transaction doesn't have
+	kindString
+	kindNumber
+	kindDate
+	kindBool
+	kindList
+)
+
+// field returns the value of a field of a transaction
+type field func(t *trade.Trade) value
+
+// fields holds every field an expression can refer to, keyed by the lower
+// case name, since names are matched without regard to case
+var fields = map[string]field{
+	"broker":      func(t *trade.Trade) value { return stringValue(t.Broker) },
+	"account":     func(t *trade.Trade) value { return stringValue(t.Account) },
+	"id":          func(t *trade.Trade) value { return stringValue(t.ID) },
+	"date":        func(t *trade.Trade) value { return value{kind: kindDate, date: t.Date} },
+	"description": func(t *trade.Trade) value { return stringValue(t.Description) },
+	"symbol":      func(t *trade.Trade) value { return stringValue(t.Symbol) },
+	"currency":    func(t *trade.Trade) value { return stringValue(t.Currency) },
+	"type":        func(t *trade.Trade) value { return stringValue(string(t.Type)) },
+	"effect":      func(t *trade.Trade) value { return stringValue(string(t.Effect)) },
+	"transfer":    func(t *trade.Trade) value { return stringValue(string(t.Transfer)) },
+	"quantity":    func(t *trade.Trade) value { return tradedValue(t, t.Quantity) },
+	"price":       func(t *trade.Trade) value { return tradedValue(t, t.Price) },
+	"commission":  func(t *trade.Trade) value { return numberValue(t.Commission) },
+	"amount":      func(t *trade.Trade) value { return numberValue(t.Amount) },
+	"fees": func(t *trade.Trade) value {
+		if t.Fees == nil {
+			return numberValue(nil)
+		}
+		return numberValue(t.Fees.Total())
+	},
+	"class": func(t *trade.Trade) value {
+		if t.Instrument == nil {
+			return value{}
+		}
+		return stringValue(string(t.Instrument.Class))
+	},
+	"underlying": func(t *trade.Trade) value {
+		if t.Instrument == nil || t.Instrument.Underlying == "" {
+			return stringValue(t.Symbol)
+		}
+		return stringValue(t.Instrument.Underlying)
+	},
+	"optiontype": func(t *trade.Trade) value {
+		if t.Instrument == nil {
+			return value{}
+		}
+		return stringValue(string(t.Instrument.OptionType))
+	},
+	"strike": func(t *trade.Trade) value {
+		if t.Instrument == nil {
+			return value{}
+		}
+		return numberValue(t.Instrument.Strike)
+	},
+	"expiration": func(t *trade.Trade) value {
+		if t.Instrument == nil || t.Instrument.Expiration.IsZero() {
+			return value{}
+		}
+		return value{kind: kindDate, date: t.Instrument.Expiration}
+	},
+	"tags": func(t *trade.Trade) value {
+		tags := make([]value, len(t.Tags))
+		for i := 0; i < len(t.Tags); i++ {
+			tags[i] = stringValue(t.Tags[i])
+		}
+		return value{kind: kindList, list: tags}
+	},
+}
+
+// numericFields are the names of the fields holding numbers, the only
+// fields that can be summed, averaged or compared for a minimum or maximum
+var numericFields = map[string]bool{
+	"quantity":   true,
+	"price":      true,
+	"commission": true,
+	"amount":     true,
+	"fees":       true,
+	"strike":     true,
+}
+
+// tradedValue returns the value of a quantity or price, or no value for a
+// transaction that isn't a trade and has none, eg the blank price of a
+// dividend, which brokers report as zero
+func tradedValue(t *trade.Trade, n *big.Float) value {
+	if n != nil && n.Sign() == 0 && !t.IsTrade() {
+		return value{}
+	}
+	return numberValue(n)
+}
+
+// lookupField returns the field of a name, matched without regard to case
+func lookupField(name string) (field, error) {
+	f, ok := fields[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", name)
+	}
+	return f, nil
+}
+
+// stringValue returns a string value
+func stringValue(s string) value {
+	return value{kind: kindString, str: s}
+}
+
+// numberValue returns a number value, or no value if the number is nil
+func numberValue(n *big.Float) value {
+	if n == nil {
+		return value{}
+	}
+	return value{kind: kindNumber, number: n}
+}
+
+// String formats the value the way it is grouped by and reported
+func (v value) String() string {
+	switch v.kind {
+	case kindString:
+		return v.str
+	case kindNumber:
+		return v.number.Text('f', -1)
+	case kindDate:
+		return v.date.Format(dateLayout)
+	case kindBool:
+		if v.truth {
+			return "true"
+		}
+		return "false"
+	case kindList:
+		items := make([]string, len(v.list))
+		for i := 0; i < len(v.list); i++ {
+			items[i] = v.list[i].String()
+		}
+		return strings.Join(items, ",")
+	}
+	return ""
+}
+
+// compare compares two values, returning -1, 0 or 1 as a is less than,
+// equal to or greater than b. strings compared with dates are parsed as
+// dates, and strings are compared without regard to case, so that
+// type == "dividend" matches. ok is false if the values can't be compared.
+func compare(a, b value) (result int, ok bool) {
+	if a.kind == kindDate && b.kind == kindString {
+		d, err := time.ParseInLocation(dateLayout, b.str, a.date.Location())
+		if err != nil {
+			return 0, false
+		}
+		b = value{kind: kindDate, date: d}
+	}
+	if a.kind == kindString && b.kind == kindDate {
+		result, ok = compare(b, a)
+		return -result, ok
+	}
+	if a.kind != b.kind {
+		return 0, false
+	}
+	switch a.kind {
+	case kindString:
+		return strings.Compare(strings.ToLower(a.str), strings.ToLower(b.str)), true
+	case kindNumber:
+		return a.number.Cmp(b.number), true
+	case kindDate:
+		a.date = truncateDay(a.date)
+		b.date = truncateDay(b.date)
+		switch {
+		case a.date.Before(b.date):
+			return -1, true
+		case a.date.After(b.date):
+			return 1, true
+		}
+		return 0, true
+	case kindBool:
+		if a.truth == b.truth {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+// truncateDay drops the time of day, since transactions are compared to
+// dates by the day they were made
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,263 @@
+package query
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/stonks/analytics"
+	"github.com/stonks/trade"
+)
+
+// Aggregate is how a metric combines the values of a field
+type Aggregate string
+
+const (
+	Sum   Aggregate = "sum"
+	Count Aggregate = "count"
+	Avg   Aggregate = "avg"
+	Min   Aggregate = "min"
+	Max   Aggregate = "max"
+)
+
+// dimension returns the keys of the groups a transaction is counted in
+type dimension func(t *trade.Trade) []string
+
+// lookupDimension returns the dimension of a name, either a time bucket
+// (day, week, month, quarter or year) or a field. a transaction is counted
+// once under each of its tags.
+func lookupDimension(name string) (dimension, error) {
+	if bucket, err := analytics.TimeBucket(analytics.Period(strings.ToUpper(name))); err == nil {
+		return func(t *trade.Trade) []string {
+			return []string{bucket(t)}
+		}, nil
+	}
+	if strings.EqualFold(name, "tag") {
+		name = "tags"
+	}
+	f, err := lookupField(name)
+	if err != nil {
+		return nil, err
+	}
+	return func(t *trade.Trade) []string {
+		v := f(t)
+		if v.kind != kindList {
+			return []string{v.String()}
+		}
+		keys := make([]string, len(v.list))
+		for i := 0; i < len(v.list); i++ {
+			keys[i] = v.list[i].String()
+		}
+		return keys
+	}, nil
+}
+
+// Metric is a custom aggregation of the transactions, written as
+//
+//	<aggregate>(<field>) [where <condition>] [group by <dimension>]
+//
+// eg sum(Amount) where Type == "DIVIDEND" group by month. the aggregate is
+// one of sum, count, avg, min or max, and count(*) counts the transactions
+// themselves. the dimension is day, week, month, quarter, year, tag or any
+// field.
+type Metric struct {
+	Name       string
+	Expression string
+	aggregate  Aggregate
+	field      field  // nil for count(*)
+	where      node   // nil if every transaction is counted
+	groupBy    string // blank if not grouped
+	dimension  dimension
+}
+
+// ParseMetric parses the expression of a metric
+func ParseMetric(name string, expression string) (*Metric, error) {
+	p, err := newParser(expression)
+	if err != nil {
+		return nil, err
+	}
+	m := &Metric{Name: name, Expression: expression}
+
+	aggregate, ok := p.accept(string(Sum), string(Count), string(Avg), string(Min), string(Max))
+	if !ok {
+		return nil, p.errorf("expected sum, count, avg, min or max")
+	}
+	m.aggregate = Aggregate(aggregate)
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("*"); ok {
+		if m.aggregate != Count {
+			return nil, fmt.Errorf("%s(*) is not supported, only count(*)", m.aggregate)
+		}
+	} else {
+		t := p.next()
+		if t.kind != tokenIdent {
+			return nil, fmt.Errorf("expected a field at %d", t.pos)
+		}
+		if m.field, err = lookupField(t.text); err != nil {
+			return nil, fmt.Errorf("%v at %d", err, t.pos)
+		}
+		// guard clause: only numbers can be summed, averaged or compared
+		if m.aggregate != Count && !numericFields[strings.ToLower(t.text)] {
+			return nil, fmt.Errorf("%s(%s) is not supported, %s isn't a number at %d", m.aggregate, t.text, t.text, t.pos)
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if _, ok := p.accept("where"); ok {
+		if m.where, err = p.parseCondition(); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := p.accept("group"); ok {
+		if err := p.expect("by"); err != nil {
+			return nil, err
+		}
+		t := p.next()
+		if t.kind != tokenIdent {
+			return nil, fmt.Errorf("expected a dimension at %d", t.pos)
+		}
+		if m.dimension, err = lookupDimension(t.text); err != nil {
+			return nil, fmt.Errorf("%v at %d", err, t.pos)
+		}
+		m.groupBy = strings.ToLower(t.text)
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.errorf("expected where, group by or end of expression")
+	}
+	return m, nil
+}
+
+// MetricGroup is the value of a metric over the transactions in a group
+type MetricGroup struct {
+	Key   string
+	Count int        // number of transactions counted
+	Value *big.Float // nil for an average, minimum or maximum of nothing
+}
+
+// MetricResult is the value of a metric over every transaction it counts,
+// and of each group if it is grouped
+type MetricResult struct {
+	Name       string
+	Expression string
+	GroupBy    string `json:",omitempty"`
+	Count      int
+	Value      *big.Float
+	Groups     []*MetricGroup `json:",omitempty"` // in order of the key
+}
+
+// accumulator combines the values of a field for a metric
+type accumulator struct {
+	count    int
+	sum      *big.Float
+	min, max *big.Float
+}
+
+// newAccumulator returns an accumulator of nothing
+func newAccumulator() *accumulator {
+	return &accumulator{sum: big.NewFloat(0.0)}
+}
+
+// add counts a transaction, with the value of the field if it has one
+func (a *accumulator) add(v value) {
+	a.count++
+	if v.kind != kindNumber {
+		return
+	}
+	a.sum = a.sum.Add(a.sum, v.number)
+	if a.min == nil || v.number.Cmp(a.min) < 0 {
+		a.min = v.number
+	}
+	if a.max == nil || v.number.Cmp(a.max) > 0 {
+		a.max = v.number
+	}
+}
+
+// result returns the aggregate of the values counted
+func (a *accumulator) result(aggregate Aggregate) *big.Float {
+	switch aggregate {
+	case Count:
+		return big.NewFloat(float64(a.count))
+	case Sum:
+		return a.sum
+	case Avg:
+		if a.count == 0 {
+			return nil
+		}
+		return big.NewFloat(0.0).Quo(a.sum, big.NewFloat(float64(a.count)))
+	case Min:
+		return copyOf(a.min)
+	case Max:
+		return copyOf(a.max)
+	}
+	return nil
+}
+
+// copyOf returns a copy of a number, nil if it is nil
+func copyOf(n *big.Float) *big.Float {
+	if n == nil {
+		return nil
+	}
+	return big.NewFloat(0.0).Copy(n)
+}
+
+// Evaluate computes the metric over the transactions
+func (m *Metric) Evaluate(trans []*trade.Trade) *MetricResult {
+	total := newAccumulator()
+	groups := make(map[string]*accumulator)
+	keys := make([]string, 0)
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		// guard clause: only the transactions meeting the condition count
+		if m.where != nil && !truth(m.where.eval(t)) {
+			continue
+		}
+		v := value{}
+		counted := true
+		if m.field != nil {
+			v = m.field(t)
+			// count(field) counts the transactions that have the field, and
+			// the other aggregates only those with a number in it
+			counted = v.kind != kindNone && (m.aggregate == Count || v.kind == kindNumber)
+		}
+		if !counted {
+			continue
+		}
+		total.add(v)
+		if m.dimension == nil {
+			continue
+		}
+		groupKeys := m.dimension(t)
+		for j := 0; j < len(groupKeys); j++ {
+			g := groups[groupKeys[j]]
+			if g == nil {
+				g = newAccumulator()
+				groups[groupKeys[j]] = g
+				keys = append(keys, groupKeys[j])
+			}
+			g.add(v)
+		}
+	}
+
+	result := &MetricResult{
+		Name:       m.Name,
+		Expression: m.Expression,
+		GroupBy:    m.groupBy,
+		Count:      total.count,
+		Value:      total.result(m.aggregate),
+	}
+	if m.dimension == nil {
+		return result
+	}
+	sort.Strings(keys)
+	result.Groups = make([]*MetricGroup, len(keys))
+	for i := 0; i < len(keys); i++ {
+		g := groups[keys[i]]
+		result.Groups[i] = &MetricGroup{Key: keys[i], Count: g.count, Value: g.result(m.aggregate)}
+	}
+	return result
+}
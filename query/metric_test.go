@@ -0,0 +1,84 @@
+package query
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestMetricEvaluate(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantCount  int
+		wantValue  string // blank for no value
+		wantGroups map[string]string
+	}{
+		{`count(*)`, 3, "3", nil},
+		{`sum(Amount)`, 3, "-587.5", nil},
+		{`sum(amount) where type == "DIVIDEND"`, 1, "12.5", nil},
+		{`avg(Price)`, 2, "135", nil},
+		{`min(Amount)`, 3, "-1300", nil},
+		{`max(Amount) where symbol == "AAPL"`, 2, "700", nil},
+		{`avg(Price) where type == "DIVIDEND"`, 0, "", nil},
+		{`count(Price)`, 2, "2", nil},
+		{`sum(Amount) group by symbol`, 3, "-587.5", map[string]string{"AAPL": "-600", "MSFT": "12.5"}},
+		{`count(*) group by month`, 3, "3", map[string]string{"2021-01": "1", "2021-02": "1", "2021-03": "1"}},
+	}
+	trans := newTrades(t, sampleRows)
+	for i := 0; i < len(tests); i++ {
+		tt := tests[i]
+		m, err := ParseMetric("m", tt.expression)
+		if err != nil {
+			t.Errorf("ParseMetric(%q) error = %v", tt.expression, err)
+			continue
+		}
+		r := m.Evaluate(trans)
+		if r.Count != tt.wantCount {
+			t.Errorf("%q count = %d, want %d", tt.expression, r.Count, tt.wantCount)
+		}
+		if got := text(r.Value); got != tt.wantValue {
+			t.Errorf("%q value = %q, want %q", tt.expression, got, tt.wantValue)
+		}
+		if len(r.Groups) != len(tt.wantGroups) {
+			t.Errorf("%q has %d groups, want %d", tt.expression, len(r.Groups), len(tt.wantGroups))
+			continue
+		}
+		for j := 0; j < len(r.Groups); j++ {
+			g := r.Groups[j]
+			if want, ok := tt.wantGroups[g.Key]; !ok || text(g.Value) != want {
+				t.Errorf("%q group %q = %q, want %q", tt.expression, g.Key, text(g.Value), want)
+			}
+		}
+	}
+}
+
+// text formats a metric value, blank for none
+func text(n *big.Float) string {
+	if n == nil {
+		return ""
+	}
+	return n.Text('f', -1)
+}
+
+func TestParseMetricErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`total(Amount)`,
+		`sum(*)`,
+		`avg(*)`,
+		`sum(Ticker)`,
+		`sum(Description)`,
+		`max(Symbol)`,
+		`avg(Date)`,
+		`sum(Amount`,
+		`sum(Amount) where`,
+		`sum(Amount) where amount`,
+		`sum(Amount) group symbol`,
+		`sum(Amount) group by sector`,
+		`sum(Amount) sort by symbol`,
+	}
+	for i := 0; i < len(tests); i++ {
+		if _, err := ParseMetric("m", tests[i]); err == nil {
+			t.Errorf("ParseMetric(%q) succeeded, want an error", tests[i])
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package query
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/stonks/trade"
+)
+
+// node is a parsed expression, evaluated against a transaction
+type node interface {
+	eval(t *trade.Trade) value
+}
+
+// literal is a string, number or boolean written in the expression
+type literal struct {
+	v value
+}
+
+func (n *literal) eval(t *trade.Trade) value {
+	return n.v
+}
+
+// fieldRef is a field of the transaction
+type fieldRef struct {
+	f field
+}
+
+func (n *fieldRef) eval(t *trade.Trade) value {
+	return n.f(t)
+}
+
+// list is a list of values written in brackets
+type list struct {
+	items []node
+}
+
+func (n *list) eval(t *trade.Trade) value {
+	items := make([]value, len(n.items))
+	for i := 0; i < len(n.items); i++ {
+		items[i] = n.items[i].eval(t)
+	}
+	return value{kind: kindList, list: items}
+}
+
+// not negates a condition
+type not struct {
+	x node
+}
+
+func (n *not) eval(t *trade.Trade) value {
+	return value{kind: kindBool, truth: !truth(n.x.eval(t))}
+}
+
+// logical is a condition joined with && or ||
+type logical struct {
+	op          string
+	left, right node
+}
+
+func (n *logical) eval(t *trade.Trade) value {
+	left := truth(n.left.eval(t))
+	// guard clause: the right side is only evaluated if it decides the result
+	if n.op == "&&" && !left || n.op == "||" && left {
+		return value{kind: kindBool, truth: left}
+	}
+	return value{kind: kindBool, truth: truth(n.right.eval(t))}
+}
+
+// comparison compares two values. a transaction without the field compared,
+// eg the strike of a stock trade, matches nothing but !=.
+type comparison struct {
+	op          string
+	left, right node
+}
+
+func (n *comparison) eval(t *trade.Trade) value {
+	result, ok := compare(n.left.eval(t), n.right.eval(t))
+	if !ok {
+		return value{kind: kindBool, truth: n.op == "!="}
+	}
+	matched := false
+	switch n.op {
+	case "==":
+		matched = result == 0
+	case "!=":
+		matched = result != 0
+	case "<":
+		matched = result < 0
+	case "<=":
+		matched = result <= 0
+	case ">":
+		matched = result > 0
+	case ">=":
+		matched = result >= 0
+	}
+	return value{kind: kindBool, truth: matched}
+}
+
+// membership is true if the value is in the list. a list on the left, such
+// as the tags, is in the list if any of its items are.
+type membership struct {
+	x, in node
+}
+
+func (n *membership) eval(t *trade.Trade) value {
+	x := n.x.eval(t)
+	in := n.in.eval(t)
+	items := []value{x}
+	if x.kind == kindList {
+		items = x.list
+	}
+	for i := 0; i < len(items); i++ {
+		for j := 0; j < len(in.list); j++ {
+			if result, ok := compare(items[i], in.list[j]); ok && result == 0 {
+				return value{kind: kindBool, truth: true}
+			}
+		}
+	}
+	return value{kind: kindBool, truth: false}
+}
+
+// truth returns true if a value is a true condition
+func truth(v value) bool {
+	return v.kind == kindBool && v.truth
+}
+
+// isCondition returns true if a node evaluates to true or false
+func isCondition(n node) bool {
+	switch x := n.(type) {
+	case *not, *logical, *comparison, *membership:
+		return true
+	case *literal:
+		return x.v.kind == kindBool
+	}
+	return false
+}
+
+// parser is a recursive descent parser of the tokens of an expression.
+// from the loosest binding:
+//
+//	or         := and (("||" | "or") and)*
+//	and        := unary (("&&" | "and") unary)*
+//	unary      := ("!" | "not") unary | comparison
+//	comparison := operand (op operand | "in" operand)?
+//	operand    := string | number | true | false | field | list | "(" or ")"
+//	list       := "[" (operand ("," operand)*)? "]"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// newParser returns a parser of an expression
+func newParser(expression string) (*parser, error) {
+	tokens, err := lex(expression)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens}, nil
+}
+
+// peek returns the next token without consuming it
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next consumes the next token
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the texts. keywords are
+// matched without regard to case.
+func (p *parser) accept(texts ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator && t.kind != tokenPunct && t.kind != tokenIdent {
+		return "", false
+	}
+	for i := 0; i < len(texts); i++ {
+		if strings.EqualFold(t.text, texts[i]) {
+			p.next()
+			return texts[i], true
+		}
+	}
+	return "", false
+}
+
+// expect consumes the next token, returning an error if it isn't the text
+func (p *parser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		return p.errorf("expected %q", text)
+	}
+	return nil
+}
+
+// errorf returns an error at the next token
+func (p *parser) errorf(format string, args ...interface{}) error {
+	t := p.peek()
+	found := "end of expression"
+	if t.kind != tokenEOF {
+		found = fmt.Sprintf("%q", t.text)
+	}
+	return fmt.Errorf("%s at %d, found %s", fmt.Sprintf(format, args...), t.pos, found)
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "||", left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.accept("!", "not"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &not{x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("in"); ok {
+		in, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &membership{x: left, in: in}, nil
+	}
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &comparison{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenString:
+		p.next()
+		return &literal{v: stringValue(t.text)}, nil
+	case tokenNumber:
+		p.next()
+		n, _, err := big.ParseFloat(t.text, 10, 128, big.ToNearestEven)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", t.text, t.pos)
+		}
+		return &literal{v: numberValue(n)}, nil
+	case tokenIdent:
+		switch strings.ToLower(t.text) {
+		case "true", "false":
+			p.next()
+			return &literal{v: value{kind: kindBool, truth: strings.EqualFold(t.text, "true")}}, nil
+		}
+		f, err := lookupField(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("%v at %d", err, t.pos)
+		}
+		p.next()
+		return &fieldRef{f: f}, nil
+	}
+	if _, ok := p.accept("("); ok {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+	if _, ok := p.accept("["); ok {
+		l := &list{items: make([]node, 0)}
+		if _, ok := p.accept("]"); ok {
+			return l, nil
+		}
+		for {
+			item, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			l.items = append(l.items, item)
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+	return nil, p.errorf("expected a value")
+}
+
+// parseCondition parses a condition, returning an error if the expression
+// doesn't evaluate to true or false
+func (p *parser) parseCondition() (node, error) {
+	start := p.peek().pos
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !isCondition(n) {
+		return nil, fmt.Errorf("expression at %d is not a condition", start)
+	}
+	return n, nil
+}
+
+// Condition is a parsed condition on a transaction, such as
+// symbol == "AAPL" && date >= "2023-01-01" && type in ["BUY", "SELL"].
+// field names and string comparisons ignore case, and dates are written as
+// strings in the form 2006-01-02.
+type Condition struct {
+	expression string
+	root       node
+}
+
+// ParseCondition parses a condition
+func ParseCondition(expression string) (*Condition, error) {
+	p, err := newParser(expression)
+	if err != nil {
+		return nil, err
+	}
+	root, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.errorf("expected end of expression")
+	}
+	return &Condition{expression: expression, root: root}, nil
+}
+
+// String returns the condition as written
+func (c *Condition) String() string {
+	return c.expression
+}
+
+// Match returns true if the transaction meets the condition
+func (c *Condition) Match(t *trade.Trade) bool {
+	return truth(c.root.eval(t))
+}
+
+// Filter returns the transactions that meet the condition
+func (c *Condition) Filter(trans []*trade.Trade) []*trade.Trade {
+	results := make([]*trade.Trade, 0, len(trans))
+	for i := 0; i < len(trans); i++ {
+		if c.Match(trans[i]) {
+			results = append(results, trans[i])
+		}
+	}
+	return results
+}
@@ -0,0 +1,100 @@
+package query
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stonks/trade"
+)
+
+// newTrades parses TDA transaction log rows of date, description,
+// quantity, symbol, price and amount, numbering their ids from 1
+func newTrades(t *testing.T, rows [][]string) []*trade.Trade {
+	trans := make([]*trade.Trade, 0, len(rows))
+	for i := 0; i < len(rows); i++ {
+		r := rows[i]
+		tr, err := trade.NewTradeTDA([]string{r[0], strconv.Itoa(i + 1), r[1], r[2], r[3], r[4], "0", r[5]})
+		if err != nil {
+			t.Fatalf("row %d: %v", i+1, err)
+		}
+		trans = append(trans, tr)
+	}
+	return trans
+}
+
+// sampleRows are a purchase, a sale and a dividend
+var sampleRows = [][]string{
+	{"01/04/2021", "Bought 10 AAPL @ 130", "10", "AAPL", "130", "-1300"},
+	{"02/04/2021", "Sold 5 AAPL @ 140", "5", "AAPL", "140", "700"},
+	{"03/15/2021", "ORDINARY DIVIDEND (MSFT)", "", "MSFT", "", "12.5"},
+}
+
+func TestConditionMatch(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       []bool // whether each of the sample rows matches
+	}{
+		{`symbol == "AAPL"`, []bool{true, true, false}},
+		{`symbol == "aapl"`, []bool{true, true, false}},
+		{`symbol != "AAPL"`, []bool{false, false, true}},
+		{`type == "dividend"`, []bool{false, false, true}},
+		{`type in ["BUY", "SELL"]`, []bool{true, true, false}},
+		{`amount > 0`, []bool{false, true, true}},
+		{`amount >= -1300 && amount < 700`, []bool{true, false, true}},
+		{`date >= "2021-02-04"`, []bool{false, true, true}},
+		{`date < "2021-02-04" || type == "DIVIDEND"`, []bool{true, false, true}},
+		{`!(symbol == "AAPL")`, []bool{false, false, true}},
+		{`not symbol == "AAPL" and amount > 10`, []bool{false, false, true}},
+		{`price > 100`, []bool{true, true, false}},
+		{`strike > 0`, []bool{false, false, false}},
+		{`strike != 0`, []bool{true, true, true}},
+	}
+	trans := newTrades(t, sampleRows)
+	for i := 0; i < len(tests); i++ {
+		tt := tests[i]
+		c, err := ParseCondition(tt.expression)
+		if err != nil {
+			t.Errorf("ParseCondition(%q) error = %v", tt.expression, err)
+			continue
+		}
+		for j := 0; j < len(trans); j++ {
+			if got := c.Match(trans[j]); got != tt.want[j] {
+				t.Errorf("%q matches row %d = %v, want %v", tt.expression, j+1, got, tt.want[j])
+			}
+		}
+		if got := len(c.Filter(trans)); got != count(tt.want) {
+			t.Errorf("%q filters %d rows, want %d", tt.expression, got, count(tt.want))
+		}
+	}
+}
+
+// count returns how many are true
+func count(matches []bool) int {
+	n := 0
+	for i := 0; i < len(matches); i++ {
+		if matches[i] {
+			n++
+		}
+	}
+	return n
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`symbol ==`,
+		`symbol == "AAPL" &&`,
+		`symbol == "AAPL`,
+		`ticker == "AAPL"`,
+		`amount`,
+		`symbol == "AAPL")`,
+		`(symbol == "AAPL"`,
+		`type in ["BUY", "SELL"`,
+		`amount > 1 # 2`,
+	}
+	for i := 0; i < len(tests); i++ {
+		if _, err := ParseCondition(tests[i]); err == nil {
+			t.Errorf("ParseCondition(%q) succeeded, want an error", tests[i])
+		}
+	}
+}
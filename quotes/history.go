@@ -0,0 +1,206 @@
+package quotes
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyDateFormat is the format of dates in price history files
+const historyDateFormat = "2006-01-02"
+
+// History looks up the historical closing prices of symbols
+type History interface {
+	// Close returns the closing price of the symbol on the date, or on
+	// the last trading day before it
+	Close(symbol string, date time.Time) (*big.Float, error)
+}
+
+// DailyClose is the closing price of a symbol on a day
+type DailyClose struct {
+	Date  time.Time
+	Close *big.Float
+}
+
+// Series is the closing prices of a symbol in date order
+type Series []*DailyClose
+
+// on returns the close on the date or the last day before it
+func (s Series) on(date time.Time) (*big.Float, bool) {
+	i := sort.Search(len(s), func(i int) bool {
+		return s[i].Date.After(date)
+	})
+	if i == 0 {
+		return nil, false
+	}
+	return s[i-1].Close, true
+}
+
+// HistoryFile is a price history read from a csv file, keyed by symbol
+type HistoryFile map[string]Series
+
+// LoadHistoryFile reads closing prices from a csv file of date
+// (YYYY-MM-DD), symbol and close rows
+func LoadHistoryFile(path string) (HistoryFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	history := HistoryFile{}
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 3 {
+			continue
+		}
+		date, err := time.Parse(historyDateFormat, strings.TrimSpace(record[0]))
+		if err != nil {
+			// skip the header row, if any
+			continue
+		}
+		price, _, err := big.ParseFloat(strings.TrimSpace(record[2]), 10, 53, big.ToNearestEven)
+		if err != nil {
+			continue
+		}
+		symbol := strings.TrimSpace(record[1])
+		history[symbol] = append(history[symbol], &DailyClose{Date: date, Close: price})
+	}
+	for symbol := range history {
+		s := history[symbol]
+		sort.SliceStable(s, func(i, j int) bool {
+			return s[i].Date.Before(s[j].Date)
+		})
+	}
+	return history, nil
+}
+
+// Close returns the close of the symbol on or before the date
+func (h HistoryFile) Close(symbol string, date time.Time) (*big.Float, error) {
+	if price, ok := h[symbol].on(date); ok {
+		return price, nil
+	}
+	return nil, fmt.Errorf("no price for %v on %v", symbol, date.Format(historyDateFormat))
+}
+
+// stooqHistoryURL is the csv daily price history endpoint of stooq.com
+const stooqHistoryURL = "https://stooq.com/q/d/l/"
+
+// StooqHistory is a price history downloaded from stooq.com. each
+// symbol's history is downloaded once then kept in memory.
+type StooqHistory struct {
+	Client *http.Client
+	Suffix string // market suffix appended to symbols, eg .us
+
+	mu     sync.Mutex
+	series map[string]Series
+	errs   map[string]error
+}
+
+// NewStooqHistory returns a stooq price history for the given market suffix
+func NewStooqHistory(suffix string) *StooqHistory {
+	return &StooqHistory{
+		Client: &http.Client{Timeout: 30 * time.Second},
+		Suffix: suffix,
+		series: make(map[string]Series),
+		errs:   make(map[string]error),
+	}
+}
+
+// Close returns the close of the symbol on or before the date
+func (s *StooqHistory) Close(symbol string, date time.Time) (*big.Float, error) {
+	s.mu.Lock()
+	series, ok := s.series[symbol]
+	err := s.errs[symbol]
+	if !ok {
+		series, err = s.download(symbol)
+		s.series[symbol] = series
+		s.errs[symbol] = err
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if price, ok := series.on(date); ok {
+		return price, nil
+	}
+	return nil, fmt.Errorf("no price for %v on %v", symbol, date.Format(historyDateFormat))
+}
+
+// download fetches the daily closes of a symbol
+func (s *StooqHistory) download(symbol string) (Series, error) {
+	q := url.Values{}
+	q.Set("s", strings.ToLower(symbol)+s.Suffix)
+	q.Set("i", "d")
+	resp, err := s.Client.Get(stooqHistoryURL + "?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("price history for %v: %v", symbol, resp.Status)
+	}
+
+	r := csv.NewReader(resp.Body)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	series := make(Series, 0, len(records))
+	for i := 0; i < len(records); i++ {
+		// rows are date, open, high, low, close and volume
+		if len(records[i]) < 5 {
+			continue
+		}
+		date, err := time.Parse(historyDateFormat, records[i][0])
+		if err != nil {
+			// skip the header row
+			continue
+		}
+		price, _, err := big.ParseFloat(records[i][4], 10, 53, big.ToNearestEven)
+		if err != nil {
+			continue
+		}
+		series = append(series, &DailyClose{Date: date, Close: price})
+	}
+	if len(series) == 0 {
+		return nil, fmt.Errorf("no price history for %v", symbol)
+	}
+	return series, nil
+}
+
+// Historical is a provider of the closing prices on a past date, so
+// positions can be valued as they were then
+type Historical struct {
+	history History
+	date    time.Time
+}
+
+// NewHistorical returns a provider of the closes on the date
+func NewHistorical(h History, date time.Time) *Historical {
+	return &Historical{history: h, date: date}
+}
+
+// Quote returns the close of the symbol on the date, or on the last
+// trading day before it
+func (h *Historical) Quote(symbol string) (*big.Float, error) {
+	return h.history.Close(symbol, h.date)
+}
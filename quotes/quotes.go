@@ -0,0 +1,141 @@
+// Package quotes looks up market prices of the symbols held in positions.
+package quotes
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider looks up the latest price of a symbol
+type Provider interface {
+	Quote(symbol string) (*big.Float, error)
+}
+
+// File is a provider of prices read from a csv file, keyed by symbol
+type File map[string]*big.Float
+
+// LoadFile reads prices from a csv file of symbol and price rows
+func LoadFile(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	prices := File{}
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			continue
+		}
+		price, _, err := big.ParseFloat(strings.TrimSpace(record[1]), 10, 53, big.ToNearestEven)
+		if err != nil {
+			// skip the header row, if any
+			continue
+		}
+		prices[strings.TrimSpace(record[0])] = price
+	}
+	return prices, nil
+}
+
+// Quote returns the price of the symbol from the file
+func (f File) Quote(symbol string) (*big.Float, error) {
+	price, ok := f[symbol]
+	if !ok {
+		return nil, fmt.Errorf("no price for %v", symbol)
+	}
+	return price, nil
+}
+
+// stooqURL is the csv quote endpoint of stooq.com
+const stooqURL = "https://stooq.com/q/l/"
+
+// Stooq is a provider of delayed quotes from stooq.com, which needs no
+// api key
+type Stooq struct {
+	Client *http.Client
+	Suffix string // market suffix appended to symbols, eg .us
+}
+
+// NewStooq returns a stooq provider for the given market suffix, with a
+// client that times out after 10 seconds
+func NewStooq(suffix string) *Stooq {
+	return &Stooq{Client: &http.Client{Timeout: 10 * time.Second}, Suffix: suffix}
+}
+
+// Quote fetches the latest close of the symbol
+func (s *Stooq) Quote(symbol string) (*big.Float, error) {
+	q := url.Values{}
+	q.Set("s", strings.ToLower(symbol)+s.Suffix)
+	q.Set("f", "sd2t2ohlcv")
+	q.Set("h", "")
+	q.Set("e", "csv")
+	resp, err := s.Client.Get(stooqURL + "?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("quote for %v: %v", symbol, resp.Status)
+	}
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	// guard clause: a header and a row with the close in the 7th column
+	if len(records) < 2 || len(records[1]) < 7 {
+		return nil, fmt.Errorf("no quote for %v", symbol)
+	}
+	price, _, err := big.ParseFloat(records[1][6], 10, 53, big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("no quote for %v", symbol)
+	}
+	return price, nil
+}
+
+// Cached wraps a provider so each symbol is only looked up once
+type Cached struct {
+	provider Provider
+	mu       sync.Mutex
+	prices   map[string]*big.Float
+	errs     map[string]error
+}
+
+// NewCached returns a provider caching the quotes of another
+func NewCached(p Provider) *Cached {
+	return &Cached{
+		provider: p,
+		prices:   make(map[string]*big.Float),
+		errs:     make(map[string]error),
+	}
+}
+
+// Quote returns the cached quote of the symbol, looking it up if needed
+func (c *Cached) Quote(symbol string) (*big.Float, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if price, ok := c.prices[symbol]; ok {
+		return price, c.errs[symbol]
+	}
+	price, err := c.provider.Quote(symbol)
+	c.prices[symbol] = price
+	c.errs[symbol] = err
+	return price, err
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/trade"
+)
+
+// the kinds of events the jsonl output streams
+const (
+	transactionEvent = "transaction"
+	lotCloseEvent    = "lot_close"
+	washSaleEvent    = "wash_sale"
+)
+
+// streamEvent is a line of the jsonl output: a transaction, or an event
+// derived from the transaction with TransactionID
+type streamEvent struct {
+	Event         string
+	TransactionID string             `json:",omitempty"`
+	Transaction   *trade.Trade       `json:",omitempty"`
+	LotClose      *lots.RealizedGain `json:",omitempty"`
+	WashSale      *lots.WashSale     `json:",omitempty"`
+}
+
+// writeEvents streams the transactions in date order as json lines, each
+// followed by the lots it closed and the wash sales it caused, so the
+// lines can be processed as they arrive. a loss is streamed when its lot
+// closes, so a purchase made after it that washes it is streamed as a
+// wash sale of its own.
+func writeEvents(w io.Writer, c *config, opts *lots.Options, transactions []*trade.Trade) error {
+	ordered := make([]*trade.Trade, len(transactions))
+	copy(ordered, transactions)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Date.Before(ordered[j].Date)
+	})
+	trading := filterTradingTransactions(c, transactions)
+	matched := make(map[*trade.Trade]bool, len(trading))
+	for i := 0; i < len(trading); i++ {
+		matched[trading[i]] = lots.Affects(trading[i])
+	}
+
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+	e := lots.NewEngine(opts)
+	for i := 0; i < len(ordered); i++ {
+		t := ordered[i]
+		if err := enc.Encode(&streamEvent{Event: transactionEvent, Transaction: t}); err != nil {
+			return err
+		}
+		// guard clause: doesn't open or close lots
+		if !matched[t] {
+			continue
+		}
+		realized := e.Apply(t)
+		for j := 0; j < len(realized); j++ {
+			if err := enc.Encode(&streamEvent{Event: lotCloseEvent, TransactionID: t.ID, LotClose: realized[j]}); err != nil {
+				return err
+			}
+		}
+		washed := e.WashSales()
+		for j := 0; j < len(washed); j++ {
+			if err := enc.Encode(&streamEvent{Event: washSaleEvent, TransactionID: t.ID, WashSale: washed[j]}); err != nil {
+				return err
+			}
+		}
+		// flush after each transaction so downstream readers aren't kept
+		// waiting on a full buffer
+		if err := out.Flush(); err != nil {
+			return fmt.Errorf("writing events: %v", err)
+		}
+	}
+	return out.Flush()
+}
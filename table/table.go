@@ -0,0 +1,520 @@
+// Package table flattens results into tables of rows and columns, for the
+// output formats laid out as tables such as csv, spreadsheets and
+// markdown. the tables are found by reflection, so new projections are
+// laid out without code of their own.
+package table
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Table is a result, or a list within a result, as rows of formatted cells
+type Table struct {
+	Name    string // snake case, eg realized_by_symbol
+	Header  []string
+	Numeric []bool // per column, true if the column holds numbers
+	Rows    [][]string
+	// Record is true for a table of the fields of a single result, rather
+	// than of a list
+	Record bool
+}
+
+// column is a column of a table, read from a field or a field of a field
+type column struct {
+	name    string
+	index   []int // reflect field index path
+	numeric bool
+}
+
+var (
+	bigFloatType = reflect.TypeOf(big.Float{})
+	timeType     = reflect.TypeOf(time.Time{})
+	rawType      = reflect.TypeOf(json.RawMessage{})
+)
+
+// Section holds the tables a single result is flattened into
+type Section struct {
+	Name   string // snake case name of the result
+	Tables []*Table
+}
+
+// Sections flattens each result into tables, in order. results is a struct
+// whose exported fields are the results, or a map of results keyed by
+// name, as written to the json output.
+func Sections(results interface{}) []*Section {
+	sections := make([]*Section, 0)
+	v := indirect(reflect.ValueOf(results))
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		// the fields that aren't results of their own, such as the dates
+		// of a command's result, are gathered into a summary
+		if summary := summaryTable("summary", v); summary != nil {
+			sections = append(sections, &Section{Name: "summary", Tables: []*Table{summary}})
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if exported(t.Field(i)) && !isScalar(t.Field(i).Type) {
+				name := Name(t.Field(i).Name)
+				sections = append(sections, &Section{Name: name, Tables: flatten(name, v.Field(i))})
+			}
+		}
+	case reflect.Map:
+		keys := sortedKeys(v)
+		for i := 0; i < len(keys); i++ {
+			name := Name(keys[i].String())
+			sections = append(sections, &Section{Name: name, Tables: flatten(name, v.MapIndex(keys[i]))})
+		}
+	}
+	return sections
+}
+
+// summaryTable lays out the scalar fields of a struct as a table of one
+// row, or returns nil if it has none
+func summaryTable(name string, v reflect.Value) *Table {
+	cols := columns(v.Type(), "", nil, false)
+	// guard clause: nothing to summarize
+	if len(cols) == 0 {
+		return nil
+	}
+	t := Table{Name: name, Header: make([]string, len(cols)), Numeric: make([]bool, len(cols)), Record: true}
+	row := make([]string, len(cols))
+	for i := 0; i < len(cols); i++ {
+		t.Header[i] = cols[i].name
+		t.Numeric[i] = cols[i].numeric
+		row[i] = format(fieldByIndex(v, cols[i].index))
+	}
+	t.Rows = [][]string{row}
+	return &t
+}
+
+// Tables flattens every result into tables, in order, like Sections
+func Tables(results interface{}) []*Table {
+	tables := make([]*Table, 0)
+	sections := Sections(results)
+	for i := 0; i < len(sections); i++ {
+		tables = append(tables, sections[i].Tables...)
+	}
+	return tables
+}
+
+// flatten returns the tables of a result: a table of its own fields, if
+// it has any, then a table for each list in it
+func flatten(name string, v reflect.Value) []*Table {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	switch {
+	case isScalar(v.Type()):
+		t := Table{Name: name, Header: []string{"Value"}, Numeric: []bool{isNumeric(v.Type())}}
+		t.Rows = append(t.Rows, []string{format(v)})
+		return []*Table{&t}
+	case isRecord(v.Type()):
+		tables := make([]*Table, 0)
+		if summary := recordTable(name+"_summary", []reflect.Value{v}, nil); summary != nil {
+			summary.Record = true
+			tables = append(tables, summary)
+		}
+		return append(tables, lists(name, v)...)
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		if !isRecord(v.Type().Elem()) {
+			return nil
+		}
+		rows := make([]reflect.Value, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			rows[i] = v.Index(i)
+		}
+		if t := recordTable(name, rows, nil); t != nil {
+			return []*Table{t}
+		}
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		keys := sortedKeys(v)
+		// values with fields of their own are rows keyed by the map key,
+		// anything else, such as grouped results, is flattened per key
+		if isRecord(v.Type().Elem()) && len(columns(derefType(v.Type().Elem()), "", nil, false)) > 0 {
+			rows := make([]reflect.Value, len(keys))
+			for i := 0; i < len(keys); i++ {
+				rows[i] = v.MapIndex(keys[i])
+			}
+			return []*Table{recordTable(name, rows, keys)}
+		}
+		tables := make([]*Table, 0)
+		for i := 0; i < len(keys); i++ {
+			tables = append(tables, flatten(name+"_"+Name(keys[i].String()), v.MapIndex(keys[i]))...)
+		}
+		return tables
+	}
+	return nil
+}
+
+// lists returns a table for each list in a struct, and in the structs in
+// it, whose own fields are already columns of the struct's table
+func lists(name string, v reflect.Value) []*Table {
+	tables := make([]*Table, 0)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !exported(f) || isScalar(f.Type) {
+			continue
+		}
+		field := Name(f.Name)
+		listName := name + "_" + field
+		if field == name {
+			listName = name
+		}
+		if !isRecord(f.Type) {
+			tables = append(tables, flatten(listName, v.Field(i))...)
+		} else if nested := indirect(v.Field(i)); nested.IsValid() {
+			tables = append(tables, lists(listName, nested)...)
+		}
+	}
+	return tables
+}
+
+// WriteCSV writes the table as csv with a header row
+func (t *Table) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(t.Header); err != nil {
+		return err
+	}
+	if err := out.WriteAll(t.Rows); err != nil {
+		return err
+	}
+	return out.Error()
+}
+
+// Transposed returns the fields of a single result as a table of a Field
+// and a Value column, which reads better than one wide row. other tables
+// are returned as they are.
+func (t *Table) Transposed() *Table {
+	// guard clause: only records are transposed
+	if !t.Record || len(t.Rows) != 1 || len(t.Header) < 2 {
+		return t
+	}
+	transposed := Table{Name: t.Name, Header: []string{"Field", "Value"}, Numeric: []bool{false, false}, Rows: make([][]string, len(t.Header))}
+	for i := 0; i < len(t.Header); i++ {
+		transposed.Rows[i] = []string{t.Header[i], t.Rows[0][i]}
+	}
+	return &transposed
+}
+
+// Select returns a table of only the named columns, in the table's order,
+// matching the names ignoring case. it returns nil if the table has none
+// of them.
+func (t *Table) Select(names []string) *Table {
+	columns := make([]int, 0, len(names))
+	for i := 0; i < len(t.Header); i++ {
+		for j := 0; j < len(names); j++ {
+			if strings.EqualFold(t.Header[i], strings.TrimSpace(names[j])) {
+				columns = append(columns, i)
+				break
+			}
+		}
+	}
+	// guard clause: none of the columns are in the table
+	if len(columns) == 0 {
+		return nil
+	}
+	selected := Table{Name: t.Name, Header: make([]string, len(columns)), Numeric: make([]bool, len(columns)), Rows: make([][]string, len(t.Rows)), Record: t.Record}
+	for i := 0; i < len(columns); i++ {
+		selected.Header[i] = t.Header[columns[i]]
+		selected.Numeric[i] = t.Numeric[columns[i]]
+	}
+	for i := 0; i < len(t.Rows); i++ {
+		selected.Rows[i] = make([]string, len(columns))
+		for j := 0; j < len(columns); j++ {
+			selected.Rows[i][j] = t.Rows[i][columns[j]]
+		}
+	}
+	return &selected
+}
+
+// WriteMarkdown writes the table as a markdown table with numbers aligned
+// right, and the fields of a single result transposed
+func (t *Table) WriteMarkdown(w io.Writer) error {
+	transposed := t.Transposed()
+	header, numeric, rows := transposed.Header, transposed.Numeric, transposed.Rows
+	var b strings.Builder
+	b.WriteString("|")
+	for i := 0; i < len(header); i++ {
+		b.WriteString(" " + markdownCell(header[i]) + " |")
+	}
+	b.WriteString("\n|")
+	for i := 0; i < len(header); i++ {
+		if i < len(numeric) && numeric[i] {
+			b.WriteString(" ---: |")
+		} else {
+			b.WriteString(" --- |")
+		}
+	}
+	b.WriteString("\n")
+	for i := 0; i < len(rows); i++ {
+		b.WriteString("|")
+		for j := 0; j < len(rows[i]); j++ {
+			b.WriteString(" " + markdownCell(rows[i][j]) + " |")
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes the pipes in a cell and keeps it on one line
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ").Replace(s)
+}
+
+// recordTable lays out structs as the rows of a table, with a Key column
+// first when keys are given. it returns nil if the structs have no columns.
+func recordTable(name string, rows []reflect.Value, keys []reflect.Value) *Table {
+	if len(rows) == 0 {
+		return nil
+	}
+	var cols []column
+	for i := 0; i < len(rows) && cols == nil; i++ {
+		if r := indirect(rows[i]); r.IsValid() {
+			cols = columns(r.Type(), "", nil, true)
+		}
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+	t := Table{Name: name, Header: make([]string, 0, len(cols)+1), Numeric: make([]bool, 0, len(cols)+1)}
+	if keys != nil {
+		t.Header = append(t.Header, "Key")
+		t.Numeric = append(t.Numeric, false)
+	}
+	for i := 0; i < len(cols); i++ {
+		t.Header = append(t.Header, cols[i].name)
+		t.Numeric = append(t.Numeric, cols[i].numeric)
+	}
+	for i := 0; i < len(rows); i++ {
+		r := indirect(rows[i])
+		if !r.IsValid() {
+			continue
+		}
+		row := make([]string, 0, len(t.Header))
+		if keys != nil {
+			row = append(row, keys[i].String())
+		}
+		for j := 0; j < len(cols); j++ {
+			row = append(row, format(fieldByIndex(r, cols[j].index)))
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return &t
+}
+
+// columns returns the columns of a struct: its scalar fields and, at the
+// top level, the scalar fields of the structs in it, named Parent.Field
+func columns(t reflect.Type, prefix string, index []int, nested bool) []column {
+	cols := make([]column, 0)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !exported(f) {
+			continue
+		}
+		path := append(append(make([]int, 0, len(index)+1), index...), i)
+		switch {
+		case isScalar(f.Type):
+			cols = append(cols, column{name: prefix + f.Name, index: path, numeric: isNumeric(f.Type)})
+		case f.Anonymous && isRecord(f.Type):
+			// embedded fields are promoted, as in the json output
+			cols = append(cols, columns(derefType(f.Type), prefix, path, nested)...)
+		case nested && isRecord(f.Type):
+			cols = append(cols, columns(derefType(f.Type), prefix+f.Name+".", path, false)...)
+		}
+	}
+	return cols
+}
+
+// fieldByIndex returns the field at the index path, or an invalid value if
+// a struct on the way is nil
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i := 0; i < len(index); i++ {
+		v = indirect(v)
+		if !v.IsValid() {
+			return v
+		}
+		v = v.Field(index[i])
+	}
+	return v
+}
+
+// exported returns true if the field is exported and not left out of the
+// json output
+func exported(f reflect.StructField) bool {
+	return f.PkgPath == "" && f.Tag.Get("json") != "-"
+}
+
+// indirect follows pointers and interfaces, returning an invalid value
+// for nil
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// derefType follows pointer types
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isScalar returns true if values of the type are a single cell. lists of
+// scalars, such as tags, are joined into one.
+func isScalar(t reflect.Type) bool {
+	t = derefType(t)
+	if t == bigFloatType || t == timeType || t == rawType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Slice:
+		return derefType(t.Elem()).Kind() != reflect.Slice && isScalar(t.Elem())
+	}
+	return false
+}
+
+// isNumeric returns true if values of the type are numbers
+func isNumeric(t reflect.Type) bool {
+	t = derefType(t)
+	if t == bigFloatType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// isRecord returns true if values of the type are structs laid out as rows
+func isRecord(t reflect.Type) bool {
+	t = derefType(t)
+	return t.Kind() == reflect.Struct && !isScalar(t)
+}
+
+// format formats a scalar as a cell
+func format(v reflect.Value) string {
+	v = indirect(v)
+	if !v.IsValid() {
+		return ""
+	}
+	switch v.Type() {
+	case bigFloatType:
+		if v.CanAddr() {
+			return Decimal(v.Addr().Interface().(*big.Float))
+		}
+		f := v.Interface().(big.Float)
+		return Decimal(&f)
+	case timeType:
+		return Date(v.Interface().(time.Time))
+	case rawType:
+		return string(v.Bytes())
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items[i] = format(v.Index(i))
+		}
+		return strings.Join(items, ", ")
+	}
+	return ""
+}
+
+// Decimal formats a number with up to 8 decimal places, dropping trailing
+// zeros, so amounts read as 12.5 rather than 12.50000000000000071
+func Decimal(f *big.Float) string {
+	if f == nil {
+		return ""
+	}
+	if f.IsInf() {
+		return f.String()
+	}
+	s := f.Text('f', 8)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// Date formats a time as a date, with the time of day only if it has one,
+// and a zero time as blank
+func Date(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// Name returns a snake case name, eg realized_pl for RealizedPL, safe to
+// use in file names
+func Name(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteRune('_')
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 && b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// sortedKeys returns the string keys of a map in order
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/stonks/table"
+)
+
+// templateFuncs are the functions report templates can call on top of the
+// builtins of text/template
+var templateFuncs = template.FuncMap{
+	// decimal formats an amount without trailing zeros, eg 12.5
+	"decimal": table.Decimal,
+	// money formats an amount with cents, eg 12.50
+	"money": func(f *big.Float) string {
+		if f == nil {
+			return ""
+		}
+		return f.Text('f', 2)
+	},
+	// date formats a time as a date, with the time of day if it has one
+	"date": table.Date,
+	// tables flattens a result into its tables, as the csv output does
+	"tables": table.Tables,
+	// table returns the table of a result with the name, eg
+	// realized_by_symbol, or nil if it has none
+	"table": func(name string, results interface{}) *table.Table {
+		tables := table.Tables(results)
+		for i := 0; i < len(tables); i++ {
+			if tables[i].Name == name {
+				return tables[i]
+			}
+		}
+		return nil
+	},
+	// markdown writes a table as markdown
+	"markdown": func(t *table.Table) (string, error) {
+		var b strings.Builder
+		err := t.WriteMarkdown(&b)
+		return b.String(), err
+	},
+	// json writes a value as indented json
+	"json": func(v interface{}) (string, error) {
+		b, err := json.MarshalIndent(v, "", "  ")
+		return string(b), err
+	},
+	// title turns a snake case table name into a heading
+	"title": markdownTitle,
+	"join":  strings.Join,
+	// now is the time the template is rendered, for dating the output
+	"now": time.Now,
+}
+
+// writeTemplate renders the results with the text/template at path. the
+// results are the data of the template, so the report's fields are
+// reached as {{.Realized.Total}} and the projections by name as
+// {{index . "stats"}}.
+func writeTemplate(w io.Writer, path string, results interface{}) error {
+	t, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, results)
+}
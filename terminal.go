@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/stonks/chart"
+	"github.com/stonks/table"
+)
+
+// colorMode is when the table output is colorized
+type colorMode string
+
+const (
+	// autoColor colorizes when stdout is a terminal and NO_COLOR isn't set
+	autoColor   colorMode = "auto"
+	alwaysColor colorMode = "always"
+	neverColor  colorMode = "never"
+)
+
+// ansi escape codes of the styles the table output uses
+const (
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiReset = "\x1b[0m"
+)
+
+// terminalSummary names the tables of the report written to the terminal,
+// in order: the P/L, the positions and the volume traded
+var terminalSummary = []string{
+	"realized_summary",
+	"realized_by_symbol",
+	"unrealized_summary",
+	"unrealized_positions",
+	"positions",
+	"volume_summary",
+	"volume_by_underlying",
+}
+
+// parseColor checks the color mode of the configs, defaulting to auto
+func parseColor(c *config) error {
+	switch c.Color {
+	case "":
+		c.Color = autoColor
+		return nil
+	case autoColor, alwaysColor, neverColor:
+		return nil
+	}
+	return fmt.Errorf("unsupported color mode %q, expected auto, always or never", c.Color)
+}
+
+// useColor returns true if the table output written to stdout should be
+// colorized
+func useColor(c *config) bool {
+	switch c.Color {
+	case alwaysColor:
+		return true
+	case neverColor:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// writeTerminal writes the results as aligned tables for reading in a
+// terminal. the report is summarized by its P/L, positions and volume,
+// anything else is written as all of its tables. when the configs select
+// columns, only those columns are shown, leaving out the tables without
+// any of them.
+func writeTerminal(w io.Writer, c *config, results interface{}) error {
+	tables := table.Tables(results)
+	if _, ok := results.(*report); ok {
+		byName := make(map[string]*table.Table, len(tables))
+		for i := 0; i < len(tables); i++ {
+			byName[tables[i].Name] = tables[i]
+		}
+		tables = make([]*table.Table, 0, len(terminalSummary))
+		for i := 0; i < len(terminalSummary); i++ {
+			if t := byName[terminalSummary[i]]; t != nil {
+				tables = append(tables, t)
+			}
+		}
+	}
+	color := useColor(c)
+	for i := 0; i < len(tables); i++ {
+		t := tables[i]
+		if len(c.Columns) > 0 {
+			if t = t.Select(c.Columns); t == nil {
+				continue
+			}
+		}
+		if err := writeTerminalTable(w, t, color); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTerminalTable writes a table under its title with its columns
+// padded to line up, numbers aligned right. with color, the header is
+// bold and P/L columns are red when negative and green when positive.
+func writeTerminalTable(w io.Writer, t *table.Table, color bool) error {
+	record := t.Record && len(t.Rows) == 1
+	// the P/L fields of a record are colored by the name in their row
+	plField := make([]bool, len(t.Header))
+	for i := 0; i < len(t.Header); i++ {
+		plField[i] = t.Numeric[i] && isPLColumn(t.Header[i])
+	}
+	t = t.Transposed()
+
+	widths := make([]int, len(t.Header))
+	for i := 0; i < len(t.Header); i++ {
+		widths[i] = utf8.RuneCountInString(t.Header[i])
+	}
+	for i := 0; i < len(t.Rows); i++ {
+		for j := 0; j < len(t.Rows[i]) && j < len(widths); j++ {
+			if n := utf8.RuneCountInString(t.Rows[i][j]); n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+
+	var b strings.Builder
+	title := markdownTitle(t.Name)
+	if color {
+		title = ansiBold + title + ansiReset
+	}
+	b.WriteString(title + "\n")
+	cells := make([]string, len(t.Header))
+	rules := make([]string, len(t.Header))
+	for i := 0; i < len(t.Header); i++ {
+		cells[i] = pad(t.Header[i], widths[i], t.Numeric[i])
+		if color {
+			cells[i] = ansiBold + cells[i] + ansiReset
+		}
+		rules[i] = strings.Repeat("-", widths[i])
+	}
+	b.WriteString(strings.Join(cells, "  ") + "\n")
+	b.WriteString(strings.Join(rules, "  ") + "\n")
+	for i := 0; i < len(t.Rows); i++ {
+		cells = make([]string, 0, len(t.Rows[i]))
+		for j := 0; j < len(t.Rows[i]) && j < len(widths); j++ {
+			value := t.Rows[i][j]
+			cell := pad(value, widths[j], t.Numeric[j])
+			signed := t.Numeric[j] && isPLColumn(t.Header[j])
+			if record && j == 1 {
+				signed = plField[i]
+			}
+			if color && signed {
+				cell = colorBySign(value, cell)
+			}
+			cells = append(cells, cell)
+		}
+		b.WriteString(strings.Join(cells, "  ") + "\n")
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// pad pads a cell to the width of its column, on the left for numbers so
+// they line up on the right
+func pad(s string, width int, right bool) string {
+	padding := strings.Repeat(" ", width-utf8.RuneCountInString(s))
+	if right {
+		return padding + s
+	}
+	return s + padding
+}
+
+// colorBySign colors a padded cell red when its value is negative and
+// green when it is positive
+func colorBySign(value string, cell string) string {
+	switch {
+	case strings.HasPrefix(value, "-"):
+		return ansiRed + cell + ansiReset
+	case value != "" && strings.Trim(value, "0.") != "":
+		return ansiGreen + cell + ansiReset
+	}
+	return cell
+}
+
+// sparklineWidth is the most values a sparkline is drawn with
+const sparklineWidth = 60
+
+// writeCharts draws the equity curve and the realized P/L of each month
+// of the report in the terminal, for quick checks over ssh. the equity
+// curve is also summed up as a sparkline.
+func writeCharts(w io.Writer, c *config, results interface{}) error {
+	r, ok := results.(*report)
+	// guard clause: only the report has charts
+	if !ok {
+		return fmt.Errorf("the chart output only charts the report")
+	}
+	if equity := equityCurve(r); equity != nil {
+		points := equity.Lines[0].Points
+		values := make([]float64, len(points))
+		for i := 0; i < len(points); i++ {
+			values[i] = points[i].Y
+		}
+		values = sample(values, sparklineWidth)
+		if _, err := fmt.Fprintf(w, "%s %s %.2f\n\n", equity.Lines[0].Name, chart.Sparkline(values), values[len(values)-1]); err != nil {
+			return err
+		}
+		if err := equity.WriteText(w); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	if r.Realized != nil && len(r.Realized.Transactions) > 0 {
+		if err := monthlyPL(r.Realized).WriteText(w, useColor(c)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sample returns at most n of the values, evenly spaced and keeping the
+// last
+func sample(values []float64, n int) []float64 {
+	// guard clause: few enough already
+	if len(values) <= n {
+		return values
+	}
+	sampled := make([]float64, n)
+	for i := 0; i < n; i++ {
+		sampled[i] = values[(len(values)-1)*i/(n-1)]
+	}
+	return sampled
+}
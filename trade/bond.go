@@ -0,0 +1,112 @@
+package trade
+
+import (
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// bondPricePer is the face value bond prices are quoted against, eg a
+// price of 99.5 means 99.5% of par
+const bondPricePer = 100.0
+
+// bondCouponPattern finds the coupon rate in a bond description, eg "2.5%"
+var bondCouponPattern = regexp.MustCompile(`(\d+(?:\.\d+)?)%`)
+
+// bondMaturityPattern finds the maturity date in a bond description, eg "01/25/24"
+var bondMaturityPattern = regexp.MustCompile(`\b(\d{2}/\d{2}/\d{2}(?:\d{2})?)\b`)
+
+// cusipInDescriptionPattern finds a CUSIP given in parentheses in a
+// description, eg "BOND INTEREST (912828X88)"
+var cusipInDescriptionPattern = regexp.MustCompile(`\(([0-9A-Z]{9})\)`)
+
+// isCUSIP returns true if the symbol is a valid 9 character CUSIP,
+// which is how TDA identifies bonds and treasuries.
+func isCUSIP(s string) bool {
+	if len(s) != 9 {
+		return false
+	}
+	// every CUSIP issued in the US starts with a digit, which keeps this
+	// from matching 9 letter symbols
+	if s[0] < '0' || s[0] > '9' {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 8; i++ {
+		c := s[i]
+		var v int
+		switch {
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c >= 'A' && c <= 'Z':
+			v = int(c-'A') + 10
+		case c == '*':
+			v = 36
+		case c == '@':
+			v = 37
+		case c == '#':
+			v = 38
+		default:
+			return false
+		}
+		// double every second character
+		if i%2 == 1 {
+			v *= 2
+		}
+		sum += v/10 + v%10
+	}
+	checkDigit := (10 - sum%10) % 10
+	return int(s[8]-'0') == checkDigit
+}
+
+// applyBondDetails fills in the bond specific details of a trade from its
+// description and amounts.
+func (t *Trade) applyBondDetails() {
+	t.FaceValue = big.NewFloat(0.0).Abs(t.Quantity)
+
+	if m := bondCouponPattern.FindStringSubmatch(t.Description); m != nil {
+		if rate, _, err := big.ParseFloat(m[1], 10, 53, big.ToNearestEven); err == nil {
+			t.Instrument.CouponRate = rate
+		}
+	}
+	if m := bondMaturityPattern.FindStringSubmatch(t.Description); m != nil {
+		format := "01/02/06"
+		if len(m[1]) == 10 {
+			format = "01/02/2006"
+		}
+		if maturity, err := time.Parse(format, m[1]); err == nil {
+			t.Instrument.Expiration = maturity
+		}
+	}
+
+	t.AccruedInterest = big.NewFloat(0.0)
+	if !t.IsTrade() {
+		return
+	}
+
+	// the amount of a bond trade includes the accrued interest owed to the
+	// seller, so whatever isn't explained by the price, commission and fees is interest
+	charges := big.NewFloat(0.0).Add(t.Commission, t.Fees.Total())
+	accrued := big.NewFloat(0.0).Abs(t.Amount)
+	accrued = accrued.Sub(accrued, t.Notional())
+	if t.Type == Buy {
+		accrued = accrued.Sub(accrued, charges)
+	} else {
+		accrued = accrued.Add(accrued, charges)
+	}
+	if accrued.Sign() > 0 {
+		t.AccruedInterest = accrued
+	}
+}
+
+// cusipFromDescription returns the CUSIP referenced in a transaction
+// description, or a blank string if there isn't one
+func cusipFromDescription(description string) string {
+	m := cusipInDescriptionPattern.FindStringSubmatch(strings.ToUpper(description))
+	if m == nil || !isCUSIP(m[1]) {
+		return ""
+	}
+	return m[1]
+}
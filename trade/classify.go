@@ -0,0 +1,160 @@
+package trade
+
+import (
+	"math/big"
+	"strings"
+)
+
+// TradeType identifies the kind of activity a transaction represents
+type TradeType string
+
+const (
+	Buy            TradeType = "BUY"
+	Sell           TradeType = "SELL"
+	Coupon         TradeType = "COUPON"          // interest paid on a bond position
+	Maturity       TradeType = "MATURITY"        // bond redeemed at maturity or called
+	Interest       TradeType = "INTEREST"        // credit interest paid on the cash balance
+	MarginInterest TradeType = "MARGIN_INTEREST" // interest charged on a margin balance
+	// MoneyMarket is a purchase or redemption of a money market fund or
+	// bank deposit sweep, which moves idle cash rather than trading
+	MoneyMarket TradeType = "MONEY_MARKET"
+	Deposit     TradeType = "DEPOSIT"    // cash added to the account
+	Withdrawal  TradeType = "WITHDRAWAL" // cash taken out of the account
+	Journal     TradeType = "JOURNAL"    // cash moved between accounts at the same broker
+	Fee         TradeType = "FEE"        // fee charged as its own transaction such as a wire or ADR fee
+	Other       TradeType = "OTHER"
+)
+
+// TransferMethod identifies how cash was moved in or out of an account
+type TransferMethod string
+
+const (
+	ACH          TransferMethod = "ACH"
+	Wire         TransferMethod = "WIRE"
+	Check        TransferMethod = "CHECK"
+	JournalEntry TransferMethod = "JOURNAL"
+	NoTransfer   TransferMethod = ""
+)
+
+// descriptionRule maps text found in a transaction description
+// to the type of transaction it indicates
+type descriptionRule struct {
+	pattern   string
+	tradeType TradeType
+}
+
+// tdaDescriptionRules are checked in order against the upper cased
+// description of a TDA transaction. the first matching rule wins.
+var tdaDescriptionRules = []descriptionRule{
+	{"MONEY MARKET INTEREST", Interest},
+	{"MONEY MARKET", MoneyMarket},
+	{"DEPOSIT SWEEP", MoneyMarket},
+	{"CASH ALTERNATIVES INTEREST", Interest},
+	{"CASH ALTERNATIVES", MoneyMarket},
+	{"STOCK DIVIDEND", StockDividend},
+	{"SPIN OFF", SpinOff},
+	{"SPINOFF", SpinOff},
+	{"SPIN-OFF", SpinOff},
+	{"DIVIDEND", Dividend},
+	{"GAIN DISTRIBUTION", Dividend},
+	{"CAPITAL GAIN", Dividend},
+	{"RETURN OF CAPITAL", Dividend},
+	{"DUE TO EXPIRATION", Expiration},
+	{"EXPIRED", Expiration},
+	{"DUE TO ASSIGNMENT", Assignment},
+	{"ASSIGNED", Assignment},
+	{"DUE TO EXERCISE", Exercise},
+	{"EXERCISED", Exercise},
+	{"STOCK SPLIT", StockSplit},
+	{"FORWARD SPLIT", StockSplit},
+	{"REVERSE SPLIT", StockSplit},
+	{"ADR FEE", Fee},
+	{"WIRE FEE", Fee},
+	{"TRANSFER FEE", Fee},
+	{"ELECTRONIC FUNDING RECEIPT", Deposit},
+	{"ELECTRONIC FUNDING DISBURSEMENT", Withdrawal},
+	{"ACH DEPOSIT", Deposit},
+	{"ACH WITHDRAWAL", Withdrawal},
+	{"WIRE INCOMING", Deposit},
+	{"INCOMING WIRE", Deposit},
+	{"WIRE OUTGOING", Withdrawal},
+	{"OUTGOING WIRE", Withdrawal},
+	{"CHECK RECEIVED", Deposit},
+	{"CHECK DEPOSIT", Deposit},
+	{"DISBURSEMENT BY CHECK", Withdrawal},
+	{"CHECK DISBURSEMENT", Withdrawal},
+	{"INTERNAL TRANSFER", Journal},
+	{"JOURNAL", Journal},
+	{"BOND INTEREST", Coupon},
+	{"COUPON", Coupon},
+	{"REDEMPTION", Maturity},
+	{"MATURITY", Maturity},
+	{"MARGIN INTEREST", MarginInterest},
+	{"INTEREST", Interest},
+}
+
+// classifyTDA determines the type of a TDA transaction from its description
+// and quantity
+func classifyTDA(description string, quantity *big.Float) TradeType {
+	if strings.HasPrefix(description, "Bought") {
+		return Buy
+	}
+	if strings.HasPrefix(description, "Sold") {
+		return Sell
+	}
+
+	upper := strings.ToUpper(description)
+	// a reinvested dividend is both the cash credit of the dividend and the
+	// purchase of shares with it, only the row with the shares is a buy
+	if strings.Contains(upper, "DIVIDEND REINVEST") && quantity.Sign() != 0 {
+		return Buy
+	}
+	for i := 0; i < len(tdaDescriptionRules); i++ {
+		rule := tdaDescriptionRules[i]
+		if strings.Contains(upper, rule.pattern) {
+			return rule.tradeType
+		}
+	}
+	return Other
+}
+
+// transferMethodTDA determines how cash was moved from the description of
+// a TDA transaction. NoTransfer is returned if the description doesn't
+// describe a cash movement.
+func transferMethodTDA(description string, tradeType TradeType) TransferMethod {
+	if tradeType != Deposit && tradeType != Withdrawal && tradeType != Journal && tradeType != Fee {
+		return NoTransfer
+	}
+
+	upper := strings.ToUpper(description)
+	switch {
+	case tradeType == Journal:
+		return JournalEntry
+	case strings.Contains(upper, "WIRE"):
+		return Wire
+	case strings.Contains(upper, "CHECK"):
+		return Check
+	case strings.Contains(upper, "ELECTRONIC FUNDING") || strings.Contains(upper, "ACH"):
+		return ACH
+	}
+	return NoTransfer
+}
+
+// IsTrade returns true if the transaction is a purchase or sale
+func (t *Trade) IsTrade() bool {
+	return t.Type == Buy || t.Type == Sell
+}
+
+// IsCashEquivalent returns true if the transaction only moves cash in or
+// out of a money market sweep. these are excluded from trading statistics
+// but still affect the cash balance of the account.
+func (t *Trade) IsCashEquivalent() bool {
+	return t.Type == MoneyMarket || (t.Instrument != nil && t.Instrument.Class == MoneyMarketFund)
+}
+
+// IsExternalCashFlow returns true if the transaction moves cash in or out
+// of the account rather than being the result of investment activity.
+// journals count as external since they move cash to a different account.
+func (t *Trade) IsExternalCashFlow() bool {
+	return t.Type == Deposit || t.Type == Withdrawal || t.Type == Journal
+}
@@ -0,0 +1,157 @@
+package trade
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// BrokerCoinbase identifies transactions imported from Coinbase
+const BrokerCoinbase = "COINBASE"
+
+const (
+	// TransferIn is crypto received from another wallet, which moves
+	// basis between wallets without being a purchase
+	TransferIn TradeType = "TRANSFER_IN"
+	// TransferOut is crypto sent to another wallet, which moves basis
+	// between wallets without being a taxable disposal
+	TransferOut TradeType = "TRANSFER_OUT"
+)
+
+// CryptoPrecision is the precision, in bits, quantities of crypto are
+// parsed with. crypto trades in fractions down to 8 decimal places or
+// smaller (eg satoshis), which together with large whole amounts is more
+// significant digits than the default precision holds.
+const CryptoPrecision = 128
+
+// coinbaseTypes maps the transaction types of a Coinbase transaction
+// report to the type of trade. converts are treated as a sale of the
+// asset converted from, with the purchase of the asset converted to as
+// the trade's ConvertedTo.
+var coinbaseTypes = map[string]TradeType{
+	"BUY":                 Buy,
+	"ADVANCED TRADE BUY":  Buy,
+	"SELL":                Sell,
+	"ADVANCED TRADE SELL": Sell,
+	"CONVERT":             Sell,
+	"SEND":                TransferOut,
+	"RECEIVE":             TransferIn,
+}
+
+// NewTradeCoinbase constructs a new trade struct from a csv row in a
+// transaction report downloaded from Coinbase. the report's columns are
+// Timestamp, Transaction Type, Asset, Quantity Transacted, Spot Price
+// Currency, Spot Price at Transaction, Subtotal, Total (inclusive of fees
+// and/or spread), Fees and/or Spread and Notes.
+func NewTradeCoinbase(r []string) (*Trade, error) {
+	// guard clause: the report starts with a preamble that isn't transactions
+	if len(r) < 9 {
+		return nil, fmt.Errorf("expected at least 9 columns, found %d", len(r))
+	}
+
+	transactionDt, err := time.Parse(time.RFC3339, strings.TrimSpace(r[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	tradeType, ok := coinbaseTypes[strings.ToUpper(strings.TrimSpace(r[1]))]
+	if !ok {
+		tradeType = Other
+	}
+
+	notes := ""
+	if len(r) > 9 {
+		notes = r[9]
+	}
+	quantity := parseDecimalPrec(r[3], CryptoPrecision)
+	total := parseDecimal(r[7])
+	fees := newFees()
+	fees.NetworkFee = parseDecimal(r[8])
+
+	t := Trade{
+		Broker:      BrokerCoinbase,
+		Date:        transactionDt,
+		Description: strings.TrimSpace(r[1] + " " + notes),
+		Symbol:      strings.TrimSpace(r[2]),
+		Quantity:    quantity,
+		Price:       parseDecimal(r[5]),
+		Commission:  big.NewFloat(0.0),
+		Fees:        fees,
+		Amount:      big.NewFloat(0.0),
+		Type:        tradeType,
+		Instrument:  NewInstrumentOn(strings.TrimSpace(r[2]), transactionDt),
+	}
+	// coinbase only lists assets it trades, so anything in the report is crypto
+	t.Instrument.Class = Crypto
+	if strings.TrimSpace(r[4]) != "" {
+		t.Instrument.Currency = strings.TrimSpace(r[4])
+	}
+
+	switch tradeType {
+	case Buy:
+		t.Amount = t.Amount.Neg(total)
+	case Sell:
+		t.Amount = t.Amount.Copy(total)
+		t.Quantity = t.Quantity.Neg(quantity)
+	case TransferOut:
+		t.Quantity = t.Quantity.Neg(quantity)
+	}
+	if strings.EqualFold(strings.TrimSpace(r[1]), "CONVERT") {
+		t.ConvertedTo = coinbaseConvertedTo(&t, notes, total)
+	}
+
+	return &t, nil
+}
+
+// coinbaseConvertedTo returns the purchase of the asset a convert
+// converted to, at the value converted, from notes like Converted 0.01 BTC
+// to 0.15 ETH. nil if the notes don't name the asset.
+func coinbaseConvertedTo(t *Trade, notes string, total *big.Float) *Trade {
+	fields := strings.Fields(notes)
+	// guard clause: the asset converted to is only named by the notes
+	if len(fields) != 6 || !strings.EqualFold(fields[0], "Converted") || !strings.EqualFold(fields[3], "to") {
+		return nil
+	}
+	quantity := parseDecimalPrec(fields[4], CryptoPrecision)
+	symbol := strings.ToUpper(fields[5])
+	// guard clause: nothing received
+	if quantity.Sign() <= 0 {
+		return nil
+	}
+	price := big.NewFloat(0.0).Quo(total, quantity)
+	bought := Trade{
+		Broker:      BrokerCoinbase,
+		ID:          t.ID,
+		Date:        t.Date,
+		Description: t.Description,
+		Symbol:      symbol,
+		Quantity:    quantity,
+		Price:       price,
+		Commission:  big.NewFloat(0.0),
+		Fees:        newFees(),
+		Amount:      big.NewFloat(0.0).Neg(total),
+		Type:        Buy,
+		Instrument:  NewInstrumentOn(symbol, t.Date),
+	}
+	bought.Instrument.Class = Crypto
+	bought.Instrument.Currency = t.Instrument.Currency
+	return &bought
+}
+
+// parseDecimalPrec parses a numeric column like parseDecimal, keeping the
+// given number of bits of precision
+func parseDecimalPrec(s string, prec uint) *big.Float {
+	cleaned := strings.NewReplacer(",", "", "$", "", " ", "").Replace(s)
+	f, _, err := big.ParseFloat(cleaned, 10, prec, big.ToNearestEven)
+	if err != nil {
+		return new(big.Float).SetPrec(prec) // sane default
+	}
+	return f
+}
+
+// IsWalletTransfer returns true if the transaction moves crypto between
+// wallets, which is neither a purchase nor a taxable disposal
+func (t *Trade) IsWalletTransfer() bool {
+	return t.Type == TransferIn || t.Type == TransferOut
+}
@@ -0,0 +1,134 @@
+package trade
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NativeValues holds the amounts of a transaction in the currency it
+// was made in, before conversion to the base currency
+type NativeValues struct {
+	Currency   string
+	Price      *big.Float
+	Commission *big.Float
+	Fees       *Fees
+	Amount     *big.Float
+}
+
+// fxRate is the value of one unit of a currency in the base currency
+// as of a date
+type fxRate struct {
+	date time.Time
+	rate *big.Float
+}
+
+// FXRates holds historical exchange rates keyed by currency, each
+// ordered by date
+type FXRates map[string][]fxRate
+
+// LoadFXRates reads exchange rates from a csv file where each row is a
+// date in YYYY-MM-DD format, a currency code and the value of one unit of
+// the currency in the base currency.
+func LoadFXRates(path string) (FXRates, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rates := make(FXRates)
+	r := csv.NewReader(f)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			// skip the header row, if any
+			continue
+		}
+		currency := strings.ToUpper(strings.TrimSpace(record[1]))
+		rates[currency] = append(rates[currency], fxRate{date: date, rate: parseDecimal(record[2])})
+	}
+
+	for currency := range rates {
+		series := rates[currency]
+		sort.Slice(series, func(i, j int) bool {
+			return series[i].date.Before(series[j].date)
+		})
+	}
+	return rates, nil
+}
+
+// Rate returns the most recent exchange rate for a currency on or before
+// the given date
+func (r FXRates) Rate(currency string, date time.Time) (*big.Float, error) {
+	series := r[currency]
+	// find the first rate after the date, the one before it is the rate in effect
+	idx := sort.Search(len(series), func(i int) bool {
+		return series[i].date.After(date)
+	})
+	if idx == 0 {
+		return nil, fmt.Errorf("no %v exchange rate on or before %v", currency, date.Format("2006-01-02"))
+	}
+	return series[idx-1].rate, nil
+}
+
+// ConvertToBase restates the price, commission, fees and amount of
+// transactions made in a currency other than the base currency, in the
+// base currency. the original amounts are kept in Native. transactions
+// that already have an FX rate (eg from a broker that reports one per
+// transaction) use it, otherwise the rate in effect on the transaction
+// date is looked up.
+func ConvertToBase(trans []*Trade, base string, rates FXRates) error {
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		currency := t.Currency
+		if currency == "" && t.Instrument != nil {
+			currency = t.Instrument.Currency
+		}
+		if currency == "" {
+			currency = defaultCurrency
+		}
+		// guard clause: already in the base currency
+		if currency == base {
+			t.Currency = base
+			continue
+		}
+
+		rate := t.FXRate
+		if rate == nil {
+			var err error
+			if rate, err = rates.Rate(currency, t.Date); err != nil {
+				return err
+			}
+		}
+
+		t.Native = &NativeValues{
+			Currency:   currency,
+			Price:      t.Price,
+			Commission: t.Commission,
+			Fees:       t.Fees,
+			Amount:     t.Amount,
+		}
+		t.Price = big.NewFloat(0.0).Mul(t.Price, rate)
+		t.Commission = big.NewFloat(0.0).Mul(t.Commission, rate)
+		if t.Fees != nil {
+			t.Fees = t.Fees.scaled(rate)
+		}
+		t.Amount = big.NewFloat(0.0).Mul(t.Amount, rate)
+		t.Currency = base
+		t.FXRate = rate
+	}
+	return nil
+}
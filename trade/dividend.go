@@ -0,0 +1,90 @@
+package trade
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Dividend is a cash distribution paid on a position
+const Dividend TradeType = "DIVIDEND"
+
+// DividendClass identifies how a dividend is treated for taxes
+type DividendClass string
+
+const (
+	Qualified               DividendClass = "QUALIFIED"
+	NonQualified            DividendClass = "NON_QUALIFIED"
+	ReturnOfCapital         DividendClass = "RETURN_OF_CAPITAL"
+	CapitalGainDistribution DividendClass = "CAPITAL_GAIN_DISTRIBUTION"
+)
+
+// tdaDividendRules are checked in order against the upper cased
+// description of a TDA dividend to determine its class. the first
+// matching rule wins. ordinary dividends are treated as non qualified
+// since TDA doesn't say whether they qualify until the 1099 is issued,
+// use the dividend overrides for payers that are known to qualify.
+var tdaDividendRules = []struct {
+	pattern string
+	class   DividendClass
+}{
+	{"NON-QUALIFIED", NonQualified},
+	{"NON QUALIFIED", NonQualified},
+	{"NONQUALIFIED", NonQualified},
+	{"QUALIFIED", Qualified},
+	{"RETURN OF CAPITAL", ReturnOfCapital},
+	{"NON-TAXABLE", ReturnOfCapital},
+	// short term capital gain distributions are taxed as ordinary dividends
+	{"SHORT TERM", NonQualified},
+	{"SHORT-TERM", NonQualified},
+	{"LONG TERM GAIN", CapitalGainDistribution},
+	{"LONG-TERM GAIN", CapitalGainDistribution},
+	{"CAPITAL GAIN", CapitalGainDistribution},
+	{"IN LIEU", NonQualified},
+	{"ORDINARY", NonQualified},
+}
+
+// tickerInDescriptionPattern finds a ticker given in parentheses in a
+// description, eg "QUALIFIED DIVIDEND (MSFT)"
+var tickerInDescriptionPattern = regexp.MustCompile(`\(([A-Z][A-Z0-9.]{0,5})\)`)
+
+// classifyDividendTDA determines the tax class of a TDA dividend from
+// its description
+func classifyDividendTDA(description string) DividendClass {
+	upper := strings.ToUpper(description)
+	for i := 0; i < len(tdaDividendRules); i++ {
+		rule := tdaDividendRules[i]
+		if strings.Contains(upper, rule.pattern) {
+			return rule.class
+		}
+	}
+	return NonQualified
+}
+
+// tickerFromDescription returns the ticker referenced in a transaction
+// description, or a blank string if there isn't one
+func tickerFromDescription(description string) string {
+	m := tickerInDescriptionPattern.FindStringSubmatch(strings.ToUpper(description))
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// ApplyDividendOverrides reclassifies dividends using a mapping of symbol
+// to dividend class. this is used to correct the class of dividends that
+// can't be determined from the description alone, eg ordinary dividends
+// that are known to be qualified.
+func ApplyDividendOverrides(trans []*Trade, overrides map[string]DividendClass) {
+	if len(overrides) == 0 {
+		return
+	}
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if t.Type != Dividend {
+			continue
+		}
+		if class, ok := overrides[strings.TrimSpace(t.Symbol)]; ok {
+			t.DividendClass = class
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package trade
+
+import "testing"
+
+func TestClassifyDividendTDA(t *testing.T) {
+	tests := []struct {
+		description string
+		want        DividendClass
+	}{
+		{"QUALIFIED DIVIDEND (MSFT)", Qualified},
+		{"NON-QUALIFIED DIVIDEND (T)", NonQualified},
+		{"NON QUALIFIED DIVIDEND (T)", NonQualified},
+		{"NONQUALIFIED DIVIDEND (T)", NonQualified},
+		{"LONG TERM GAIN DISTRIBUTION (VTI)", CapitalGainDistribution},
+		{"CAPITAL GAIN DISTRIBUTION (VTI)", CapitalGainDistribution},
+		{"SHORT TERM CAPITAL GAIN (VTI)", NonQualified},
+		{"SHORT-TERM CAPITAL GAIN DISTRIBUTION (VTI)", NonQualified},
+		{"RETURN OF CAPITAL (O)", ReturnOfCapital},
+		{"ORDINARY DIVIDEND (AAPL)", NonQualified},
+	}
+	for i := 0; i < len(tests); i++ {
+		tt := tests[i]
+		if got := classifyDividendTDA(tt.description); got != tt.want {
+			t.Errorf("classifyDividendTDA(%q) = %v, want %v", tt.description, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package trade
+
+import (
+	"math/big"
+	"strings"
+)
+
+// dripTolerance is how far apart a dividend and a purchase of the paying
+// symbol can be and still be considered a reinvestment of the dividend
+var dripTolerance = big.NewFloat(0.01)
+
+// DetectReinvestments flags purchases that reinvest a dividend. a purchase
+// is a reinvestment if its description says so, or if it buys the paying
+// symbol on the same day for the same amount as a dividend. reinvestments
+// open a new lot in the paying position, so their effect is set to Open.
+func DetectReinvestments(trans []*Trade) {
+	dividends := make(map[string][]*Trade)
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if t.Type == Dividend {
+			key := reinvestmentKey(t)
+			dividends[key] = append(dividends[key], t)
+		}
+	}
+
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if t.Type != Buy {
+			continue
+		}
+
+		if strings.Contains(strings.ToUpper(t.Description), "REINVEST") {
+			t.Reinvestment = true
+			t.Effect = Open
+			continue
+		}
+
+		paid := dividends[reinvestmentKey(t)]
+		for j := 0; j < len(paid); j++ {
+			diff := big.NewFloat(0.0).Add(paid[j].Amount, t.Amount)
+			if diff.Abs(diff).Cmp(dripTolerance) <= 0 {
+				t.Reinvestment = true
+				t.Effect = Open
+				break
+			}
+		}
+	}
+}
+
+// reinvestmentKey groups a dividend with purchases made on the same day,
+// in the same account, of the same symbol
+func reinvestmentKey(t *Trade) string {
+	return t.Account + "|" + strings.TrimSpace(t.Symbol) + "|" + t.Date.Format("2006-01-02")
+}
@@ -0,0 +1,36 @@
+package trade
+
+import "strings"
+
+// PositionEffect identifies whether a trade opens or closes a position
+type PositionEffect string
+
+const (
+	Open    PositionEffect = "OPEN"
+	Close   PositionEffect = "CLOSE"
+	Unknown PositionEffect = "" // the broker didn't say, eg a plain stock buy or sale
+)
+
+// positionEffectTDA determines whether a TDA trade opens or closes a
+// position from its description, eg "Sold Short", "Bought To Cover" or
+// "Sold to Open"
+func positionEffectTDA(description string) PositionEffect {
+	upper := strings.ToUpper(description)
+	switch {
+	case strings.Contains(upper, "TO OPEN"), strings.HasPrefix(upper, "SOLD SHORT"):
+		return Open
+	case strings.Contains(upper, "TO CLOSE"), strings.Contains(upper, "TO COVER"):
+		return Close
+	}
+	return Unknown
+}
+
+// IsShortSale returns true if the trade opens a short position
+func (t *Trade) IsShortSale() bool {
+	return t.Type == Sell && t.Effect == Open
+}
+
+// IsBuyToCover returns true if the trade closes a short position
+func (t *Trade) IsBuyToCover() bool {
+	return t.Type == Buy && t.Effect == Close
+}
@@ -0,0 +1,78 @@
+package trade
+
+import (
+	"math/big"
+	"strings"
+)
+
+// Fees breaks down the charges on a transaction beyond the commission.
+// brokers report these differently, so any component a broker doesn't
+// itemize is left at zero.
+type Fees struct {
+	RegFee        *big.Float // regulatory fee as reported by TDA, which combines the SEC fee and TAF
+	ADRFee        *big.Float // custody fee charged by ADR depositary banks
+	RedemptionFee *big.Float // short term redemption, fund redemption and deferred sales charges
+	AccountFee    *big.Float // account level fees such as wire fees
+	NetworkFee    *big.Float // crypto network fees and exchange spread
+}
+
+// newFees returns a new instance of a fees struct with every component zeroed out
+func newFees() *Fees {
+	return &Fees{
+		RegFee:        big.NewFloat(0.0),
+		ADRFee:        big.NewFloat(0.0),
+		RedemptionFee: big.NewFloat(0.0),
+		AccountFee:    big.NewFloat(0.0),
+		NetworkFee:    big.NewFloat(0.0),
+	}
+}
+
+// newFeesTDA builds the fee breakdown for a row of a TDA transaction log.
+// TDA reports the regulatory fee and redemption charges in their own
+// columns, while ADR and wire fees show up as separate transactions whose
+// amount is the fee.
+func newFeesTDA(r []string, tradeType TradeType, amount *big.Float) *Fees {
+	f := newFees()
+	if len(r) > 8 {
+		f.RegFee = parseDecimal(r[8])
+	}
+	// short term redemption fee, fund redemption fee and deferred sales charge
+	for i := 9; i < len(r) && i < 12; i++ {
+		f.RedemptionFee = f.RedemptionFee.Add(f.RedemptionFee, parseDecimal(r[i]))
+	}
+
+	if tradeType == Fee {
+		// fee transactions are debits, flip the sign to report the cost
+		cost := big.NewFloat(0.0).Neg(amount)
+		if strings.Contains(strings.ToUpper(r[2]), "ADR") {
+			f.ADRFee = cost
+		} else {
+			f.AccountFee = cost
+		}
+	}
+	return f
+}
+
+// Total returns the sum of every fee component
+func (f *Fees) Total() *big.Float {
+	total := big.NewFloat(0.0)
+	total = total.Add(total, f.RegFee)
+	total = total.Add(total, f.ADRFee)
+	total = total.Add(total, f.RedemptionFee)
+	total = total.Add(total, f.AccountFee)
+	total = total.Add(total, f.NetworkFee)
+	return total
+}
+
+// scaled returns a copy of the fees with every component multiplied by a
+// factor, eg an exchange rate
+func (f *Fees) scaled(factor *big.Float) *Fees {
+	s := Fees{
+		RegFee:        big.NewFloat(0.0).Mul(f.RegFee, factor),
+		ADRFee:        big.NewFloat(0.0).Mul(f.ADRFee, factor),
+		RedemptionFee: big.NewFloat(0.0).Mul(f.RedemptionFee, factor),
+		AccountFee:    big.NewFloat(0.0).Mul(f.AccountFee, factor),
+		NetworkFee:    big.NewFloat(0.0).Mul(f.NetworkFee, factor),
+	}
+	return &s
+}
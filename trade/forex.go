@@ -0,0 +1,90 @@
+package trade
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Forex identifies currency pair instruments
+const Forex AssetClass = "FOREX"
+
+// CurrencyPair holds the currencies of a forex pair. the price of a pair
+// is the amount of the quote currency one unit of the base currency buys.
+type CurrencyPair struct {
+	Base  string // currency being bought or sold, eg EUR in EUR/USD
+	Quote string // currency the pair is priced in, eg USD in EUR/USD
+}
+
+// fiatCurrencies are the ISO currency codes recognized as forex pair legs
+var fiatCurrencies = map[string]bool{
+	"USD": true,
+	"EUR": true,
+	"JPY": true,
+	"GBP": true,
+	"CHF": true,
+	"CAD": true,
+	"AUD": true,
+	"NZD": true,
+	"SEK": true,
+	"NOK": true,
+	"DKK": true,
+	"HKD": true,
+	"SGD": true,
+	"MXN": true,
+	"ZAR": true,
+	"CNH": true,
+}
+
+// currencyPairPattern matches pairs written as EUR/USD or EUR.USD
+var currencyPairPattern = regexp.MustCompile(`^([A-Z]{3})[/.]([A-Z]{3})$`)
+
+// ParseCurrencyPair parses a forex symbol such as EUR/USD into its base
+// and quote currencies. an error is returned if either leg isn't a
+// recognized fiat currency.
+func ParseCurrencyPair(s string) (*CurrencyPair, error) {
+	m := currencyPairPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil || !fiatCurrencies[m[1]] || !fiatCurrencies[m[2]] {
+		return nil, fmt.Errorf("not a currency pair: %q", s)
+	}
+	return &CurrencyPair{Base: m[1], Quote: m[2]}, nil
+}
+
+// PipSize returns the price increment of one pip for the pair. pairs
+// quoted in yen are priced to 2 decimal places, everything else to 4.
+func (c *CurrencyPair) PipSize() *big.Float {
+	if c.Quote == "JPY" {
+		return big.NewFloat(0.01)
+	}
+	return big.NewFloat(0.0001)
+}
+
+// String returns the pair in BASE/QUOTE form
+func (c *CurrencyPair) String() string {
+	return c.Base + "/" + c.Quote
+}
+
+// Pips converts a change in price of a forex instrument to a number of pips.
+// zero is returned for instruments that aren't currency pairs.
+func (i *Instrument) Pips(priceChange *big.Float) *big.Float {
+	pips := big.NewFloat(0.0)
+	if i.Class != Forex || priceChange == nil {
+		return pips
+	}
+	pair := CurrencyPair{Base: i.BaseCurrency, Quote: i.Currency}
+	return pips.Quo(priceChange, pair.PipSize())
+}
+
+// PipValue returns the value of a one pip move, in the quote currency,
+// for the given quantity of the base currency. zero is returned for
+// instruments that aren't currency pairs.
+func (i *Instrument) PipValue(quantity *big.Float) *big.Float {
+	value := big.NewFloat(0.0)
+	if i.Class != Forex || quantity == nil {
+		return value
+	}
+	pair := CurrencyPair{Base: i.BaseCurrency, Quote: i.Currency}
+	value = value.Mul(quantity, pair.PipSize())
+	return value.Abs(value)
+}
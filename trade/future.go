@@ -0,0 +1,133 @@
+package trade
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FutureSymbol holds the details encoded in a futures contract symbol
+type FutureSymbol struct {
+	Root  string     // product root symbol including the leading '/', eg /ES
+	Month time.Month // contract month, zero if the symbol doesn't specify one
+	Year  int        // contract year, zero if the symbol doesn't specify one
+}
+
+// futureMonthCodes maps the standard futures month codes to the
+// contract month they represent
+var futureMonthCodes = map[byte]time.Month{
+	'F': time.January,
+	'G': time.February,
+	'H': time.March,
+	'J': time.April,
+	'K': time.May,
+	'M': time.June,
+	'N': time.July,
+	'Q': time.August,
+	'U': time.September,
+	'V': time.October,
+	'X': time.November,
+	'Z': time.December,
+}
+
+// futureMultipliers holds the contract multipliers (dollars per point)
+// of commonly traded futures products, keyed by root symbol
+var futureMultipliers = map[string]float64{
+	"/ES":  50,
+	"/MES": 5,
+	"/NQ":  20,
+	"/MNQ": 2,
+	"/RTY": 50,
+	"/M2K": 5,
+	"/YM":  5,
+	"/MYM": 0.5,
+	"/CL":  1000,
+	"/MCL": 100,
+	"/NG":  10000,
+	"/GC":  100,
+	"/MGC": 10,
+	"/SI":  5000,
+	"/HG":  25000,
+	"/ZB":  1000,
+	"/ZN":  1000,
+	"/ZF":  1000,
+	"/ZT":  2000,
+	"/ZC":  50,
+	"/ZS":  50,
+	"/ZW":  50,
+	"/6E":  125000,
+	"/6J":  12500000,
+	"/VX":  1000,
+}
+
+// futureContractPattern matches a futures symbol with a contract month,
+// eg /ESH24, /MESh24 or /CLZ4
+var futureContractPattern = regexp.MustCompile(`^(/[A-Z0-9]{1,4}?)([FGHJKMNQUVXZ])(\d{1,2})$`)
+
+// futureRootPattern matches a futures symbol without a contract month, eg /ES
+var futureRootPattern = regexp.MustCompile(`^/[A-Z0-9]{1,4}$`)
+
+// ParseFutureSymbol parses a futures symbol into its root and contract month.
+// both the bare root (/ES) and the root with a month code and year (/ESH24,
+// /MESh24) are accepted. single digit years are resolved to the year
+// ending in that digit closest to date, the date the symbol was traded on.
+func ParseFutureSymbol(s string, date time.Time) (*FutureSymbol, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	if m := futureContractPattern.FindStringSubmatch(upper); m != nil {
+		year, err := strconv.Atoi(m[3])
+		if err != nil {
+			return nil, err
+		}
+		if len(m[3]) == 1 {
+			year = resolveSingleDigitYear(year, date)
+		} else {
+			year += 2000
+		}
+		return &FutureSymbol{
+			Root:  m[1],
+			Month: futureMonthCodes[m[2][0]],
+			Year:  year}, nil
+	}
+
+	if futureRootPattern.MatchString(upper) {
+		return &FutureSymbol{Root: upper}, nil
+	}
+
+	return nil, fmt.Errorf("not a futures symbol: %q", s)
+}
+
+// resolveSingleDigitYear returns the year ending in the given digit
+// that is closest to the year of date
+func resolveSingleDigitYear(digit int, date time.Time) int {
+	year := (date.Year()/10)*10 + digit
+	if year-date.Year() > 5 {
+		year -= 10
+	} else if date.Year()-year > 5 {
+		year += 10
+	}
+	return year
+}
+
+// Multiplier returns the contract multiplier for the futures product.
+// unknown products are given a multiplier of 1.
+func (f *FutureSymbol) Multiplier() *big.Float {
+	if m, ok := futureMultipliers[f.Root]; ok {
+		return big.NewFloat(m)
+	}
+	return big.NewFloat(1.0)
+}
+
+// Expiration returns the expiration date of the contract, approximated as
+// the third friday of the contract month. the zero time is returned if the
+// symbol doesn't specify a contract month.
+func (f *FutureSymbol) Expiration() time.Time {
+	if f.Year == 0 || f.Month == 0 {
+		return time.Time{}
+	}
+	first := time.Date(f.Year, f.Month, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(time.Friday) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, offset+14)
+}
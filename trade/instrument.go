@@ -0,0 +1,180 @@
+package trade
+
+import (
+	"math/big"
+	"strings"
+	"time"
+)
+
+// AssetClass identifies the type of security an instrument represents
+type AssetClass string
+
+const (
+	Equity AssetClass = "EQUITY"
+	Option AssetClass = "OPTION"
+	Future AssetClass = "FUTURE"
+	Crypto AssetClass = "CRYPTO"
+	Bond   AssetClass = "BOND"
+
+	MutualFund      AssetClass = "MUTUAL_FUND"
+	MoneyMarketFund AssetClass = "MONEY_MARKET_FUND"
+)
+
+// defaultCurrency is the currency instruments are assumed to be
+// denominated in when the source doesn't say otherwise
+const defaultCurrency = "USD"
+
+// cryptoSymbols are the crypto currency tickers recognized when
+// classifying a symbol quoted against USD, eg BTC-USD. the bare tickers
+// aren't recognized, since some are also listed equities (eg LTC), and
+// trades from crypto brokers are classified by the broker instead.
+var cryptoSymbols = map[string]bool{
+	"BTC":  true,
+	"ETH":  true,
+	"LTC":  true,
+	"BCH":  true,
+	"SOL":  true,
+	"ADA":  true,
+	"DOGE": true,
+	"XRP":  true,
+	"DOT":  true,
+	"USDC": true,
+}
+
+// Instrument describes the security a trade was made in along with the
+// attributes needed to value it.
+type Instrument struct {
+	Class      AssetClass // type of security
+	Symbol     string     // symbol as it appears in the transaction log
+	Underlying string     // symbol of the underlying security, same as Symbol for equities
+	Multiplier *big.Float // units of the underlying controlled by one unit of quantity
+	Expiration time.Time  // expiration date, zero for instruments that don't expire
+	Strike     *big.Float // strike price, nil for anything but options
+	OptionType OptionType // put or call, blank for anything but options
+	Currency   string     // currency the instrument is priced in
+	// CouponRate is the annual coupon of a bond as a percentage of face
+	// value, nil for anything but bonds
+	CouponRate *big.Float
+
+	// BaseCurrency is the currency bought or sold by a forex pair, blank
+	// for anything but forex
+	BaseCurrency string
+}
+
+// NewInstrument classifies a symbol and returns the instrument it
+// represents. unrecognized symbols are treated as equities.
+func NewInstrument(symbol string) *Instrument {
+	return NewInstrumentOn(symbol, time.Now())
+}
+
+// NewInstrumentOn classifies a symbol traded on date. the date decides the
+// contract year of futures symbols with a single digit year, eg /ESH9.
+func NewInstrumentOn(symbol string, date time.Time) *Instrument {
+	trimmed := strings.TrimSpace(symbol)
+	i := Instrument{
+		Class:      Equity,
+		Symbol:     trimmed,
+		Underlying: trimmed,
+		Multiplier: big.NewFloat(1.0),
+		Currency:   defaultCurrency,
+	}
+
+	if o, err := ParseOptionSymbol(trimmed); err == nil {
+		i.Class = Option
+		i.Underlying = o.Underlying
+		i.Multiplier = big.NewFloat(100.0)
+		i.Expiration = o.Expiration
+		i.Strike = o.Strike
+		i.OptionType = o.Type
+		return &i
+	}
+
+	if isCUSIP(strings.ToUpper(trimmed)) {
+		i.Class = Bond
+		// bonds are priced as a percentage of face value
+		i.Multiplier = big.NewFloat(1.0 / bondPricePer)
+		return &i
+	}
+
+	if f, err := ParseFutureSymbol(trimmed, date); err == nil {
+		i.Class = Future
+		i.Underlying = f.Root
+		i.Multiplier = f.Multiplier()
+		i.Expiration = f.Expiration()
+		return &i
+	}
+
+	if c, err := ParseCurrencyPair(trimmed); err == nil {
+		i.Class = Forex
+		i.Underlying = c.String()
+		i.Currency = c.Quote
+		i.BaseCurrency = c.Base
+		return &i
+	}
+
+	if isMoneyMarketSymbol(trimmed) {
+		i.Class = MoneyMarketFund
+		return &i
+	}
+
+	if isMutualFundSymbol(trimmed) {
+		i.Class = MutualFund
+		return &i
+	}
+
+	if isCryptoSymbol(trimmed) {
+		i.Class = Crypto
+		i.Underlying = strings.TrimSuffix(strings.ToUpper(trimmed), "-USD")
+		return &i
+	}
+
+	return &i
+}
+
+// isCryptoSymbol returns true if the symbol is a recognized crypto currency
+// quoted against USD
+func isCryptoSymbol(s string) bool {
+	upper := strings.ToUpper(s)
+	return strings.HasSuffix(upper, "-USD") && cryptoSymbols[strings.TrimSuffix(upper, "-USD")]
+}
+
+// isMoneyMarketSymbol returns true for money market fund tickers, which
+// are 5 letters ending in XX (eg SWVXX), and TDA's bank sweep vehicles
+// (eg MMDA1)
+func isMoneyMarketSymbol(s string) bool {
+	upper := strings.ToUpper(s)
+	if strings.HasPrefix(upper, "MMDA") {
+		return true
+	}
+	return len(upper) == 5 && isAlpha(upper) && strings.HasSuffix(upper, "XX")
+}
+
+// isMutualFundSymbol returns true for mutual fund tickers, which are
+// 5 letters ending in X (eg VFIAX)
+func isMutualFundSymbol(s string) bool {
+	upper := strings.ToUpper(s)
+	return len(upper) == 5 && isAlpha(upper) && strings.HasSuffix(upper, "X")
+}
+
+// isAlpha returns true if the string only contains the letters A-Z
+func isAlpha(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Notional returns the value of a quantity of the instrument at the given
+// price, accounting for the contract multiplier. the result is always
+// positive.
+func (i *Instrument) Notional(quantity *big.Float, price *big.Float) *big.Float {
+	notional := big.NewFloat(0.0)
+	if quantity == nil || price == nil {
+		return notional
+	}
+	notional = notional.Mul(quantity, price)
+	notional = notional.Mul(notional, i.Multiplier)
+	return notional.Abs(notional)
+}
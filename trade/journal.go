@@ -0,0 +1,61 @@
+package trade
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+)
+
+// Annotation is a trade journal entry holding the notes a trader keeps
+// about a transaction or a round trip trade
+type Annotation struct {
+	ID       string   `json:"id"`       // transaction id or round trip id the entry is about
+	Notes    string   `json:"notes"`    // free text notes
+	Strategy string   `json:"strategy"` // strategy label, eg "breakout" or "wheel"
+	Links    []string `json:"links"`    // links to charts, articles, etc..
+	// Risk is the dollar amount put at risk by a round trip, and Stop
+	// the stop price it was entered with. either sets the initial risk
+	// R-multiples are measured in, with Risk taking precedence.
+	Risk float64 `json:"risk"`
+	Stop float64 `json:"stop"`
+}
+
+// TradeJournal is a collection of journal entries keyed by the id they annotate
+type TradeJournal map[string]*Annotation
+
+// LoadTradeJournal reads a list of journal entries from a json file
+func LoadTradeJournal(path string) (TradeJournal, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Annotation
+	if err := json.Unmarshal(bytes, &entries); err != nil {
+		return nil, err
+	}
+
+	j := make(TradeJournal)
+	for i := 0; i < len(entries); i++ {
+		e := entries[i]
+		j[strings.TrimSpace(e.ID)] = e
+	}
+	return j, nil
+}
+
+// Lookup returns the journal entry for an id, or nil if there isn't one
+func (j TradeJournal) Lookup(id string) *Annotation {
+	if j == nil || id == "" {
+		return nil
+	}
+	return j[id]
+}
+
+// Annotate attaches the journal entry of each transaction to it
+func (j TradeJournal) Annotate(trans []*Trade) {
+	for i := 0; i < len(trans); i++ {
+		if e := j.Lookup(trans[i].ID); e != nil {
+			trans[i].Journal = e
+		}
+	}
+}
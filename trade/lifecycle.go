@@ -0,0 +1,152 @@
+package trade
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+)
+
+const (
+	// Expiration is the removal of an option that expired worthless
+	Expiration TradeType = "EXPIRATION"
+	// Assignment is the removal of a short option that was assigned
+	Assignment TradeType = "ASSIGNMENT"
+	// Exercise is the removal of a long option that was exercised
+	Exercise TradeType = "EXERCISE"
+)
+
+// isOptionEvent returns true if the transaction removes an option
+// from the account because it expired, was assigned or was exercised
+func (t *Trade) isOptionEvent() bool {
+	return t.Type == Expiration || t.Type == Assignment || t.Type == Exercise
+}
+
+// LinkOptionEvents links option expirations, assignments and exercises to
+// the option position they close and the stock trade they result in.
+//
+// brokers don't say whether an option removal closes a long or short
+// position, so the quantity of each event is set to offset the open option
+// position. for assignments and exercises, the premium of the closed
+// contracts, out of the position still open at the event, is moved onto
+// the resulting stock trade, which per standard
+// tax treatment adjusts the cost basis of shares bought or the proceeds
+// of shares sold.
+func LinkOptionEvents(trans []*Trade) {
+	ordered := make([]*Trade, len(trans))
+	copy(ordered, trans)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Date.Before(ordered[j].Date)
+	})
+
+	for i := 0; i < len(ordered); i++ {
+		event := ordered[i]
+		if !event.isOptionEvent() || event.Instrument == nil || event.Instrument.Class != Option {
+			continue
+		}
+
+		// total up the open position and premium of the option before the event
+		position := big.NewFloat(0.0)
+		premium := big.NewFloat(0.0)
+		for j := 0; j < i; j++ {
+			t := ordered[j]
+			if t.Account != event.Account || strings.TrimSpace(t.Symbol) != strings.TrimSpace(event.Symbol) {
+				continue
+			}
+			openPremium(position, premium, t)
+		}
+		// guard clause: nothing open to close
+		if position.Sign() == 0 {
+			continue
+		}
+
+		openContracts := big.NewFloat(0.0).Abs(position)
+		removed := big.NewFloat(0.0).Abs(event.Quantity)
+		if removed.Sign() == 0 || removed.Cmp(openContracts) > 0 {
+			removed = openContracts
+		}
+		event.Quantity = big.NewFloat(0.0).Copy(removed)
+		if position.Sign() > 0 {
+			event.Quantity = event.Quantity.Neg(event.Quantity)
+		}
+
+		if event.Type == Expiration {
+			continue
+		}
+
+		stock := findOptionDelivery(ordered, event, removed, position.Sign() < 0)
+		if stock == nil {
+			continue
+		}
+
+		// only the premium of the contracts removed moves to the stock trade
+		moved := big.NewFloat(0.0).Quo(removed, openContracts)
+		moved = moved.Mul(moved, premium)
+		stock.Amount = stock.Amount.Add(stock.Amount, moved)
+		stock.PremiumAdjustment = moved
+		stock.DeliveredFrom = strings.TrimSpace(event.Symbol)
+		event.Amount = big.NewFloat(0.0).Sub(event.Amount, moved)
+	}
+}
+
+// openPremium adds a transaction to the open position of an option and the
+// premium of the contracts still open. trades closing contracts take their
+// share of the premium with them, so the premium of round trips already
+// closed isn't counted, and a trade that flips the position opens the new
+// one with its share of the trade's cash.
+func openPremium(position *big.Float, premium *big.Float, t *Trade) {
+	if position.Sign() == 0 || position.Sign() == t.Quantity.Sign() {
+		position.Add(position, t.Quantity)
+		premium.Add(premium, t.Amount)
+		return
+	}
+	open := big.NewFloat(0.0).Abs(position)
+	closed := big.NewFloat(0.0).Abs(t.Quantity)
+	if closed.Cmp(open) >= 0 {
+		premium.SetInt64(0)
+	} else {
+		kept := big.NewFloat(0.0).Sub(open, closed)
+		premium.Mul(premium, kept.Quo(kept, open))
+	}
+	position.Add(position, t.Quantity)
+	if position.Sign() != 0 && closed.Cmp(open) > 0 {
+		opened := big.NewFloat(0.0).Sub(closed, open)
+		opened = opened.Quo(opened, closed)
+		premium.Mul(opened, t.Amount)
+	}
+}
+
+// findOptionDelivery finds the stock trade that delivered shares for an
+// assigned or exercised option. nil is returned if there isn't one.
+func findOptionDelivery(ordered []*Trade, event *Trade, contracts *big.Float, short bool) *Trade {
+	shares := big.NewFloat(0.0).Mul(contracts, event.Instrument.Multiplier)
+
+	// short puts and long calls buy the shares, short calls and long puts sell them
+	wantType := Sell
+	if (event.Instrument.OptionType == Put) == short {
+		wantType = Buy
+	}
+
+	for i := 0; i < len(ordered); i++ {
+		t := ordered[i]
+		if t.Type != wantType || t.DeliveredFrom != "" || t.Account != event.Account {
+			continue
+		}
+		if t.Instrument == nil || t.Instrument.Class != Equity || t.Instrument.Underlying != event.Instrument.Underlying {
+			continue
+		}
+		if !sameDay(t, event) {
+			continue
+		}
+		if big.NewFloat(0.0).Abs(t.Quantity).Cmp(shares) == 0 {
+			return t
+		}
+	}
+	return nil
+}
+
+// sameDay returns true if both transactions happened on the same date
+func sameDay(a *Trade, b *Trade) bool {
+	ay, am, ad := a.Date.Date()
+	by, bm, bd := b.Date.Date()
+	return ay == by && am == bm && ad == bd
+}
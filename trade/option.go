@@ -0,0 +1,120 @@
+package trade
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// OptionType identifies an option contract as either a put or a call
+type OptionType string
+
+const (
+	Call OptionType = "CALL"
+	Put  OptionType = "PUT"
+)
+
+// OptionSymbol holds the details encoded in an option contract symbol
+type OptionSymbol struct {
+	Underlying string     // ticker symbol of the underlying security
+	Expiration time.Time  // expiration date of the contract
+	Strike     *big.Float // strike price of the contract
+	Type       OptionType // put or call
+}
+
+// occPattern matches OCC formatted option symbols, eg SPY240621C00450000.
+// the root symbol may be padded with spaces out to 6 characters and TDA
+// sometimes prefixes the symbol with a '.'
+var occPattern = regexp.MustCompile(`^\.?([A-Z0-9.]{1,6})\s*(\d{6})([CP])(\d{8})$`)
+
+// ParseOptionSymbol parses an option contract symbol into its underlying,
+// expiration, strike and put/call components.
+//
+// two formats are supported:
+//   - OCC format, eg "SPY240621C00450000"
+//   - TDA's space delimited format, eg "SPY Jun 21 2024 450.0 Call"
+//
+// an error is returned if the symbol is not in a recognized option format
+func ParseOptionSymbol(s string) (*OptionSymbol, error) {
+	trimmed := strings.TrimSpace(s)
+	if m := occPattern.FindStringSubmatch(strings.ToUpper(trimmed)); m != nil {
+		return parseOCC(m)
+	}
+	return parseTDAOption(trimmed)
+}
+
+// parseOCC builds an OptionSymbol from the submatches of occPattern
+func parseOCC(m []string) (*OptionSymbol, error) {
+	expiration, err := time.Parse("060102", m[2])
+	if err != nil {
+		return nil, err
+	}
+
+	// OCC strikes are the strike price * 1000, zero padded to 8 digits
+	strike, _, err := big.ParseFloat(m[4], 10, 53, big.ToNearestEven)
+	if err != nil {
+		return nil, err
+	}
+	strike = strike.Quo(strike, big.NewFloat(1000.0))
+
+	optionType := Call
+	if m[3] == "P" {
+		optionType = Put
+	}
+
+	return &OptionSymbol{
+		Underlying: m[1],
+		Expiration: expiration,
+		Strike:     strike,
+		Type:       optionType}, nil
+}
+
+// parseTDAOption parses TDA's space delimited option symbol format,
+// eg "SPY Jun 21 2024 450.0 Call"
+func parseTDAOption(s string) (*OptionSymbol, error) {
+	parts := strings.Fields(s)
+	if len(parts) != 6 {
+		return nil, fmt.Errorf("not an option symbol: %q", s)
+	}
+
+	expiration, err := time.Parse("Jan 2 2006", strings.Join(parts[1:4], " "))
+	if err != nil {
+		return nil, fmt.Errorf("invalid expiration in option symbol %q: %v", s, err)
+	}
+
+	strike, _, err := big.ParseFloat(parts[4], 10, 53, big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("invalid strike in option symbol %q: %v", s, err)
+	}
+
+	var optionType OptionType
+	switch strings.ToUpper(parts[5]) {
+	case "CALL", "C":
+		optionType = Call
+	case "PUT", "P":
+		optionType = Put
+	default:
+		return nil, fmt.Errorf("invalid put/call in option symbol %q", s)
+	}
+
+	return &OptionSymbol{
+		Underlying: parts[0],
+		Expiration: expiration,
+		Strike:     strike,
+		Type:       optionType}, nil
+}
+
+// GetUnderlyingSymbol returns the ticker symbol of the underlying security
+// for an option symbol, or the product root for a futures contract.
+// symbols that are neither are returned as is.
+func GetUnderlyingSymbol(s string) string {
+	if o, err := ParseOptionSymbol(s); err == nil {
+		return o.Underlying
+	}
+	if f, err := ParseFutureSymbol(s, time.Now()); err == nil {
+		return f.Root
+	}
+	return strings.Split(strings.TrimSpace(s), " ")[0]
+}
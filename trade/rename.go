@@ -0,0 +1,49 @@
+package trade
+
+import "strings"
+
+// resolveRename follows a chain of symbol renames (eg a ticker that changed
+// twice) to the current symbol. the symbol is returned as is if it was
+// never renamed.
+func resolveRename(symbol string, renames map[string]string) string {
+	current := symbol
+	// bounded by the number of renames so a cycle in the map can't loop forever
+	for i := 0; i < len(renames); i++ {
+		next, ok := renames[current]
+		if !ok || next == current {
+			break
+		}
+		current = next
+	}
+	return current
+}
+
+// ApplySymbolRenames rewrites the symbols of transactions made under a
+// ticker that has since changed (eg FB to META) so they are grouped with
+// transactions made under the new ticker. the renames map old symbols to
+// new ones. options on a renamed underlying are rewritten as well.
+func ApplySymbolRenames(trans []*Trade, renames map[string]string) {
+	if len(renames) == 0 {
+		return
+	}
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if t.Instrument == nil {
+			continue
+		}
+		oldUnderlying := t.Instrument.Underlying
+		newUnderlying := resolveRename(oldUnderlying, renames)
+		if newUnderlying == oldUnderlying {
+			continue
+		}
+
+		symbol := strings.TrimSpace(t.Symbol)
+		if strings.HasPrefix(symbol, oldUnderlying) {
+			// option symbols start with the underlying in both TDA and OCC formats
+			symbol = newUnderlying + strings.TrimPrefix(symbol, oldUnderlying)
+		}
+		t.Symbol = symbol
+		t.Instrument.Symbol = symbol
+		t.Instrument.Underlying = newUnderlying
+	}
+}
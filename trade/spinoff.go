@@ -0,0 +1,148 @@
+package trade
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// SpinOff is the receipt of shares in a company spun off from a held position
+	SpinOff TradeType = "SPIN_OFF"
+	// StockDividend is the receipt of additional shares paid as a dividend
+	StockDividend TradeType = "STOCK_DIVIDEND"
+)
+
+// SpinOffEvent describes a spin-off so that cost basis can be moved from
+// the parent position to the shares received in the spun off company
+type SpinOffEvent struct {
+	Parent string `json:"parent"` // symbol of the company shares were held in
+	Child  string `json:"child"`  // symbol of the spun off company
+	Date   string `json:"date"`   // distribution date in YYYY-MM-DD format
+	// Allocation is the fraction of the parent's cost basis allocated to
+	// the child shares, eg 0.2 moves 20% of the basis to the child
+	Allocation float64 `json:"allocation"`
+}
+
+// isShareReceipt returns true for transactions that add shares to a
+// position without a purchase
+func (t *Trade) isShareReceipt() bool {
+	return t.Type == StockSplit || t.Type == SpinOff || t.Type == StockDividend
+}
+
+// parentBasis returns the average cost basis of the parent shares held in
+// an account just before the given date, as a positive amount.
+func parentBasis(trans []*Trade, account string, parent string, date time.Time) *big.Float {
+	parentTrans := make([]*Trade, 0)
+	for i := 0; i < len(trans); i++ {
+		t := trans[i]
+		if t.Account == account && strings.TrimSpace(t.Symbol) == parent && t.IsTrade() && t.Date.Before(date) {
+			parentTrans = append(parentTrans, t)
+		}
+	}
+	sort.SliceStable(parentTrans, func(i, j int) bool {
+		return parentTrans[i].Date.Before(parentTrans[j].Date)
+	})
+
+	shares := big.NewFloat(0.0)
+	basis := big.NewFloat(0.0)
+	for i := 0; i < len(parentTrans); i++ {
+		t := parentTrans[i]
+		if t.Type == Buy {
+			shares = shares.Add(shares, t.Quantity)
+			basis = basis.Sub(basis, t.Amount)
+			continue
+		}
+		// sales remove basis in proportion to the shares sold
+		if shares.Sign() == 0 {
+			continue
+		}
+		sold := big.NewFloat(0.0).Neg(t.Quantity)
+		removed := big.NewFloat(0.0).Quo(sold, shares)
+		removed = removed.Mul(removed, basis)
+		basis = basis.Sub(basis, removed)
+		shares = shares.Sub(shares, sold)
+	}
+	return basis
+}
+
+// ApplySpinOffs moves cost basis from parent positions to the shares
+// received in each spin-off. the share receipts of the child in each
+// account are given their allocated portion of the parent's basis in that
+// account, and an adjustment transaction crediting the same amount to the
+// parent in the account is appended to the transactions.
+//
+// stock dividends need no adjustment, the shares received are added to the
+// position at no cost which spreads the existing basis across more shares.
+func ApplySpinOffs(trans []*Trade, spinOffs []*SpinOffEvent) ([]*Trade, error) {
+	for i := 0; i < len(spinOffs); i++ {
+		s := spinOffs[i]
+		date, err := time.Parse("2006-01-02", s.Date)
+		if err != nil {
+			return trans, fmt.Errorf("spin-off of %v: %v", s.Child, err)
+		}
+		if s.Allocation < 0 || s.Allocation > 1 {
+			return trans, fmt.Errorf("spin-off of %v: allocation must be between 0 and 1", s.Child)
+		}
+
+		// find the receipts of the child shares in each account
+		accounts := make([]string, 0)
+		receipts := make(map[string][]*Trade)
+		for j := 0; j < len(trans); j++ {
+			t := trans[j]
+			if t.Type == SpinOff && strings.TrimSpace(t.Symbol) == s.Child {
+				if receipts[t.Account] == nil {
+					accounts = append(accounts, t.Account)
+				}
+				receipts[t.Account] = append(receipts[t.Account], t)
+			}
+		}
+		if len(accounts) == 0 {
+			return trans, fmt.Errorf("spin-off of %v: no spin-off transaction found", s.Child)
+		}
+
+		for j := 0; j < len(accounts); j++ {
+			trans = append(trans, allocateSpinOff(trans, s, date, receipts[accounts[j]]))
+		}
+	}
+	return trans, nil
+}
+
+// allocateSpinOff gives the receipts of the child shares in an account the
+// allocated portion of the parent's basis in the account, shared by the
+// quantity received, and returns the adjustment crediting it to the parent
+func allocateSpinOff(trans []*Trade, s *SpinOffEvent, date time.Time, receipts []*Trade) *Trade {
+	receipt := receipts[0]
+	allocated := parentBasis(trans, receipt.Account, s.Parent, date)
+	allocated = allocated.Mul(allocated, big.NewFloat(s.Allocation))
+
+	received := big.NewFloat(0.0)
+	for i := 0; i < len(receipts); i++ {
+		received = received.Add(received, big.NewFloat(0.0).Abs(receipts[i].Quantity))
+	}
+	for i := 0; i < len(receipts); i++ {
+		portion := big.NewFloat(0.0).Copy(allocated)
+		if received.Sign() != 0 && len(receipts) > 1 {
+			portion = portion.Mul(portion, big.NewFloat(0.0).Abs(receipts[i].Quantity))
+			portion = portion.Quo(portion, received)
+		}
+		receipts[i].Amount = portion.Neg(portion)
+	}
+	adjustment := Trade{
+		Broker:      receipt.Broker,
+		Account:     receipt.Account,
+		Date:        receipt.Date,
+		Description: fmt.Sprintf("Spin-off basis allocated to %v", s.Child),
+		Symbol:      s.Parent,
+		Quantity:    big.NewFloat(0.0),
+		Price:       big.NewFloat(0.0),
+		Commission:  big.NewFloat(0.0),
+		Fees:        newFees(),
+		Amount:      big.NewFloat(0.0).Copy(allocated),
+		Type:        SpinOff,
+		Instrument:  NewInstrumentOn(s.Parent, receipt.Date),
+	}
+	return &adjustment
+}
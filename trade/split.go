@@ -0,0 +1,97 @@
+package trade
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StockSplit is the transaction a broker records when shares are
+// added or removed by a split
+const StockSplit TradeType = "STOCK_SPLIT"
+
+// Split describes a stock split so that transactions from before the
+// split can be restated in post split shares.
+type Split struct {
+	Symbol string `json:"symbol"`
+	Date   string `json:"date"`  // effective date of the split in YYYY-MM-DD format
+	Ratio  string `json:"ratio"` // new shares to old shares, eg "4:1" or "1:10" for a reverse split
+}
+
+// splitWindow is how far from the effective date a broker's split
+// transaction can be recorded and still be matched to the split
+const splitWindow = 7 * 24 * time.Hour
+
+// parse returns the effective date and the number of new shares per old share
+func (s *Split) parse() (time.Time, *big.Float, error) {
+	date, err := time.Parse("2006-01-02", s.Date)
+	if err != nil {
+		return date, nil, err
+	}
+
+	parts := strings.Split(s.Ratio, ":")
+	if len(parts) != 2 {
+		return date, nil, fmt.Errorf("invalid split ratio %q", s.Ratio)
+	}
+	newShares, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return date, nil, err
+	}
+	oldShares, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return date, nil, err
+	}
+	if newShares <= 0 || oldShares <= 0 {
+		return date, nil, fmt.Errorf("invalid split ratio %q", s.Ratio)
+	}
+	return date, big.NewFloat(newShares / oldShares), nil
+}
+
+// ApplySplits restates transactions made before each split in post split
+// terms. share quantities are multiplied by the split ratio and prices are
+// divided by it, so cost basis is unchanged while positions line up with
+// shares held after the split. options on the split symbol keep their
+// contract count but have their strike and multiplier adjusted.
+//
+// the broker's own split transaction is zeroed out, since the shares it
+// adds are already accounted for by restating the earlier transactions.
+func ApplySplits(trans []*Trade, splits []*Split) error {
+	for i := 0; i < len(splits); i++ {
+		split := splits[i]
+		date, ratio, err := split.parse()
+		if err != nil {
+			return fmt.Errorf("split of %v: %v", split.Symbol, err)
+		}
+
+		for j := 0; j < len(trans); j++ {
+			t := trans[j]
+			if t.Instrument == nil || t.Instrument.Underlying != split.Symbol {
+				continue
+			}
+
+			if t.Type == StockSplit {
+				if diff := t.Date.Sub(date); diff > -splitWindow && diff < splitWindow {
+					t.Quantity = big.NewFloat(0.0)
+				}
+				continue
+			}
+
+			// guard clause: transactions on or after the split are already in post split terms
+			if !t.Date.Before(date) {
+				continue
+			}
+
+			if t.Instrument.Class == Option {
+				t.Instrument.Strike = big.NewFloat(0.0).Quo(t.Instrument.Strike, ratio)
+				t.Instrument.Multiplier = big.NewFloat(0.0).Mul(t.Instrument.Multiplier, ratio)
+				t.Price = t.Price.Quo(t.Price, ratio)
+				continue
+			}
+			t.Quantity = t.Quantity.Mul(t.Quantity, ratio)
+			t.Price = t.Price.Quo(t.Price, ratio)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package trade
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// TagRule attaches a tag to every transaction matching all of the rule's
+// criteria. blank criteria match every transaction.
+type TagRule struct {
+	Tag         string   `json:"tag"`
+	Symbols     []string `json:"symbols"`     // symbols or underlying symbols to match
+	From        string   `json:"from"`        // first date to match in YYYY-MM-DD format
+	To          string   `json:"to"`          // last date to match in YYYY-MM-DD format
+	Description string   `json:"description"` // regular expression matched against the description
+
+	from        time.Time
+	to          time.Time
+	description *regexp.Regexp
+}
+
+// tagDateFormat is the date format used for dates in tag rules
+const tagDateFormat = "2006-01-02"
+
+// LoadTagRules reads a list of tag rules from a json file
+func LoadTagRules(path string) ([]*TagRule, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*TagRule
+	if err := json.Unmarshal(bytes, &rules); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(rules); i++ {
+		if err := rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("tag rule %q: %v", rules[i].Tag, err)
+		}
+	}
+	return rules, nil
+}
+
+// compile parses the dates and regular expression of a rule
+func (r *TagRule) compile() error {
+	var err error
+	if r.From != "" {
+		if r.from, err = time.Parse(tagDateFormat, r.From); err != nil {
+			return err
+		}
+	}
+	if r.To != "" {
+		if r.to, err = time.Parse(tagDateFormat, r.To); err != nil {
+			return err
+		}
+	}
+	if r.Description != "" {
+		if r.description, err = regexp.Compile(r.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches returns true if the transaction meets every criteria of the rule
+func (r *TagRule) matches(t *Trade) bool {
+	if len(r.Symbols) > 0 {
+		found := false
+		symbol := strings.TrimSpace(t.Symbol)
+		underlying := GetUnderlyingSymbol(symbol)
+		for i := 0; i < len(r.Symbols); i++ {
+			if r.Symbols[i] == symbol || r.Symbols[i] == underlying {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !r.from.IsZero() && t.Date.Before(r.from) {
+		return false
+	}
+	if !r.to.IsZero() && t.Date.After(r.to) {
+		return false
+	}
+	if r.description != nil && !r.description.MatchString(t.Description) {
+		return false
+	}
+	return true
+}
+
+// ApplyTagRules tags every transaction matched by each of the rules
+func ApplyTagRules(trans []*Trade, rules []*TagRule) {
+	for i := 0; i < len(trans); i++ {
+		for j := 0; j < len(rules); j++ {
+			if rules[j].matches(trans[i]) {
+				trans[i].AddTag(rules[j].Tag)
+			}
+		}
+	}
+}
+
+// ApplyTagsFile tags transactions from a sidecar csv file where each row is
+// a transaction id followed by one or more tags. rows referencing unknown
+// transaction ids are ignored.
+func ApplyTagsFile(trans []*Trade, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	byID := make(map[string][]*Trade)
+	for i := 0; i < len(trans); i++ {
+		byID[trans[i].ID] = append(byID[trans[i].ID], trans[i])
+	}
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		matched := byID[strings.TrimSpace(record[0])]
+		for i := 0; i < len(matched); i++ {
+			for j := 1; j < len(record); j++ {
+				matched[i].AddTag(strings.TrimSpace(record[j]))
+			}
+		}
+	}
+	return nil
+}
+
+// AddTag attaches a tag to the transaction. blank and duplicate tags are ignored.
+func (t *Trade) AddTag(tag string) {
+	if tag == "" || t.HasTag(tag) {
+		return
+	}
+	t.Tags = append(t.Tags, tag)
+}
+
+// HasTag returns true if the tag is attached to the transaction
+func (t *Trade) HasTag(tag string) bool {
+	for i := 0; i < len(t.Tags); i++ {
+		if t.Tags[i] == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -1,21 +1,58 @@
 package trade
 
 import (
-	"time"
+	"fmt"
 	"math/big"
 	"strings"
+	"time"
 )
 
+// BrokerTDA identifies transactions imported from TD Ameritrade
+const BrokerTDA = "TDA"
+
 // transaction represents a transaction from a TD Ameritrade
 // account transaction log.
 type Trade struct {
+	Broker      string
+	Account     string // name of the account the transaction was made in
+	ID          string // transaction id assigned by the broker
 	Date        time.Time
 	Description string
 	Quantity    *big.Float
 	Symbol      string
 	Price       *big.Float
 	Commission  *big.Float
+	Fees        *Fees
 	Amount      *big.Float
+	Currency    string        // currency the price, commission and amount are in
+	FXRate      *big.Float    // value of one unit of the native currency in the base currency
+	Native      *NativeValues // amounts in the native currency, nil if already in the base currency
+	Type        TradeType
+	Effect      PositionEffect // whether a buy or sale opens or closes a position
+	Instrument  *Instrument
+	Transfer    TransferMethod // how cash was moved for deposits, withdrawals and journals
+
+	Tags    []string      // user defined labels attached by tag rules
+	Journal *Annotation // trade journal notes about the transaction
+
+	DividendClass DividendClass // tax treatment of a dividend, blank for anything but dividends
+
+	// Reinvestment is true for purchases that reinvest a dividend
+	Reinvestment bool
+
+	// DeliveredFrom is the symbol of the option whose assignment or exercise
+	// resulted in this stock trade, and PremiumAdjustment is the option
+	// premium included in its amount
+	DeliveredFrom     string
+	PremiumAdjustment *big.Float
+
+	FaceValue       *big.Float // par value of a bond position, nil for anything but bonds
+	AccruedInterest *big.Float // accrued interest paid or received on a bond trade
+
+	// ConvertedTo is the purchase of the asset a crypto convert converted
+	// to, recorded by the same row of the transactions file as the sale of
+	// the asset converted from. nil for anything but converts
+	ConvertedTo *Trade
 }
 
 
@@ -23,26 +60,15 @@ type Trade struct {
 // from a csv row in a trade transaction log downloaded from
 // TD Ameritrade.
 func NewTradeTDA(r []string) (*Trade, error) {
-
-	quantity, _, err := big.ParseFloat(r[3], 10, 53, big.ToNearestEven)
-	if err != nil {
-		quantity = big.NewFloat(0) // sane default
+	// guard clause: rows such as the end of file marker don't have all columns
+	if len(r) < 8 {
+		return nil, fmt.Errorf("expected at least 8 columns, found %d", len(r))
 	}
 
-	price, _, err := big.ParseFloat(r[5], 10, 53, big.ToNearestEven)
-	if err != nil {
-		price = big.NewFloat(0)
-	}
-
-	commission, _, err := big.ParseFloat(r[6], 10, 53, big.ToNearestEven)
-	if err != nil {
-		commission = big.NewFloat(0)
-	}
-
-	amount, _, err := big.ParseFloat(r[7], 10, 53, big.ToNearestEven)
-	if err != nil {
-		amount = big.NewFloat(0)
-	}
+	quantity := parseDecimal(r[3])
+	price := parseDecimal(r[5])
+	commission := parseDecimal(r[6])
+	amount := parseDecimal(r[7])
 
 	dtFormat := "01/02/2006"
 	transactionDt, err := time.Parse(dtFormat, r[0])
@@ -50,19 +76,70 @@ func NewTradeTDA(r []string) (*Trade, error) {
 		return nil, err
 	}
 
+	tradeType := classifyTDA(r[2], quantity)
+	symbol := r[4]
+	// bond coupons and redemptions sometimes only reference the bond
+	// in the description, as do some dividends
+	if strings.TrimSpace(symbol) == "" {
+		symbol = cusipFromDescription(r[2])
+	}
+	if strings.TrimSpace(symbol) == "" && tradeType == Dividend {
+		symbol = tickerFromDescription(r[2])
+	}
+
 	t := Trade{
+		Broker:      BrokerTDA,
+		ID:          strings.TrimSpace(r[1]),
 		Date:        transactionDt,
 		Description: r[2],
-		Symbol:      r[4],
+		Symbol:      symbol,
 		Quantity:    quantity,
 		Price:       price,
 		Commission:  commission,
-		Amount:      amount}
-	// make quantity negative if not a 'buy' transaction
-	if !strings.HasPrefix(t.Description, "Bought") {
+		Fees:        newFeesTDA(r, tradeType, amount),
+		Amount:      amount,
+		Currency:    defaultCurrency,
+		Type:        tradeType,
+		Instrument:  NewInstrumentOn(symbol, transactionDt)}
+	// make quantity negative if not a 'buy' transaction or shares received
+	if t.Type != Buy && !t.isShareReceipt() {
 		t.Quantity.Neg(quantity)
 	}
 
+	t.Transfer = transferMethodTDA(t.Description, t.Type)
+	if t.IsTrade() {
+		t.Effect = positionEffectTDA(t.Description)
+	}
+	if t.Type == Dividend {
+		t.DividendClass = classifyDividendTDA(t.Description)
+	}
+
+	if t.Instrument.Class == Bond {
+		t.applyBondDetails()
+	}
+
 	return &t, nil
 
-}
\ No newline at end of file
+}
+
+// parseDecimal parses a numeric column from a transaction log, ignoring
+// thousands separators and currency symbols. blank or invalid values
+// default to zero.
+func parseDecimal(s string) *big.Float {
+	cleaned := strings.NewReplacer(",", "", "$", "", " ", "").Replace(s)
+	f, _, err := big.ParseFloat(cleaned, 10, 53, big.ToNearestEven)
+	if err != nil {
+		return big.NewFloat(0) // sane default
+	}
+	return f
+}
+
+// Notional returns the absolute value of the trade, computed from
+// the quantity and price using the contract multiplier of the
+// instrument traded.
+func (t *Trade) Notional() *big.Float {
+	if t.Instrument == nil {
+		return NewInstrument(t.Symbol).Notional(t.Quantity, t.Price)
+	}
+	return t.Instrument.Notional(t.Quantity, t.Price)
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/stonks/lots"
+	"github.com/stonks/query"
+	"github.com/stonks/table"
+	"github.com/stonks/trade"
+)
+
+// tuiView is a screen of the tui
+type tuiView int
+
+const (
+	// dashboardView shows the P/L summary, the positions and the recent
+	// transactions
+	dashboardView tuiView = iota
+	// transactionsView lists every transaction, newest first, narrowed by
+	// the search and filter
+	transactionsView
+)
+
+// recentTransactions is how many transactions the dashboard shows
+const recentTransactions = 10
+
+// tuiHelp lists the key bindings at the bottom of the screen
+const tuiHelp = "tab switch view  j/k scroll  / search  f filter  esc clear  q quit"
+
+// tui is the state of the interactive dashboard
+type tui struct {
+	r            *report
+	transactions []*trade.Trade // newest first
+	view         tuiView
+	offset       int              // first transaction shown in the list
+	search       string           // text the listed transactions contain
+	filter       *query.Condition // condition the listed transactions meet, nil for none
+	prompt       string           // "/" or "f" while reading a search or filter
+	input        []rune
+	message      string // error shown until the next key
+	rows, cols   int
+}
+
+// runTUI runs the tui command, an interactive dashboard of the report in
+// the terminal. the terminal is put in raw mode with stty, so it needs a
+// unix terminal.
+func runTUI(c *config, opts *lots.Options, transactions []*trade.Trade, args []string) error {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	info, err := os.Stdin.Stat()
+	// guard clause: the keys are read from a terminal
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("the tui needs to be run in a terminal")
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return err
+	}
+	defer func() {
+		stty(strings.TrimSpace(saved))
+		// leave the alternate screen and show the cursor again
+		fmt.Print("\x1b[?1049l\x1b[?25h")
+	}()
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+
+	t := newTUI(newReport(c, opts, transactions), transactions)
+	key := make([]byte, 16)
+	for {
+		t.rows, t.cols = terminalSize()
+		// the title, status and help need a few lines however small
+		if t.rows < 4 {
+			t.rows = 4
+		}
+		fmt.Print(t.render())
+		n, err := os.Stdin.Read(key)
+		if err != nil {
+			return err
+		}
+		if t.handle(string(key[:n])) {
+			return nil
+		}
+	}
+}
+
+// newTUI returns the dashboard of the report and its transactions
+func newTUI(r *report, transactions []*trade.Trade) *tui {
+	t := tui{r: r, transactions: make([]*trade.Trade, len(transactions)), rows: 24, cols: 80}
+	for i := 0; i < len(transactions); i++ {
+		t.transactions[len(transactions)-1-i] = transactions[i]
+	}
+	return &t
+}
+
+// stty runs stty on the terminal and returns its output
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// terminalSize returns the rows and columns of the terminal, or 24 by 80
+// if stty can't tell
+func terminalSize() (int, int) {
+	out, err := stty("size")
+	var rows, cols int
+	if err != nil {
+		return 24, 80
+	}
+	if _, err := fmt.Sscan(out, &rows, &cols); err != nil || rows <= 0 || cols <= 0 {
+		return 24, 80
+	}
+	return rows, cols
+}
+
+// handle applies a key press and returns true to quit
+func (t *tui) handle(key string) bool {
+	t.message = ""
+	if t.prompt != "" {
+		t.handlePrompt(key)
+		return false
+	}
+	switch key {
+	case "q", "\x03":
+		return true
+	case "\t", "1", "2":
+		if key == "1" || (key == "\t" && t.view == transactionsView) {
+			t.view = dashboardView
+		} else {
+			t.view = transactionsView
+		}
+	case "j", "\x1b[B":
+		t.scroll(1)
+	case "k", "\x1b[A":
+		t.scroll(-1)
+	case " ", "\x1b[6~":
+		t.scroll(t.pageSize())
+	case "b", "\x1b[5~":
+		t.scroll(-t.pageSize())
+	case "/", "f":
+		t.view = transactionsView
+		t.prompt = key
+		t.input = t.input[:0]
+	case "\x1b":
+		t.search, t.filter, t.offset = "", nil, 0
+	}
+	return false
+}
+
+// handlePrompt edits the search or filter being typed, applying it on
+// enter and dropping it on escape
+func (t *tui) handlePrompt(key string) {
+	switch key {
+	case "\r", "\n":
+		t.apply(string(t.input))
+		t.prompt = ""
+	case "\x1b", "\x03":
+		t.prompt = ""
+	case "\x7f", "\b":
+		if len(t.input) > 0 {
+			t.input = t.input[:len(t.input)-1]
+		}
+	default:
+		// guard clause: arrows and other escape sequences aren't text
+		if strings.HasPrefix(key, "\x1b") {
+			return
+		}
+		t.input = append(t.input, []rune(key)...)
+	}
+}
+
+// apply sets the search or filter from the text typed at the prompt
+func (t *tui) apply(text string) {
+	t.offset = 0
+	if t.prompt == "/" {
+		t.search = strings.TrimSpace(text)
+		return
+	}
+	if strings.TrimSpace(text) == "" {
+		t.filter = nil
+		return
+	}
+	condition, err := query.ParseCondition(text)
+	if err != nil {
+		t.message = err.Error()
+		return
+	}
+	t.filter = condition
+}
+
+// pageSize is how many transactions the list shows at once
+func (t *tui) pageSize() int {
+	// less the title, the view's heading, the prompt and the help
+	if n := t.rows - 5; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// scroll moves the list by n transactions, keeping it on the screen
+func (t *tui) scroll(n int) {
+	// guard clause: the dashboard doesn't scroll
+	if t.view != transactionsView {
+		return
+	}
+	t.offset += n
+	if last := len(t.listed()) - t.pageSize(); t.offset > last {
+		t.offset = last
+	}
+	if t.offset < 0 {
+		t.offset = 0
+	}
+}
+
+// listed returns the transactions matching the search and filter
+func (t *tui) listed() []*trade.Trade {
+	listed := make([]*trade.Trade, 0, len(t.transactions))
+	search := strings.ToLower(t.search)
+	for i := 0; i < len(t.transactions); i++ {
+		tr := t.transactions[i]
+		if t.filter != nil && !t.filter.Match(tr) {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(transactionLine(tr)), search) {
+			continue
+		}
+		listed = append(listed, tr)
+	}
+	return listed
+}
+
+// transactionFormat lays out a transaction as a line of the list
+const transactionFormat = "%-10s %-10s %-16s %-20s %12s %12s %14s  %s"
+
+// transactionLine formats a transaction as a line of the list
+func transactionLine(tr *trade.Trade) string {
+	return fmt.Sprintf(transactionFormat, tr.Date.Format("2006-01-02"), fit(tr.Account, 10), fit(string(tr.Type), 16), fit(tr.Symbol, 20),
+		decimal(tr.Quantity), decimal(tr.Price), decimal(tr.Amount), strings.TrimSpace(tr.Description))
+}
+
+// transactionHeader heads the columns of the transaction lines
+var transactionHeader = fmt.Sprintf(transactionFormat, "Date", "Account", "Type", "Symbol", "Quantity", "Price", "Amount", "Description")
+
+// decimal formats an amount, blank when there is none
+func decimal(f *big.Float) string {
+	if f == nil {
+		return ""
+	}
+	return table.Decimal(f)
+}
+
+// fit cuts text to fit a column of n characters
+func fit(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// render draws the whole screen
+func (t *tui) render() string {
+	lines := make([]string, 0, t.rows)
+	title := "\x1b[7m 1 Dashboard \x1b[0m  2 Transactions"
+	if t.view == transactionsView {
+		title = " 1 Dashboard  \x1b[7m 2 Transactions \x1b[0m"
+	}
+	lines = append(lines, title)
+	if t.view == dashboardView {
+		lines = append(lines, t.dashboard()...)
+	} else {
+		lines = append(lines, t.list()...)
+	}
+	for len(lines) < t.rows-2 {
+		lines = append(lines, "")
+	}
+	lines = lines[:t.rows-2]
+
+	status := fmt.Sprintf("search: %q  filter: %s", t.search, "none")
+	if t.filter != nil {
+		status = fmt.Sprintf("search: %q  filter: %s", t.search, t.filter.String())
+	}
+	if t.prompt != "" {
+		status = t.prompt + string(t.input) + "\x1b[7m \x1b[0m"
+	} else if t.message != "" {
+		status = "\x1b[31m" + t.message + "\x1b[0m"
+	}
+	lines = append(lines, status, "\x1b[2m"+tuiHelp+"\x1b[0m")
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	for i := 0; i < len(lines); i++ {
+		b.WriteString(clip(lines[i], t.cols))
+		if i < len(lines)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	return b.String()
+}
+
+// clip cuts a line to the width of the screen. lines with escape codes are
+// left whole, since the codes take no room.
+func clip(line string, cols int) string {
+	if strings.Contains(line, "\x1b") {
+		return line
+	}
+	return fit(line, cols)
+}
+
+// dashboard draws the P/L summary, the positions and the recent
+// transactions
+func (t *tui) dashboard() []string {
+	r := t.r
+	amount := "  %-24s %14s"
+	lines := []string{"", "\x1b[1mP/L summary\x1b[0m"}
+	lines = append(lines,
+		fmt.Sprintf(amount, "Realized", decimal(r.Realized.Total)),
+		fmt.Sprintf(amount, "  Short term", decimal(r.Realized.Term.ShortTerm)),
+		fmt.Sprintf(amount, "  Long term", decimal(r.Realized.Term.LongTerm)),
+		fmt.Sprintf(amount, "Fees", decimal(r.Fees.Total.Total)),
+		fmt.Sprintf(amount, "Cash", decimal(r.Cash.Current)),
+	)
+	if overall := r.Performance.Overall; overall != nil {
+		lines = append(lines,
+			fmt.Sprintf(amount, "Round trips", fmt.Sprint(overall.Trades)),
+			fmt.Sprintf(amount, "Win rate %", decimal(overall.WinRate)),
+			fmt.Sprintf(amount, "Profit factor", decimal(overall.ProfitFactor)),
+			fmt.Sprintf(amount, "Expectancy", decimal(overall.Expectancy)),
+		)
+	}
+
+	lines = append(lines, "", fmt.Sprintf("\x1b[1mPositions (%d)\x1b[0m", len(r.Positions.Positions)))
+	format := "  %-10s %-20s %14s %14s %14s"
+	lines = append(lines, fmt.Sprintf(format, "Account", "Symbol", "Quantity", "Cost", "Average Cost"))
+	// the positions and the recent transactions share the lines left
+	// between the title and the status, less the heading of the recent
+	// transactions. the positions get at least half when they need it.
+	room := t.rows - len(lines) - 6
+	recent := len(t.transactions)
+	if recent > recentTransactions {
+		recent = recentTransactions
+	}
+	shown := room - recent
+	if shown < room/2 {
+		shown = room / 2
+	}
+	positions := r.Positions.Positions
+	for i := 0; i < len(positions) && i < shown; i++ {
+		if i == shown-1 && len(positions) > shown {
+			lines = append(lines, fmt.Sprintf("  ... %d more", len(positions)-i))
+			break
+		}
+		p := positions[i]
+		lines = append(lines, fmt.Sprintf(format, fit(p.Account, 10), fit(p.Symbol, 20), decimal(p.Quantity), decimal(p.Cost), decimal(p.AverageCost)))
+	}
+
+	lines = append(lines, "", "\x1b[1mRecent transactions\x1b[0m", "  "+transactionHeader)
+	for i := 0; i < recent && len(lines) < t.rows-3; i++ {
+		lines = append(lines, "  "+transactionLine(t.transactions[i]))
+	}
+	return lines
+}
+
+// list draws a page of the transactions matching the search and filter
+func (t *tui) list() []string {
+	listed := t.listed()
+	lines := []string{fmt.Sprintf("\x1b[1m%d of %d transactions\x1b[0m", len(listed), len(t.transactions)), transactionHeader}
+	for i := t.offset; i < len(listed) && i < t.offset+t.pageSize(); i++ {
+		lines = append(lines, transactionLine(listed[i]))
+	}
+	return lines
+}
@@ -0,0 +1,310 @@
+// Package xlsx writes simple spreadsheet workbooks in the Office Open XML
+// format using only the standard library. cells are numbers or text, with
+// bold headers and columns whose numbers are colored by their sign.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxSheetName = 31 // longest sheet name Excel allows
+	maxWidth     = 60 // widest a column is sized to, in characters
+)
+
+// styles are the indexes of the cell formats in the stylesheet
+const (
+	plainStyle  = 0
+	headerStyle = 1
+	titleStyle  = 2
+)
+
+// Workbook is a workbook made up of sheets
+type Workbook struct {
+	sheets []*Sheet
+}
+
+// Sheet is a sheet of rows of cells
+type Sheet struct {
+	name   string
+	rows   [][]cell
+	widths []int
+	signed []string // cell ranges colored red when negative and green when positive
+}
+
+// cell is a value and the format it is shown in
+type cell struct {
+	value   string
+	numeric bool
+	style   int
+}
+
+// NewWorkbook returns an empty workbook
+func NewWorkbook() *Workbook {
+	return &Workbook{sheets: make([]*Sheet, 0)}
+}
+
+// AddSheet adds an empty sheet to the end of the workbook. names are cut
+// to the length Excel allows, stripped of the characters it doesn't and
+// numbered if needed so each is unique.
+func (w *Workbook) AddSheet(name string) *Sheet {
+	name = strings.NewReplacer(":", "", `\`, "", "/", "", "?", "", "*", "", "[", "", "]", "").Replace(name)
+	if name == "" {
+		name = "Sheet"
+	}
+	unique := truncate(name, maxSheetName)
+	for n := 2; w.hasSheet(unique); n++ {
+		suffix := "_" + strconv.Itoa(n)
+		unique = truncate(name, maxSheetName-len(suffix)) + suffix
+	}
+	s := Sheet{name: unique, rows: make([][]cell, 0)}
+	w.sheets = append(w.sheets, &s)
+	return &s
+}
+
+// hasSheet returns true if a sheet has the name, which Excel compares
+// ignoring case
+func (w *Workbook) hasSheet(name string) bool {
+	for i := 0; i < len(w.sheets); i++ {
+		if strings.EqualFold(w.sheets[i].name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate cuts a name to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	for len(s) > n {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
+
+// AddTitle adds a row with the title in bold
+func (s *Sheet) AddTitle(title string) {
+	s.add([]cell{{value: title, style: titleStyle}})
+}
+
+// AddHeader adds a row of column names in bold on a shaded background
+func (s *Sheet) AddHeader(names []string) {
+	row := make([]cell, len(names))
+	for i := 0; i < len(names); i++ {
+		row[i] = cell{value: names[i], style: headerStyle}
+	}
+	s.add(row)
+}
+
+// AddRow adds a row of cells. cells in numeric columns are written as
+// numbers, unless they don't parse as one, and the rest as text.
+func (s *Sheet) AddRow(values []string, numeric []bool) {
+	row := make([]cell, len(values))
+	for i := 0; i < len(values); i++ {
+		row[i] = cell{value: values[i]}
+		if i < len(numeric) && numeric[i] {
+			_, err := strconv.ParseFloat(values[i], 64)
+			row[i].numeric = err == nil
+		}
+	}
+	s.add(row)
+}
+
+// AddBlank adds an empty row
+func (s *Sheet) AddBlank() {
+	s.add(nil)
+}
+
+// Rows returns the number of rows added so far
+func (s *Sheet) Rows() int {
+	return len(s.rows)
+}
+
+// ColorBySign colors the numbers in a column from the first row through
+// the last, counted from 0, red when negative and green when positive. the
+// colors are conditional formats, so they follow edits to the cells.
+func (s *Sheet) ColorBySign(column int, first int, last int) {
+	// guard clause: no rows to color
+	if last < first {
+		return
+	}
+	ref := fmt.Sprintf("%s%d:%s%d", columnName(column), first+1, columnName(column), last+1)
+	s.signed = append(s.signed, ref)
+}
+
+// add adds a row, widening the columns to fit it
+func (s *Sheet) add(row []cell) {
+	for i := 0; i < len(row); i++ {
+		// titles run across the columns after them instead
+		if row[i].style == titleStyle {
+			continue
+		}
+		for len(s.widths) <= i {
+			s.widths = append(s.widths, 0)
+		}
+		if n := utf8.RuneCountInString(row[i].value); n > s.widths[i] {
+			s.widths[i] = n
+		}
+	}
+	s.rows = append(s.rows, row)
+}
+
+// columnName returns the letters of a column counted from 0, eg A, Z, AA
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// escape escapes text for xml
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// xml returns the worksheet part of the sheet
+func (s *Sheet) xml() []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(s.widths) > 0 {
+		b.WriteString("<cols>")
+		for i := 0; i < len(s.widths); i++ {
+			width := s.widths[i] + 2
+			if width > maxWidth {
+				width = maxWidth
+			}
+			fmt.Fprintf(&b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, width)
+		}
+		b.WriteString("</cols>")
+	}
+	b.WriteString("<sheetData>")
+	for i := 0; i < len(s.rows); i++ {
+		// guard clause: blank rows are left out of the sheet data
+		if len(s.rows[i]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, `<row r="%d">`, i+1)
+		for j := 0; j < len(s.rows[i]); j++ {
+			c := s.rows[i][j]
+			ref := columnName(j) + strconv.Itoa(i+1)
+			if c.numeric {
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, c.style, c.value)
+			} else {
+				fmt.Fprintf(&b, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, c.style, escape(c.value))
+			}
+		}
+		b.WriteString("</row>")
+	}
+	b.WriteString("</sheetData>")
+	for i := 0; i < len(s.signed); i++ {
+		fmt.Fprintf(&b, `<conditionalFormatting sqref="%s">`+
+			`<cfRule type="cellIs" dxfId="0" priority="%d" operator="lessThan"><formula>0</formula></cfRule>`+
+			`<cfRule type="cellIs" dxfId="1" priority="%d" operator="greaterThan"><formula>0</formula></cfRule>`+
+			`</conditionalFormatting>`, s.signed[i], 2*i+1, 2*i+2)
+	}
+	b.WriteString("</worksheet>")
+	return b.Bytes()
+}
+
+// stylesheet holds the cell formats: plain, bold on grey for headers and
+// bold for titles, then the red and green the conditional formats use
+const stylesheet = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="3">` +
+	`<font><sz val="11"/><name val="Calibri"/></font>` +
+	`<font><b/><sz val="11"/><name val="Calibri"/></font>` +
+	`<font><b/><sz val="13"/><name val="Calibri"/></font>` +
+	`</fonts>` +
+	`<fills count="3">` +
+	`<fill><patternFill patternType="none"/></fill>` +
+	`<fill><patternFill patternType="gray125"/></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFD9D9D9"/><bgColor indexed="64"/></patternFill></fill>` +
+	`</fills>` +
+	`<borders count="2">` +
+	`<border><left/><right/><top/><bottom/><diagonal/></border>` +
+	`<border><left/><right/><top/><bottom style="thin"><color auto="1"/></bottom><diagonal/></border>` +
+	`</borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="2" borderId="1" xfId="0" applyFont="1" applyFill="1" applyBorder="1"/>` +
+	`<xf numFmtId="0" fontId="2" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`<dxfs count="2">` +
+	`<dxf><font><color rgb="FF9C0006"/></font><fill><patternFill><bgColor rgb="FFFFC7CE"/></patternFill></fill></dxf>` +
+	`<dxf><font><color rgb="FF006100"/></font><fill><patternFill><bgColor rgb="FFC6EFCE"/></patternFill></fill></dxf>` +
+	`</dxfs>` +
+	`</styleSheet>`
+
+// part is a file in the zip package of a workbook
+type part struct {
+	name string
+	data []byte
+}
+
+// Write writes the workbook to w
+func (w *Workbook) Write(out io.Writer) error {
+	sheets := w.sheets
+	// a workbook needs at least one sheet to open
+	if len(sheets) == 0 {
+		sheets = []*Sheet{{name: "Sheet1"}}
+	}
+
+	var types, workbook, rels bytes.Buffer
+	types.WriteString(xml.Header)
+	types.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(xml.Header)
+	workbook.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(xml.Header)
+	rels.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 0; i < len(sheets); i++ {
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(sheets[i].name), i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	types.WriteString("</Types>")
+	workbook.WriteString("</sheets></workbook>")
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+	rels.WriteString("</Relationships>")
+
+	parts := []part{
+		{"[Content_Types].xml", types.Bytes()},
+		{"_rels/.rels", []byte(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`)},
+		{"xl/workbook.xml", workbook.Bytes()},
+		{"xl/_rels/workbook.xml.rels", rels.Bytes()},
+		{"xl/styles.xml", []byte(stylesheet)},
+	}
+	for i := 0; i < len(sheets); i++ {
+		parts = append(parts, part{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheets[i].xml()})
+	}
+
+	z := zip.NewWriter(out)
+	for i := 0; i < len(parts); i++ {
+		f, err := z.Create(parts[i].name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(parts[i].data); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}